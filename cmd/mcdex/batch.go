@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"mcdex/pkg"
+)
+
+// batch is registered separately since it runs the other commands
+func init() {
+	gCommands["batch"] = command{
+		Fn:        cmdBatch,
+		Desc:      "Run commands read from stdin as JSON (one per line), writing JSON events and results to stdout",
+		ArgsCount: 0,
+		Args:      "",
+	}
+}
+
+// batchRequest is a command read from stdin in batch mode, e.g.
+//
+//	{"id": 1, "command": "mod.select", "args": ["mypack", "jei"], "flags": {"n": true}}
+//
+// Flags apply only to that command; other flags keep the values given on the command line.
+type batchRequest struct {
+	ID      interface{}            `json:"id"`
+	Command string                 `json:"command"`
+	Args    []string               `json:"args"`
+	Flags   map[string]interface{} `json:"flags"`
+}
+
+// batchEvent is written to stdout (one JSON object per line) as each command runs: output for
+// each line the command prints, download/finished for its downloads, and finally a result
+type batchEvent struct {
+	ID       interface{}         `json:"id"`
+	Event    string              `json:"event"`
+	Msg      string              `json:"msg,omitempty"`
+	File     string              `json:"file,omitempty"`
+	Size     int64               `json:"size,omitempty"`
+	OK       *bool               `json:"ok,omitempty"`
+	Error    string              `json:"error,omitempty"`
+	Warnings map[string][]string `json:"warnings,omitempty"`
+}
+
+type batchRunner struct {
+	lock    sync.Mutex
+	encoder *json.Encoder
+	id      interface{}
+}
+
+func (b *batchRunner) emit(e batchEvent) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	e.ID = b.id
+	b.encoder.Encode(e)
+}
+
+func (b *batchRunner) DownloadStarted(label string, total int64) {
+	b.emit(batchEvent{Event: "download", File: label, Size: total})
+}
+
+func (b *batchRunner) DownloadProgress(label string, count int64) {}
+
+func (b *batchRunner) DownloadFinished(label string, err error) {
+	e := batchEvent{Event: "finished", File: label}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	b.emit(e)
+}
+
+// cmdBatch runs commands read from stdin in a single process, so the database and environment
+// are only set up once; a failed command doesn't stop the batch
+func cmdBatch(ctx context.Context) error {
+	runner := &batchRunner{encoder: json.NewEncoder(os.Stdout)}
+
+	// Commands run with the flags from the command line, plus their own
+	defaults := make(map[string]string)
+	flag.VisitAll(func(f *flag.Flag) {
+		defaults[f.Name] = f.Value.String()
+	})
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var request batchRequest
+		err := json.Unmarshal(scanner.Bytes(), &request)
+		if err != nil {
+			runner.id = nil
+			runner.emit(batchResult(fmt.Errorf("invalid request: %+v", err)))
+			continue
+		}
+
+		runner.id = request.ID
+		flag.VisitAll(func(f *flag.Flag) {
+			f.Value.Set(defaults[f.Name])
+		})
+		err = runBatchCommand(ctx, runner, request)
+		runner.emit(batchResult(err))
+	}
+	return scanner.Err()
+}
+
+func runBatchCommand(ctx context.Context, runner *batchRunner, request batchRequest) error {
+	command, exists := gCommands[request.Command]
+	if !exists || request.Command == "batch" {
+		return fmt.Errorf("unknown command '%s'", request.Command)
+	}
+
+	for name, value := range request.Flags {
+		err := flag.Set(name, fmt.Sprint(value))
+		if err != nil {
+			return fmt.Errorf("invalid flag %s: %+v", name, err)
+		}
+	}
+
+	flag.CommandLine.Parse(append([]string{request.Command}, request.Args...))
+	if flag.NArg() < command.ArgsCount+1 {
+		return fmt.Errorf("insufficient arguments for %s; usage: mcdex %s %s", request.Command, request.Command, command.Args)
+	}
+
+	// Capture everything the command prints as output events
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to capture output: %+v", err)
+	}
+	captured := make(chan bool)
+	go func() {
+		copyBatchOutput(runner, reader)
+		captured <- true
+	}()
+
+	stdout := os.Stdout
+	os.Stdout = writer
+	pkg.SetOutput(writer)
+	pkg.SetDownloadObserver(runner)
+	pkg.ResetWarnings()
+
+	err = command.Fn(ctx)
+
+	pkg.SetDownloadObserver(nil)
+	pkg.SetOutput(stdout)
+	os.Stdout = stdout
+	writer.Close()
+	<-captured
+	return err
+}
+
+func copyBatchOutput(runner *batchRunner, reader io.ReadCloser) {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		runner.emit(batchEvent{Event: "output", Msg: scanner.Text()})
+	}
+	reader.Close()
+}
+
+func batchResult(err error) batchEvent {
+	ok := err == nil
+	result := batchEvent{Event: "result", OK: &ok, Warnings: pkg.Warnings()}
+	if err != nil {
+		result.Error = err.Error()
+	}
+	pkg.ResetWarnings()
+	return result
+}
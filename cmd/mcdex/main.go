@@ -18,16 +18,19 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"log"
+	"mcdex/pkg/api"
 	"mcdex/pkg/ui"
 	"os"
-	"os/exec"
-	"path/filepath"
+	"os/signal"
 	"regexp"
 	"sort"
 	"strings"
+	"syscall"
+	"text/tabwriter"
 	"time"
 
 	"github.com/xeonx/timeago"
@@ -38,11 +41,52 @@ var version string
 
 var ARG_MMC bool
 var ARG_VERBOSE bool
+var ARG_QUIET bool
+var ARG_NO_COLOR bool
+var ARG_WIZARD bool
+var ARG_FILES bool
+var ARG_INSTALL bool
+var ARG_FROM_FILE string
+var ARG_NO_SIDE_DETECT bool
+var ARG_VERSION_URL string
+var ARG_VERSION_REGEX string
+var ARG_VERSION_PATH string
 var ARG_SKIPMODS bool
+var ARG_OPTIONAL bool
+var ARG_DEPS bool
+var ARG_WITH_OPTIONAL bool
+var ARG_IGNORE_FAILURES bool
 var ARG_DRY_RUN bool
+var ARG_INTERACTIVE bool
+var ARG_FORGE_CHANNEL string
+var ARG_LOADER_VERSION string
+var ARG_NO_KEYRING bool
+var ARG_SMOKE_TEST bool
+var ARG_SMOKE_TIMEOUT time.Duration
+var ARG_ACCEPT_EULA bool
+var ARG_MEM string
+var ARG_NO_BACKUP bool
+var ARG_XMX string
+var ARG_XMS string
+var ARG_JAVA_ARGS string
+var ARG_BACKUP_CONFIG bool
+var ARG_BACKUP_KEEP int
+var ARG_JSON bool
+var ARG_CATEGORY string
+var ARG_LOADER string
+var ARG_SORT string
+var ARG_TUI bool
+var ARG_LOG_LEVEL string
+var ARG_LOG_FORMAT string
+var ARG_LOG_FILE string
+var ARG_NOTIFY string
+var ARG_LISTEN string
+
+// Set by commands that include the warnings in their own output
+var warningsPrinted bool
 
 type command struct {
-	Fn        func() error
+	Fn        func(ctx context.Context) error
 	Desc      string
 	ArgsCount int
 	Args      string
@@ -51,9 +95,9 @@ type command struct {
 var gCommands = map[string]command{
 	"pack.create": {
 		Fn:        cmdPackCreate,
-		Desc:      "Create a new mod pack",
+		Desc:      "Create a new mod pack (-wizard to choose its details interactively)",
 		ArgsCount: 3,
-		Args:      "<directory/name> fabric|forge <minecraft version>",
+		Args:      "<directory/name> fabric|forge <minecraft version|snapshot> [<loader version>]",
 	},
 	"pack.list": {
 		Fn:        cmdPackList,
@@ -71,7 +115,55 @@ var gCommands = map[string]command{
 		Fn:        cmdPackInstall,
 		Desc:      fmt.Sprintf("Install a mod pack, optionally using a URL to download. Use %s for the directory with a URL to use the name from the downloaded manifest", pkg.NamePlaceholder),
 		ArgsCount: 1,
-		Args:      "<directory/name> [<url>]",
+		Args:      "<directory/name> [<url>|<pack.zip>|<slug> [<fileID>|<version>]]",
+	},
+	"pack.update": {
+		Fn:        cmdPackUpdate,
+		Desc:      "Update an installed mod pack to the latest file of its CurseForge or Modrinth project, keeping changed configs (-n to only check)",
+		ArgsCount: 1,
+		Args:      "<directory/name>",
+	},
+	"pack.versions": {
+		Fn:        cmdPackVersions,
+		Desc:      "List the available files (versions) of a mod pack",
+		ArgsCount: 1,
+		Args:      "<slug>",
+	},
+	"pack.adopt": {
+		Fn:        cmdPackAdopt,
+		Desc:      "Take over management of an existing MultiMC/PolyMC/Prism instance (use with -mmc)",
+		ArgsCount: 1,
+		Args:      "<directory/name>",
+	},
+	"pack.adopt.mmc": {
+		Fn:        cmdPackAdoptMMC,
+		Desc:      "Import an existing MultiMC/PolyMC/Prism instance (by name or path) so mcdex can manage its mods",
+		ArgsCount: 1,
+		Args:      "<instance name or directory>",
+	},
+	"pack.adopt.curseforge": {
+		Fn:        cmdPackAdoptCurseForge,
+		Desc:      "Import an instance created by the CurseForge App so mcdex can manage its mods",
+		ArgsCount: 1,
+		Args:      "<instance directory>",
+	},
+	"pack.export.atlauncher": {
+		Fn:        cmdPackExportATLauncher,
+		Desc:      "Generate an ATLauncher instance.json for a pack",
+		ArgsCount: 1,
+		Args:      "<directory/name>",
+	},
+	"pack.export.technic": {
+		Fn:        cmdPackExportTechnic,
+		Desc:      "Build a Technic-compatible ZIP of a pack in the current directory",
+		ArgsCount: 1,
+		Args:      "<directory/name>",
+	},
+	"pack.doctor": {
+		Fn:        cmdPackDoctor,
+		Desc:      "Check a mod pack for potential problems, such as abandoned mods",
+		ArgsCount: 1,
+		Args:      "<directory/name>",
 	},
 	"info": {
 		Fn:        cmdInfo,
@@ -80,15 +172,15 @@ var gCommands = map[string]command{
 	},
 	"mod.list": {
 		Fn:        cmdModList,
-		Desc:      "List mods matching a name and Minecraft version",
+		Desc:      "List mods matching a search (or slug regex), Minecraft version and mod loader",
 		ArgsCount: 0,
-		Args:      "[<mod name> <minecraft version>]",
+		Args:      "[<mod name> <minecraft version> [fabric|forge|neoforge]]",
 	},
 	"mod.info": {
 		Fn: cmdModInfo,
-		Desc: "Display information about a mod",
+		Desc: "Display information about a mod, optionally with the file that would be selected for a pack (-files to list all its files)",
 		ArgsCount: 1,
-		Args: "<mod slug>",
+		Args: "<mod slug> [<directory/name>]",
 	},
 	"mod.list.latest": {
 		Fn:        cmdModListLatest,
@@ -96,6 +188,12 @@ var gCommands = map[string]command{
 		ArgsCount: 0,
 		Args:      "[<minecraft version>]",
 	},
+	"serve": {
+		Fn:        cmdServe,
+		Desc:      "Run a local HTTP API for listing packs, searching and running installs/updates (see -listen); requests need the token it prints",
+		ArgsCount: 0,
+		Args:      "",
+	},
 	"mod.explore": {
 		Fn: cmdModExplore,
 		Desc: "Explore available mods",
@@ -104,19 +202,49 @@ var gCommands = map[string]command{
 	},
 	"mod.select": {
 		Fn:        cmdModSelect,
-		Desc:      "Select a mod to include in the specified pack",
-		ArgsCount: 2,
-		Args:      "<directory/name> <mod name or maven artifact ID> [<URL>]",
+		Desc:      "Select mods to include in the specified pack, or those listed in a file with -from-file (-install to install them right away)",
+		ArgsCount: 1,
+		Args:      "<directory/name> [<mod name[@version], modrinth:<slug>[@version], ext:<name>[@version] or maven artifact ID> [<URL>|<more mods>...]]",
 	},
 	"mod.select.client": {
 		Fn:        cmdModSelectClient,
-		Desc:      "Select a client-side only mod to include in the specified pack",
+		Desc:      "Select client-side only mods to include in the specified pack, or those listed in a file with -from-file (-install to install them right away)",
+		ArgsCount: 1,
+		Args:      "<directory/name> [<mod name[@version], modrinth:<slug>[@version], ext:<name>[@version] or maven artifact ID> [<URL>|<more mods>...]]",
+	},
+	"mod.select.server": {
+		Fn:        cmdModSelectServer,
+		Desc:      "Select server-side only mods to include in the specified pack, or those listed in a file with -from-file (-install to install them right away)",
+		ArgsCount: 1,
+		Args:      "<directory/name> [<mod name[@version], modrinth:<slug>[@version], ext:<name>[@version] or maven artifact ID> [<URL>|<more mods>...]]",
+	},
+	"mod.disable": {
+		Fn:        cmdModDisable,
+		Desc:      "Disable a mod in the specified pack without removing it from the manifest",
+		ArgsCount: 2,
+		Args:      "<directory/name> <mod name or maven artifact ID>",
+	},
+	"mod.enable": {
+		Fn:        cmdModEnable,
+		Desc:      "Re-enable a previously disabled mod in the specified pack",
 		ArgsCount: 2,
-		Args:      "<directory/name> <mod name or maven artifact ID> [<URL>]",
+		Args:      "<directory/name> <mod name or maven artifact ID>",
 	},
 	"mod.update.all": {
 		Fn:        cmdModUpdateAll,
-		Desc:      "Update all mods entries to latest available file",
+		Desc:      "Update all mods entries to latest available file (-interactive to review each update)",
+		ArgsCount: 1,
+		Args:      "<directory/name>",
+	},
+	"mod.outdated": {
+		Fn:        cmdModOutdated,
+		Desc:      "List mods with newer files available, without changing the pack (-json for JSON output)",
+		ArgsCount: 1,
+		Args:      "<directory/name>",
+	},
+	"retry": {
+		Fn:        cmdRetry,
+		Desc:      "Retry the mod downloads that failed while installing a pack with -ignore-failures",
 		ArgsCount: 1,
 		Args:      "<directory/name>",
 	},
@@ -126,135 +254,361 @@ var gCommands = map[string]command{
 		ArgsCount: 1,
 		Args:      "<directory/name>",
 	},
+	"server.docker": {
+		Fn:        cmdServerDocker,
+		Desc:      "Generate a Dockerfile and docker-compose.yml for an installed server",
+		ArgsCount: 1,
+		Args:      "<directory/name>",
+	},
+	"server.egg": {
+		Fn:        cmdServerEgg,
+		Desc:      "Export a Pterodactyl egg for an installed server",
+		ArgsCount: 1,
+		Args:      "<directory/name>",
+	},
 	"db.update": {
 		Fn:        cmdDBUpdate,
 		Desc:      "Update local database of available mods",
 		ArgsCount: 0,
 	},
+	"auth.login": {
+		Fn:        cmdAuthLogin,
+		Desc:      "Store an API token for a service (curseforge, github) in the system keyring; the token is read from stdin or MCDEX_<SERVICE>_TOKEN",
+		ArgsCount: 1,
+		Args:      "<service>",
+	},
+	"auth.logout": {
+		Fn:        cmdAuthLogout,
+		Desc:      "Remove the stored API token for a service",
+		ArgsCount: 1,
+		Args:      "<service>",
+	},
+	"target.add": {
+		Fn:        cmdTargetAdd,
+		Desc:      "Register a Minecraft directory under a name for use with -target",
+		ArgsCount: 2,
+		Args:      "<name> <directory>",
+	},
+	"target.remove": {
+		Fn:        cmdTargetRemove,
+		Desc:      "Remove a registered Minecraft directory",
+		ArgsCount: 1,
+		Args:      "<name>",
+	},
+	"target.list": {
+		Fn:        cmdTargetList,
+		Desc:      "List registered Minecraft directories",
+		ArgsCount: 0,
+	},
+	"cache.verify": {
+		Fn:        cmdCacheVerify,
+		Desc:      "Show the contents of a pack's mod cache and check for missing files",
+		ArgsCount: 1,
+		Args:      "<directory/name>",
+	},
+	"cache.rebuild": {
+		Fn:        cmdCacheRebuild,
+		Desc:      "Rebuild a pack's mod cache by identifying the installed mod files",
+		ArgsCount: 1,
+		Args:      "<directory/name>",
+	},
+	"pack.upgrade": {
+		Fn:        cmdPackUpgrade,
+		Desc:      "Move a pack to a new version of Minecraft, re-resolving the loader and all mods",
+		ArgsCount: 2,
+		Args:      "<directory/name> <minecraft version>",
+	},
+	"pack.channel": {
+		Fn:        cmdPackChannel,
+		Desc:      "Set the release types updates select for a pack, or a single mod in it (default prefers releases, falling back to beta/alpha files)",
+		ArgsCount: 2,
+		Args:      "<directory/name> <release|beta|alpha|default> [<mod name or modrinth:<slug>>]",
+	},
+	"loader.update": {
+		Fn:        cmdLoaderUpdate,
+		Desc:      "Update the pack's mod loader to the newest version on the selected channel",
+		ArgsCount: 1,
+		Args:      "<directory/name>",
+	},
 	"forge.list": {
 		Fn:        cmdForgeList,
 		Desc:      "List available versions of Forge",
 		ArgsCount: 1,
 		Args:      "<minecraft version>",
 	},
+	"neoforge.list": {
+		Fn:        cmdNeoForgeList,
+		Desc:      "List available versions of NeoForge",
+		ArgsCount: 1,
+		Args:      "<minecraft version>",
+	},
+	"quilt.list": {
+		Fn:        cmdQuiltList,
+		Desc:      "List available versions of the Quilt loader",
+		ArgsCount: 1,
+		Args:      "<minecraft version>",
+	},
 }
 
-func cmdPackCreate() error {
+func cmdPackCreate(ctx context.Context) error {
+	if ARG_WIZARD {
+		return _packCreateWizard(ctx)
+	}
+
 	dir := flag.Arg(1)
 	loader := flag.Arg(2)
 	minecraftVsn := flag.Arg(3)
+	loaderVsn := flag.Arg(4)
+	if loaderVsn == "" {
+		loaderVsn = ARG_LOADER_VERSION
+	}
+
+	_, err := _packCreate(ctx, dir, loader, minecraftVsn, loaderVsn, ARG_MMC)
+	return err
+}
+
+// _packCreateWizard asks for the details of the pack in the TUI, then creates it and adds the
+// chosen mods
+func _packCreateWizard(ctx context.Context) error {
+	db, err := pkg.OpenDatabase(ctx)
+	if err != nil {
+		return err
+	}
 
+	spec, err := ui.RunCreateWizard(ctx, db, ARG_MMC)
+	if err != nil || spec == nil {
+		return err
+	}
+
+	cp, err := _packCreate(ctx, spec.Name, spec.Loader, spec.MinecraftVersion, ARG_LOADER_VERSION, spec.MultiMC)
+	if err != nil {
+		return err
+	}
+
+	for _, mod := range spec.Mods {
+		err = pkg.SelectMod(ctx, cp, mod, "", false)
+		if err != nil {
+			return fmt.Errorf("created %s, but failed to add %s: %+v", cp.Name, mod, err)
+		}
+	}
+	pkg.Log(pkg.LogInfo, "Created %s; run pack.install to install it", cp.Name)
+	return nil
+}
+
+func _packCreate(ctx context.Context, dir, loader, minecraftVsn, loaderVsn string, multiMC bool) (*pkg.ModPack, error) {
 	if dir == pkg.NamePlaceholder {
-		return fmt.Errorf("%q is not allowed for the directory when creating a new pack", pkg.NamePlaceholder)
+		return nil, fmt.Errorf("%q is not allowed for the directory when creating a new pack", pkg.NamePlaceholder)
 	}
 
 	if loader != "fabric" && loader != "forge" {
-		return fmt.Errorf("'%s' is not a valid loader; it must either be 'fabric' or 'forge'", loader)
+		return nil, fmt.Errorf("'%s' is not a valid loader; it must either be 'fabric' or 'forge'", loader)
 	}
 
 	// Create a new pack directory
-	cp, err := pkg.NewModPack(dir, loader, false, ARG_MMC)
+	cp, err := pkg.NewModPack(ctx, dir, loader, false, multiMC)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	err = cp.SetJVMOptions(ARG_XMX, ARG_XMS, ARG_JAVA_ARGS)
+	if err != nil {
+		return nil, err
 	}
 
 	// Create the manifest for this new pack
-	err = cp.CreateManifest(cp.Name, minecraftVsn)
+	err = cp.CreateManifest(ctx, cp.Name, minecraftVsn, ARG_FORGE_CHANNEL, loaderVsn)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// If the -mmc flag is provided, don't create a launcher profile; just generate
-	// an instance.cfg for MultiMC to use
-	if ARG_MMC {
+	// For MultiMC, don't create a launcher profile; just generate an instance.cfg for MultiMC
+	// to use
+	if multiMC {
 		err = cp.GenerateMMCConfig()
-		if err != nil {
-			return err
-		}
 	} else {
 		// Create launcher profile
-		err = cp.CreateLauncherProfile()
-		if err != nil {
-			return err
-		}
+		err = cp.CreateLauncherProfile(ctx)
+	}
+	if err != nil {
+		return nil, err
 	}
+	return cp, nil
+}
 
-	return nil
+func cmdPackInstall(ctx context.Context) error {
+	if ARG_TUI {
+		return ui.RunInstallDashboard(fmt.Sprintf("Installing %s", flag.Arg(1)), func() error {
+			return _packInstall(ctx)
+		})
+	}
+	return _packInstall(ctx)
 }
 
-func cmdPackInstall() error {
+func _packInstall(ctx context.Context) error {
 	dir := flag.Arg(1)
 	url := flag.Arg(2)
+	version := flag.Arg(3)
 
-	db, err := pkg.OpenDatabase()
+	db, err := pkg.OpenPackDatabase(ctx)
 	if err != nil {
 		return err
 	}
 
-	if url != "" && !strings.HasPrefix(url, "https://") {
-		url, err = db.GetLatestPackURL(dir)
+	// Anything other than a URL or a local pack archive is the slug of a pack, optionally with
+	// a specific version
+	if url != "" && !strings.HasPrefix(url, "https://") && !pkg.IsLocalPackArchive(url) {
+		url, err = db.GetPackURL(ctx, url, version)
+		if err != nil {
+			return err
+		}
+	}
+
+	if ARG_DRY_RUN {
+		plan, err := pkg.PlanInstall(ctx, dir, url, true, ARG_MMC)
 		if err != nil {
 			return err
 		}
+		if ARG_SKIPMODS {
+			plan.Downloads = nil
+		}
+		plan.Print()
+		return nil
 	}
 
-	// TODO: review for how this works with downloaded packs
-	cp, err := pkg.OpenModPack(dir, ARG_MMC)
+	return pkg.InstallPack(ctx, dir, url, packInstallOptions())
+}
+
+// packInstallOptions collects the flags that control how packs are installed
+func packInstallOptions() pkg.PackInstallOptions {
+	return pkg.PackInstallOptions{
+		MultiMC:      ARG_MMC,
+		SkipMods:     ARG_SKIPMODS,
+		NoBackup:     ARG_NO_BACKUP,
+		BackupConfig: ARG_BACKUP_CONFIG,
+		BackupKeep:   ARG_BACKUP_KEEP,
+		Xmx:          ARG_XMX,
+		Xms:          ARG_XMS,
+		JavaArgs:     ARG_JAVA_ARGS,
+	}
+}
+
+func cmdPackUpdate(ctx context.Context) error {
+	cp, err := pkg.OpenModPack(ctx, flag.Arg(1), ARG_MMC)
 	if err != nil {
 		return err
 	}
 
-	if url != "" {
-		// Download the pack
-		err = cp.Download(url)
-		if err != nil {
-			return err
-		}
+	url, err := cp.CheckPackUpdate(ctx)
+	if err != nil {
+		return err
+	}
 
-		// Process manifest
-		err = cp.ProcessManifest()
-		if err != nil {
-			return err
-		}
+	if url == "" {
+		pkg.Log(pkg.LogInfo, "%s is up to date", cp.Name)
+		return nil
+	}
 
-		// Install overrides from the modpack; this is a bit of a misnomer since
-		// under usual circumstances there are no mods in the modpack file that
-		// will be also be downloaded
-		err = cp.InstallOverrides()
-		if err != nil {
-			return err
-		}
+	if ARG_DRY_RUN {
+		pkg.Log(pkg.LogInfo, "A new version of %s is available: %s", cp.Name, url)
+		return nil
 	}
 
-	// If the -mmc flag is provided, don't create a launcher profile; just generate
-	// an instance.cfg for MultiMC to use
-	if ARG_MMC == true {
-		err = cp.GenerateMMCConfig()
-		if err != nil {
-			return err
-		}
-	} else {
-		// Create launcher profile
-		err = cp.CreateLauncherProfile()
-		if err != nil {
-			return err
-		}
+	err = backupWorlds(cp)
+	if err != nil {
+		return err
 	}
 
-	if ARG_SKIPMODS == false {
-		// Install mods (include client-side only mods)
-		err = cp.InstallMods(true)
-		if err != nil {
-			return err
-		}
+	err = cp.ApplyPackUpdate(ctx, url)
+	if err != nil {
+		return err
 	}
 
+	return cp.InstallPackFiles(ctx, url, packInstallOptions())
+}
+
+func cmdPackVersions(ctx context.Context) error {
+	db, err := pkg.OpenDatabase(ctx)
+	if err != nil {
+		return err
+	}
+
+	return db.ListPackVersions(ctx, flag.Arg(1))
+}
+
+func cmdPackAdopt(ctx context.Context) error {
+	dir := flag.Arg(1)
+
+	if !ARG_MMC {
+		return fmt.Errorf("pack.adopt currently requires the -mmc flag")
+	}
+
+	cp, err := pkg.NewModPack(ctx, dir, "", false, ARG_MMC)
+	if err != nil {
+		return err
+	}
+
+	return cp.AdoptMMCInstance(ctx)
+}
+
+// cmdPackAdoptMMC is pack.adopt for MultiMC-style instances, without needing -mmc
+func cmdPackAdoptMMC(ctx context.Context) error {
+	ARG_MMC = true
+	return cmdPackAdopt(ctx)
+}
+
+func cmdPackAdoptCurseForge(ctx context.Context) error {
+	cp, err := pkg.NewModPack(ctx, flag.Arg(1), "", false, false)
+	if err != nil {
+		return err
+	}
+
+	return cp.AdoptCurseForgeInstance(ctx)
+}
+
+func cmdPackExportATLauncher(ctx context.Context) error {
+	cp, err := pkg.OpenModPack(ctx, flag.Arg(1), ARG_MMC)
+	if err != nil {
+		return err
+	}
+
+	filename, err := cp.ExportATLauncherInstance()
+	if err != nil {
+		return err
+	}
+
+	pkg.Log(pkg.LogInfo, "Exported ATLauncher instance to %s", filename)
 	return nil
 }
 
-func cmdInfo() error {
+func cmdPackExportTechnic(ctx context.Context) error {
+	cp, err := pkg.OpenModPack(ctx, flag.Arg(1), ARG_MMC)
+	if err != nil {
+		return err
+	}
+
+	filename, err := cp.ExportTechnic(ctx)
+	if err != nil {
+		return err
+	}
+
+	pkg.Log(pkg.LogInfo, "Exported Technic pack to %s", filename)
+	return nil
+}
+
+func cmdPackDoctor(ctx context.Context) error {
+	dir := flag.Arg(1)
+
+	cp, err := pkg.OpenModPack(ctx, dir, ARG_MMC)
+	if err != nil {
+		return err
+	}
+
+	return cp.Doctor(ctx)
+}
+
+func cmdInfo(ctx context.Context) error {
 	// Try to retrieve the latest available version info
-	publishedVsn, err := pkg.ReadStringFromUrl("http://files.mcdex.net/release/latest")
+	publishedVsn, err := pkg.ReadStringFromUrl(ctx, "http://files.mcdex.net/release/latest")
 
 	if err != nil && ARG_VERBOSE {
 		fmt.Printf("%s\n", err)
@@ -275,90 +629,189 @@ func cmdInfo() error {
 	return nil
 }
 
-func cmdModSelect() error {
-	return _modSelect(flag.Arg(1), flag.Arg(2), flag.Arg(3), false)
+func cmdModSelect(ctx context.Context) error {
+	return _modSelect(ctx, flag.Arg(1), flag.Args()[2:], false, false)
 }
 
-func cmdModSelectClient() error {
-	return _modSelect(flag.Arg(1), flag.Arg(2), flag.Arg(3), true)
+func cmdModSelectClient(ctx context.Context) error {
+	return _modSelect(ctx, flag.Arg(1), flag.Args()[2:], true, false)
+}
+
+func cmdModSelectServer(ctx context.Context) error {
+	return _modSelect(ctx, flag.Arg(1), flag.Args()[2:], false, true)
 }
 
 var curseForgeRegex = regexp.MustCompile("/projects/([\\w-]*)(/files/(\\d+))?")
 
-func _modSelect(dir, modId, url string, clientOnly bool) error {
+// _modSelect selects either a single mod, optionally with the URL of a specific file, or several
+// mods at once
+func _modSelect(ctx context.Context, dir string, modIds []string, clientOnly, serverOnly bool) error {
 	// Try to open the mod pack
-	cp, err := pkg.OpenModPack(dir, ARG_MMC)
+	cp, err := pkg.OpenModPack(ctx, dir, ARG_MMC)
 	if err != nil {
 		return err
 	}
 
-	// First, try to select the mod using Maven
-	err = pkg.SelectMavenModFile(cp, modId, url, clientOnly)
-	if err != nil {
-		// Hmm, not a maven-based mod; let's try as a CurseForge mod
-		err = pkg.SelectCurseForgeModFile(cp, modId, url, clientOnly)
+	// Server-side only mods are never installed on clients, so there's no need to check them
+	pkg.SetSideDetection(!ARG_NO_SIDE_DETECT && !serverOnly)
+
+	var mods []pkg.ModSelection
+	if ARG_FROM_FILE != "" {
+		mods, err = pkg.ReadModList(ARG_FROM_FILE)
 		if err != nil {
 			return err
 		}
 	}
+	for _, modId := range modIds {
+		mods = append(mods, pkg.ModSelection{ModID: modId})
+	}
+
+	// Everything selected with mod.select.client (or mod.select.server) is client-side (or
+	// server-side) only
+	for i := range mods {
+		mods[i].ClientOnly = (mods[i].ClientOnly || clientOnly) && !serverOnly
+		mods[i].ServerOnly = (mods[i].ServerOnly || serverOnly) && !clientOnly
+		mods[i].Optional = mods[i].Optional || ARG_OPTIONAL
+	}
+
+	switch {
+	case len(mods) == 0:
+		return fmt.Errorf("no mods to select; provide mods or a mod list with -from-file")
+	case len(modIds) == 2 && strings.Contains(modIds[1], "://") && ARG_FROM_FILE == "":
+		if ARG_VERSION_URL != "" {
+			// Versioned URLs are only for files downloaded directly from a URL
+			if !strings.HasPrefix(modIds[0], "ext:") {
+				return fmt.Errorf("-version-url can only be used with ext:<name> mods")
+			}
+			source := pkg.ExtVersionSource{URL: ARG_VERSION_URL, Regex: ARG_VERSION_REGEX, JSONPath: ARG_VERSION_PATH}
+			err = pkg.SelectExtModFile(ctx, cp, modIds[0], modIds[1], source, clientOnly)
+		} else {
+			err = pkg.SelectMod(ctx, cp, modIds[0], modIds[1], clientOnly)
+		}
+		if err == nil && serverOnly {
+			err = cp.SetModServerOnly(ctx, modIds[0])
+		}
+		if err == nil && ARG_OPTIONAL {
+			err = cp.SetModOptional(ctx, modIds[0])
+		}
+	default:
+		err = pkg.SelectMods(ctx, cp, mods)
+	}
+	if err != nil || !ARG_INSTALL {
+		return err
+	}
+
+	return cp.InstallMods(ctx, true)
+}
 
-	return cp.SaveManifest()
+func cmdModDisable(ctx context.Context) error {
+	return _modSetEnabled(ctx, flag.Arg(1), flag.Arg(2), false)
 }
 
-func cmdModInfo() error {
+func cmdModEnable(ctx context.Context) error {
+	return _modSetEnabled(ctx, flag.Arg(1), flag.Arg(2), true)
+}
+
+func _modSetEnabled(ctx context.Context, dir, modId string, enabled bool) error {
+	cp, err := pkg.OpenModPack(ctx, dir, ARG_MMC)
+	if err != nil {
+		return err
+	}
+
+	return cp.SetModEnabled(ctx, modId, enabled)
+}
+
+func cmdModInfo(ctx context.Context) error {
 	slug := flag.Arg(1)
 
-	db, err := pkg.OpenDatabase()
+	db, err := pkg.OpenDatabase(ctx)
 	if err != nil {
 		return err
 	}
 
 	// Lookup the project ID from the slug; use the modloader wildcard so we'll get all the projects,
-	projectId, err := db.FindProjectBySlug(slug, "fabric+forge", 0)
+	projectId, err := db.FindProjectBySlug(ctx, slug, "fabric+forge", 0)
 	if err != nil {
 		return err
 	}
 
-	return pkg.PrintCurseForgeModInfo(projectId)
+	if ARG_FILES {
+		return pkg.PrintCurseForgeModFiles(ctx, projectId)
+	}
+
+	// Show which file would be selected for a pack, if one is given
+	var cp *pkg.ModPack
+	if flag.Arg(2) != "" {
+		cp, err = pkg.OpenModPack(ctx, flag.Arg(2), ARG_MMC)
+		if err != nil {
+			return err
+		}
+	}
+
+	return pkg.PrintCurseForgeModInfo(ctx, db, projectId, cp)
 }
 
-func cmdModExplore() error {
-	db, err := pkg.OpenDatabase()
+func cmdModExplore(ctx context.Context) error {
+	db, err := pkg.OpenDatabase(ctx)
 	if err != nil {
 		return err
 	}
 
-	explorer, err := ui.NewExplorer(db)
+	explorer, err := ui.NewExplorer(ctx, db)
 	if err != nil {
 		return err
 	}
 	return explorer.Run()
 }
 
-func listProjects(ptype int) error {
+func cmdServe(ctx context.Context) error {
+	db, err := pkg.OpenDatabase(ctx)
+	if err != nil {
+		return err
+	}
+
+	server, err := api.NewServer(ctx, db)
+	if err != nil {
+		return err
+	}
+	return server.ListenAndServe(ARG_LISTEN)
+}
+
+func listProjects(ctx context.Context, ptype int, loader string) error {
 	name := flag.Arg(1)
 	mcvsn := flag.Arg(2)
 
-	db, err := pkg.OpenDatabase()
+	db, err := pkg.OpenDatabase(ctx)
 	if err != nil {
 		return err
 	}
 
-	return db.PrintProjects(name, mcvsn, ptype)
+	return db.PrintProjects(name, mcvsn, ARG_CATEGORY, loader, ARG_SORT, ptype)
 }
 
-func cmdModList() error {
-	return listProjects(0)
+func cmdModList(ctx context.Context) error {
+	loader := flag.Arg(3)
+	if loader == "" {
+		loader = ARG_LOADER
+	}
+
+	switch loader {
+	case "", "fabric", "forge", "neoforge":
+	default:
+		return fmt.Errorf("'%s' is not a valid loader; it must be 'fabric', 'forge' or 'neoforge'", loader)
+	}
+
+	return listProjects(ctx, 0, loader)
 }
 
-func cmdPackList() error {
-	return listProjects(1)
+func cmdPackList(ctx context.Context) error {
+	return listProjects(ctx, 1, "")
 }
 
-func listLatestProjects(ptype int) error {
+func listLatestProjects(ctx context.Context, ptype int) error {
 	mcvsn := flag.Arg(1)
 
-	db, err := pkg.OpenDatabase()
+	db, err := pkg.OpenDatabase(ctx)
 	if err != nil {
 		return err
 	}
@@ -366,78 +819,331 @@ func listLatestProjects(ptype int) error {
 	return db.PrintLatestProjects(mcvsn, ptype)
 }
 
-func cmdModListLatest() error {
-	return listLatestProjects(0)
+func cmdModListLatest(ctx context.Context) error {
+	return listLatestProjects(ctx, 0)
 }
 
-func cmdPackListLatest() error {
-	return listLatestProjects(1)
+func cmdPackListLatest(ctx context.Context) error {
+	return listLatestProjects(ctx, 1)
 }
 
-func cmdModUpdateAll() error {
+func cmdModUpdateAll(ctx context.Context) error {
 	dir := flag.Arg(1)
+	if ARG_INTERACTIVE && ARG_DRY_RUN {
+		return fmt.Errorf("-interactive can't be used with a dry run")
+	}
 
-	cp, err := pkg.OpenModPack(dir, ARG_MMC)
+	cp, err := pkg.OpenModPack(ctx, dir, ARG_MMC)
 	if err != nil {
 		return err
 	}
 
-	err = cp.UpdateMods(ARG_DRY_RUN)
+	if !ARG_DRY_RUN {
+		err = backupWorlds(cp)
+		if err != nil {
+			return err
+		}
+	}
+
+	var updates []pkg.ModUpdate
+	if ARG_INTERACTIVE {
+		updates, err = cp.UpdateModsInteractively(ctx)
+	} else {
+		updates, err = cp.UpdateMods(ctx, ARG_DRY_RUN)
+	}
 	if err != nil {
 		return err
 	}
 
+	// Write up the changes for the pack's players; a failed report isn't a failed update
+	if !ARG_DRY_RUN {
+		report, err := cp.WriteUpdateReport(ctx, updates)
+		if err != nil {
+			pkg.Log(pkg.LogWarn, "Unable to write update report: %+v", err)
+		} else if report != "" {
+			pkg.Log(pkg.LogInfo, "Wrote update report to %s", report)
+		}
+	}
+
+	// Let the admin know what changed; a failed notification isn't a failed update
+	webhook := ARG_NOTIFY
+	if webhook == "" {
+		webhook = pkg.NotifyWebhook()
+	}
+	if webhook != "" {
+		err = pkg.NotifyUpdates(ctx, webhook, cp, updates, !ARG_DRY_RUN)
+		if err != nil {
+			pkg.Log(pkg.LogWarn, "Unable to send update notification: %+v", err)
+		}
+	}
+
 	return nil
 }
 
-func cmdForgeList() error {
+func cmdModOutdated(ctx context.Context) error {
+	cp, err := pkg.OpenModPack(ctx, flag.Arg(1), ARG_MMC)
+	if err != nil {
+		return err
+	}
+
+	mods := cp.OutdatedMods(ctx)
+	if ARG_JSON {
+		type outdatedJSON struct {
+			Key         string     `json:"key"`
+			Name        string     `json:"name"`
+			Current     string     `json:"current"`
+			Latest      string     `json:"latest"`
+			LatestFile  string     `json:"latestFile,omitempty"`
+			ReleaseType string     `json:"releaseType,omitempty"`
+			Published   *time.Time `json:"published,omitempty"`
+			Locked      bool       `json:"locked"`
+		}
+
+		result := []outdatedJSON{}
+		for _, m := range mods {
+			entry := outdatedJSON{Key: m.Key, Name: m.Name, Current: m.Current, Latest: m.Latest, LatestFile: m.LatestFile, Locked: m.Locked}
+			if m.ReleaseType != 0 {
+				entry.ReleaseType = pkg.ReleaseTypeName(m.ReleaseType)
+			}
+			if !m.Published.IsZero() {
+				entry.Published = &m.Published
+			}
+			result = append(result, entry)
+		}
+
+		// The warnings go in the same document, rather than a second one at the end
+		data, _ := json.MarshalIndent(map[string]interface{}{"outdated": result, "warnings": pkg.Warnings()}, "", "  ")
+		fmt.Println(string(data))
+		warningsPrinted = true
+		return nil
+	}
+
+	if len(mods) == 0 {
+		pkg.Log(pkg.LogInfo, "All mods in %s are up to date", cp.Name)
+		return nil
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "MOD\tCURRENT\tLATEST\tTYPE\tAGE\t")
+	for _, m := range mods {
+		latest := m.Latest
+		if m.LatestFile != "" {
+			latest = fmt.Sprintf("%s (%s)", m.Latest, m.LatestFile)
+		}
+		releaseType, age := "-", "-"
+		if m.ReleaseType != 0 {
+			releaseType = pkg.ReleaseTypeName(m.ReleaseType)
+		}
+		if !m.Published.IsZero() {
+			age = timeago.English.Format(m.Published)
+		}
+		name := m.Name
+		if m.Locked {
+			name += " [locked]"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t\n", name, m.Current, latest, releaseType, age)
+	}
+	return w.Flush()
+}
+
+func backupWorlds(cp *pkg.ModPack) error {
+	if ARG_NO_BACKUP {
+		return nil
+	}
+
+	_, err := cp.BackupWorlds(ARG_BACKUP_CONFIG, ARG_BACKUP_KEEP)
+	return err
+}
+
+func cmdPackUpgrade(ctx context.Context) error {
+	cp, err := pkg.OpenModPack(ctx, flag.Arg(1), ARG_MMC)
+	if err != nil {
+		return err
+	}
+
+	return cp.Upgrade(ctx, flag.Arg(2), ARG_FORGE_CHANNEL, ARG_DRY_RUN)
+}
+
+func cmdPackChannel(ctx context.Context) error {
+	cp, err := pkg.OpenModPack(ctx, flag.Arg(1), ARG_MMC)
+	if err != nil {
+		return err
+	}
+
+	channel := flag.Arg(2)
+	if channel == "default" {
+		channel = ""
+	}
+
+	if flag.Arg(3) != "" {
+		return cp.SetModReleaseChannel(ctx, flag.Arg(3), channel)
+	}
+	return cp.SetReleaseChannel(channel)
+}
+
+func cmdCacheVerify(ctx context.Context) error {
+	cp, err := pkg.OpenModPack(ctx, flag.Arg(1), ARG_MMC)
+	if err != nil {
+		return err
+	}
+
+	return cp.VerifyCache()
+}
+
+func cmdCacheRebuild(ctx context.Context) error {
+	cp, err := pkg.OpenModPack(ctx, flag.Arg(1), ARG_MMC)
+	if err != nil {
+		return err
+	}
+
+	return cp.RebuildCache(ctx)
+}
+
+func cmdLoaderUpdate(ctx context.Context) error {
+	dir := flag.Arg(1)
+
+	cp, err := pkg.OpenModPack(ctx, dir, ARG_MMC)
+	if err != nil {
+		return err
+	}
+
+	return cp.UpdateLoader(ctx, ARG_FORGE_CHANNEL, ARG_DRY_RUN)
+}
+
+func cmdForgeList(ctx context.Context) error {
+	mcvsn := flag.Arg(1)
+
+	db, err := pkg.OpenDatabase(ctx)
+	if err != nil {
+		return err
+	}
+
+	return db.ListForge(ctx, mcvsn, ARG_VERBOSE)
+}
+
+func cmdNeoForgeList(ctx context.Context) error {
 	mcvsn := flag.Arg(1)
 
-	db, err := pkg.OpenDatabase()
+	db, err := pkg.OpenDatabase(ctx)
 	if err != nil {
 		return err
 	}
 
-	return db.ListForge(mcvsn, ARG_VERBOSE)
+	return db.ListNeoForge(ctx, mcvsn, ARG_VERBOSE)
 }
 
-func cmdServerInstall() error {
+func cmdQuiltList(ctx context.Context) error {
+	mcvsn := flag.Arg(1)
+
+	db, err := pkg.OpenDatabase(ctx)
+	if err != nil {
+		return err
+	}
+
+	return db.ListQuilt(ctx, mcvsn, ARG_VERBOSE)
+}
+
+func cmdServerInstall(ctx context.Context) error {
 	dir := flag.Arg(1)
 
 	if ARG_MMC == true {
 		return fmt.Errorf("-mmc arg not supported when installing a server")
 	}
 
+	if ARG_DRY_RUN {
+		plan, err := pkg.PlanInstall(ctx, dir, "", false, false)
+		if err != nil {
+			return err
+		}
+		plan.Print()
+		return nil
+	}
+
 	// Open the pack; we require the manifest and any
 	// config files to already be present
-	cp, err := pkg.OpenModPack(dir, ARG_MMC)
+	cp, err := pkg.OpenModPack(ctx, dir, ARG_MMC)
 	if err != nil {
 		return err
 	}
 
 	// Install the server jar, Forge and dependencies
-	err = cp.InstallServer()
+	err = cp.InstallServer(ctx)
 	if err != nil {
 		return err
 	}
 
 	// Make sure all mods are installed (do NOT include client-side only)
-	err = cp.InstallMods(false)
+	err = cp.InstallMods(ctx, false)
+	if err != nil {
+		return err
+	}
+
+	// Generate start scripts with tuned memory settings
+	err = cp.GenerateStartScripts(ARG_MEM)
 	if err != nil {
 		return err
 	}
 
+	// Accept the EULA on behalf of the user, if they've asked us to
+	if ARG_ACCEPT_EULA {
+		err = cp.AcceptEULA()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Optionally, verify that the server actually boots
+	if ARG_SMOKE_TEST {
+		err = cp.SmokeTestServer(ctx, ARG_SMOKE_TIMEOUT)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
-func cmdDBUpdate() error {
-	err := pkg.InstallDatabase(false)
+func cmdRetry(ctx context.Context) error {
+	cp, err := pkg.OpenModPack(ctx, flag.Arg(1), ARG_MMC)
+	if err != nil {
+		return err
+	}
+
+	return cp.RetryFailedDownloads(ctx)
+}
+
+func cmdServerDocker(ctx context.Context) error {
+	cp, err := pkg.OpenModPack(ctx, flag.Arg(1), false)
+	if err != nil {
+		return err
+	}
+
+	return cp.GenerateDockerFiles(ARG_MEM)
+}
+
+func cmdServerEgg(ctx context.Context) error {
+	cp, err := pkg.OpenModPack(ctx, flag.Arg(1), false)
+	if err != nil {
+		return err
+	}
+
+	filename, err := cp.ExportPterodactylEgg()
+	if err != nil {
+		return err
+	}
+
+	pkg.Log(pkg.LogInfo, "Exported Pterodactyl egg to %s", filename)
+	return nil
+}
+
+func cmdDBUpdate(ctx context.Context) error {
+	err := pkg.InstallDatabase(ctx, false)
 	if err != nil {
 		return err
 	}
 
 	// Display last updated file in database (simple way to know how recent a file we have)
-	db, err := pkg.OpenDatabase()
+	db, err := pkg.OpenDatabase(ctx)
 	if err != nil {
 		return err
 	}
@@ -450,14 +1156,75 @@ func cmdDBUpdate() error {
 	elapsed := time.Unix(int64(tstamp), 0)
 	elapsedFriendly := timeago.English.Format(elapsed)
 
-	fmt.Printf("Database up-to-date as of %s (%s)\n", elapsedFriendly, elapsed)
+	pkg.Log(pkg.LogInfo, "Database up-to-date as of %s (%s)", elapsedFriendly, elapsed)
 	return nil
 }
 
+func cmdAuthLogin(ctx context.Context) error {
+	token, err := pkg.ReadAPIToken(flag.Arg(1))
+	if err != nil {
+		return err
+	}
+	return pkg.SaveAPIToken(flag.Arg(1), token, !ARG_NO_KEYRING)
+}
+
+func cmdAuthLogout(ctx context.Context) error {
+	return pkg.DeleteAPIToken(flag.Arg(1))
+}
+
+func cmdTargetAdd(ctx context.Context) error {
+	return pkg.AddTarget(flag.Arg(1), flag.Arg(2))
+}
+
+func cmdTargetRemove(ctx context.Context) error {
+	return pkg.RemoveTarget(flag.Arg(1))
+}
+
+func cmdTargetList(ctx context.Context) error {
+	return pkg.ListTargets()
+}
+
 func console(f string, args ...interface{}) {
 	fmt.Printf(f, args...)
 }
 
+// fatal logs an error and exits; the log file (if any) is closed first so nothing is lost
+func fatal(f string, args ...interface{}) {
+	pkg.Log(pkg.LogError, f, args...)
+	pkg.CloseLogFile()
+	os.Exit(1)
+}
+
+// initLogging configures the level, format and destination of status messages from the
+// command-line flags
+func initLogging() error {
+	level, err := pkg.ParseLogLevel(ARG_LOG_LEVEL)
+	if err != nil {
+		return err
+	}
+	if ARG_VERBOSE && ARG_QUIET {
+		return fmt.Errorf("-v and -q can't be used together")
+	}
+	if ARG_VERBOSE && level > pkg.LogDebug {
+		level = pkg.LogDebug
+	}
+	// Quiet runs (e.g. from cron) only display errors and the summary of warnings
+	if ARG_QUIET {
+		level = pkg.LogError
+	}
+	pkg.SetLogLevel(level)
+
+	err = pkg.SetLogFormat(ARG_LOG_FORMAT)
+	if err != nil {
+		return err
+	}
+
+	if ARG_LOG_FILE != "" {
+		return pkg.SetLogFile(ARG_LOG_FILE)
+	}
+	return nil
+}
+
 func usage() {
 	console("usage: mcdex [<options>] <command> [<args>]\n")
 	console("<options>\n")
@@ -477,22 +1244,57 @@ func usage() {
 
 func main() {
 	var mcDir string
+	var target string
 
-	// Look for MultiMC on the path
-	var mmcDir string
-	if path, err := exec.LookPath("MultiMC"); err == nil {
-		if path, err := filepath.EvalSymlinks(path); err == nil {
-			mmcDir = filepath.Dir(path)
-		}
-	}
+	// Look for MultiMC (or PolyMC/Prism Launcher) on the path
+	mmcDir := pkg.FindMMCDir()
 
 	// Register
 	flag.BoolVar(&ARG_MMC, "mmc", false, "Generate MultiMC instance.cfg when installing a pack")
-	flag.StringVar(&mmcDir, "mmcdir", mmcDir, "Path to directory containing MultiMC executable.")
+	flag.StringVar(&mmcDir, "mmcdir", mmcDir, "Path to directory containing MultiMC, PolyMC or Prism Launcher (or its data directory).")
 	flag.StringVar(&mcDir, "mcdir", "","Minecraft home folder to use. If -mmc is used, will use the value of -mmcdir as the default.")
+	flag.StringVar(&target, "target", "", "Name of a registered Minecraft directory (see target.add) to use instead of -mcdir")
 	flag.BoolVar(&ARG_VERBOSE, "v", false, "Enable verbose logging of operations")
+	flag.BoolVar(&ARG_NO_COLOR, "no-color", false, "Disable colored output (also disabled when output isn't a terminal or NO_COLOR is set)")
+	flag.BoolVar(&ARG_QUIET, "q", false, "Only display errors and the summary of warnings (e.g. for cron jobs and CI)")
 	flag.BoolVar(&ARG_SKIPMODS, "skipmods", false, "Skip download of mods when installing a pack")
-	flag.BoolVar(&ARG_DRY_RUN, "n", false, "Dry run; don't save any changes to manifest")
+	flag.BoolVar(&ARG_WITH_OPTIONAL, "with-optional", false, "Install optional mods without asking when installing a pack")
+	flag.BoolVar(&ARG_IGNORE_FAILURES, "ignore-failures", false, "Keep installing a pack when mods fail to download; the failures can be retried later with retry")
+	flag.BoolVar(&ARG_DRY_RUN, "n", false, "Dry run; don't save any changes to manifest (with pack.install or server.install, show what would be installed)")
+	flag.BoolVar(&ARG_INTERACTIVE, "interactive", false, "Review each update with mod.update.all, choosing to apply it, skip it or lock the mod")
+	flag.BoolVar(&ARG_NO_KEYRING, "no-keyring", false, "Store API tokens in the config file instead of the system keyring")
+	flag.BoolVar(&ARG_SMOKE_TEST, "smoke-test", false, "After server.install, start the server to verify that it boots")
+	flag.BoolVar(&ARG_ACCEPT_EULA, "accept-eula", false, "Accept the Minecraft EULA (https://aka.ms/MinecraftEULA) when installing a server")
+	flag.StringVar(&ARG_MEM, "mem", "", "Heap size for generated server start scripts and Dockerfiles (e.g. 6G); defaults to a size based on the number of mods")
+	flag.StringVar(&ARG_XMX, "xmx", "", "Maximum heap size (e.g. 6G) for the launcher profile or MultiMC instance")
+	flag.StringVar(&ARG_XMS, "xms", "", "Initial heap size (e.g. 2G) for the launcher profile or MultiMC instance")
+	flag.StringVar(&ARG_JAVA_ARGS, "javaargs", "", "Additional JVM arguments for the launcher profile or MultiMC instance")
+	flag.BoolVar(&ARG_NO_BACKUP, "no-backup", false, "Don't back up worlds before updating mods or re-installing a pack")
+	flag.BoolVar(&ARG_BACKUP_CONFIG, "backup-config", false, "Include the config directory when backing up worlds")
+	flag.IntVar(&ARG_BACKUP_KEEP, "backup-keep", 5, "Number of world backups to keep (0 keeps all of them)")
+	flag.DurationVar(&ARG_SMOKE_TIMEOUT, "smoke-timeout", 10*time.Minute, "Maximum time to wait for the server to start during a smoke test")
+	flag.StringVar(&ARG_CATEGORY, "category", "", "Only list mods in the given category (worldgen, tech, magic, etc)")
+	flag.StringVar(&ARG_LOADER, "loader", "", "Only list mods for the given mod loader: fabric|forge|neoforge")
+	flag.BoolVar(&ARG_FILES, "files", false, "List all the files of a mod in mod.info (ID, date, name, Minecraft versions, loader and release type)")
+	flag.BoolVar(&ARG_INSTALL, "install", false, "Install the pack's mods after selecting mods with mod.select")
+	flag.StringVar(&ARG_FROM_FILE, "from-file", "", "Text file listing mods for mod.select, one per line (add \"client\", \"server\" or \"optional\" after a mod to mark it)")
+	flag.BoolVar(&ARG_DEPS, "deps", false, "Also select the mods required by mods selected with mod.select or added from the explorer")
+	flag.BoolVar(&ARG_OPTIONAL, "optional", false, "Mark mods selected with mod.select as optional; they're skipped by pack.install unless -with-optional is used")
+	flag.StringVar(&ARG_VERSION_URL, "version-url", "", "Page or JSON document listing the versions of an ext:<name> mod whose URL contains {version}")
+	flag.StringVar(&ARG_VERSION_REGEX, "version-regex", "", "Regular expression matching each version on the -version-url page (the first group, if any)")
+	flag.StringVar(&ARG_VERSION_PATH, "version-path", "", "Path to the versions in the -version-url JSON document (e.g. versions.name)")
+	flag.BoolVar(&ARG_NO_SIDE_DETECT, "no-side-detect", false, "Don't mark mods selected with mod.select as client-side only, even if their metadata says they are")
+	flag.StringVar(&ARG_SORT, "sort", pkg.SortByName, "Sort order for mod.list and pack.list: name|downloads|updated")
+	flag.BoolVar(&ARG_WIZARD, "wizard", false, "Choose the name, loader, Minecraft version, launcher and mods of a new pack interactively (pack.create)")
+	flag.BoolVar(&ARG_TUI, "tui", false, "Display a progress dashboard while installing a pack")
+	flag.BoolVar(&ARG_JSON, "json", false, "Print mod.outdated results and the summary of warnings at the end of a command as JSON")
+	flag.StringVar(&ARG_FORGE_CHANNEL, "forge-channel", pkg.ForgeChannelRecommended, "Forge release channel to use when selecting a version: recommended|latest")
+	flag.StringVar(&ARG_LOADER_VERSION, "loader-version", "", "Specific mod loader version to use when creating a pack, instead of the latest")
+	flag.StringVar(&ARG_NOTIFY, "notify", "", "Discord or Slack webhook URL to notify of updates found by mod.update.all (default: notify.webhook in the config file)")
+	flag.StringVar(&ARG_LISTEN, "listen", "127.0.0.1:8080", "Address for serve to listen on")
+	flag.StringVar(&ARG_LOG_LEVEL, "log-level", "info", "Minimum level of messages to display: debug|info|warn|error (-v implies debug, -q implies error)")
+	flag.StringVar(&ARG_LOG_FORMAT, "log-format", pkg.LogFormatText, "Format of messages: text|json (one JSON object per message)")
+	flag.StringVar(&ARG_LOG_FILE, "log-file", "", "Append all messages, including debug messages, to a file")
 
 	// Process command-line args
 	flag.Parse()
@@ -501,22 +1303,61 @@ func main() {
 		os.Exit(-1)
 	}
 
+	if err := initLogging(); err != nil {
+		fatal("%+v", err)
+	}
+	defer pkg.CloseLogFile()
+
+	pkg.SetInstallOptional(ARG_WITH_OPTIONAL)
+	pkg.SetIgnoreFailures(ARG_IGNORE_FAILURES)
+	pkg.SetSelectDependencies(ARG_DEPS)
+	pkg.SetColor(!ARG_NO_COLOR)
+
+	if target != "" {
+		if mcDir != "" {
+			fatal("-target and -mcdir can't be used together")
+		}
+		dir, err := pkg.LookupTarget(target)
+		if err != nil {
+			fatal("Failed to initialize: %s", err)
+		}
+		mcDir = dir
+	}
+
 	if ARG_MMC {
 		if mmcDir == "" {
-			log.Fatal("-mmc specified, but could not find MultiMC executable! Set MultiMC directory using -mmcdir")
+			fatal("-mmc specified, but could not find MultiMC, PolyMC or Prism Launcher! Set the launcher directory using -mmcdir")
 		}
-		if _, err := exec.LookPath(filepath.Join(mmcDir, "MultiMC")); err != nil {
-			log.Fatalf("Invalid MultiMC path specified: %s", mmcDir)
+		if !pkg.IsMMCDir(mmcDir) {
+			fatal("Invalid MultiMC path specified: %s", mmcDir)
 		}
 		if mcDir == "" {
 			mcDir = mmcDir
 		}
 	}
 
+	// On interrupt, cancel whatever is in progress so downloads and installers stop cleanly; a
+	// second interrupt exits immediately (still cleaning up temporary files)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interrupts := make(chan os.Signal, 2)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-interrupts
+		pkg.Log(pkg.LogWarn, "Interrupted; stopping (press Ctrl-C again to exit immediately)")
+		cancel()
+
+		<-interrupts
+		pkg.CleanupTempDirs()
+		pkg.CloseLogFile()
+		os.Exit(1)
+	}()
+
 	// Initialize our environment
-	err := pkg.InitEnv(mcDir, mmcDir)
+	err := pkg.InitEnv(ctx, mcDir, mmcDir)
 	if err != nil {
-		log.Fatalf("Failed to initialize: %s\n", err)
+		fatal("Failed to initialize: %s", err)
 	}
 
 	commandName := flag.Arg(0)
@@ -527,15 +1368,23 @@ func main() {
 		os.Exit(-1)
 	}
 
-	// Check that the required number of arguments is present
-	if flag.NArg() < command.ArgsCount+1 {
+	// Check that the required number of arguments is present; the pack.create wizard asks for them
+	wizard := ARG_WIZARD && commandName == "pack.create"
+	if flag.NArg() < command.ArgsCount+1 && !wizard {
 		console("ERROR: insufficient arguments for %s\n", commandName)
 		console("usage: mcdex %s %s\n", commandName, command.Args)
 		os.Exit(-1)
 	}
 
-	err = command.Fn()
+	err = command.Fn(ctx)
+
+	// Summarize any warnings that came up while running the command
+	if !warningsPrinted {
+		pkg.PrintWarnings(ARG_JSON || ARG_LOG_FORMAT == pkg.LogFormatJSON)
+	}
+
 	if err != nil {
-		log.Fatalf("%+v\n", err)
+		pkg.CleanupTempDirs()
+		fatal("%+v", err)
 	}
 }
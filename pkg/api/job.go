@@ -0,0 +1,176 @@
+package api
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mcdex/pkg"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Only report download progress this often, so a fast download doesn't flood the event stream
+const progressInterval = 250 * time.Millisecond
+
+// event is a server-sent event describing the progress of a job
+type event struct {
+	Type  string `json:"type"` // log, download, progress, finished, done
+	File  string `json:"file,omitempty"`
+	Total int64  `json:"total,omitempty"`
+	Count int64  `json:"count,omitempty"`
+	Msg   string `json:"msg,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type job struct {
+	id   int
+	name string
+
+	lock     sync.Mutex
+	events   []event
+	changed  chan struct{} // closed (and replaced) whenever an event is added
+	done     bool
+	err      error
+	result   interface{}
+	progress map[string]time.Time
+}
+
+type jobStatus struct {
+	ID     int         `json:"id"`
+	Name   string      `json:"name"`
+	Done   bool        `json:"done"`
+	Error  string      `json:"error,omitempty"`
+	Result interface{} `json:"result,omitempty"`
+}
+
+func newJob(id int, name string) *job {
+	return &job{id: id, name: name, changed: make(chan struct{}), progress: make(map[string]time.Time)}
+}
+
+// run runs the job with its output and downloads captured as events
+func (j *job) run(f func(j *job) error) {
+	reader, writer := io.Pipe()
+	logged := make(chan bool)
+	go func() {
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			j.addEvent(event{Type: "log", Msg: scanner.Text()})
+		}
+		logged <- true
+	}()
+
+	pkg.SetOutput(writer)
+	pkg.SetDownloadObserver(j)
+	err := f(j)
+	pkg.SetDownloadObserver(nil)
+	pkg.SetOutput(os.Stdout)
+	writer.Close()
+	<-logged
+
+	if err != nil {
+		pkg.Log(pkg.LogError, "Job %d (%s) failed: %+v", j.id, j.name, err)
+	} else {
+		pkg.Log(pkg.LogInfo, "Job %d (%s) complete", j.id, j.name)
+	}
+
+	j.lock.Lock()
+	j.done = true
+	j.err = err
+	j.lock.Unlock()
+
+	done := event{Type: "done"}
+	if err != nil {
+		done.Error = err.Error()
+	}
+	j.addEvent(done)
+}
+
+func (j *job) setResult(result interface{}) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.result = result
+}
+
+func (j *job) status() jobStatus {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	status := jobStatus{ID: j.id, Name: j.name, Done: j.done, Result: j.result}
+	if j.err != nil {
+		status.Error = j.err.Error()
+	}
+	return status
+}
+
+func (j *job) addEvent(e event) {
+	j.lock.Lock()
+	defer j.lock.Unlock()
+	j.events = append(j.events, e)
+	close(j.changed)
+	j.changed = make(chan struct{})
+}
+
+func (j *job) DownloadStarted(label string, total int64) {
+	j.addEvent(event{Type: "download", File: label, Total: total})
+}
+
+func (j *job) DownloadProgress(label string, count int64) {
+	j.lock.Lock()
+	last := j.progress[label]
+	if time.Since(last) < progressInterval {
+		j.lock.Unlock()
+		return
+	}
+	j.progress[label] = time.Now()
+	j.lock.Unlock()
+
+	j.addEvent(event{Type: "progress", File: label, Count: count})
+}
+
+func (j *job) DownloadFinished(label string, err error) {
+	e := event{Type: "finished", File: label}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	j.addEvent(e)
+}
+
+// streamEvents sends the job's events (from the beginning) as server-sent events until the job is
+// done or the client goes away
+func (j *job) streamEvents(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, fmt.Errorf("streaming not supported"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+
+	sent := 0
+	for {
+		j.lock.Lock()
+		pending := j.events[sent:]
+		changed := j.changed
+		j.lock.Unlock()
+
+		for _, e := range pending {
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", e.Type, data)
+			if e.Type == "done" {
+				flusher.Flush()
+				return
+			}
+		}
+		sent += len(pending)
+		flusher.Flush()
+
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
@@ -0,0 +1,389 @@
+package api
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mcdex/pkg"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Server exposes mcdex over HTTP so dashboards and game panel plugins can drive it without
+// shelling out:
+//
+//	GET  /packs                     installed packs
+//	GET  /packs/{name}/mods         mods selected in a pack
+//	POST /packs/{name}/update       update the mods in a pack (?dryRun=true to only check)
+//	POST /packs/install             install a modpack; body: {"dir": ..., "url": ..., "multimc": bool}
+//	GET  /search?mcvsn=&loader=&q=  search the mod database (type=modpack for modpacks)
+//	GET  /jobs/{id}                 status of an install or update
+//	GET  /jobs/{id}/events          progress of an install or update, as server-sent events
+//
+// Installs and updates run in the background as jobs; mcdex can only run one at a time, so
+// starting a job while another is running fails with 409 Conflict.
+//
+// Every request must carry the token generated when the server starts (Authorization: Bearer
+// <token>), and be addressed to a loopback host; POST bodies must be application/json. Together
+// these keep web pages the user visits from driving the API.
+type Server struct {
+	ctx   context.Context // canceled when mcdex shuts down; jobs outlive their requests, so they use it
+	db    *pkg.Database
+	token string
+
+	lock   sync.Mutex
+	jobs   map[int]*job
+	nextID int
+	active *job
+}
+
+func NewServer(ctx context.Context, db *pkg.Database) (*Server, error) {
+	token := make([]byte, 16)
+	_, err := rand.Read(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate API token: %+v", err)
+	}
+	return &Server{ctx: ctx, db: db, token: hex.EncodeToString(token), jobs: make(map[int]*job), nextID: 1}, nil
+}
+
+// Token is the token clients must send with each request
+func (s *Server) Token() string {
+	return s.token
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/packs", s.handlePacks)
+	mux.HandleFunc("/packs/", s.handlePack)
+	mux.HandleFunc("/search", s.handleSearch)
+	mux.HandleFunc("/jobs/", s.handleJob)
+	return s.authorize(mux)
+}
+
+// authorize rejects requests without the server's token, for a host other than loopback, from
+// another site, or (for POSTs) with a body that isn't JSON
+func (s *Server) authorize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isLoopbackHost(r.Host) {
+			writeError(w, http.StatusForbidden, fmt.Errorf("invalid host %s", r.Host))
+			return
+		}
+
+		if origin := r.Header.Get("Origin"); origin != "" {
+			u, err := url.Parse(origin)
+			if err != nil || !isLoopbackHost(u.Host) {
+				writeError(w, http.StatusForbidden, fmt.Errorf("invalid origin %s", origin))
+				return
+			}
+		}
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, fmt.Errorf("missing or invalid token"))
+			return
+		}
+
+		if r.Method == http.MethodPost {
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != "application/json" {
+				writeError(w, http.StatusUnsupportedMediaType, fmt.Errorf("requests must be application/json"))
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// isLoopbackHost checks that a host (with or without a port) is localhost or a loopback address
+func isLoopbackHost(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+	if strings.EqualFold(host, "localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// ListenAndServe serves the API until the listener fails or the server's context is canceled
+func (s *Server) ListenAndServe(addr string) error {
+	server := &http.Server{Addr: addr, Handler: s.Handler()}
+	go func() {
+		<-s.ctx.Done()
+		server.Close()
+	}()
+
+	pkg.Log(pkg.LogInfo, "Listening on http://%s", addr)
+	pkg.Log(pkg.LogInfo, "API token (send as Authorization: Bearer <token>): %s", s.token)
+	err := server.ListenAndServe()
+	if err == http.ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+func (s *Server) handlePacks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s not allowed", r.Method))
+		return
+	}
+
+	type packJSON struct {
+		Name             string `json:"name"`
+		Dir              string `json:"dir"`
+		MultiMC          bool   `json:"multimc"`
+		MinecraftVersion string `json:"minecraftVersion"`
+		Loader           string `json:"loader"`
+	}
+
+	result := []packJSON{}
+	for _, p := range pkg.ListPacks() {
+		result = append(result, packJSON{p.Name, p.Dir, p.MultiMC, p.MinecraftVersion, p.Loader})
+	}
+	writeJSON(w, result)
+}
+
+// handlePack dispatches /packs/install, /packs/{name}/mods and /packs/{name}/update
+func (s *Server) handlePack(w http.ResponseWriter, r *http.Request) {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, "/packs/"), "/")
+	if path == "install" {
+		s.handleInstall(w, r)
+		return
+	}
+
+	parts := strings.Split(path, "/")
+	if len(parts) != 2 {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown path %s", r.URL.Path))
+		return
+	}
+
+	location, ok := findPack(parts[0])
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown pack %s", parts[0]))
+		return
+	}
+
+	switch {
+	case parts[1] == "mods" && r.Method == http.MethodGet:
+		pack, err := pkg.OpenModPack(r.Context(), location.Dir, location.MultiMC)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, modsJSON(pack.Mods()))
+
+	case parts[1] == "update" && r.Method == http.MethodPost:
+		dryRun, _ := strconv.ParseBool(r.URL.Query().Get("dryRun"))
+		s.startJob(w, fmt.Sprintf("update %s", location.Name), func(j *job) error {
+			pack, err := pkg.OpenModPack(s.ctx, location.Dir, location.MultiMC)
+			if err != nil {
+				return err
+			}
+
+			updates, err := pack.UpdateMods(s.ctx, dryRun)
+			j.setResult(updates)
+			return err
+		})
+
+	default:
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown path %s %s", r.Method, r.URL.Path))
+	}
+}
+
+func (s *Server) handleInstall(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, fmt.Errorf("%s not allowed", r.Method))
+		return
+	}
+
+	var request struct {
+		Dir     string `json:"dir"`
+		URL     string `json:"url"`
+		MultiMC bool   `json:"multimc"`
+	}
+	err := json.NewDecoder(r.Body).Decode(&request)
+	if err != nil || request.Dir == "" || request.URL == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("dir and url are required"))
+		return
+	}
+
+	// Packs can only be installed into the packs (or MultiMC instances) directory
+	dir, err := pkg.PackDir(request.Dir, request.MultiMC)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	s.startJob(w, fmt.Sprintf("install %s", request.Dir), func(j *job) error {
+		return pkg.InstallPack(s.ctx, dir, request.URL, pkg.PackInstallOptions{MultiMC: request.MultiMC})
+	})
+}
+
+// Sort orders for searches, mapped to the columns ForEachProject orders by
+var searchOrders = map[string]struct {
+	field     string
+	ascending bool
+}{
+	pkg.SortByDownloads: {"downloads", false},
+	pkg.SortByUpdated:   {"modified_ts", false},
+	pkg.SortByName:      {"slug", true},
+}
+
+func (s *Server) handleSearch(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	mcvsn := query.Get("mcvsn")
+	if mcvsn == "" {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("mcvsn is required"))
+		return
+	}
+
+	loader := query.Get("loader")
+	switch loader {
+	case "":
+		loader = "forge"
+	case "fabric", "forge", "neoforge":
+	default:
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid loader %s", loader))
+		return
+	}
+
+	ptype := 0
+	if query.Get("type") == "modpack" {
+		ptype = 1
+	}
+
+	sortBy := query.Get("sort")
+	if sortBy == "" {
+		sortBy = pkg.SortByDownloads
+	}
+	order, ok := searchOrders[sortBy]
+	if !ok {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid sort order %s", sortBy))
+		return
+	}
+
+	type projectJSON struct {
+		ID          int    `json:"id"`
+		Slug        string `json:"slug"`
+		Loader      string `json:"loader"`
+		Description string `json:"description"`
+		Downloads   int    `json:"downloads"`
+		Modified    int    `json:"modified"`
+		Created     int    `json:"created"`
+	}
+
+	result := []projectJSON{}
+	_, err := s.db.ForEachProject(ptype, mcvsn, loader, query.Get("q"), query.Get("category"), order.field, order.ascending,
+		func(id int, slug string, loader string, description string, downloads int, modifiedTs int, createdTs int) error {
+			result = append(result, projectJSON{id, slug, loader, description, downloads, modifiedTs, createdTs})
+			return nil
+		})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, result)
+}
+
+// handleJob dispatches /jobs/{id} and /jobs/{id}/events
+func (s *Server) handleJob(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/jobs/"), "/"), "/")
+	id, err := strconv.Atoi(parts[0])
+	if err != nil || len(parts) > 2 || (len(parts) == 2 && parts[1] != "events") {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown path %s", r.URL.Path))
+		return
+	}
+
+	s.lock.Lock()
+	j, ok := s.jobs[id]
+	s.lock.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, fmt.Errorf("unknown job %d", id))
+		return
+	}
+
+	if len(parts) == 2 {
+		j.streamEvents(w, r)
+	} else {
+		writeJSON(w, j.status())
+	}
+}
+
+// startJob runs an install or update in the background, responding with the job's ID
+func (s *Server) startJob(w http.ResponseWriter, name string, run func(j *job) error) {
+	s.lock.Lock()
+	if active := s.active; active != nil {
+		s.lock.Unlock()
+		writeError(w, http.StatusConflict, fmt.Errorf("job %d (%s) is still running", active.id, active.name))
+		return
+	}
+
+	j := newJob(s.nextID, name)
+	s.nextID++
+	s.jobs[j.id] = j
+	s.active = j
+	s.lock.Unlock()
+
+	go func() {
+		j.run(run)
+
+		s.lock.Lock()
+		s.active = nil
+		s.lock.Unlock()
+	}()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(j.status())
+}
+
+func findPack(name string) (pkg.PackLocation, bool) {
+	for _, p := range pkg.ListPacks() {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return pkg.PackLocation{}, false
+}
+
+func modsJSON(mods []pkg.ModStatus) interface{} {
+	type modJSON struct {
+		Key        string `json:"key"`
+		Name       string `json:"name"`
+		Filename   string `json:"filename,omitempty"`
+		ClientOnly bool   `json:"clientOnly"`
+		ServerOnly bool   `json:"serverOnly"`
+		Optional   bool   `json:"optional"`
+		Locked     bool   `json:"locked"`
+		Disabled   bool   `json:"disabled"`
+	}
+
+	result := []modJSON{}
+	for _, m := range mods {
+		result = append(result, modJSON{m.Key, m.Name, m.Filename, m.ClientOnly, m.ServerOnly, m.Optional, m.Locked, m.Disabled})
+	}
+	return result
+}
+
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,95 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// ATLauncher stores each instance as a directory containing instance.json (a Mojang version
+// descriptor with a "launcher" section describing the pack) alongside mods/, config/, etc.
+
+var atLauncherLoaderTypes = map[string]string{
+	"forge":    "Forge",
+	"neoforge": "NeoForge",
+	"fabric":   "Fabric",
+	"quilt":    "Quilt",
+}
+
+// ExportATLauncherInstance writes an ATLauncher instance.json into the pack's game directory, so
+// that the directory can be dropped into ATLauncher's instances folder; returns the filename
+func (pack *ModPack) ExportATLauncherInstance() (string, error) {
+	minecraftVsn, loaderVsn := pack.getVersions()
+	loaderType, ok := atLauncherLoaderTypes[pack.modLoader]
+	if !ok {
+		return "", fmt.Errorf("ATLauncher does not support mod loader %s", pack.modLoader)
+	}
+
+	mods := []map[string]interface{}{}
+	files := manifestFiles(pack.manifest)
+	for _, f := range files {
+		modFile, err := newModPackFile(f)
+		if err != nil {
+			warn(WarnManifest, "%+v", err)
+			continue
+		}
+
+		filename := modFile.installedFilename(pack)
+		if filename == "" {
+			warn(WarnOther, "%s is not installed; not included in instance.json", modFile.getName())
+			continue
+		}
+
+		mod := map[string]interface{}{
+			"name":        modFile.getName(),
+			"version":     strings.TrimSuffix(filename, filepath.Ext(filename)),
+			"optional":    false,
+			"file":        filename,
+			"type":        "mods",
+			"description": modFile.getName(),
+			"disabled":    pack.modCache.IsDisabled(modFile.cacheKey()),
+			"userAdded":   false,
+			"wasSelected": true,
+			"skipped":     false,
+		}
+		if cf, ok := modFile.(*CurseForgeModFile); ok {
+			mod["curseForgeProjectId"] = cf.projectID
+			mod["curseForgeFileId"] = cf.fileID
+		}
+		mods = append(mods, mod)
+	}
+
+	name, _ := strValue(pack.manifest, "name")
+	version, _ := strValue(pack.manifest, "version")
+	instance := map[string]interface{}{
+		"id":   minecraftVsn,
+		"type": "release",
+		"launcher": map[string]interface{}{
+			"name":    name,
+			"pack":    name,
+			"version": version,
+			"loaderVersion": map[string]interface{}{
+				"version":     loaderVsn,
+				"rawVersion":  fmt.Sprintf("%s-%s", minecraftVsn, loaderVsn),
+				"recommended": false,
+				"type":        loaderType,
+			},
+			"enableCurseForgeIntegration": true,
+			"enableEditingMods":           true,
+			"mods":                        mods,
+		},
+	}
+
+	data, err := json.MarshalIndent(instance, "", "  ")
+	if err != nil {
+		return "", err
+	}
+
+	filename := filepath.Join(pack.gamePath(), "instance.json")
+	err = writeStringFile(filename, string(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s: %+v", filename, err)
+	}
+	return filename, nil
+}
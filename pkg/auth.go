@@ -0,0 +1,160 @@
+package pkg
+
+import (
+	"bufio"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/zalando/go-keyring"
+	"golang.org/x/term"
+)
+
+const keyringService = "mcdex"
+
+// Services that mcdex knows how to authenticate against, along with the API hosts the token is
+// sent to; these must match the hosts mcdex actually requests
+var authServices = map[string][]string{
+	"curseforge": {urlHostname(curseForgeAPI), urlHostname(curseForgeCoreAPI)},
+	"github":     {"api.github.com"},
+}
+
+func urlHostname(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+	return u.Hostname()
+}
+
+// APITokenEnv is the environment variable that provides the token for a service (e.g.
+// MCDEX_CURSEFORGE_TOKEN); it takes precedence over a stored token
+func APITokenEnv(service string) string {
+	return "MCDEX_" + strings.ToUpper(service) + "_TOKEN"
+}
+
+// ReadAPIToken reads the token for a service from its environment variable or, failing that, from
+// stdin (without echoing it on a terminal); tokens are never taken from the command line, where
+// they'd show up in ps and shell history
+func ReadAPIToken(service string) (string, error) {
+	if _, ok := authServices[service]; !ok {
+		return "", fmt.Errorf("unknown service %s", service)
+	}
+
+	if token := os.Getenv(APITokenEnv(service)); token != "" {
+		return token, nil
+	}
+
+	var token string
+	if fd := int(os.Stdin.Fd()); term.IsTerminal(fd) {
+		fmt.Fprintf(consoleOutput, "%s token: ", service)
+		data, err := term.ReadPassword(fd)
+		fmt.Fprintln(consoleOutput)
+		if err != nil {
+			return "", fmt.Errorf("failed to read token: %+v", err)
+		}
+		token = string(data)
+	} else {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read token from stdin: %+v", err)
+		}
+		token = line
+	}
+
+	token = strings.TrimSpace(token)
+	if token == "" {
+		return "", fmt.Errorf("no token provided for %s; pipe it to stdin or set %s", service, APITokenEnv(service))
+	}
+	return token, nil
+}
+
+// SaveAPIToken stores the token for a service; by default, the token is placed in the OS keyring
+// (Keychain, Credential Manager, Secret Service) and only a marker is kept in the config. If the
+// keyring is unavailable or disabled, the token is stored in the config file in plaintext, which is
+// then only readable by the user.
+func SaveAPIToken(service, token string, useKeyring bool) error {
+	if _, ok := authServices[service]; !ok {
+		return fmt.Errorf("unknown service %s", service)
+	}
+
+	cfg := GetConfig()
+	if useKeyring {
+		err := keyring.Set(keyringService, service, token)
+		if err == nil {
+			cfg.delete("auth", service, "token")
+			cfg.set("keyring", "auth", service, "storage")
+			logInfo("Stored %s token in system keyring\n", service)
+			return cfg.save()
+		}
+		logInfo("System keyring not available (%+v); storing token in %s\n", err, cfg.filename)
+	}
+
+	cfg.set("config", "auth", service, "storage")
+	cfg.set(token, "auth", service, "token")
+	err := cfg.save()
+	if err != nil {
+		return err
+	}
+	return os.Chmod(cfg.filename, 0600)
+}
+
+// DeleteAPIToken removes the token for a service from both the keyring and config
+func DeleteAPIToken(service string) error {
+	if _, ok := authServices[service]; !ok {
+		return fmt.Errorf("unknown service %s", service)
+	}
+
+	cfg := GetConfig()
+	if cfg.getString("auth."+service+".storage", "") == "keyring" {
+		err := keyring.Delete(keyringService, service)
+		if err != nil && err != keyring.ErrNotFound {
+			return fmt.Errorf("failed to remove %s token from keyring: %+v", service, err)
+		}
+	}
+
+	cfg.delete("auth", service)
+	return cfg.save()
+}
+
+func apiToken(service string) string {
+	if token := os.Getenv(APITokenEnv(service)); token != "" {
+		return token
+	}
+
+	cfg := GetConfig()
+	if cfg == nil {
+		return ""
+	}
+
+	switch cfg.getString("auth."+service+".storage", "") {
+	case "keyring":
+		token, err := keyring.Get(keyringService, service)
+		if err != nil {
+			logWarn("Unable to retrieve %s token from keyring: %+v\n", service, err)
+			return ""
+		}
+		return token
+	case "config":
+		return cfg.getString("auth."+service+".token", "")
+	}
+	return ""
+}
+
+// apiTokenForURL finds the service token (if any) that applies to the host of a URL
+func apiTokenForURL(rawurl string) (string, string) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", ""
+	}
+
+	for service, hosts := range authServices {
+		for _, host := range hosts {
+			if u.Hostname() == host {
+				return service, apiToken(service)
+			}
+		}
+	}
+	return "", ""
+}
@@ -0,0 +1,134 @@
+package pkg
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Directories holding worlds; clients keep them in saves/, servers in world/
+var worldDirs = []string{"saves", "world"}
+
+const backupDir = "backups"
+
+// BackupWorlds creates a timestamped ZIP of the pack's worlds (and optionally the config
+// directory) under backups/, then removes all but the most recent keep backups (keep <= 0
+// retains all of them). Returns the backup filename, or an empty string if there was
+// nothing to back up.
+func (pack *ModPack) BackupWorlds(includeConfig bool, keep int) (string, error) {
+	dirs := worldDirs
+	if includeConfig {
+		dirs = append(dirs, "config")
+	}
+
+	var sources []string
+	for _, dir := range dirs {
+		if dirExists(filepath.Join(pack.gamePath(), dir)) {
+			sources = append(sources, dir)
+		}
+	}
+
+	if len(sources) == 0 {
+		return "", nil
+	}
+
+	filename := filepath.Join(pack.gamePath(), backupDir, fmt.Sprintf("backup-%s.zip", time.Now().Format("20060102-150405")))
+	logAction("Backing up %v to %s\n", sources, filename)
+
+	err := os.MkdirAll(filepath.Dir(filename), 0700)
+	if err != nil {
+		return "", fmt.Errorf("failed to create backup directory: %+v", err)
+	}
+
+	err = writeBackup(filename, pack.gamePath(), sources)
+	if err != nil {
+		os.Remove(filename)
+		return "", fmt.Errorf("failed to create backup: %+v", err)
+	}
+
+	return filename, pruneBackups(filepath.Dir(filename), keep)
+}
+
+func writeBackup(filename, baseDir string, sources []string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	writer := zip.NewWriter(f)
+	for _, source := range sources {
+		err = addDirToZip(writer, baseDir, source)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = writer.Close()
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// addDirToZip adds all the files in baseDir/source to the ZIP, named relative to baseDir
+func addDirToZip(writer *zip.Writer, baseDir, source string) error {
+	return filepath.Walk(filepath.Join(baseDir, source), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+
+		// Skip files that can't be read (e.g. session.lock on a running Windows server); they're
+		// opened before their entry is created, so the backup doesn't get an empty copy
+		name, _ := filepath.Rel(baseDir, path)
+		data, err := os.Open(path)
+		if err != nil {
+			warn(WarnOther, "unable to add %s to %s: %+v", name, filepath.Base(baseDir), err)
+			return nil
+		}
+		defer data.Close()
+
+		header, err := zip.FileInfoHeader(info)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(name)
+		header.Method = zip.Deflate
+
+		entry, err := writer.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(entry, data)
+		return err
+	})
+}
+
+// pruneBackups removes the oldest backups in dir so that at most keep remain
+func pruneBackups(dir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	backups, err := filepath.Glob(filepath.Join(dir, "backup-*.zip"))
+	if err != nil {
+		return err
+	}
+
+	// Timestamped names sort chronologically
+	sort.Strings(backups)
+	for len(backups) > keep {
+		logAction("Removing old backup %s\n", backups[0])
+		err = os.Remove(backups[0])
+		if err != nil {
+			return fmt.Errorf("failed to remove old backup %s: %+v", backups[0], err)
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
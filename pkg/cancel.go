@@ -0,0 +1,20 @@
+package pkg
+
+import (
+	"context"
+	"os/exec"
+)
+
+// Long-running operations (downloads, installers and the mods they install) take a context and stop
+// when it's done; the CLI cancels it on Ctrl-C, and programs embedding mcdex can use it to impose
+// deadlines
+
+// checkCanceled returns an error if the operation should stop
+func checkCanceled(ctx context.Context) error {
+	return ctx.Err()
+}
+
+// javaCommand prepares to run java; the process is killed if the operation is canceled
+func javaCommand(ctx context.Context, args ...string) *exec.Cmd {
+	return exec.CommandContext(ctx, javaCmd(), args...)
+}
@@ -0,0 +1,81 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Categories (worldgen, tech, magic, etc) are stored in the categories table, with one row for each
+// category a project belongs to; older databases don't include the table.
+
+func (db *Database) hasCategories() bool {
+	return db.hasTable("categories")
+}
+
+// categoryClause returns the SQL condition that restricts projects to a category
+func (db *Database) categoryClause() (string, error) {
+	if !db.hasCategories() {
+		return "", fmt.Errorf("database does not include categories; try using db.update command")
+	}
+	return " and projectid in (select projectid from categories where category = ?)", nil
+}
+
+// GetCategories returns the names of all the categories in the database
+func (db *Database) GetCategories() ([]string, error) {
+	if !db.hasCategories() {
+		return []string{}, nil
+	}
+
+	rows, err := db.sqlDb.Query("select distinct category from categories order by category")
+	if err != nil {
+		return []string{}, fmt.Errorf("failed to lookup categories: %+v", err)
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var category string
+		err = rows.Scan(&category)
+		if err != nil {
+			return []string{}, fmt.Errorf("failed to scan row: %+v", err)
+		}
+		result = append(result, category)
+	}
+	return result, nil
+}
+
+func (db *Database) getProjectCategories(projectID int) ([]string, error) {
+	if !db.hasCategories() {
+		return []string{}, nil
+	}
+
+	rows, err := db.sqlDb.Query("select category from categories where projectid = ? order by category", projectID)
+	if err != nil {
+		return []string{}, fmt.Errorf("failed to lookup categories for %d: %+v", projectID, err)
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var category string
+		err = rows.Scan(&category)
+		if err != nil {
+			return []string{}, fmt.Errorf("failed to scan row: %+v", err)
+		}
+		result = append(result, category)
+	}
+	return result, nil
+}
+
+// PrintProjectCategories displays the categories a project belongs to, if any
+func (db *Database) PrintProjectCategories(projectID int) error {
+	categories, err := db.getProjectCategories(projectID)
+	if err != nil {
+		return err
+	}
+
+	if len(categories) > 0 {
+		fmt.Fprintf(consoleOutput, "Categories: %s\n", strings.Join(categories, ", "))
+	}
+	return nil
+}
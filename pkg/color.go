@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"io"
+	"os"
+)
+
+// ANSI styles used to set off warnings, errors, skipped and downloaded files and the two sides of
+// an update
+const (
+	styleNone     = ""
+	styleBold     = "1"
+	styleDim      = "2"
+	styleError    = "1;31"
+	styleWarn     = "33"
+	styleDownload = "32"
+	styleOld      = "31"
+	styleNew      = "32"
+)
+
+var colorEnabled = true
+
+// SetColor enables (the default) or disables colored output; color is only ever used when the output
+// is a terminal and the NO_COLOR environment variable (see no-color.org) isn't set
+func SetColor(enabled bool) {
+	colorEnabled = enabled
+}
+
+// useColor decides if escape codes are written to an output; stdout and stderr are checked
+// separately, since either may be redirected to a file
+func useColor(w io.Writer) bool {
+	if !colorEnabled {
+		return false
+	}
+	if _, set := os.LookupEnv("NO_COLOR"); set {
+		return false
+	}
+	if !isTerminal(w) {
+		return false
+	}
+	return enableColorOutput(w.(*os.File))
+}
+
+// colorize wraps text in the escape codes for a style, if color is being used for the output
+func colorize(w io.Writer, style, text string) string {
+	if style == styleNone || text == "" || !useColor(w) {
+		return text
+	}
+	return "\x1b[" + style + "m" + text + "\x1b[0m"
+}
+
+// levelStyle is the style of messages at a level that aren't given one explicitly
+func levelStyle(level LogLevel) string {
+	switch level {
+	case LogDebug:
+		return styleDim
+	case LogWarn:
+		return styleWarn
+	case LogError:
+		return styleError
+	}
+	return styleNone
+}
@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package pkg
+
+import "os"
+
+// enableColorOutput prepares a terminal for escape codes; other terminals handle them as-is
+func enableColorOutput(f *os.File) bool {
+	return true
+}
@@ -0,0 +1,39 @@
+//go:build windows
+// +build windows
+
+package pkg
+
+import (
+	"os"
+	"sync"
+
+	"github.com/mattn/go-isatty"
+	"golang.org/x/sys/windows"
+)
+
+// Windows consoles only interpret escape codes once virtual terminal processing is turned on
+// (Windows 10 and later); whether that worked is remembered for each handle
+var virtualTerminal sync.Map
+
+// enableColorOutput turns on escape code processing for a console; color isn't used if it can't be
+func enableColorOutput(f *os.File) bool {
+	if enabled, ok := virtualTerminal.Load(f.Fd()); ok {
+		return enabled.(bool)
+	}
+	enabled := enableVirtualTerminal(f)
+	virtualTerminal.Store(f.Fd(), enabled)
+	return enabled
+}
+
+func enableVirtualTerminal(f *os.File) bool {
+	handle := windows.Handle(f.Fd())
+	var mode uint32
+	if err := windows.GetConsoleMode(handle, &mode); err != nil {
+		// Cygwin and MSYS terminals aren't consoles, and handle escape codes themselves
+		return isatty.IsCygwinTerminal(f.Fd())
+	}
+	if mode&windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING != 0 {
+		return true
+	}
+	return windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING) == nil
+}
@@ -0,0 +1,97 @@
+package pkg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/Jeffail/gabs"
+)
+
+// Config holds user settings for mcdex, stored as config.json in the mcdex directory
+type Config struct {
+	data     *gabs.Container
+	filename string
+}
+
+var configData *Config
+
+func loadConfig(dir string) (*Config, error) {
+	cfg := new(Config)
+	cfg.filename = filepath.Join(dir, "config.json")
+	cfg.data = gabs.New()
+
+	if fileExists(cfg.filename) {
+		rawdata, err := ioutil.ReadFile(cfg.filename)
+		if err != nil {
+			return nil, err
+		}
+
+		cfg.data, err = gabs.ParseJSON(rawdata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %+v", cfg.filename, err)
+		}
+	}
+	return cfg, nil
+}
+
+// GetConfig returns the currently loaded configuration
+func GetConfig() *Config {
+	return configData
+}
+
+func (cfg *Config) getString(path string, defaultValue string) string {
+	value, ok := cfg.data.Path(path).Data().(string)
+	if !ok {
+		return defaultValue
+	}
+	return value
+}
+
+func (cfg *Config) getInt(path string, defaultValue int) int {
+	value, ok := cfg.data.Path(path).Data().(float64)
+	if !ok {
+		return defaultValue
+	}
+	return int(value)
+}
+
+func (cfg *Config) getBool(path string, defaultValue bool) bool {
+	value, ok := cfg.data.Path(path).Data().(bool)
+	if !ok {
+		return defaultValue
+	}
+	return value
+}
+
+func (cfg *Config) set(value interface{}, path ...string) error {
+	_, err := cfg.data.Set(value, path...)
+	return err
+}
+
+func (cfg *Config) delete(path ...string) error {
+	return cfg.data.Delete(path...)
+}
+
+// save writes the config; it can hold API tokens (see SaveAPIToken), so it's only readable by the
+// user
+func (cfg *Config) save() error {
+	return ioutil.WriteFile(cfg.filename, []byte(cfg.data.StringIndent("", " ")), 0600)
+}
+
+// TUIMouseEnabled determines if the explorer should respond to the mouse (tui.mouse)
+func (cfg *Config) TUIMouseEnabled() bool {
+	return cfg.getBool("tui.mouse", false)
+}
+
+// TUIKeys returns the explorer key bindings the user has overridden (tui.keys), by action
+func (cfg *Config) TUIKeys() map[string]string {
+	result := make(map[string]string)
+	bindings, _ := cfg.data.Path("tui.keys").ChildrenMap()
+	for action, key := range bindings {
+		if value, ok := key.Data().(string); ok {
+			result[action] = value
+		}
+	}
+	return result
+}
@@ -3,18 +3,106 @@ package pkg
 import (
 	"fmt"
 	"github.com/apoorvam/goterminal"
+	"github.com/mattn/go-isatty"
+	"io"
 	"os"
+	"strings"
+	"sync"
 )
 
+// All progress and status messages are written to consoleOutput, except warnings and errors, which
+// are written to errorOutput; see SetOutput
+var consoleOutput io.Writer = os.Stdout
+var errorOutput io.Writer = os.Stderr
+
 var CONSOLE = goterminal.New(os.Stdout)
 
+// SetOutput redirects the progress and status messages that mcdex writes while it works (stdout, by
+// default, with warnings and errors on stderr); programs embedding mcdex can use ioutil.Discard to
+// silence them. Redirected output includes the warnings and errors. Errors are always returned to
+// the caller rather than written to the output.
+func SetOutput(w io.Writer) {
+	consoleLock.Lock()
+	defer consoleLock.Unlock()
+	consoleOutput = w
+	errorOutput = w
+	if w == os.Stdout {
+		errorOutput = os.Stderr
+	}
+	CONSOLE = goterminal.New(w)
+}
+
+// isTerminalOutput determines if messages are going to a terminal (and so can be redrawn in place);
+// JSON logs are never redrawn
+func isTerminalOutput() bool {
+	return isTerminal(consoleOutput)
+}
+
+// isTerminal determines if a writer is a terminal; JSON logs are never treated as one
+func isTerminal(w io.Writer) bool {
+	if logFormat == LogFormatJSON {
+		return false
+	}
+	f, ok := w.(*os.File)
+	return ok && (isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd()))
+}
+
+// Actions may be logged from multiple goroutines (e.g. while installing libraries)
+var consoleLock sync.Mutex
+
+// logAction logs a step of an install; on a terminal, each step replaces the previous one
 func logAction(format string, values ...interface{}) {
-	CONSOLE.Clear()
-	fmt.Fprintf(CONSOLE, format, values...)
-	CONSOLE.Print()
+	consoleLock.Lock()
+	defer consoleLock.Unlock()
+	writeLog(LogInfo, styleNone, strings.TrimRight(fmt.Sprintf(format, values...), "\n"), true)
 }
 
+// logSection logs the completion of a group of steps, which remains on the terminal
 func logSection(format string, values ...interface{}) {
-	CONSOLE.Clear()
-	fmt.Printf(format, values...)
+	consoleLock.Lock()
+	defer consoleLock.Unlock()
+	writeLog(LogInfo, styleNone, strings.TrimRight(fmt.Sprintf(format, values...), "\n"), false)
+}
+
+// promptYesNo asks the user a yes/no question on the console; when stdin isn't interactive
+// the answer is always no
+func promptYesNo(question string) bool {
+	if !isInteractive() {
+		fmt.Fprintf(consoleOutput, "%s [skipped; not interactive]\n", question)
+		return false
+	}
+
+	fmt.Fprintf(consoleOutput, "%s [y/N] ", question)
+	var answer string
+	fmt.Scanln(&answer)
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// promptChoice asks the user to pick one of several single-letter answers, asking again until a
+// valid one is given; the default is used when nothing is entered or stdin isn't interactive
+func promptChoice(question string, choices []string, defaultChoice string) string {
+	if !isInteractive() {
+		fmt.Fprintf(consoleOutput, "%s [skipped; not interactive]\n", question)
+		return defaultChoice
+	}
+
+	for {
+		fmt.Fprintf(consoleOutput, "%s [%s] ", question, strings.Join(choices, "/"))
+		var answer string
+		fmt.Scanln(&answer)
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if answer == "" {
+			return defaultChoice
+		}
+		for _, choice := range choices {
+			if answer == choice {
+				return choice
+			}
+		}
+	}
+}
+
+func isInteractive() bool {
+	return isatty.IsTerminal(os.Stdin.Fd()) || isatty.IsCygwinTerminal(os.Stdin.Fd())
 }
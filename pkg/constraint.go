@@ -0,0 +1,252 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A mod can be selected with a constraint on its version, e.g. jei@10.x or sodium@<=0.5.8; several
+// constraints can be combined with commas (e.g. sodium@>=0.5,<0.6). The constraint is recorded in
+// the manifest so updates respect it.
+var versionConstraintRegex = regexp.MustCompile(`^(.+)@((?:[<>=!]=?|~)?\s*\d.*)$`)
+
+// splitVersionConstraint separates a mod ID from its version constraint, if any
+func splitVersionConstraint(modId string) (string, string) {
+	match := versionConstraintRegex.FindStringSubmatch(modId)
+	if match == nil {
+		return modId, ""
+	}
+	return match[1], match[2]
+}
+
+type versionCondition struct {
+	op      string
+	version string
+}
+
+// parseVersionConstraint parses a constraint; a version without an operator matches every version
+// that starts with it, so 10 and 10.x both match 10.2.3 (but not 100.1). A tilde allows later
+// versions with the same minor version (or major version, if that's all that's given), so ~1.2.3
+// matches 1.2.3 up to (but not including) 1.3 and ~1 matches anything from 1 up to 2.
+func parseVersionConstraint(constraint string) ([]versionCondition, error) {
+	var result []versionCondition
+	for _, part := range strings.Split(constraint, ",") {
+		part = strings.TrimSpace(part)
+		op := ""
+		for _, candidate := range []string{"<=", ">=", "!=", "==", "<", ">", "=", "~"} {
+			if strings.HasPrefix(part, candidate) {
+				op = candidate
+				break
+			}
+		}
+
+		version := strings.TrimSpace(strings.TrimPrefix(part, op))
+		version = strings.TrimSuffix(strings.TrimSuffix(version, ".x"), ".*")
+		if version == "" || !minecraftVersionRegex.MatchString(version+".0") {
+			return nil, fmt.Errorf("invalid version constraint %q", part)
+		}
+		result = append(result, versionCondition{op, version})
+	}
+	return result, nil
+}
+
+func (c versionCondition) matches(version string) bool {
+	cmp := compareVersions(version, c.version)
+	switch c.op {
+	case "~":
+		return cmp >= 0 && compareVersions(version, tildeLimit(c.version)) < 0
+	case "":
+		return version == c.version || strings.HasPrefix(version, c.version+".") ||
+			strings.HasPrefix(version, c.version+"-") || strings.HasPrefix(version, c.version+"+")
+	case "=", "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	}
+	return false
+}
+
+// tildeLimit is the first version a tilde range no longer matches: the next minor version, or the
+// next major version if only that's given (e.g. 1.3 for ~1.2.3 and 2 for ~1)
+func tildeLimit(version string) string {
+	parts := strings.FieldsFunc(version, func(r rune) bool { return r == '.' || r == '-' || r == '+' })
+	i := 1
+	if len(parts) < 2 {
+		i = 0
+	}
+	n, _ := strconv.Atoi(strings.TrimRightFunc(parts[i], func(r rune) bool { return !unicode.IsDigit(r) }))
+	return strings.Join(append(parts[:i:i], strconv.Itoa(n+1)), ".")
+}
+
+func matchesConditions(version string, conditions []versionCondition) bool {
+	for _, c := range conditions {
+		if !c.matches(version) {
+			return false
+		}
+	}
+	return true
+}
+
+// latestMatchingVersion picks the newest of a list of versions that meets the constraint
+func latestMatchingVersion(versions []string, constraint string) (string, error) {
+	conditions, err := parseVersionConstraint(constraint)
+	if err != nil {
+		return "", err
+	}
+
+	var latest string
+	for _, v := range versions {
+		if matchesConditions(v, conditions) && (latest == "" || compareVersions(v, latest) > 0) {
+			latest = v
+		}
+	}
+
+	if latest == "" {
+		return "", fmt.Errorf("no version matches %s", constraint)
+	}
+	return latest, nil
+}
+
+// combineConstraints requires all of the (non-empty) constraints to be met
+func combineConstraints(constraints ...string) string {
+	var parts []string
+	for _, c := range constraints {
+		if c != "" {
+			parts = append(parts, c)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// Mod versions are the first dotted number in a filename, once the Minecraft versions are removed
+var modVersionRegex = regexp.MustCompile(`\d+(\.\d+)+`)
+
+// fileModVersion guesses the version of a mod from the name of one of its files, e.g. 7.7.1.153
+// from jei-1.16.5-7.7.1.153.jar; every Minecraft version the file is for is removed first, since
+// files for several versions often list them all (e.g. jei-1.20.1-1.20.2-15.2.0.jar)
+func fileModVersion(filename string, minecraftVsns []string) string {
+	name := strings.TrimSuffix(path.Base(filename), path.Ext(filename))
+
+	// Longer versions first, so 1.20 doesn't clip 1.20.1
+	vsns := append([]string(nil), minecraftVsns...)
+	sort.Slice(vsns, func(i, j int) bool { return len(vsns[i]) > len(vsns[j]) })
+	for _, vsn := range vsns {
+		if vsn == "" {
+			continue
+		}
+		// Only whole versions are removed, so 1.20 isn't taken out of a mod version like 3.1.20
+		vsnRegex := regexp.MustCompile(`(^|[^\d.])` + regexp.QuoteMeta(vsn) + `([^\d.]|$)`)
+		name = vsnRegex.ReplaceAllString(name, "$1$2")
+	}
+	return modVersionRegex.FindString(name)
+}
+
+// filterFilesByConstraint returns the files whose version meets the constraint
+func filterFilesByConstraint(files []FileInfo, constraint string) ([]FileInfo, error) {
+	if constraint == "" {
+		return files, nil
+	}
+
+	conditions, err := parseVersionConstraint(constraint)
+	if err != nil {
+		return nil, constraintError{err.Error()}
+	}
+
+	// Files are listed once for each version of Minecraft they're for
+	minecraftVsns := make(map[string][]string)
+	for _, file := range files {
+		minecraftVsns[file.ID] = append(minecraftVsns[file.ID], file.MinecraftVersion)
+	}
+
+	var result []FileInfo
+	for _, file := range files {
+		version := fileModVersion(file.Filename, minecraftVsns[file.ID])
+		if version != "" && matchesConditions(version, conditions) {
+			result = append(result, file)
+		}
+	}
+
+	if len(result) == 0 {
+		return nil, constraintError{fmt.Sprintf("no file matches version %s", constraint)}
+	}
+	return result, nil
+}
+
+// constraintError indicates that a mod's version constraint is invalid or that none of its files
+// meet it; updates skip the mod rather than failing
+type constraintError struct {
+	msg string
+}
+
+func (e constraintError) Error() string {
+	return e.msg
+}
+
+// Packs can bound the versions that updates select with a constraints file next to the manifest,
+// which maps mods (named as they're selected) to constraints, e.g.:
+//
+//	{"create": "<0.6", "modrinth:sodium": "0.5.x,!=0.5.3"}
+const constraintsFilename = "mcdex-constraints.json"
+
+// modConstraint combines the constraint a mod was selected with and the pack's constraint for it
+func (pack *ModPack) modConstraint(ctx context.Context, modFile ModPackFile, selected string) string {
+	if pack.constraints == nil {
+		pack.constraints = pack.loadConstraints(ctx)
+	}
+	return combineConstraints(selected, pack.constraints[modFile.cacheKey()])
+}
+
+// loadConstraints reads the pack's constraints file, if it has one; invalid entries are ignored
+// with a warning so a typo doesn't block every update
+func (pack *ModPack) loadConstraints(ctx context.Context) map[string]string {
+	result := make(map[string]string)
+	filename := filepath.Join(pack.gamePath(), constraintsFilename)
+	if !fileExists(filename) {
+		return result
+	}
+
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		warn(WarnManifest, "unable to read %s: %+v", constraintsFilename, err)
+		return result
+	}
+
+	var constraints map[string]string
+	err = json.Unmarshal(data, &constraints)
+	if err != nil {
+		warn(WarnManifest, "unable to parse %s: %+v", constraintsFilename, err)
+		return result
+	}
+
+	for name, constraint := range constraints {
+		if _, err := parseVersionConstraint(constraint); err != nil {
+			warn(WarnManifest, "%s: %+v for %s; ignored", constraintsFilename, err, name)
+			continue
+		}
+
+		modFile, err := pack.resolveModName(ctx, name)
+		if err != nil {
+			warn(WarnManifest, "%s: %+v; ignored", constraintsFilename, err)
+			continue
+		}
+		result[modFile.cacheKey()] = constraint
+	}
+	return result
+}
@@ -0,0 +1,112 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseVersionConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		want       []versionCondition // nil if the constraint is invalid
+	}{
+		{"10", []versionCondition{{"", "10"}}},
+		{"10.x", []versionCondition{{"", "10"}}},
+		{"0.5.*", []versionCondition{{"", "0.5"}}},
+		{"<=0.5.8", []versionCondition{{"<=", "0.5.8"}}},
+		{">= 0.5, <0.6", []versionCondition{{">=", "0.5"}, {"<", "0.6"}}},
+		{"0.5.x,!=0.5.3", []versionCondition{{"", "0.5"}, {"!=", "0.5.3"}}},
+		{"==1.2.3", []versionCondition{{"==", "1.2.3"}}},
+		{"=1.2.3", []versionCondition{{"=", "1.2.3"}}},
+		{">1", []versionCondition{{">", "1"}}},
+		{"~1.2.3", []versionCondition{{"~", "1.2.3"}}},
+		{"", nil},
+		{"<", nil},
+		{"abc", nil},
+		{">=1.0,", nil},
+		{"~", nil},
+		{"x", nil},
+	}
+
+	for _, test := range tests {
+		got, err := parseVersionConstraint(test.constraint)
+		switch {
+		case test.want == nil && err == nil:
+			t.Errorf("parseVersionConstraint(%q) = %v; expected an error", test.constraint, got)
+		case test.want != nil && err != nil:
+			t.Errorf("parseVersionConstraint(%q) failed: %v", test.constraint, err)
+		case test.want != nil && !reflect.DeepEqual(got, test.want):
+			t.Errorf("parseVersionConstraint(%q) = %v; expected %v", test.constraint, got, test.want)
+		}
+	}
+}
+
+func TestVersionConditionMatches(t *testing.T) {
+	tests := []struct {
+		op, constraint, version string
+		want                    bool
+	}{
+		{"", "10", "10", true},
+		{"", "10", "10.2.3", true},
+		{"", "10", "10-beta", true},
+		{"", "10", "10+build.5", true},
+		{"", "10", "100.1", false},
+		{"", "10", "9.9", false},
+		{"", "0.5", "0.5.8", true},
+		{"", "0.5", "0.50", false},
+		{"=", "1.2.3", "1.2.3", true},
+		{"==", "1.2.3", "1.2.4", false},
+		{"!=", "0.5.3", "0.5.3", false},
+		{"!=", "0.5.3", "0.5.4", true},
+		{"<", "0.6", "0.5.9", true},
+		{"<", "0.6", "0.6", false},
+		{"<=", "0.5.8", "0.5.8", true},
+		{"<=", "0.5.8", "0.5.10", false},
+		{">", "1", "1.0.1", true},
+		{">", "2.0", "1.9", false},
+		{">=", "0.5", "0.5", true},
+		{">=", "0.5", "0.4.9", false},
+		{"~", "1.2.3", "1.2.3", true},
+		{"~", "1.2.3", "1.2.10", true},
+		{"~", "1.2.3", "1.2.2", false},
+		{"~", "1.2.3", "1.3", false},
+		{"~", "1.2.3", "1.3.0", false},
+		{"~", "1.2", "1.2.0", true},
+		{"~", "1.2", "1.2.99", true},
+		{"~", "1.2", "1.20", false},
+		{"~", "1", "1.9.9", true},
+		{"~", "1", "2.0", false},
+		{"~", "1", "0.9", false},
+	}
+
+	for _, test := range tests {
+		c := versionCondition{test.op, test.constraint}
+		if got := c.matches(test.version); got != test.want {
+			t.Errorf("%s%s matches %s = %v; expected %v", test.op, test.constraint, test.version, got, test.want)
+		}
+	}
+}
+
+func TestFileModVersion(t *testing.T) {
+	tests := []struct {
+		filename      string
+		minecraftVsns []string
+		want          string
+	}{
+		{"jei-1.16.5-7.7.1.153.jar", []string{"1.16.5"}, "7.7.1.153"},
+		{"jei-1.20.1-1.20.2-15.2.0.jar", []string{"1.20.1", "1.20.2"}, "15.2.0"},
+		{"jei-1.20.1-1.20.2-15.2.0.jar", []string{"1.20.2", "1.20.1"}, "15.2.0"},
+		{"create-1.20-1.20.1-0.5.1.jar", []string{"1.20", "1.20.1"}, "0.5.1"},
+		{"sodium-fabric-mc1.20.1-0.5.3.jar", []string{"1.20.1"}, "0.5.3"},
+		{"mod-3.1.20.jar", []string{"1.20"}, "3.1.20"},
+		{"mod-1.20.1-forge.jar", []string{"1.20.1"}, ""},
+		{"mod-2.0.jar", nil, "2.0"},
+		{"mods/mod-1.19.2-4.1.jar", []string{"1.19.2"}, "4.1"},
+	}
+
+	for _, test := range tests {
+		if got := fileModVersion(test.filename, test.minecraftVsns); got != test.want {
+			t.Errorf("fileModVersion(%q, %v) = %q; expected %q", test.filename, test.minecraftVsns, got, test.want)
+		}
+	}
+}
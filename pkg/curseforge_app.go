@@ -0,0 +1,104 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Jeffail/gabs"
+)
+
+// The CurseForge App describes each instance with a minecraftinstance.json in the instance
+// directory; mods are installed into the mods directory as usual
+const curseForgeInstanceFile = "minecraftinstance.json"
+
+// Mod loader types used by the CurseForge App
+var curseForgeAppLoaders = map[int]string{
+	1: "forge",
+	4: "fabric",
+	5: "quilt",
+	6: "neoforge",
+}
+
+// AdoptCurseForgeInstance generates a manifest (and mod cache) for an instance created by the
+// CurseForge App, using the list of installed addons it records
+func (pack *ModPack) AdoptCurseForgeInstance(ctx context.Context) error {
+	if pack.manifest != nil {
+		return fmt.Errorf("%s is already managed by mcdex", pack.Name)
+	}
+
+	instance, err := gabs.ParseJSONFile(filepath.Join(pack.rootPath, curseForgeInstanceFile))
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %+v", curseForgeInstanceFile, err)
+	}
+
+	minecraftVsn, _ := strValue(instance, "gameVersion")
+	loaderType, _ := intValue(instance, "baseModLoader.type")
+	loaderVsn, _ := strValue(instance, "baseModLoader.forgeVersion")
+	pack.modLoader = curseForgeAppLoaders[loaderType]
+
+	// Older instances only record the loader name (e.g. forge-36.2.0)
+	if loaderName, err := strValue(instance, "baseModLoader.name"); err == nil && (pack.modLoader == "" || loaderVsn == "") {
+		parts := strings.SplitN(loaderName, "-", 3)
+		if len(parts) > 1 {
+			pack.modLoader = parts[0]
+			loaderVsn = parts[1]
+		}
+	}
+
+	if minecraftVsn == "" || pack.modLoader == "" || loaderVsn == "" {
+		return fmt.Errorf("unable to identify Minecraft and mod loader versions in %s", curseForgeInstanceFile)
+	}
+
+	name, err := strValue(instance, "name")
+	if err != nil {
+		name = pack.Name
+	}
+
+	logInfo("Adopting %s (Minecraft %s, %s %s)\n", name, minecraftVsn, pack.modLoader, loaderVsn)
+
+	pack.initManifest(name, minecraftVsn)
+	pack.manifest.ArrayOfSizeP(0, "files")
+	pack.setLoaderVersion(loaderVsn)
+
+	addons, _ := instance.Path("installedAddons").Children()
+	for _, addon := range addons {
+		projectID, _ := intValue(addon, "addonID")
+		fileID, _ := intValue(addon, "installedFile.id")
+		addonName, _ := strValue(addon, "name")
+		filename, err := strValue(addon, "installedFile.fileNameOnDisk")
+		if err != nil {
+			filename, _ = strValue(addon, "installedFile.fileName")
+		}
+
+		// Only mods can be managed; resource packs, shaders, etc. are left alone
+		if filename == "" || !modFileExists(filepath.Join(pack.modPath(), filename)) {
+			warn(WarnOther, "%s is not installed in the mods directory; not adopted", addonName)
+			continue
+		}
+
+		modFile := CurseForgeModFile{projectID: projectID, fileID: fileID, name: addonName, desc: addonName}
+		err = pack.selectMod(&modFile)
+		if err != nil {
+			return err
+		}
+
+		err = pack.modCache.AddModFile(projectID, fileID, filename)
+		if err != nil {
+			return fmt.Errorf("failed to register %s in mod cache: %+v", filename, err)
+		}
+	}
+
+	err = pack.SaveManifest()
+	if err != nil {
+		return err
+	}
+
+	// Pick up anything that was added to the mods directory by hand
+	err = pack.adoptUntrackedMods(ctx, false)
+	if err != nil {
+		warn(WarnUnknownMods, "unable to identify mods in %s: %+v", pack.Name, err)
+	}
+	return nil
+}
@@ -18,10 +18,16 @@
 package pkg
 
 import (
+	"context"
 	"fmt"
-	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/Jeffail/gabs"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
 )
 
 type CurseForgeModFile struct {
@@ -30,24 +36,30 @@ type CurseForgeModFile struct {
 	desc       string
 	name       string
 	clientOnly bool
+	serverOnly bool
+	optional   bool
+	constraint string // only files with a matching version are selected (see parseVersionConstraint)
+	channel    string // overrides the pack's release channel (see ModPack.releaseChannel)
 }
 
-func SelectCurseForgeModFile(pack *ModPack, mod string, url string, clientOnly bool) error {
+func SelectCurseForgeModFile(ctx context.Context, pack *ModPack, mod string, url string, clientOnly bool) error {
+	mod, constraint := splitVersionConstraint(mod)
+
 	// Try to find the project ID using the mod name as a slug
-	projectID, err := pack.db.findModBySlug(mod, pack.modLoader)
+	projectID, err := pack.db.findModBySlug(ctx, mod, pack.modLoader)
 	if err != nil {
 		return fmt.Errorf("unknown mod %s: %+v", mod, err)
 	}
 
 	// Look up the slug, name and description
-	_, name, desc, err := pack.db.getProjectInfo(projectID)
+	_, name, desc, err := pack.db.getProjectInfo(ctx, projectID)
 	if err != nil {
 		return fmt.Errorf("no name/description available for %s (%d): %+v", mod, projectID, err)
 	}
 
 	// Setup a mod file entry and then pull the latest file info
-	modFile := CurseForgeModFile{projectID: projectID, desc: desc, name: name, clientOnly: clientOnly}
-	fileId, err := modFile.getLatestFile(pack.minecraftVersion(), pack.modLoader)
+	modFile := CurseForgeModFile{projectID: projectID, desc: desc, name: name, clientOnly: clientOnly, constraint: constraint}
+	fileId, err := modFile.getLatestFile(ctx, pack.minecraftVersion(), pack.modLoader, pack.releaseChannel(""), pack.modConstraint(ctx, &modFile, constraint))
 	if err != nil {
 		return fmt.Errorf("failed to get latest file for %s (%d): %+v", mod, projectID, err)
 	}
@@ -55,6 +67,17 @@ func SelectCurseForgeModFile(pack *ModPack, mod string, url string, clientOnly b
 	// If we found a newer file, update entry and then the pack
 	if fileId > modFile.fileID {
 		modFile.fileID = fileId
+
+		if !clientOnly && sideDetection {
+			// Which sides the mod runs on is only a hint; if it can't be checked, assume both
+			file, err := modFile.source().GetFile(ctx, strconv.Itoa(projectID), strconv.Itoa(fileId))
+			if err != nil {
+				warn(WarnOther, "unable to check which sides %s runs on; assuming both: %+v", mod, err)
+			} else {
+				modFile.clientOnly = detectClientOnly(ctx, file, name)
+			}
+		}
+
 		err = pack.selectMod(&modFile)
 		if err != nil {
 			return err
@@ -67,20 +90,21 @@ func SelectCurseForgeModFile(pack *ModPack, mod string, url string, clientOnly b
 func NewCurseForgeModFile(modJson *gabs.Container) *CurseForgeModFile {
 	projectID, _ := intValue(modJson, "projectID")
 	fileID, _ := intValue(modJson, "fileID")
-	name, ok := modJson.Path("desc").Data().(string)
+	name, ok := mcdexString(modJson, "desc")
 	if !ok {
 		name = fmt.Sprintf("Curseforge project %d: %d", projectID, fileID)
 	}
-	clientOnly, ok := modJson.S("clientOnly").Data().(bool)
-	return &CurseForgeModFile{projectID, fileID, name, name, ok && clientOnly}
+	constraint, _ := mcdexString(modJson, "constraint")
+	channel, _ := mcdexString(modJson, "releaseChannel")
+	return &CurseForgeModFile{projectID, fileID, name, name, mcdexBool(modJson, "clientOnly"), mcdexBool(modJson, "serverOnly"), !isRequired(modJson), constraint, channel}
 }
 
-func (f CurseForgeModFile) install(pack *ModPack) error {
+func (f CurseForgeModFile) install(ctx context.Context, pack *ModPack) error {
 	// Check the mod cache to see if we already have the right file ID installed
 	lastFileId, lastFilename := pack.modCache.GetLastModFile(f.projectID)
 	if lastFileId == f.fileID {
 		// Nothing to do; we can skip this installed file
-		fmt.Printf("Skipping %s\n", lastFilename)
+		logDebug("Skipping %s\n", lastFilename)
 		return nil
 	} else if lastFileId > 0 {
 		// A different version of the file is installed; clean it up
@@ -88,33 +112,46 @@ func (f CurseForgeModFile) install(pack *ModPack) error {
 	}
 
 	// Resolve the project ID into a slug
-	slug, err := pack.db.findSlugByProject(f.projectID)
+	slug, err := pack.db.findSlugByProject(ctx, f.projectID)
 	if err != nil {
 		return fmt.Errorf("failed to find slug for project %d: %+v", f.projectID, err)
 	}
 
-	// Now, retrieve the JSON descriptor for this file so we can get the CDN url
-	descriptorUrl := fmt.Sprintf("https://addons-ecs.forgesvc.net/api/v2/addon/%d/file/%d", f.projectID, f.fileID)
-	descriptor, err := getJSONFromURL(descriptorUrl)
+	// Now, retrieve the descriptor for this file so we can get the CDN url
+	file, err := f.source().GetFile(ctx, strconv.Itoa(f.projectID), strconv.Itoa(f.fileID))
 	if err != nil {
 		return fmt.Errorf("failed to retrieve descriptor for %s: %+v", slug, err)
 	}
 
 	// Download the file to the pack mod directory
-	finalUrl := descriptor.Path("downloadUrl").Data().(string)
+	finalUrl := file.DownloadURL
 
-	filename, err := downloadHttpFileToDir(finalUrl, pack.modPath(), true)
+	filename, err := downloadHttpFileToDir(ctx, finalUrl, pack.modPath(), true)
 	if err != nil {
 		return err
 	}
 
+	// If the database knows the hash of the file, make sure we got the right thing
+	expectedHash := pack.db.getFileHash(f.fileID)
+	if expectedHash != "" {
+		fullName := filepath.Join(pack.modPath(), filename)
+		hash, err := sha1File(fullName)
+		if err != nil {
+			return fmt.Errorf("failed to verify %s: %+v", filename, err)
+		}
+		if hash != expectedHash {
+			os.Remove(fullName)
+			return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", filename, expectedHash, hash)
+		}
+	}
+
 	// Download succeeded; register this mod as installed in the cache
 	pack.modCache.AddModFile(f.projectID, f.fileID, filename)
-	return nil
+	return pack.applyDisabledState(&f, filename)
 }
 
-func (f *CurseForgeModFile) update(pack *ModPack) (bool, error) {
-	latestFile, err := f.getLatestFile(pack.minecraftVersion(), pack.modLoader)
+func (f *CurseForgeModFile) update(ctx context.Context, pack *ModPack) (bool, error) {
+	latestFile, err := f.getLatestFile(ctx, pack.minecraftVersion(), pack.modLoader, pack.releaseChannel(f.channel), pack.modConstraint(ctx, f, f.constraint))
 	if err != nil {
 		return false, err
 	}
@@ -135,35 +172,69 @@ func (f CurseForgeModFile) isClientOnly() bool {
 	return f.clientOnly
 }
 
+func (f CurseForgeModFile) isServerOnly() bool {
+	return f.serverOnly
+}
+
+func (f CurseForgeModFile) isOptional() bool {
+	return f.optional
+}
+
+func (f CurseForgeModFile) cacheKey() string {
+	return fmt.Sprintf("curseforge:%d", f.projectID)
+}
+
+func (f CurseForgeModFile) installedFilename(pack *ModPack) string {
+	_, filename := pack.modCache.GetLastModFile(f.projectID)
+	return filename
+}
+
 func (f CurseForgeModFile) equalsJson(modJson *gabs.Container) bool {
-	projectID, ok := modJson.Path("projectID").Data().(float64)
-	return ok && int(projectID) == f.projectID
+	// Entries added during this run hold an int rather than a (parsed) float
+	projectID, err := intValue(modJson, "projectID")
+	return err == nil && projectID == f.projectID
 }
 
 func (f CurseForgeModFile) toJson() map[string]interface{} {
 	result := map[string]interface{}{
 		"projectID": f.projectID,
 		"fileID":    f.fileID,
-		"required":  true,
-		"desc":      f.name,
+		"required":  !f.optional,
 	}
+	mcdexFields(result)["desc"] = f.name
 	if f.clientOnly {
-		result["clientOnly"] = true
+		mcdexFields(result)["clientOnly"] = true
+	}
+	if f.serverOnly {
+		mcdexFields(result)["serverOnly"] = true
+	}
+	if f.constraint != "" {
+		mcdexFields(result)["constraint"] = f.constraint
+	}
+	if f.channel != "" {
+		mcdexFields(result)["releaseChannel"] = f.channel
 	}
 	return result
 }
 
-func (f CurseForgeModFile) getLatestFile(minecraftVersion string, modLoader string) (int, error) {
+func (f CurseForgeModFile) source() ProjectSource {
+	return projectSources["curseforge"]
+}
+
+func (f CurseForgeModFile) getLatestFile(ctx context.Context, minecraftVersion, modLoader, channel, constraint string) (int, error) {
 	// Setup a retry counter to deal with long timeouts (a recent problem)
 	retryCount := 3
 
-	// Pull the project's descriptor, which has a list of the latest files for each version of Minecraft
+	// Pull the project's list of the latest files for each version of Minecraft; if the version is
+	// constrained, the latest files may not match, so look through all of them
 	retry:
-		projectUrl := fmt.Sprintf("https://addons-ecs.forgesvc.net/api/v2/addon/%d", f.projectID)
-		project, err := getJSONFromURL(projectUrl)
+		files, err := f.source().GetLatestFiles(ctx, strconv.Itoa(f.projectID))
+		if err == nil && constraint != "" {
+			files, err = getCurseForgeFileInfos(ctx, f.projectID)
+		}
 		if err != nil {
 			if retryCount > 0 {
-				fmt.Printf("Retrying update check for %s (%s)\n", f.name, projectUrl)
+				logInfo("Retrying update check for %s\n", f.name)
 				retryCount -= 1
 				goto retry
 			} else {
@@ -171,90 +242,72 @@ func (f CurseForgeModFile) getLatestFile(minecraftVersion string, modLoader stri
 			}
 		}
 
-	selectedFileType := math.MaxInt8
-	selectedFileId := 0
+	files, err = filterFilesByConstraint(files, constraint)
+	if err != nil {
+		return -1, err
+	}
 
 	// Look for the file with the matching version
-	files, _ := project.Path("gameVersionLatestFiles").Children()
-	for _, file := range files {
-		fileType, _ := intValue(file, "fileType") // 1 = release, 2 = beta, 3 = alpha
-		fileId, _ := intValue(file, "projectFileId")
-		modLoaderId, _ := intValue(file, "modLoader")
-		targetVsn := file.Path("gameVersion").Data().(string)
-
-		if targetVsn != minecraftVersion {
-			continue
-		}
-
-		if modLoaderId == 1 && modLoader != "forge" {
-			continue
-		}
+	file, err := selectLatestFile(files, minecraftVersion, modLoader, channel)
+	if err != nil {
+		return -1, err
+	}
 
-		if modLoaderId == 4 && modLoader != "fabric" {
-			continue
-		}
+	// TODO: Pull file descriptor and check for deps
+	return strconv.Atoi(file.ID)
+}
 
-		// Matched on version; prefer releases over beta/alpha
-		if fileType < selectedFileType {
-			selectedFileType = fileType
-			selectedFileId = fileId
-		}
+// PrintCurseForgeModInfo displays a project along with its downloads, categories and required
+// dependencies; if a pack is given, the file that would be selected for the pack is shown, and
+// dependencies are those of that file (otherwise they're for the latest file)
+func PrintCurseForgeModInfo(ctx context.Context, db *Database, projectId int, pack *ModPack) error {
+	files, err := printProjectInfo(ctx, projectSources["curseforge"], strconv.Itoa(projectId))
+	if err != nil {
+		return err
 	}
 
-	if selectedFileId == 0 {
-		return -1, fmt.Errorf("no version found for Minecraft %s\n", minecraftVersion)
+	var downloads int
+	err = db.sqlDb.QueryRow("select downloads from projects where projectid = ?", projectId).Scan(&downloads)
+	if err == nil {
+		message.NewPrinter(language.English).Fprintf(consoleOutput, "Downloads: %d\n", downloads)
 	}
 
-	// TODO: Pull file descriptor and check for deps
-	return selectedFileId, nil
-}
-
-func PrintCurseForgeModInfo(projectId int) error {
-	// Pull the project's descriptor, which has a list of the latest files for each version of Minecraft
-	projectUrl := fmt.Sprintf("https://addons-ecs.forgesvc.net/api/v2/addon/%d", projectId)
-	project, err := getJSONFromURL(projectUrl)
+	err = db.PrintProjectCategories(projectId)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve project %d: %+v", projectId, err)
+		return err
 	}
+	printProjectFiles(files)
 
-	name, _ := strValue(project, "name")
-	slug, _ := strValue(project, "slug")
-	summary, _ := strValue(project, "summary")
-
-	fmt.Printf("%s (%s)\n  %s\nFiles:\n", name, slug, summary)
-
-	// List recent files
-	files, _ := project.Path("gameVersionLatestFiles").Children()
-	for _, file := range files {
-		filename, _ := strValue(file, "projectFileName")
-		fileType, _ := intValue(file, "fileType") // 1 = release, 2 = beta, 3 = alpha
-		modLoaderId, _ := intValue(file, "modLoader") // 1 == forge, 4 == fabric
-		targetVsn, _ := strValue(file, "gameVersion")
-
-		var releaseType string
-		switch fileType {
-		case 1:
-			releaseType = "release"
-		case 2:
-			releaseType = "beta"
-		case 3:
-			releaseType = "alpha"
-		default:
-			releaseType = "unknown-release"
+	var fileID int
+	if pack != nil {
+		minecraftVsn := pack.minecraftVersion()
+		file, err := selectLatestFile(files, minecraftVsn, pack.modLoader, pack.releaseChannel(""))
+		if err != nil {
+			fmt.Fprintf(consoleOutput, "No file available for %s (Minecraft %s, %s)\n", pack.Name, minecraftVsn, pack.modLoader)
+			return nil
 		}
-
-		var modLoader string
-		switch modLoaderId {
-		case 1:
-			modLoader = "forge"
-		case 4:
-			modLoader = "fabric"
-		default:
-			modLoader = "forge"
+		fmt.Fprintf(consoleOutput, "Selected for %s (Minecraft %s, %s): %s\n", pack.Name, minecraftVsn, pack.modLoader, file.Filename)
+		fileID, _ = strconv.Atoi(file.ID)
+	} else {
+		fileID, err = db.getLatestProjectFile(projectId, "")
+		if err != nil {
+			return err
 		}
+	}
 
-		fmt.Printf("* %s for Minecraft %s, %s, %s\n", filename, targetVsn, modLoader, releaseType)
+	deps, err := db.getFileDependencies(fileID)
+	if err != nil {
+		return err
 	}
 
-	return nil;
+	var required []string
+	for _, dep := range deps {
+		if dep.Required {
+			required = append(required, dep.Slug)
+		}
+	}
+	if len(required) > 0 {
+		fmt.Fprintf(consoleOutput, "Requires: %s\n", strings.Join(required, ", "))
+	}
+	return nil
 }
@@ -18,36 +18,124 @@
 package pkg
 
 import (
+	"bytes"
 	"compress/bzip2"
+	"context"
+	"crypto/sha256"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 
 	"regexp"
 
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 type Database struct {
-	sqlDb     *sql.DB
-	sqlDbPath string
-	version   string
+	sqlDb          *sql.DB
+	sqlDbPath      string
+	version        string
+	hasSearchIndex bool
+	live           bool // mcdex.dat isn't available; projects are looked up online (see openLiveDatabase)
 }
 
-func OpenDatabase() (*Database, error) {
-	db := new(Database)
+// Default number of days after which the database is considered stale; can be changed with the
+// db.staleDays config setting. If db.autoUpdate is set, a stale database is refreshed automatically.
+const defaultStaleDays = 7
+
+var staleDatabaseChecked bool
+
+// The database is opened once and shared, so processes that run many commands (e.g. batch
+// mode) don't pay for the integrity check each time
+var sharedDb *Database
+
+func OpenDatabase(ctx context.Context) (*Database, error) {
+	if sharedDb != nil {
+		return sharedDb, nil
+	}
 
-	err := InstallDatabase(true)
+	db, err := openCheckedDatabase(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sharedDb = db
+	return db, nil
+}
+
+func openCheckedDatabase(ctx context.Context) (*Database, error) {
+	err := InstallDatabase(ctx, true)
 	if err != nil {
 		return nil, fmt.Errorf("Database not available; try using db.update command")
 	}
 
+	db, err := openDatabase()
+	if err != nil {
+		return nil, err
+	}
+
+	// Only check for a stale database once per run
+	if staleDatabaseChecked {
+		return db, nil
+	}
+	staleDatabaseChecked = true
+
+	age, stale := db.isStale()
+	if !stale {
+		return db, nil
+	}
+
+	if GetConfig() != nil && GetConfig().getBool("db.autoUpdate", false) {
+		logInfo("Database is %d days old; updating\n", age)
+		db.sqlDb.Close()
+		err = InstallDatabase(ctx, false)
+		if err != nil {
+			warn(WarnStaleDatabase, "automatic update failed: %+v", err)
+		}
+
+		db, err = openDatabase()
+		if err != nil {
+			return nil, err
+		}
+
+		age, stale = db.isStale()
+		if !stale {
+			return db, nil
+		}
+	}
+
+	warn(WarnStaleDatabase, "the mod database is %d days old; run db.update to get the latest mods and files", age)
+	return db, nil
+}
+
+// isStale checks the age (in days) of the newest file in the database against the threshold
+func (db *Database) isStale() (int, bool) {
+	tstamp, err := db.GetLatestFileTstamp()
+	if err != nil || tstamp == 0 {
+		return 0, false
+	}
+
+	staleDays := defaultStaleDays
+	if GetConfig() != nil {
+		staleDays = GetConfig().getInt("db.staleDays", defaultStaleDays)
+	}
+
+	age := int(time.Since(time.Unix(int64(tstamp), 0)).Hours() / 24)
+	return age, staleDays > 0 && age >= staleDays
+}
+
+func openDatabase() (*Database, error) {
+	db := new(Database)
+
 	db.sqlDbPath = filepath.Join(Env().McdexDir, "mcdex.dat")
-	sqlDb, err := sql.Open("sqlite3", db.sqlDbPath)
+	sqlDb, err := sql.Open(sqliteDriver, db.sqlDbPath)
 	if err != nil {
 		return nil, err
 	}
@@ -59,45 +147,211 @@ func OpenDatabase() (*Database, error) {
 
 	db.sqlDb = sqlDb
 
+	// Searches can still be done without the index, albeit slowly
+	err = db.ensureSearchIndex()
+	if err != nil {
+		logWarn("Search index not available: %+v\n", err)
+	}
+	db.hasSearchIndex = err == nil
+
+	err = db.ensureSortIndexes()
+	if err != nil {
+		logWarn("%+v\n", err)
+	}
+
 	return db, nil
 }
 
-func InstallDatabase(skipIfExists bool) error {
-	if skipIfExists && fileExists(filepath.Join(Env().McdexDir, "mcdex.dat")) {
+// Database files (and their checksums) are only downloaded over https, since deltas are run as SQL
+// against the local database
+const databaseURL = "https://files.mcdex.net/data"
+
+func InstallDatabase(ctx context.Context, skipIfExists bool) error {
+	dbFileName := filepath.Join(Env().McdexDir, "mcdex.dat")
+	if skipIfExists && fileExists(dbFileName) {
 		return nil
 	}
 
+	// The database is about to be replaced; make sure it's reopened afterwards
+	if sharedDb != nil {
+		sharedDb.sqlDb.Close()
+		sharedDb = nil
+	}
+
 	// Get the latest version
-	version, err := ReadStringFromUrl("http://files.mcdex.net/data/latest.v6")
+	version, err := ReadStringFromUrl(ctx, databaseURL + "/latest.v6")
 	if err != nil {
 		return err
 	}
 
+	// If we already have a database, try to apply a delta from our current version to the latest;
+	// if that doesn't work out, fallback to downloading the whole database
+	if fileExists(dbFileName) {
+		currentVersion := installedDatabaseVersion(dbFileName)
+		if currentVersion == version {
+			logInfo("Database is already at latest version %s\n", version)
+			return nil
+		}
+
+		if currentVersion != "" {
+			err = installDatabaseDelta(ctx, currentVersion, version)
+			if err == nil {
+				return nil
+			}
+			logWarn("Unable to apply incremental update (%+v); downloading full database\n", err)
+		}
+	}
+
+	return installFullDatabase(ctx, version)
+}
+
+// installedDatabaseVersion returns the version of the local database, as recorded in the meta table
+func installedDatabaseVersion(filename string) string {
+	sqlDb, err := sql.Open(sqliteDriver, filename)
+	if err != nil {
+		return ""
+	}
+	defer sqlDb.Close()
+
+	var version string
+	err = sqlDb.QueryRow("select value from meta where key = 'version'").Scan(&version)
+	if err != nil {
+		return ""
+	}
+	return version
+}
+
+func installFullDatabase(ctx context.Context, version string) error {
+	url := fmt.Sprintf("%s/mcdex-v6-%s.dat.bz2", databaseURL, version)
+	expectedHash, err := readPublishedHash(ctx, url)
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve checksum for %s data file: %+v", version, err)
+	}
+
 	// Download the latest data file to mcdex/mcdex.dat
-	url := fmt.Sprintf("http://files.mcdex.net/data/mcdex-v6-%s.dat.bz2", version)
-	res, err := HttpGet(url)
+	res, err := HttpGet(ctx, url)
 	if err != nil {
 		return fmt.Errorf("Failed to retrieve %s data file: %+v", version, err)
 	}
 	defer res.Body.Close()
 
-	// Stream the data file to mcdex.dat.tmp
+	if res.StatusCode != 200 {
+		return fmt.Errorf("Failed to retrieve %s data file: HTTP %d", version, res.StatusCode)
+	}
+
+	// Stream the data file to mcdex.dat.tmp, hashing the compressed data as it goes by
+	progress := newProgressReader(res.Body, "Downloading database "+version, res.ContentLength)
+	hasher := sha256.New()
+	tmpFileName := filepath.Join(Env().McdexDir, "mcdex.dat.tmp")
+	err = writeStream(tmpFileName, bzip2.NewReader(io.TeeReader(progress, hasher)))
+	progress.finish(err)
+	if err != nil {
+		return err
+	}
+
+	// Make sure we consumed the whole file (in case of trailing data), then check the hash
+	io.Copy(hasher, progress)
+	actualHash := hex.EncodeToString(hasher.Sum(nil))
+	if actualHash != expectedHash {
+		os.Remove(tmpFileName)
+		return fmt.Errorf("Checksum mismatch for %s data file: expected %s, got %s", version, expectedHash, actualHash)
+	}
+
+	return finishDatabaseInstall(tmpFileName, version, "")
+}
+
+// readPublishedHash retrieves the SHA256 published alongside a data file (or delta), which covers
+// the compressed file
+func readPublishedHash(ctx context.Context, url string) (string, error) {
+	hash, err := ReadStringFromUrl(ctx, url+".sha256")
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(hash)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum")
+	}
+	return strings.ToLower(fields[0]), nil
+}
+
+// installDatabaseDelta applies a delta (a SQL script of changed rows) to a copy of the current
+// database, moving from one published version to another. The script is only run if it matches its
+// published checksum.
+func installDatabaseDelta(ctx context.Context, fromVersion, toVersion string) error {
+	url := fmt.Sprintf("%s/mcdex-v6-delta-%s-%s.sql.bz2", databaseURL, fromVersion, toVersion)
+	expectedHash, err := readPublishedHash(ctx, url)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve checksum for delta: %+v", err)
+	}
+
+	res, err := HttpGet(ctx, url)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve delta: %+v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return fmt.Errorf("no delta available from %s to %s (HTTP %d)", fromVersion, toVersion, res.StatusCode)
+	}
+
+	compressed, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("failed to download delta: %+v", err)
+	}
+
+	hash := sha256.Sum256(compressed)
+	if actualHash := hex.EncodeToString(hash[:]); actualHash != expectedHash {
+		return fmt.Errorf("checksum mismatch for delta: expected %s, got %s", expectedHash, actualHash)
+	}
+
+	script, err := ioutil.ReadAll(bzip2.NewReader(bytes.NewReader(compressed)))
+	if err != nil {
+		return fmt.Errorf("failed to decompress delta: %+v", err)
+	}
+
+	// Work on a copy of the database so that a failure leaves the current one intact
 	tmpFileName := filepath.Join(Env().McdexDir, "mcdex.dat.tmp")
-	err = writeStream(tmpFileName, bzip2.NewReader(res.Body))
+	src, err := os.Open(filepath.Join(Env().McdexDir, "mcdex.dat"))
 	if err != nil {
 		return err
 	}
+	err = writeStream(tmpFileName, src)
+	src.Close()
+	if err != nil {
+		return err
+	}
+
+	logInfo("Applying incremental database update %s -> %s\n", fromVersion, toVersion)
+	return finishDatabaseInstall(tmpFileName, toVersion, string(script))
+}
 
+// finishDatabaseInstall applies an optional update script to the temporary database, records the
+// version, validates it and then moves it into place
+func finishDatabaseInstall(tmpFileName, version, script string) error {
 	// Open the temporary database and validate it
-	tmpDb, err := sql.Open("sqlite3", tmpFileName)
+	tmpDb, err := sql.Open(sqliteDriver, tmpFileName)
 	if err != nil {
 		// TODO: Add log entry about the file being corrupt
+		os.Remove(tmpFileName)
 		return err
 	}
 	defer tmpDb.Close()
 
-	_, err = tmpDb.Exec("PRAGMA integrity_check;")
+	err = updateDatabase(tmpDb, version, script)
 	if err != nil {
+		tmpDb.Close()
+		os.Remove(tmpFileName)
+		return err
+	}
+
+	var integrity string
+	err = tmpDb.QueryRow("PRAGMA integrity_check;").Scan(&integrity)
+	if err == nil && integrity != "ok" {
+		err = fmt.Errorf("integrity check failed: %s", integrity)
+	}
+	if err != nil {
+		tmpDb.Close()
+		os.Remove(tmpFileName)
 		return err
 	}
 
@@ -113,55 +367,155 @@ func InstallDatabase(skipIfExists bool) error {
 	return nil
 }
 
-func (db *Database) ListForge(mcvsn string, verbose bool) error {
-	rows, err := db.sqlDb.Query("select version, isrec from forge where mcvsn = ? order by version desc", mcvsn)
-	switch {
-	case err == sql.ErrNoRows:
-		return fmt.Errorf("No Forge version found for %s", mcvsn)
-	case err != nil:
+func updateDatabase(sqlDb *sql.DB, version, script string) error {
+	tx, err := sqlDb.Begin()
+	if err != nil {
 		return err
 	}
 
-	latest := false
+	if script != "" {
+		_, err = tx.Exec(script)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply update: %+v", err)
+		}
+
+		// The search index is rebuilt on next open
+		_, err = tx.Exec("DROP TABLE IF EXISTS " + searchTable)
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to reset search index: %+v", err)
+		}
+	}
+
+	// Note the version so we can request deltas later
+	_, err = tx.Exec("delete from meta where key = 'version'")
+	if err == nil {
+		_, err = tx.Exec("insert into meta(key, value) values ('version', ?)", version)
+	}
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to record database version: %+v", err)
+	}
+
+	return tx.Commit()
+}
+
+const (
+	ForgeChannelRecommended = "recommended"
+	ForgeChannelLatest      = "latest"
+)
+
+type forgeVersion struct {
+	version string
+	isrec   bool
+	tstamp  int
+}
+
+// getForgeVersions returns all the known versions of Forge for a given version of Minecraft, most
+// recent first
+func (db *Database) getForgeVersions(ctx context.Context, mcvsn string) ([]forgeVersion, error) {
+	// Release dates are only available in newer versions of the database
+	query := "select version, isrec, 0 from forge where mcvsn = ?"
+	if db.hasColumn("forge", "tstamp") {
+		query = "select version, isrec, tstamp from forge where mcvsn = ?"
+	}
 
+	rows, err := db.sqlDb.Query(query, mcvsn)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
+
+	var result []forgeVersion
 	for rows.Next() {
-		var version string
-		var isrec bool
-		err := rows.Scan(&version, &isrec)
+		var fv forgeVersion
+		err := rows.Scan(&fv.version, &fv.isrec, &fv.tstamp)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		if isrec {
-			fmt.Printf("%s (recommended)\n", version)
+		result = append(result, fv)
+	}
+
+	// Don't make users wait for a new database to use a new version of Minecraft
+	if len(result) == 0 {
+		logDebug("No Forge versions for %s in the database; checking Forge's site", mcvsn)
+		return db.getLiveForgeVersions(ctx, mcvsn)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return compareVersions(result[i].version, result[j].version) > 0
+	})
+
+	return result, nil
+}
+
+func (db *Database) ListForge(ctx context.Context, mcvsn string, verbose bool) error {
+	versions, err := db.getForgeVersions(ctx, mcvsn)
+	if err != nil {
+		return err
+	}
+
+	if len(versions) == 0 {
+		return fmt.Errorf("No Forge version found for %s", mcvsn)
+	}
+
+	latest := false
+	for _, fv := range versions {
+		var released string
+		if fv.tstamp > 0 {
+			released = time.Unix(int64(fv.tstamp), 0).Format(" - released 2006-01-02")
+		}
+
+		if fv.isrec {
+			fmt.Fprintf(consoleOutput, "%s (recommended)%s\n", fv.version, released)
 		} else if !latest {
-			fmt.Printf("%s (latest)\n", version)
+			fmt.Fprintf(consoleOutput, "%s (latest)%s\n", fv.version, released)
 			latest = true
 		} else if verbose {
-			fmt.Printf("%s\n", version)
+			fmt.Fprintf(consoleOutput, "%s%s\n", fv.version, released)
 		}
 	}
 	return nil
 }
 
-func (db *Database) lookupForgeVsn(mcvsn string) (string, error) {
-	var forgeVsn string
-	err := db.sqlDb.QueryRow("select version from forge where mcvsn = ? and isrec = 1", mcvsn).Scan(&forgeVsn)
-	switch {
-	case err == sql.ErrNoRows:
-		return "", fmt.Errorf("No Forge version found for %s", mcvsn)
-	case err != nil:
+// lookupForgeVsn finds the Forge version to use for a Minecraft version on the given channel; if
+// no recommended build is available yet, the latest build is used instead
+func (db *Database) lookupForgeVsn(ctx context.Context, mcvsn string, channel string) (string, error) {
+	if channel != ForgeChannelRecommended && channel != ForgeChannelLatest {
+		return "", fmt.Errorf("invalid Forge channel %s; must be either %s or %s", channel,
+			ForgeChannelRecommended, ForgeChannelLatest)
+	}
+
+	versions, err := db.getForgeVersions(ctx, mcvsn)
+	if err != nil {
 		return "", err
 	}
-	return forgeVsn, nil
+
+	if len(versions) == 0 {
+		return "", fmt.Errorf("No Forge version found for %s", mcvsn)
+	}
+
+	if channel == ForgeChannelRecommended {
+		for _, fv := range versions {
+			if fv.isrec {
+				return fv.version, nil
+			}
+		}
+		logInfo("No recommended Forge version for %s; using latest\n", mcvsn)
+	}
+
+	return versions[0].version, nil
 }
 
-func (db *Database) lookupFabricVsn(mcvsn string) (string, error) {
+func (db *Database) lookupFabricVsn(ctx context.Context, mcvsn string) (string, error) {
 	var fabricVsn string
 	err := db.sqlDb.QueryRow("SELECT version FROM fabric_loaders WHERE mcversion = ?", mcvsn).Scan((&fabricVsn))
 	switch {
 	case err == sql.ErrNoRows:
-		return "", fmt.Errorf("No Fabric version found for %s", mcvsn)
+		// Don't make users wait for a new database to use a new version (or snapshot) of Minecraft
+		logDebug("No Fabric versions for %s in the database; checking Fabric's site", mcvsn)
+		return db.lookupLiveFabricVsn(ctx, mcvsn)
 	case err != nil:
 		return "", err
 	}
@@ -169,19 +523,171 @@ func (db *Database) lookupFabricVsn(mcvsn string) (string, error) {
 	return fabricVsn, nil
 }
 
-func (db *Database) PrintProjects(slug, mcvsn string, ptype int) error {
+// lookupLiveFabricVsn finds the latest stable Fabric loader build for a Minecraft version on Fabric's
+// site, or the latest build if none are stable
+func (db *Database) lookupLiveFabricVsn(ctx context.Context, mcvsn string) (string, error) {
+	versions, err := db.getLiveFabricVersions(ctx, mcvsn)
+	if err != nil {
+		return "", err
+	}
+
+	if len(versions) == 0 {
+		return "", fmt.Errorf("No Fabric version found for %s", mcvsn)
+	}
+
+	for _, v := range versions {
+		if v.stable {
+			return v.version, nil
+		}
+	}
+	return versions[0].version, nil
+}
+
+// checkLoaderVsn makes sure a specific version of a mod loader is known to work with a Minecraft
+// version; Fabric loader builds aren't tied to a Minecraft version, so any known build is accepted
+// as long as Fabric supports the Minecraft version
+func (db *Database) checkLoaderVsn(ctx context.Context, loader, mcvsn, loaderVsn string) error {
+	if loader == "fabric" {
+		_, err := db.lookupFabricVsn(ctx, mcvsn)
+		if err != nil {
+			return err
+		}
+
+		var count int
+		err = db.sqlDb.QueryRow("SELECT count(*) FROM fabric_loaders WHERE version = ?", loaderVsn).Scan(&count)
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			return nil
+		}
+
+		// Loader builds newer than the database
+		versions, err := db.getLiveFabricVersions(ctx, mcvsn)
+		if err != nil {
+			return err
+		}
+		for _, v := range versions {
+			if v.version == loaderVsn {
+				return nil
+			}
+		}
+		return fmt.Errorf("unknown Fabric loader version %s", loaderVsn)
+	}
+
+	versions, err := db.getForgeVersions(ctx, mcvsn)
+	if err != nil {
+		return err
+	}
+
+	for _, fv := range versions {
+		if fv.version == loaderVsn {
+			return nil
+		}
+	}
+	return fmt.Errorf("Forge %s is not available for Minecraft %s; use forge.list to see the available versions", loaderVsn, mcvsn)
+}
+
+// hasColumn checks if a column is present on a table; used to support features that depend on
+// newer versions of the database
+func (db *Database) hasColumn(table, column string) bool {
+	rows, err := db.sqlDb.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return false
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notnull, pk int
+		var name, ctype string
+		var dflt interface{}
+		if rows.Scan(&cid, &name, &ctype, &notnull, &dflt, &pk) == nil && name == column {
+			return true
+		}
+	}
+	return false
+}
+
+// hasTable checks if a table is present in the database
+func (db *Database) hasTable(table string) bool {
+	var count int
+	err := db.sqlDb.QueryRow("select count(*) from sqlite_master where type = 'table' and name = ?", table).Scan(&count)
+	return err == nil && count > 0
+}
+
+// Sort orders for project listings, along with the SQL to implement them
+const (
+	SortByName      = "name"
+	SortByDownloads = "downloads"
+	SortByUpdated   = "updated"
+)
+
+var sortOrders = map[string]string{
+	SortByName:      "slug asc",
+	SortByDownloads: "downloads desc",
+	SortByUpdated:   "modified_ts desc",
+}
+
+var sortIndexes = map[string]string{
+	"projects_downloads_idx": "downloads",
+	"projects_modified_idx":  "modified_ts",
+}
+
+// ensureSortIndexes makes sure the columns used for sorting projects are indexed
+func (db *Database) ensureSortIndexes() error {
+	for name, column := range sortIndexes {
+		_, err := db.sqlDb.Exec(fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON projects(%s)", name, column))
+		if err != nil {
+			return fmt.Errorf("failed to create index on %s: %+v", column, err)
+		}
+	}
+	return nil
+}
+
+// PrintProjects lists the projects matching a name (or slug regex), optionally limited to those
+// available for a Minecraft version, category and mod loader
+func (db *Database) PrintProjects(slug, mcvsn, category, loader, sortBy string, ptype int) error {
+	orderBy, ok := sortOrders[sortBy]
+	if !ok {
+		return fmt.Errorf("invalid sort order %s; must be one of: %s, %s, %s", sortBy, SortByName, SortByDownloads, SortByUpdated)
+	}
+
 	// Turn the name into a pre-compiled regex
 	slugRegex, err := regexp.Compile("(?i)" + slug)
 	if err != nil {
 		return fmt.Errorf("Failed to convert %s into regex: %s", slug, err)
 	}
 
-	query := "select slug, description from projects where type = ? and projectid in (select projectid from versions where mcvsn = ?) order by slug"
-	if mcvsn == "" {
-		query = "select slug, description from projects where type = ? order by slug"
+	query := "select slug, description from projects where type = ?"
+	args := []interface{}{ptype}
+	if mcvsn != "" {
+		query += " and projectid in (select projectid from versions where mcvsn = ?)"
+		args = append(args, mcvsn)
+	}
+
+	if category != "" {
+		clause, err := db.categoryClause()
+		if err != nil {
+			return err
+		}
+		query += clause
+		args = append(args, category)
 	}
 
-	rows, err := db.sqlDb.Query(query, ptype, mcvsn)
+	// Projects that support multiple loaders list them all, e.g. fabric+forge
+	if loader != "" {
+		query += " and ('+' || modloader || '+') like ?"
+		args = append(args, "%+"+loader+"+%")
+	}
+
+	// Use the search index if possible; the regex will match everything
+	if db.useSearchIndex(slug) {
+		query += " and projectid in (select docid from " + searchTable + " where " + searchTable + " match ?)"
+		args = append(args, searchExpression(slug))
+		slugRegex = regexp.MustCompile("")
+	}
+
+	rows, err := db.sqlDb.Query(query+" order by "+orderBy, args...)
 	if err != nil {
 		return fmt.Errorf("Query failed: %+v", err)
 	}
@@ -233,7 +739,80 @@ func (db *Database) GetLatestFileTstamp() (int, error) {
 	return tstamp, err
 }
 
-func (db *Database) FindProjectBySlug(slug string, modLoader string, ptype int) (int, error) {
+// getLatestProjectTstamp returns the timestamp of the most recent file for a project that targets
+// the given Minecraft version
+func (db *Database) getLatestProjectTstamp(projectID int, mcvsn string) (int, error) {
+	query := "select coalesce(max(tstamp), 0) from files where projectid = ?"
+	args := []interface{}{projectID}
+	if db.hasColumn("versions", "fileid") {
+		query = `select coalesce(max(tstamp), 0) from files where projectid = ? and 
+					fileid in (select fileid from versions where projectid = ? and mcvsn = ?)`
+		args = append(args, projectID, mcvsn)
+	}
+
+	var tstamp int
+	err := db.sqlDb.QueryRow(query, args...).Scan(&tstamp)
+	return tstamp, err
+}
+
+// getFileHash returns the SHA1 of a file, if the database includes file hashes
+func (db *Database) getFileHash(fileID int) string {
+	if !db.hasColumn("files", "sha1") {
+		return ""
+	}
+
+	var hash string
+	err := db.sqlDb.QueryRow("select sha1 from files where fileid = ?", fileID).Scan(&hash)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(hash)
+}
+
+// findFileByHash identifies a file (and its project) by SHA1
+func (db *Database) findFileByHash(hash string) (int, int, error) {
+	if !db.hasColumn("files", "sha1") {
+		return 0, 0, fmt.Errorf("database does not include file hashes")
+	}
+
+	var projectID, fileID int
+	err := db.sqlDb.QueryRow("select projectid, fileid from files where sha1 = ?", strings.ToLower(hash)).Scan(&projectID, &fileID)
+	if err != nil {
+		return 0, 0, err
+	}
+	return projectID, fileID, nil
+}
+
+// getSuccessors returns the slugs of maintained forks that replace an abandoned project
+func (db *Database) getSuccessors(projectID int) ([]string, error) {
+	if !db.hasTable("successors") {
+		return nil, nil
+	}
+
+	rows, err := db.sqlDb.Query(`select slug from projects where projectid in 
+									(select successorid from successors where projectid = ?)`, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []string
+	for rows.Next() {
+		var slug string
+		err = rows.Scan(&slug)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, slug)
+	}
+	return result, nil
+}
+
+func (db *Database) FindProjectBySlug(ctx context.Context, slug string, modLoader string, ptype int) (int, error) {
+	if db.live {
+		return db.liveFindProjectBySlug(ctx, slug, ptype)
+	}
+
 	var modID int
 	var supportedModLoader string
 	err := db.sqlDb.QueryRow("select projectid, modloader from projects where type = ? and slug = ?", ptype, slug).Scan(&modID, &supportedModLoader)
@@ -251,7 +830,12 @@ func (db *Database) FindProjectBySlug(slug string, modLoader string, ptype int)
 	return modID, nil
 }
 
-func (db *Database) findSlugByProject(id int) (string, error) {
+func (db *Database) findSlugByProject(ctx context.Context, id int) (string, error) {
+	if db.live {
+		slug, _, _, err := db.liveProjectInfo(ctx, id)
+		return slug, err
+	}
+
 	var slug string
 	err := db.sqlDb.QueryRow("select slug from projects where projectid = ?", id).Scan(&slug)
 	switch {
@@ -263,8 +847,8 @@ func (db *Database) findSlugByProject(id int) (string, error) {
 	return slug, nil
 }
 
-func (db *Database) findModBySlug(slug string, modLoader string) (int, error) {
-	return db.FindProjectBySlug(slug, modLoader, 0)
+func (db *Database) findModBySlug(ctx context.Context, slug string, modLoader string) (int, error) {
+	return db.FindProjectBySlug(ctx, slug, modLoader, 0)
 }
 
 func (db *Database) findModByName(name string) (int, error) {
@@ -279,7 +863,11 @@ func (db *Database) findModByName(name string) (int, error) {
 	return modID, nil
 }
 
-func (db *Database) getProjectInfo(projectID int) (string, string, string, error) {
+func (db *Database) getProjectInfo(ctx context.Context, projectID int) (string, string, string, error) {
+	if db.live {
+		return db.liveProjectInfo(ctx, projectID)
+	}
+
 	var slug, name, desc string
 	err := db.sqlDb.QueryRow("select slug, name, description from projects where projectid = ? and type = 0", projectID).Scan(&slug, &name, &desc)
 	if err != nil {
@@ -322,45 +910,50 @@ func (db *Database) getDeps(fileID int) ([]string, error) {
 	return result, nil
 }
 
-func (db *Database) GetLatestPackURL(slug string) (string, error) {
-	// First try to find the pack by looking for the specific slug
-	// TODO: Remove forge
-	pid, err := db.FindProjectBySlug(slug, "forge",1)
-	if err != nil {
-		return "", err
-	}
-
-	// Find the latest file given the project ID; we don't need to worry about matching the MC version,
-	// since modpacks are always locked to a specific version anyways
-	var fileID int
-	err = db.sqlDb.QueryRow("select fileid from files where projectid = ? order by tstamp desc limit 1", pid).Scan(&fileID)
-	switch {
-	case err == sql.ErrNoRows:
-		return "", fmt.Errorf("No modpack file found for %s", slug)
-	case err != nil:
-		return "", err
-	}
-
-	// Construct a URL using the slug and file ID
-	return fmt.Sprintf("https://minecraft.curseforge.com/projects/%d/files/%d/download", pid, fileID), nil
-
+func (db *Database) GetLatestPackURL(ctx context.Context, slug string) (string, error) {
+	return db.GetPackURL(ctx, slug, "")
 }
 
 type ForEachModHandler func(id int, slug string, loader string, description string, downloads int, modified_ts int, created_ts int) error
 
-func (db *Database) ForEachMod(mcvsn string, loader string, orderByField string, ascending bool, handler ForEachModHandler) (int, error) {
+func (db *Database) ForEachMod(mcvsn string, loader string, search string, category string, orderByField string, ascending bool, handler ForEachModHandler) (int, error) {
+	return db.ForEachProject(0, mcvsn, loader, search, category, orderByField, ascending, handler)
+}
+
+// ForEachProject invokes the handler for each project of the given type (0 = mod, 1 = modpack)
+// that matches the filters
+func (db *Database) ForEachProject(ptype int, mcvsn string, loader string, search string, category string, orderByField string, ascending bool, handler ForEachModHandler) (int, error) {
 	orderByDirection := "desc"
 	if ascending {
 		orderByDirection = "asc"
 	}
 
-	query := fmt.Sprintf("select projectid, slug, modloader, description, downloads, modified_ts, created_ts from projects where type = %d and (modloader = '%s' or modLoader = 'fabric+forge') and projectid in (select projectid from versions where mcvsn = '%s') order by %s %s",
-		0, loader, mcvsn, orderByField, orderByDirection)
-	rows, err := db.sqlDb.Query(query)
+	args := []interface{}{loader, mcvsn}
+	searchClause := ""
+	if db.useSearchIndex(search) {
+		searchClause = " and projectid in (select docid from " + searchTable + " where " + searchTable + " match ?)"
+		args = append(args, searchExpression(search))
+	} else if search != "" {
+		searchClause = " and (slug like ? or description like ?)"
+		args = append(args, "%"+search+"%", "%"+search+"%")
+	}
+
+	if category != "" {
+		clause, err := db.categoryClause()
+		if err != nil {
+			return 0, err
+		}
+		searchClause += clause
+		args = append(args, category)
+	}
+
+	query := fmt.Sprintf("select projectid, slug, modloader, description, downloads, modified_ts, created_ts from projects where type = %d and (modloader = ? or modLoader = 'fabric+forge') and projectid in (select projectid from versions where mcvsn = ?)%s order by %s %s",
+		ptype, searchClause, orderByField, orderByDirection)
+	rows, err := db.sqlDb.Query(query, args...)
 
 	switch {
 	case err == sql.ErrNoRows:
-		fmt.Printf("No rows returned!\n")
+		logDebug("No rows returned!\n")
 		return 0, nil
 	case err != nil:
 		return 0, fmt.Errorf("failed to lookup mods: %+v", err)
@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// CurseForge sections for each type of project in the database (0 = mods, 1 = modpacks)
+var curseForgeSections = map[int]int{
+	0: 6,
+	1: 4471,
+}
+
+// The live database is created when OpenPackDatabase can't open mcdex.dat, and shared for the rest
+// of the run; it's kept apart from sharedDb so OpenDatabase never returns it
+var sharedLiveDb *Database
+
+// OpenPackDatabase opens the database for installing packs and selecting mods; if it can't be
+// downloaded, projects are looked up online instead, so packs can still be installed
+func OpenPackDatabase(ctx context.Context) (*Database, error) {
+	db, err := OpenDatabase(ctx)
+	if err == nil {
+		return db, nil
+	}
+
+	if sharedLiveDb != nil {
+		return sharedLiveDb, nil
+	}
+
+	warn(WarnNoDatabase, "%+v; looking up mods with the CurseForge and Modrinth APIs instead", err)
+	sharedLiveDb, err = openLiveDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return sharedLiveDb, nil
+}
+
+// The tables of mcdex.dat that are queried while installing packs and selecting mods; they're
+// left empty in the live database, so lookups find nothing and fall back to the sites' APIs
+// (e.g. getForgeVersions) rather than failing
+var liveSchema = []string{
+	"CREATE TABLE projects(projectid int primary key, type int, slug, name, description, modloader, downloads int, modified_ts int, created_ts int)",
+	"CREATE TABLE files(projectid int, fileid int, tstamp int, sha1)",
+	"CREATE TABLE versions(projectid int, mcvsn)",
+	"CREATE TABLE deps(fileid int, projectid int, level int)",
+	"CREATE TABLE forge(version, mcvsn, isrec int)",
+	"CREATE TABLE fabric_loaders(version, mcversion)",
+	"CREATE TABLE meta(key, value)",
+	metadataCacheSchema,
+}
+
+// openLiveDatabase is used when mcdex.dat isn't available (e.g. files.mcdex.net is down); it has
+// no projects, so mods are looked up with the CurseForge API instead. Metadata (see getCachedURL)
+// is only cached for the current run.
+func openLiveDatabase() (*Database, error) {
+	sqlDb, err := sql.Open(sqliteDriver, ":memory:")
+	if err != nil {
+		return nil, err
+	}
+
+	// Each connection to an in-memory database gets its own copy
+	sqlDb.SetMaxOpenConns(1)
+
+	for _, statement := range liveSchema {
+		_, err = sqlDb.Exec(statement)
+		if err != nil {
+			sqlDb.Close()
+			return nil, fmt.Errorf("failed to create live database: %+v", err)
+		}
+	}
+	return &Database{sqlDb: sqlDb, live: true}, nil
+}
+
+// liveFindProjectBySlug searches CurseForge for the project with a slug
+func (db *Database) liveFindProjectBySlug(ctx context.Context, slug string, ptype int) (int, error) {
+	results, err := getJSONFromURL(ctx, fmt.Sprintf("%s/addon/search?gameId=432&sectionId=%d&searchFilter=%s",
+		curseForgeAPI, curseForgeSections[ptype], url.QueryEscape(slug)))
+	if err != nil {
+		return -1, fmt.Errorf("failed to search CurseForge for %s: %+v", slug, err)
+	}
+
+	projects, _ := results.Children()
+	for _, project := range projects {
+		if projectSlug, _ := strValue(project, "slug"); projectSlug == slug {
+			return intValue(project, "id")
+		}
+	}
+	return -1, fmt.Errorf("no mod found %s", slug)
+}
+
+func (db *Database) liveProjectInfo(ctx context.Context, projectID int) (string, string, string, error) {
+	project, err := projectSources["curseforge"].GetProject(ctx, strconv.Itoa(projectID))
+	if err != nil {
+		return "", "", "", fmt.Errorf("failed to get project info for %d: %+v", projectID, err)
+	}
+	return project.Slug, project.Name, project.Summary, nil
+}
+
+// livePackFiles lists the files of a modpack on CurseForge, newest first
+func (db *Database) livePackFiles(ctx context.Context, pid int) ([]PackFile, error) {
+	files, err := getCurseForgeFiles(ctx, pid)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve files for modpack %d: %+v", pid, err)
+	}
+
+	var result []PackFile
+	for _, file := range files {
+		result = append(result, PackFile{file.ID, int(file.Date.Unix()), file.Filename, file.DisplayName})
+	}
+	return result, nil
+}
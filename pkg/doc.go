@@ -0,0 +1,21 @@
+// Package pkg implements mcdex: finding mods and modpacks, and installing them (along with the
+// Forge or Fabric loader) into a launcher profile, MultiMC instance or server directory.
+//
+// The command line tool is a thin layer over this package, so other programs can use it to do
+// the same things. The environment must be initialized before anything else:
+//
+//	ctx := context.Background()                  // or one that's canceled on interrupt
+//	err := pkg.InitEnv(ctx, "", "")              // default Minecraft directory
+//	db, err := pkg.OpenDatabase(ctx)             // mod/modpack metadata; see InstallDatabase
+//	pack, err := pkg.OpenModPack(ctx, "mypack", false)
+//	err = pkg.SelectMod(ctx, pack, "jei", "", false)
+//	err = pack.InstallMods(ctx, true)
+//
+// Progress and status messages are written to stdout; use SetOutput to redirect or discard
+// them, SetLogLevel and SetLogFormat to control which are written and how, and SetLogFile to keep
+// a complete log. Failures are always returned as errors. Project metadata can also be retrieved
+// directly from the sites that host it using GetProjectSource.
+//
+// Functions that download files or run installers take a context; they stop when it's done, so a
+// caller can cancel an install or impose a deadline on it. Partially downloaded files are removed.
+package pkg
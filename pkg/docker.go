@@ -0,0 +1,80 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// Docker image names are restricted to lowercase letters, digits and separators
+var dockerNameRegex = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+const dockerfileTemplate = `# Generated by mcdex for %s (Minecraft %s, %s)
+FROM eclipse-temurin:%d-jre
+
+WORKDIR /server
+COPY . /server
+
+EXPOSE 25565
+CMD %s
+`
+
+const dockerComposeTemplate = `# Generated by mcdex for %s
+services:
+  minecraft:
+    build: .
+    image: %s
+    ports:
+      - "25565:25565"
+    volumes:
+      - ./world:/server/world
+      - ./config:/server/config
+    stdin_open: true
+    tty: true
+    restart: unless-stopped
+`
+
+// GenerateDockerFiles writes a Dockerfile and docker-compose.yml into the server directory; the
+// server must already be installed. mem is the heap size to use (e.g. 6G) or empty to pick one
+// based on the size of the pack
+func (pack *ModPack) GenerateDockerFiles(mem string) error {
+	memory := pack.defaultServerMemory()
+	if mem != "" {
+		var err error
+		memory, err = parseMemory(mem)
+		if err != nil {
+			return err
+		}
+	}
+
+	launchArgs, err := pack.serverLaunchArgsFor("unix_args.txt")
+	if err != nil {
+		return err
+	}
+
+	// Use the exec form of CMD so the server receives signals (and shuts down cleanly) directly
+	cmd := append([]string{"java"}, serverJvmArgs(memory)...)
+	cmd = append(cmd, launchArgs...)
+	cmd = append(cmd, "nogui")
+	cmdJson, _ := json.Marshal(cmd)
+
+	minecraftVsn, loaderVsn := pack.getVersions()
+	loader := fmt.Sprintf("%s %s", pack.modLoader, loaderVsn)
+	image := "mcdex-" + dockerNameRegex.ReplaceAllString(strings.ToLower(pack.Name), "-")
+
+	files := map[string]string{
+		"Dockerfile":         fmt.Sprintf(dockerfileTemplate, pack.Name, minecraftVsn, loader, requiredJavaVersion(minecraftVsn), cmdJson),
+		"docker-compose.yml": fmt.Sprintf(dockerComposeTemplate, pack.Name, image),
+	}
+	for name, content := range files {
+		err := writeStringFile(filepath.Join(pack.gamePath(), name), content)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %+v", name, err)
+		}
+	}
+
+	logAction("Generated Dockerfile and docker-compose.yml (Java %d, %dM heap)\n", requiredJavaVersion(minecraftVsn), memory)
+	return nil
+}
@@ -0,0 +1,67 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/xeonx/timeago"
+)
+
+// Mods with no release for the pack's Minecraft version in this long are considered abandoned
+const abandonedModAge = 18 * 30 * 24 * time.Hour
+
+// Doctor checks the pack for potential problems and reports them
+func (pack *ModPack) Doctor(ctx context.Context) error {
+	issues := 0
+
+	count, err := pack.checkAbandonedMods(ctx)
+	if err != nil {
+		return err
+	}
+	issues += count
+
+	if issues == 0 {
+		fmt.Fprintf(consoleOutput, "No problems found in %s\n", pack.Name)
+	} else {
+		fmt.Fprintf(consoleOutput, "%d potential problem(s) found in %s\n", issues, pack.Name)
+	}
+	return nil
+}
+
+func (pack *ModPack) checkAbandonedMods(ctx context.Context) (int, error) {
+	minecraftVsn := pack.minecraftVersion()
+	cutoff := time.Now().Add(-abandonedModAge)
+	count := 0
+
+	files, _ := pack.manifest.Path("files").Children()
+	for _, f := range files {
+		projectID, err := intValue(f, "projectID")
+		if err != nil {
+			continue
+		}
+
+		tstamp, err := pack.db.getLatestProjectTstamp(projectID, minecraftVsn)
+		if err != nil {
+			return count, fmt.Errorf("failed to check release history for %d: %+v", projectID, err)
+		}
+
+		lastRelease := time.Unix(int64(tstamp), 0)
+		if tstamp == 0 || lastRelease.After(cutoff) {
+			continue
+		}
+
+		count++
+		slug, _ := pack.db.findSlugByProject(ctx, projectID)
+		fmt.Fprintf(consoleOutput, "%s %s has had no release for Minecraft %s since %s; it may be abandoned\n",
+			colorize(consoleOutput, styleWarn, "WARNING:"), slug, minecraftVsn, timeago.English.Format(lastRelease))
+
+		successors, err := pack.db.getSuccessors(projectID)
+		if err == nil && len(successors) > 0 {
+			fmt.Fprintf(consoleOutput, "  Maintained alternatives: %s\n", strings.Join(successors, ", "))
+		}
+	}
+
+	return count, nil
+}
@@ -18,6 +18,7 @@
 package pkg
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"os/exec"
@@ -36,23 +37,42 @@ type EnvConsts struct {
 
 var envData EnvConsts
 
-func InitEnv(minecraftDir string, mmcDir string) error {
+func InitEnv(ctx context.Context, minecraftDir string, mmcDir string) error {
 	// If no specific minecraft directory is provided, use the platform-appropriate one
 	if minecraftDir == "" {
 		minecraftDir = MinecraftDir()
 	}
 	envData.MinecraftDir = minecraftDir
-	os.Mkdir(envData.MinecraftDir, 0700)
 
 	// Get the mcdex directory, create if necessary
-	mcdexDir := filepath.Join(envData.MinecraftDir, "mcdex")
-	os.Mkdir(mcdexDir, 0700)
+	mcdexDir := _findMcdexDir(envData.MinecraftDir)
+	if strings.HasPrefix(mcdexDir, envData.MinecraftDir) {
+		os.Mkdir(envData.MinecraftDir, 0700)
+	}
+	os.MkdirAll(mcdexDir, 0700)
 	envData.McdexDir = mcdexDir
 
+	// Load the user's configuration
+	cfg, err := loadConfig(mcdexDir)
+	if err != nil {
+		return err
+	}
+	configData = cfg
+
+	// Remove any temp directories left behind by previous runs
+	sweepTempDirs()
+
 	// Figure out where the JVM (and unpack200) commands can be found
 	javaDir := _findJavaDir(envData.MinecraftDir)
 	if javaDir == "" {
-		return fmt.Errorf("missing Java directory")
+		// Fall back to a runtime we've downloaded before, or offer to download one
+		javaDir = findAnyDownloadedJava()
+		if javaDir == "" {
+			javaDir, err = offerJavaDownload(ctx, defaultJavaVersion, "Java was not found")
+			if err != nil {
+				return fmt.Errorf("missing Java directory: %+v", err)
+			}
+		}
 	}
 	envData.JavaDir = javaDir
 
@@ -85,6 +105,22 @@ func MinecraftDir() string {
 	}
 }
 
+// _findMcdexDir determines where the database, caches and packs are stored; MCDEX_HOME takes
+// precedence, then $XDG_DATA_HOME/mcdex (unless there's already an mcdex directory inside the
+// Minecraft directory), and finally the mcdex directory inside the Minecraft directory
+func _findMcdexDir(mcdir string) string {
+	if dir := os.Getenv("MCDEX_HOME"); dir != "" {
+		return dir
+	}
+
+	legacyDir := filepath.Join(mcdir, "mcdex")
+	if xdgDir := os.Getenv("XDG_DATA_HOME"); xdgDir != "" && !dirExists(legacyDir) {
+		return filepath.Join(xdgDir, "mcdex")
+	}
+
+	return legacyDir
+}
+
 func _findJavaDir(mcdir string) string {
 	// Check for JAVA_HOME; validate that contains bin/java
 	javaDir := os.Getenv("JAVA_HOME")
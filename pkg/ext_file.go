@@ -0,0 +1,237 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/Jeffail/gabs"
+)
+
+// Prefix used to select a file that's downloaded directly from a URL (e.g. ext:mymod)
+const extPrefix = "ext:"
+
+// Sides an external file is used on; files without a side are used on both
+const (
+	sideClient = "client"
+	sideServer = "server"
+)
+
+// ExtModFile is a mod downloaded directly from a URL, rather than from a site mcdex knows how to
+// search. If its URL is a template (see ExtVersionSource), newer versions are found by updates;
+// otherwise selecting it again with a new URL bumps it.
+type ExtModFile struct {
+	name          string
+	url           string
+	sha256        string // empty if the file wasn't verified when it was selected
+	side          string
+	version       string // guessed from the filename if not provided
+	optional      bool
+	urlTemplate   string // empty unless the URL contains {version}
+	versionSource ExtVersionSource
+}
+
+// SelectExtModFile adds (or bumps) a file downloaded from a URL; the file is downloaded so that its
+// hash can be recorded, and installs are verified against it. A URL containing {version} is a
+// template: the version is either given with the mod (ext:<name>@<version>) or the latest one
+// found by the version source.
+func SelectExtModFile(ctx context.Context, pack *ModPack, mod, url string, source ExtVersionSource, clientOnly bool) error {
+	name, version := splitVersionConstraint(strings.TrimPrefix(mod, extPrefix))
+	if url == "" {
+		return fmt.Errorf("no URL provided for %s", mod)
+	}
+	if strings.IndexAny(version, "<>=!~,") != -1 {
+		return fmt.Errorf("invalid version %s for %s; external files can't be constrained", version, mod)
+	}
+	if err := source.Validate(); err != nil {
+		return err
+	}
+
+	var urlTemplate string
+	if strings.Contains(url, versionPlaceholder) {
+		urlTemplate = url
+		if version == "" {
+			if source.URL == "" {
+				return fmt.Errorf("%s needs a version (ext:%s@<version>) or a version source", url, name)
+			}
+
+			var err error
+			version, err = source.latestVersion(ctx, pack.modConstraint(ctx, &ExtModFile{name: name}, ""))
+			if err != nil {
+				return fmt.Errorf("failed to find latest version of %s: %+v", name, err)
+			}
+		}
+		url = expandURLTemplate(urlTemplate, version)
+	} else if source.URL != "" {
+		return fmt.Errorf("%s has no %s placeholder; a version source can't be used", url, versionPlaceholder)
+	}
+
+	dir, err := newTempDir("", "mcdex-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		os.RemoveAll(dir)
+		forgetTempDir(dir)
+	}()
+
+	filename, err := downloadHttpFileToDir(ctx, url, dir, false)
+	if err != nil {
+		return err
+	}
+
+	hash, err := sha256File(filepath.Join(dir, filename))
+	if err != nil {
+		return fmt.Errorf("failed to hash %s: %+v", filename, err)
+	}
+
+	if version == "" {
+		version = fileModVersion(filename, []string{pack.minecraftVersion()})
+	}
+
+	modFile := ExtModFile{name: name, url: url, sha256: hash, version: version, urlTemplate: urlTemplate, versionSource: source}
+	if clientOnly {
+		modFile.side = sideClient
+	} else if sideDetection {
+		if jarOnClient, known := jarClientOnly(filepath.Join(dir, filename)); known && jarOnClient {
+			logInfo("%s is client-side only; it won't be installed on servers", name)
+			modFile.side = sideClient
+		}
+	}
+
+	return pack.selectMod(&modFile)
+}
+
+func NewExtModFile(modJson *gabs.Container) *ExtModFile {
+	name, _ := strValue(modJson, "name")
+	url, _ := strValue(modJson, "url")
+	hash, _ := strValue(modJson, "sha256")
+	side, _ := strValue(modJson, "side")
+	version, _ := strValue(modJson, "version")
+
+	// Entries can also be marked with the flags used by other mods (see SetModServerOnly)
+	if mcdexBool(modJson, "clientOnly") {
+		side = sideClient
+	} else if mcdexBool(modJson, "serverOnly") {
+		side = sideServer
+	}
+	urlTemplate, _ := strValue(modJson, "urlTemplate")
+	return &ExtModFile{name, url, hash, side, version, !isRequired(modJson), urlTemplate, newExtVersionSource(modJson.S("versionSource"))}
+}
+
+func (f ExtModFile) install(ctx context.Context, pack *ModPack) error {
+	// Check the mod cache to see if we already have this URL installed
+	lastURL, lastFilename := pack.modCache.GetLastExtURL(f.cacheKey())
+	if lastURL == f.url && modFileExists(filepath.Join(pack.modPath(), lastFilename)) {
+		logDebug("Skipping %s\n", lastFilename)
+		return nil
+	} else if lastURL != "" {
+		// A different version of the file is installed; clean it up
+		pack.modCache.CleanupExtFile(f.cacheKey())
+	}
+
+	filename, err := downloadHttpFileToDir(ctx, f.url, pack.modPath(), true)
+	if err != nil {
+		return err
+	}
+
+	fullName := filepath.Join(pack.modPath(), filename)
+	hash, err := sha256File(fullName)
+	if err != nil {
+		return fmt.Errorf("failed to verify %s: %+v", filename, err)
+	}
+	if f.sha256 == "" {
+		// A new version (see update) is verified from now on against the file that was downloaded
+		err = pack.setModHash(f.cacheKey(), hash)
+		if err != nil {
+			return err
+		}
+	} else if hash != f.sha256 {
+		os.Remove(fullName)
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", filename, f.sha256, hash)
+	}
+
+	pack.modCache.AddExtFile(f.cacheKey(), f.url, filename)
+	return pack.applyDisabledState(&f, filename)
+}
+
+func (f *ExtModFile) update(ctx context.Context, pack *ModPack) (bool, error) {
+	if f.urlTemplate == "" || f.versionSource.URL == "" {
+		logInfo("%s is downloaded from a URL; select it again with a new URL to update it\n", f.getName())
+		return false, nil
+	}
+
+	latest, err := f.versionSource.latestVersion(ctx, pack.modConstraint(ctx, f, ""))
+	if err != nil {
+		return false, fmt.Errorf("failed to find latest version of %s: %+v", f.name, err)
+	}
+
+	if compareVersions(latest, f.version) > 0 {
+		// The new file's hash isn't known until it's downloaded; install records it
+		f.version = latest
+		f.url = expandURLTemplate(f.urlTemplate, latest)
+		f.sha256 = ""
+		return true, nil
+	}
+	return false, nil
+}
+
+func (f ExtModFile) getName() string {
+	if f.version != "" {
+		return fmt.Sprintf("%s %s", f.name, f.version)
+	}
+	return f.name
+}
+
+func (f ExtModFile) isClientOnly() bool {
+	return f.side == sideClient
+}
+
+func (f ExtModFile) isServerOnly() bool {
+	return f.side == sideServer
+}
+
+func (f ExtModFile) isOptional() bool {
+	return f.optional
+}
+
+func (f ExtModFile) cacheKey() string {
+	return extPrefix + f.name
+}
+
+func (f ExtModFile) installedFilename(pack *ModPack) string {
+	_, filename := pack.modCache.GetLastExtURL(f.cacheKey())
+	return filename
+}
+
+func (f ExtModFile) equalsJson(modJson *gabs.Container) bool {
+	name, ok := modJson.Path("name").Data().(string)
+	return ok && sourceForEntry(modJson) == "ext" && name == f.name
+}
+
+func (f ExtModFile) toJson() map[string]interface{} {
+	result := map[string]interface{}{
+		"name":     f.name,
+		"url":      f.url,
+		"required": !f.optional,
+	}
+	if f.sha256 != "" {
+		result["sha256"] = f.sha256
+	}
+	if f.side != "" {
+		result["side"] = f.side
+	}
+	if f.version != "" {
+		result["version"] = f.version
+	}
+	if f.urlTemplate != "" {
+		result["urlTemplate"] = f.urlTemplate
+	}
+	if f.versionSource.URL != "" {
+		result["versionSource"] = f.versionSource.toJson()
+	}
+	mcdexFields(result)["source"] = "ext"
+	return result
+}
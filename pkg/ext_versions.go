@@ -0,0 +1,130 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Jeffail/gabs"
+)
+
+// URLs of external files may contain a placeholder for the version of the file
+const versionPlaceholder = "{version}"
+
+// ExtVersionSource finds the versions of an external file that are available: either a web page
+// with a regular expression that matches each version (the first group, if the expression has one),
+// or a JSON document with the path to the versions in it (e.g. versions.name)
+type ExtVersionSource struct {
+	URL      string
+	Regex    string
+	JSONPath string
+}
+
+func newExtVersionSource(c *gabs.Container) ExtVersionSource {
+	var source ExtVersionSource
+	source.URL, _ = strValue(c, "url")
+	source.Regex, _ = strValue(c, "regex")
+	source.JSONPath, _ = strValue(c, "jsonPath")
+	return source
+}
+
+func (s ExtVersionSource) toJson() map[string]interface{} {
+	result := map[string]interface{}{"url": s.URL}
+	if s.Regex != "" {
+		result["regex"] = s.Regex
+	}
+	if s.JSONPath != "" {
+		result["jsonPath"] = s.JSONPath
+	}
+	return result
+}
+
+// Validate makes sure the source has a URL and exactly one way of finding versions
+func (s ExtVersionSource) Validate() error {
+	switch {
+	case s.URL == "" && (s.Regex != "" || s.JSONPath != ""):
+		return fmt.Errorf("a version source needs a URL")
+	case s.URL == "":
+		return nil
+	case s.Regex != "" && s.JSONPath != "":
+		return fmt.Errorf("a version source can use a regex or a JSON path, but not both")
+	case s.Regex == "" && s.JSONPath == "":
+		return fmt.Errorf("a version source needs a regex or a JSON path")
+	}
+
+	if s.Regex != "" {
+		if _, err := regexp.Compile(s.Regex); err != nil {
+			return fmt.Errorf("invalid version regex: %+v", err)
+		}
+	}
+	return nil
+}
+
+// latestVersion retrieves the source and returns the most recent version in it that meets the
+// constraint, if there is one
+func (s ExtVersionSource) latestVersion(ctx context.Context, constraint string) (string, error) {
+	err := s.Validate()
+	if err != nil {
+		return "", err
+	}
+
+	data, err := ReadStringFromUrl(ctx, s.URL)
+	if err != nil {
+		return "", err
+	}
+
+	var versions []string
+	if s.Regex != "" {
+		// The version is the regex's first group or, if it has none, the whole match
+		for _, match := range regexp.MustCompile(s.Regex).FindAllStringSubmatch(data, -1) {
+			if len(match) > 1 {
+				versions = append(versions, match[1])
+			} else {
+				versions = append(versions, match[0])
+			}
+		}
+	} else {
+		doc, err := gabs.ParseJSON([]byte(data))
+		if err != nil {
+			return "", fmt.Errorf("unable to parse %s: %+v", s.URL, err)
+		}
+		versions = jsonStrings(doc.Path(s.JSONPath).Data())
+	}
+
+	var latest string
+	for i, v := range versions {
+		versions[i] = strings.TrimSpace(v)
+		if versions[i] != "" && (latest == "" || compareVersions(versions[i], latest) > 0) {
+			latest = versions[i]
+		}
+	}
+	if latest == "" {
+		return "", fmt.Errorf("no versions found in %s", s.URL)
+	}
+
+	if constraint != "" {
+		return latestMatchingVersion(versions, constraint)
+	}
+	return latest, nil
+}
+
+// jsonStrings flattens a value found by a JSON path (which may be a nested array when the path
+// goes through arrays) into a list of strings
+func jsonStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		var result []string
+		for _, item := range v {
+			result = append(result, jsonStrings(item)...)
+		}
+		return result
+	}
+	return nil
+}
+
+func expandURLTemplate(template, version string) string {
+	return strings.Replace(template, versionPlaceholder, version, -1)
+}
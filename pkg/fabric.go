@@ -1,114 +1,168 @@
 package pkg
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
+	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
 )
 
+// Name of the server launcher JAR, whether downloaded directly or created by the installer
+const fabricServerLauncher = "fabric-server-launch.jar"
+
 type fabricContext struct {
+	ctx context.Context
 	baseDir string
 	minecraftVsn string
 	fabricVsn string
 	isClient bool
-	tmpDir string
 }
 
-func installClientFabric(minecraftVsn, fabricVsn string) (string, error) {
-	ctx := fabricContext{
-		baseDir:      Env().MinecraftDir,
+func installClientFabric(ctx context.Context, baseDir, minecraftVsn, fabricVsn string) (string, error) {
+	fc := fabricContext{
+		ctx:          ctx,
+		baseDir:      baseDir,
 		minecraftVsn: minecraftVsn,
 		fabricVsn:    fabricVsn,
 		isClient:     true,
 	}
-	return ctx.installFabric()
+	return fc.installFabric()
 }
 
-func installServerFabric(minecraftVsn, fabricVsn string, targetDir string) error {
-	ctx := fabricContext{
+func installServerFabric(ctx context.Context, minecraftVsn, fabricVsn string, targetDir string) error {
+	fc := fabricContext{
+		ctx: ctx,
 		baseDir: targetDir,
 		minecraftVsn: minecraftVsn,
 		fabricVsn: fabricVsn,
 		isClient: false,
 	}
-	_, err := ctx.installFabric()
+	_, err := fc.installFabric()
 	return err
 }
 
 
-func (ctx fabricContext) fabricId() string {
-	return fmt.Sprintf("fabric-loader-%s-%s", ctx.fabricVsn, ctx.minecraftVsn)
+func (fc fabricContext) fabricId() string {
+	return fmt.Sprintf("fabric-loader-%s-%s", fc.fabricVsn, fc.minecraftVsn)
 }
 
-func (ctx fabricContext) isFabricInstalled() bool {
-	if ctx.isClient {
-		return fileExists(filepath.Join(ctx.baseDir, "versions", ctx.fabricId(), ctx.fabricId() + ".jar"))
+func (fc fabricContext) isFabricInstalled() bool {
+	if fc.isClient {
+		return fileExists(filepath.Join(fc.baseDir, "versions", fc.fabricId(), fc.fabricId() + ".jar"))
 	} else {
-		return fileExists(filepath.Join(ctx.baseDir, "fabric-server-launch.jar"))
+		return fileExists(filepath.Join(fc.baseDir, fabricServerLauncher))
 	}
 }
 
-func(ctx fabricContext) installFabric() (string, error) {
+func(fc fabricContext) installFabric() (string, error) {
 	// If fabric is already installed, bail early
-	if ctx.isFabricInstalled() {
-		logAction("Fabric %s is already available.\n", ctx.fabricVsn)
-		return ctx.fabricId(), nil
+	if fc.isFabricInstalled() {
+		logAction("Fabric %s is already available.\n", fc.fabricVsn)
+		return fc.fabricId(), nil
 	}
 
-	// Setup a temp directory that will get cleaned up (for downloads, etc)
-	ctx.tmpDir, _ = ioutil.TempDir("", "*-fabricinstall")
-	defer os.RemoveAll(ctx.tmpDir)
+	// Clients only need the version profile, which we can generate directly
+	if fc.isClient {
+		return fc.installClientProfile()
+	}
 
 	// Get the latest fabric-installer URL from maven
-	url, err := ctx.getLatestInstallerUrl()
+	url, installerVsn, err := fc.getLatestInstallerUrl()
 	if err != nil {
 		return "", fmt.Errorf("failed to get URL of fabric installer: %+v", err)
 	}
 
-	// Download the installer
-	installerFilename := filepath.Join(ctx.tmpDir, "fabric-installer.jar")
-	err = downloadHttpFile(url, installerFilename)
+	// Try downloading a server launcher for this loader/installer; it takes care of fetching
+	// Minecraft when the server first starts
+	err = fc.installServerLauncher(installerVsn)
+	if err == nil {
+		return fc.fabricId(), nil
+	}
+	logWarn("Unable to download Fabric server launcher (%+v); falling back to installer\n", err)
+
+	// Download the installer (or use the cached copy)
+	installerFilename, err := downloadInstaller(fc.ctx, url, fmt.Sprintf("fabric-installer-%s.jar", installerVsn))
 	if err != nil {
 		return "", fmt.Errorf("failed to download fabric installer from %s: %+v", url, err)
 	}
 
 	// Setup arguments for the installer
-	args := []string{"-Djava.awt.headless=true", "-jar", installerFilename}
-	if ctx.isClient {
-		args = append(args, "client")
-	} else {
-		args = append(args, "server", "-downloadMinecraft")
-	}
-
-	args = append(args, "-mcversion", ctx.minecraftVsn, "-loader", ctx.fabricVsn)
+	args := []string{"-Djava.awt.headless=true", "-jar", installerFilename, "server", "-downloadMinecraft"}
+	args = append(args, "-mcversion", fc.minecraftVsn, "-loader", fc.fabricVsn)
 
 	// Run the installer!
 	// TODO: Investigate if we need to set the path in which to execute installer
-	logAction("Running fabric installer for %s\n", ctx.fabricId())
-	cmd := exec.Command(javaCmd(), args...)
-	// TODO: Convert to log.debug
-	//if ARG_VERBOSE {
-	//	fmt.Printf("Fabric installer command: %s\n", cmd.String())
-	//}
+	logAction("Running fabric installer for %s\n", fc.fabricId())
+	cmd := javaCommand(fc.ctx, args...)
+	logDebug("Fabric installer command: %s", cmd.String())
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		fmt.Printf("%s\n", out)
-		return "", fmt.Errorf("failed to run fabric installer %s: %+v", ctx.fabricId(), err)
+		logError("%s\n", out)
+		return "", fmt.Errorf("failed to run fabric installer %s: %+v", fc.fabricId(), err)
 	}
 
-	return ctx.fabricId(), nil
+	return fc.fabricId(), nil
 }
 
-func (ctx fabricContext) getLatestInstallerUrl() (string, error) {
+func (fc fabricContext) getLatestInstallerUrl() (string, string, error) {
 	mavenMod, _ := NewMavenModule("net.fabricmc:fabric-installer")
-	metadata, err := mavenMod.loadMetadata("https://maven.fabricmc.net")
+	metadata, err := mavenMod.loadMetadata(fc.ctx, "https://maven.fabricmc.net")
 	if err != nil {
-		return "", fmt.Errorf("failed to load fabric installer metadata: %+v", err)
+		return "", "", fmt.Errorf("failed to load fabric installer metadata: %+v", err)
 	}
 
-	return mavenMod.toVersionPath("https://maven.fabricmc.net", metadata.VersionInfo.Release, "jar")
+	url, err := mavenMod.toVersionPath("https://maven.fabricmc.net", metadata.VersionInfo.Release, "jar")
+	return url, metadata.VersionInfo.Release, err
 }
 
+const fabricMetaURL = "https://meta.fabricmc.net/v2"
+
+// installClientProfile writes the version profile for the loader (as provided by the Fabric
+// meta service) into the launcher's versions directory, without needing Java
+func (fc fabricContext) installClientProfile() (string, error) {
+	url := fmt.Sprintf("%s/versions/loader/%s/%s/profile/json", fabricMetaURL, fc.minecraftVsn, fc.fabricVsn)
+	profile, err := getJSONFromURL(fc.ctx, url)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve Fabric profile for %s: %+v", fc.fabricId(), err)
+	}
+
+	// Make sure the profile ID matches what we expect
+	profile.Set(fc.fabricId(), "id")
+
+	versionDir := filepath.Join(fc.baseDir, "versions", fc.fabricId())
+	logAction("Installing Fabric profile %s\n", fc.fabricId())
+	err = writeStringFile(filepath.Join(versionDir, fc.fabricId()+".json"), profile.StringIndent("", " "))
+	if err != nil {
+		return "", fmt.Errorf("failed to write Fabric profile: %+v", err)
+	}
 
+	// The launcher expects a JAR alongside the profile; like the official installer, leave it empty
+	err = writeStringFile(filepath.Join(versionDir, fc.fabricId()+".jar"), "")
+	if err != nil {
+		return "", fmt.Errorf("failed to write Fabric JAR: %+v", err)
+	}
+
+	return fc.fabricId(), nil
+}
+
+// installServerLauncher downloads the self-contained server launcher JAR from the Fabric meta service
+func (fc fabricContext) installServerLauncher(installerVsn string) error {
+	url := fmt.Sprintf("%s/versions/loader/%s/%s/%s/server/jar", fabricMetaURL, fc.minecraftVsn, fc.fabricVsn, installerVsn)
+	resp, err := HttpGet(fc.ctx, url)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve %s: %+v", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to retrieve %s: %s", url, resp.Status)
+	}
+
+	logAction("Downloading Fabric server launcher for %s\n", fc.fabricId())
+	err = os.MkdirAll(fc.baseDir, 0700)
+	if err != nil {
+		return err
+	}
+	return writeStream(filepath.Join(fc.baseDir, fabricServerLauncher), resp.Body)
+}
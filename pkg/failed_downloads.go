@@ -0,0 +1,96 @@
+package pkg
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Mods that couldn't be downloaded by an install that ignored failures are recorded in this file
+// (in the game directory), so they can be retried later
+const failedDownloadsFilename = "failed-downloads.json"
+
+var ignoreFailures = false
+
+// SetIgnoreFailures makes installs continue past mods that fail to download; the failures are
+// recorded so they can be retried (see RetryFailedDownloads)
+func SetIgnoreFailures(enabled bool) {
+	ignoreFailures = enabled
+}
+
+type failedDownload struct {
+	Key   string `json:"key"`
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+type failedDownloads struct {
+	Failures []failedDownload `json:"failures"`
+}
+
+// writeFailedDownloads records the mods that failed to download; the file is removed once there
+// are no failures left
+func (pack *ModPack) writeFailedDownloads(failures []failedDownload) error {
+	filename := filepath.Join(pack.gamePath(), failedDownloadsFilename)
+	if len(failures) == 0 {
+		os.Remove(filename)
+		return nil
+	}
+
+	data, err := json.MarshalIndent(failedDownloads{failures}, "", " ")
+	if err != nil {
+		return fmt.Errorf("failed to encode %s: %+v", failedDownloadsFilename, err)
+	}
+
+	err = ioutil.WriteFile(filename, data, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to save %s: %+v", failedDownloadsFilename, err)
+	}
+	logWarn("%d mod(s) failed to download; use retry to try them again\n", len(failures))
+	return nil
+}
+
+// RetryFailedDownloads installs the mods that failed to download during an earlier install; any
+// that fail again are kept for the next retry
+func (pack *ModPack) RetryFailedDownloads(ctx context.Context) error {
+	filename := filepath.Join(pack.gamePath(), failedDownloadsFilename)
+	data, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no failed downloads recorded for %s", pack.Name)
+	} else if err != nil {
+		return fmt.Errorf("failed to read %s: %+v", failedDownloadsFilename, err)
+	}
+
+	var previous failedDownloads
+	err = json.Unmarshal(data, &previous)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %+v", failedDownloadsFilename, err)
+	}
+
+	var failures []failedDownload
+	for _, failure := range previous.Failures {
+		modFile, _, _, err := pack.findModFileByKey(failure.Key)
+		if err != nil {
+			logInfo("%s is no longer part of the pack; skipping\n", failure.Name)
+			continue
+		}
+
+		err = modFile.install(ctx, pack)
+		if err != nil {
+			warn(WarnFailedDownloads, "%s: %+v", modFile.getName(), err)
+			failures = append(failures, failedDownload{modFile.cacheKey(), modFile.getName(), err.Error()})
+		}
+	}
+
+	err = pack.writeFailedDownloads(failures)
+	if err != nil {
+		return err
+	}
+	if len(failures) > 0 {
+		return fmt.Errorf("%d mod(s) still failed to download", len(failures))
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+)
+
+// curseFingerprint computes the fingerprint CurseForge uses to identify files; it's a
+// 32-bit MurmurHash2 (seed 1) over the file contents with all whitespace bytes removed
+func curseFingerprint(data []byte) uint32 {
+	// Strip whitespace (tab, newline, carriage return and space)
+	normalized := make([]byte, 0, len(data))
+	for _, b := range data {
+		if b == 9 || b == 10 || b == 13 || b == 32 {
+			continue
+		}
+		normalized = append(normalized, b)
+	}
+
+	const m = 0x5bd1e995
+	const r = 24
+
+	length := uint32(len(normalized))
+	h := 1 ^ length
+
+	i := 0
+	for ; len(normalized)-i >= 4; i += 4 {
+		k := uint32(normalized[i]) | uint32(normalized[i+1])<<8 | uint32(normalized[i+2])<<16 | uint32(normalized[i+3])<<24
+		k *= m
+		k ^= k >> r
+		k *= m
+		h *= m
+		h ^= k
+	}
+
+	switch len(normalized) - i {
+	case 3:
+		h ^= uint32(normalized[i+2]) << 16
+		fallthrough
+	case 2:
+		h ^= uint32(normalized[i+1]) << 8
+		fallthrough
+	case 1:
+		h ^= uint32(normalized[i])
+		h *= m
+	}
+
+	h ^= h >> 13
+	h *= m
+	h ^= h >> 15
+	return h
+}
+
+type fingerprintMatch struct {
+	projectID int
+	fileID    int
+	filename  string
+	name      string
+}
+
+// findFingerprintMatches submits the fingerprints of the provided files to CurseForge and returns
+// any exact matches, keyed by the local filename
+func findFingerprintMatches(ctx context.Context, dir string, filenames []string) (map[string]fingerprintMatch, error) {
+	fingerprints := make(map[uint32]string)
+	var request []uint32
+	for _, filename := range filenames {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filename))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %+v", filename, err)
+		}
+
+		fp := curseFingerprint(data)
+		fingerprints[fp] = filename
+		request = append(request, fp)
+	}
+
+	result := make(map[string]fingerprintMatch)
+	if len(request) == 0 {
+		return result, nil
+	}
+
+	if apiToken("curseforge") == "" {
+		return nil, fmt.Errorf("fingerprint lookup needs a CurseForge API key; set one with auth.login curseforge")
+	}
+
+	response, err := postJSONToURL(ctx, fingerprintURL, map[string][]uint32{"fingerprints": request})
+	if err != nil {
+		return nil, fmt.Errorf("fingerprint lookup failed: %+v", err)
+	}
+
+	matches, _ := response.Path("data.exactMatches").Children()
+	for _, match := range matches {
+		projectID, _ := intValue(match, "file.modId")
+		fileID, _ := intValue(match, "file.id")
+		fingerprint, _ := intValue(match, "file.fileFingerprint")
+		displayName, _ := strValue(match, "file.displayName")
+
+		filename, ok := fingerprints[uint32(fingerprint)]
+		if !ok {
+			continue
+		}
+
+		result[filename] = fingerprintMatch{projectID, fileID, filename, displayName}
+	}
+
+	return result, nil
+}
+
+// identifyModFiles matches files in the mods directory to CurseForge projects; files are first
+// looked up by hash in the local database, and anything left over is fingerprinted via the API
+func (pack *ModPack) identifyModFiles(ctx context.Context, dir string, filenames []string) (map[string]fingerprintMatch, error) {
+	result := make(map[string]fingerprintMatch)
+	var remaining []string
+	for _, filename := range filenames {
+		hash, err := sha1File(filepath.Join(dir, filename))
+		if err == nil {
+			projectID, fileID, err := pack.db.findFileByHash(hash)
+			if err == nil {
+				_, name, _, _ := pack.db.getProjectInfo(ctx, projectID)
+				result[filename] = fingerprintMatch{projectID, fileID, filename, name}
+				continue
+			}
+		}
+		remaining = append(remaining, filename)
+	}
+
+	if len(remaining) == 0 {
+		return result, nil
+	}
+
+	matches, err := findFingerprintMatches(ctx, dir, remaining)
+	if err != nil {
+		// If we identified anything offline, go with that
+		if len(result) > 0 {
+			warn(WarnUnknownMods, "unable to identify %d mod files: %+v", len(remaining), err)
+			return result, nil
+		}
+		return nil, err
+	}
+
+	for filename, match := range matches {
+		result[filename] = match
+	}
+	return result, nil
+}
+
+// untrackedModFiles returns the list of JARs in the mods directory that are not associated
+// with an entry in the manifest
+func (pack *ModPack) untrackedModFiles() ([]string, error) {
+	known, err := pack.modCache.TrackedFilenames()
+	if err != nil {
+		return nil, err
+	}
+
+	// Maven files aren't tracked in the cache, but we know what their filename will be
+	files, _ := pack.manifest.Path("files").Children()
+	for _, f := range files {
+		if modFile, ok := f.Path("module").Data().(string); ok {
+			module, err := NewMavenModule(modFile)
+			if err == nil && module.version != "" {
+				path, _ := module.toRepositoryPath("/")
+				known[filepath.Base(path)] = true
+			}
+		}
+	}
+
+	entries, err := ioutil.ReadDir(pack.modPath())
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %+v", pack.modPath(), err)
+	}
+
+	var result []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".jar") {
+			continue
+		}
+
+		if !known[entry.Name()] {
+			result = append(result, entry.Name())
+		}
+	}
+
+	return result, nil
+}
+
+// adoptUntrackedMods fingerprints any JARs that were manually added to the mods directory and, for
+// those that CurseForge recognizes, offers to register them in the manifest (or registers them
+// without asking, if requested)
+func (pack *ModPack) adoptUntrackedMods(ctx context.Context, askUser bool) error {
+	untracked, err := pack.untrackedModFiles()
+	if err != nil {
+		return err
+	}
+
+	if len(untracked) == 0 {
+		return nil
+	}
+
+	matches, err := pack.identifyModFiles(ctx, pack.modPath(), untracked)
+	if err != nil {
+		return err
+	}
+
+	for _, filename := range untracked {
+		match, ok := matches[filename]
+		if !ok {
+			warn(WarnUnknownMods, "%s is unknown to CurseForge; leaving as-is", filename)
+			continue
+		}
+
+		// Prefer the name from the database, if available
+		name := match.name
+		if _, dbName, _, err := pack.db.getProjectInfo(ctx, match.projectID); err == nil {
+			name = dbName
+		}
+
+		if askUser && !promptYesNo(fmt.Sprintf("%s matches %s (%d); add it to the manifest?", filename, name, match.projectID)) {
+			continue
+		}
+
+		modFile := CurseForgeModFile{projectID: match.projectID, fileID: match.fileID, name: name, desc: name}
+		err = pack.selectMod(&modFile)
+		if err != nil {
+			return err
+		}
+
+		err = pack.modCache.AddModFile(match.projectID, match.fileID, filename)
+		if err != nil {
+			return fmt.Errorf("failed to register %s in mod cache: %+v", filename, err)
+		}
+	}
+
+	return nil
+}
@@ -18,6 +18,7 @@
 package pkg
 
 import (
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -28,14 +29,17 @@ import (
 	"bytes"
 
 	"strings"
+	"sync"
 
 	"encoding/binary"
+	"encoding/json"
 
 	"github.com/Jeffail/gabs"
 	"github.com/xi2/xz"
 )
 
 type forgeContext struct {
+	ctx            context.Context
 	baseDir        string
 	tmpDir         string
 	minecraftVsn   string
@@ -67,8 +71,9 @@ func (fc forgeContext) isForgeInstalled() bool {
 	return false
 }
 
-func installServerForge(minecraftVsn, forgeVsn, targetDir string) error {
+func installServerForge(ctx context.Context, minecraftVsn, forgeVsn, targetDir string) error {
 	_, err := installForge(forgeContext{
+		ctx:          ctx,
 		baseDir:      targetDir,
 		minecraftVsn: minecraftVsn,
 		forgeVsn:     forgeVsn,
@@ -77,9 +82,10 @@ func installServerForge(minecraftVsn, forgeVsn, targetDir string) error {
 	return err
 }
 
-func installClientForge(minecraftVsn, forgeVsn string) (string, error) {
+func installClientForge(ctx context.Context, baseDir, minecraftVsn, forgeVsn string) (string, error) {
 	return installForge(forgeContext{
-		baseDir:      Env().MinecraftDir,
+		ctx:          ctx,
+		baseDir:      baseDir,
 		minecraftVsn: minecraftVsn,
 		forgeVsn:     forgeVsn,
 		isClient:     true,
@@ -94,9 +100,98 @@ func installForge(context forgeContext) (string, error) {
 	}
 
 	// Setup a temp directory that will get cleaned up (for processors)
-	context.tmpDir, _ = ioutil.TempDir("", "*-forgeinstall")
-	defer os.RemoveAll(context.tmpDir)
+	var err error
+	context.tmpDir, err = newTempDir("", "*-forgeinstall")
+	if err != nil {
+		return "", err
+	}
+	defer releaseTempDir(context.tmpDir)
+
+	installerFile, err := downloadForgeInstaller(&context)
+	if err != nil {
+		return "", err
+	}
+
+	installerBytes, err := ioutil.ReadFile(installerFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read Forge installer: %+v", err)
+	}
+
+	// Try to install Forge ourselves; if that fails (usually due to a change in the installer
+	// format), fallback to running the official installer
+	method := forgeInstallNative
+	err = installForgeNative(&context, installerBytes)
+	if err != nil {
+		logWarn("Failed to install Forge %s: %+v\nFalling back to official Forge installer\n", context.forgeVsn, err)
+		method = forgeInstallOfficial
+		err = runForgeInstaller(&context, installerFile)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	recordForgeInstall(&context, method)
+	return context.forgeId(), nil
+}
 
+// Methods used to install Forge
+const (
+	forgeInstallNative   = "native"
+	forgeInstallOfficial = "installer"
+)
+
+// runForgeInstaller runs the official Forge installer in headless mode
+func runForgeInstaller(context *forgeContext, installerFile string) error {
+	var err error
+	os.MkdirAll(context.baseDir, 0700)
+
+	mode := "--installServer"
+	if context.isClient {
+		mode = "--installClient"
+
+		// The client installer refuses to run without a launcher profile
+		profiles := filepath.Join(context.baseDir, "launcher_profiles.json")
+		if !fileExists(profiles) {
+			err = writeStringFile(profiles, "{\"profiles\": {}}")
+			if err != nil {
+				return fmt.Errorf("failed to create %s: %+v", profiles, err)
+			}
+		}
+	}
+
+	logAction("Running Forge installer for %s\n", context.forgeId())
+	cmd := javaCommand(context.ctx, "-Djava.awt.headless=true", "-jar", installerFile, mode, context.baseDir)
+	cmd.Dir = context.baseDir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		logError("%s\n", out)
+		return fmt.Errorf("failed to run Forge installer %s: %+v", context.forgeId(), err)
+	}
+	return nil
+}
+
+// recordForgeInstall notes how Forge was installed, to help with diagnosing install problems
+func recordForgeInstall(context *forgeContext, method string) {
+	dir := context.baseDir
+	if context.isClient {
+		dir = context.versionDir()
+	}
+
+	info := map[string]interface{}{
+		"loader":    "forge",
+		"minecraft": context.minecraftVsn,
+		"version":   context.forgeVsn,
+		"method":    method,
+	}
+
+	data, _ := json.MarshalIndent(info, "", " ")
+	err := writeStringFile(filepath.Join(dir, ".mcdex-install.json"), string(data))
+	if err != nil {
+		logWarn("Failed to record Forge install method: %+v\n", err)
+	}
+}
+
+func downloadForgeInstaller(context *forgeContext) (string, error) {
 	// Choose the right format for the download URL; some older versions
 	// of Forge are a tad inconsistent
 	var forgeURL string
@@ -112,32 +207,27 @@ func installForge(context forgeContext) (string, error) {
 	// Construct the download URL
 	logAction("Downloading Forge %s\n", context.forgeVsn)
 
-	// Download the Forge installer (into memory)
-	resp, err := HttpGet(forgeURL)
-	if err != nil {
-		return "", fmt.Errorf("download failed: %+v", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("HTTP error %d", resp.StatusCode)
-	}
-
-	installerBytes, err := ioutil.ReadAll(resp.Body)
+	// Download the Forge installer (or use the cached copy)
+	installerFile, err := downloadInstaller(context.ctx, forgeURL, fmt.Sprintf("forge-%s-%s.jar", context.minecraftVsn, context.forgeVsn))
 	if err != nil {
 		return "", fmt.Errorf("failed to download Forge %s: %+v", context.forgeVsn, err)
 	}
+	return installerFile, nil
+}
 
+// installForgeNative installs Forge by processing the installer's profile directly
+func installForgeNative(context *forgeContext, installerBytes []byte) error {
 	// Setup a zip helper for the forge installer
+	var err error
 	context.installArchive, err = NewZipHelper(installerBytes)
 	if err != nil {
-		return "", fmt.Errorf("failed to open Forge installer: %+v", err)
+		return fmt.Errorf("failed to open Forge installer: %+v", err)
 	}
 
 	// Get install_profile.json from the installer
 	context.installJson, err = context.installArchive.getJsonFile("install_profile.json")
 	if err != nil {
-		return "", fmt.Errorf("failed to get JSON for install_profile.json: %+v", err)
+		return fmt.Errorf("failed to get JSON for install_profile.json: %+v", err)
 	}
 
 	// If we didn't find a version.json in the installer package, look inside the install_profile.json for
@@ -145,7 +235,7 @@ func installForge(context forgeContext) (string, error) {
 	context.versionJson, _ = context.installArchive.getJsonFile("version.json")
 	if context.versionJson == nil {
 		if !context.installJson.ExistsP("versionInfo") {
-			return "", fmt.Errorf("failed to find either version.json or versionInfo section")
+			return fmt.Errorf("failed to find either version.json or versionInfo section")
 		}
 
 		// Ok, confirmed we're in legacy mode. There's some fix-up work to do...
@@ -163,48 +253,49 @@ func installForge(context forgeContext) (string, error) {
 	context.versionJson.SetP(context.forgeId(), "id")
 
 	// Install forge artifacts (i.e. forge JAR and version file, as appropriate)
-	err = installForgeArtifacts(&context)
+	err = installForgeArtifacts(context)
 	if err != nil {
-		fmt.Printf("Failed to install Forge artifacts: %+v\n", err)
-		return "", err
+		logError("Failed to install Forge artifacts: %+v\n", err)
+		return err
 	}
 
 	logSection("Installed forge artifacts\n")
 
-	// Install libraries for install_profile.json
-	err = installForgeLibraries(context.installJson, &context)
+	// Install libraries for install_profile.json and version.json (or versionInfo)
+	err = installForgeLibraries(context, context.installJson, context.versionJson)
 	if err != nil {
-		fmt.Printf("Failed to install libraries for install_profile.json: %+v\n", err)
-		return "", err
-	}
-
-	// Install libraries for version.json (or versionInfo)
-	err = installForgeLibraries(context.versionJson, &context)
-	if err != nil {
-		fmt.Printf("Failed to install libraries for version.json: %+v\n", err)
-		return "", err
+		logError("Failed to install libraries: %+v\n", err)
+		return err
 	}
 
 	logSection("Installed all libraries\n")
 
 	// Make sure appropriate minecraft JAR is available
-	minecraftJar, err := installMinecraftJar(context.minecraftVsn, context.isClient, context.baseDir)
+	minecraftJar, err := installMinecraftJar(context.ctx, context.minecraftVsn, context.isClient, context.baseDir)
 	if err != nil {
-		return "", fmt.Errorf("failed to install minecraft jar %s: %+v", context.minecraftVsn, err)
+		return fmt.Errorf("failed to install minecraft jar %s: %+v", context.minecraftVsn, err)
 	}
 
 	logSection("Installed Minecraft %s jar\n", context.minecraftVsn)
 
 	// Run any processors we find in install_profile.json
-	err = runForgeProcessors(&context, minecraftJar)
+	err = runForgeProcessors(context, minecraftJar)
 	if err != nil {
-		fmt.Printf("Failed to run processores from install_profile.json: %+v\n", err)
-		return "", err
+		logError("Failed to run processores from install_profile.json: %+v\n", err)
+		return err
 	}
 
 	logSection("Executed forge processors\n")
 
-	return context.forgeId(), nil
+	// Modern servers need launch scripts
+	if !context.isClient && usesForgeArgFiles(context.minecraftVsn) {
+		err = installForgeServerScripts(context)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func installForgeArtifacts(context *forgeContext) error {
@@ -228,7 +319,15 @@ func installForgeArtifacts(context *forgeContext) error {
 	// - Legacy, client - get universal jar from ZIP and place in artifacts dir
 	// - Legacy, server - get universal jar from ZIP and place in base dir
 	// - Current, server - get from ZIP and place in base dir
-	artifactId := context.installJson.S("path").Data().(string)
+	// - Modern (1.17+), server - nothing to do; server is launched via argument files
+	artifactId, ok := context.installJson.S("path").Data().(string)
+	if !ok {
+		if !context.isLegacy && usesForgeArgFiles(context.minecraftVsn) {
+			return nil
+		}
+		return fmt.Errorf("missing path in install_profile.json")
+	}
+
 	forgeFilename := fmt.Sprintf("forge-%s-%s.jar", context.minecraftVsn, context.forgeVsn)
 	var sourceFile string
 	var targetFile string
@@ -253,15 +352,60 @@ func installForgeArtifacts(context *forgeContext) error {
 	return nil
 }
 
-func installForgeLibraries(versionInfo *gabs.Container, context *forgeContext) error {
-	libs, _ := versionInfo.Path("libraries").Children()
+// Number of libraries to install concurrently
+const forgeLibraryWorkers = 8
+
+func installForgeLibraries(context *forgeContext, versionInfos ...*gabs.Container) error {
+	// Gather up the libraries from all the version info; the same library is often listed in
+	// more than one place
+	var libs []*gabs.Container
+	seen := make(map[string]bool)
+	for _, versionInfo := range versionInfos {
+		children, _ := versionInfo.Path("libraries").Children()
+		for _, lib := range children {
+			name, _ := lib.Path("name").Data().(string)
+			if seen[name] {
+				continue
+			}
+			seen[name] = true
+			libs = append(libs, lib)
+		}
+	}
+
+	// Install the libraries using a pool of workers
+	jobs := make(chan *gabs.Container)
+	errs := make(chan error, len(libs))
+	var wg sync.WaitGroup
+	for i := 0; i < forgeLibraryWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for lib := range jobs {
+				err := installForgeLibrary(lib, context)
+				if err != nil {
+					errs <- fmt.Errorf("%s: %+v", lib, err)
+				}
+			}
+		}()
+	}
+
 	for _, lib := range libs {
-		err := installForgeLibrary(lib, context)
-		if err != nil {
-			return fmt.Errorf("%s: %+v", lib, err)
+		// Stop handing out work once something has failed (or the install is canceled)
+		if len(errs) > 0 {
+			break
+		}
+		if err := checkCanceled(context.ctx); err != nil {
+			errs <- err
+			break
 		}
+		jobs <- lib
 	}
-	return nil
+	close(jobs)
+	wg.Wait()
+	close(errs)
+
+	// Report the first error (if any)
+	return <-errs
 }
 
 func installForgeLibrary(library *gabs.Container, context *forgeContext) error {
@@ -279,6 +423,9 @@ func installForgeLibrary(library *gabs.Container, context *forgeContext) error {
 			filename := library.Path("downloads.artifact.path").Data().(string)
 			sourceFile := path.Join("maven", filename)
 			targetFile := filepath.Join(context.artifactDir(), filename)
+			if fileExists(targetFile) {
+				return nil
+			}
 
 			logAction("Installing %s...\n", name)
 			_, err := context.installArchive.writeFile(sourceFile, targetFile)
@@ -321,15 +468,13 @@ func installForgeLibrary(library *gabs.Container, context *forgeContext) error {
 		url = url + "/" + artifactName
 	}
 
-	err := downloadXzPack(url, filename)
-	if err != nil {
-		err = downloadJar(url, filename)
-		if err != nil {
-			return err
-		}
+	// Prefer the (smaller) pack200 version of the library, if we're able to unpack it; otherwise
+	// just get the plain JAR
+	if canUnpack200() && downloadXzPack(context.ctx, url, filename) == nil {
+		return nil
 	}
 
-	return nil
+	return downloadJar(context.ctx, url, filename)
 }
 
 func getFlag(obj *gabs.Container, flag string) bool {
@@ -341,13 +486,13 @@ func getFlag(obj *gabs.Container, flag string) bool {
 	return fval
 }
 
-func downloadXzPack(url, filename string) error {
+func downloadXzPack(ctx context.Context, url, filename string) error {
 	dir := filepath.Dir(filename)
 	filename = filepath.Base(filename)
 
 	// Construct the URL to download
 	finalURL := fmt.Sprintf("%s.pack.xz", url)
-	resp, err := HttpGet(finalURL)
+	resp, err := HttpGet(ctx, finalURL)
 	if err != nil {
 		return fmt.Errorf("failed to download %s: %+v", finalURL, err)
 	}
@@ -388,9 +533,9 @@ func downloadXzPack(url, filename string) error {
 	}
 
 	// Write the packData (minus the signature) to disk
-	err = writeStream(filepath.Join(dir, "tmp.pack"), bytes.NewReader(packData[0:packSz-sigLen]))
+	err = writeStream(filepath.Join(dir, filename+".pack"), bytes.NewReader(packData[0:packSz-sigLen]))
 	if err != nil {
-		fmt.Printf("failed to write %s: %+v", dir, err)
+		logError("failed to write %s: %+v", dir, err)
 		return err
 	}
 
@@ -403,12 +548,12 @@ func downloadXzPack(url, filename string) error {
 }
 
 // TODO - why not use downloadHttpFile??
-func downloadJar(url, filename string) error {
+func downloadJar(ctx context.Context, url, filename string) error {
 	dir := filepath.Dir(filename)
 	filename = filepath.Base(filename)
 
 	// Construct the URL to download
-	resp, err := HttpGet(url)
+	resp, err := HttpGet(ctx, url)
 	if err != nil {
 		return fmt.Errorf("failed to download %s: %+v", url, err)
 	}
@@ -428,7 +573,7 @@ func downloadJar(url, filename string) error {
 	// Save the stream to disk
 	err = writeStream(filepath.Join(dir, filename), resp.Body)
 	if err != nil {
-		fmt.Printf("failed to write %s: %+v", dir, err)
+		return fmt.Errorf("failed to write %s: %+v", filename, err)
 	}
 	return nil
 }
@@ -450,7 +595,7 @@ func signatureLen(data []byte) (int64, error) {
 
 func invokeUnpack200(libDir, libName string) error {
 	err := exec.Command(unpack200Cmd(), "-r",
-		filepath.Join(libDir, "tmp.pack"),
+		filepath.Join(libDir, libName+".pack"),
 		filepath.Join(libDir, libName)).Run()
 	if err != nil {
 		return fmt.Errorf("failed to run unpack200 on %s: %+v", libName, err)
@@ -458,16 +603,13 @@ func invokeUnpack200(libDir, libName string) error {
 	return nil
 }
 
-func invokeProcessor(name string, args []string) error {
+func invokeProcessor(ctx context.Context, name string, args []string) error {
 	logAction("Running processor %s...\n", name)
-	cmd := exec.Command(javaCmd(), args...)
-	// TODO: Convert to log.debug
-	//if ARG_VERBOSE {
-	//	fmt.Printf("Processor command: %s\n", cmd.String())
-	//}
+	cmd := javaCommand(ctx, args...)
+	logDebug("Processor command: %s", cmd.String())
 	out, err := cmd.CombinedOutput()
 	if err != nil {
-		fmt.Printf("%s\n", out)
+		logError("%s\n", out)
 		return fmt.Errorf("failed to run processor %s: %+v", name, err)
 	}
 	return nil
@@ -491,6 +633,10 @@ func runForgeProcessors(context *forgeContext, minecraftJar string) error {
 	data["MINECRAFT_JAR"] = minecraftJar
 
 	for _, p := range processors {
+		if err := checkCanceled(context.ctx); err != nil {
+			return err
+		}
+
 		var args []string
 
 		// Translate the processor artifact to a path
@@ -520,7 +666,7 @@ func runForgeProcessors(context *forgeContext, minecraftJar string) error {
 		// Finally, walk all the arguments and resolve using data section
 		args = append(args, parseProcessorArgs(p, context, data)...)
 
-		err = invokeProcessor(processor, args)
+		err = invokeProcessor(context.ctx, processor, args)
 		if err != nil {
 			return err
 		}
@@ -0,0 +1,94 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+)
+
+// Starting with 1.17, Forge servers are no longer launched from a single JAR; instead, the JVM is
+// given argument files (unix_args.txt/win_args.txt) that setup the module path and main class, along
+// with user_jvm_args.txt for the user's own settings. run.sh/run.bat tie it all together.
+
+const forgeRunSh = `#!/usr/bin/env sh
+# Forge requires a configured set of both JVM and program arguments.
+# Add custom JVM arguments to the user_jvm_args.txt
+# Add custom program arguments {such as nogui} to this file in the next line before the "$@" or
+#  pass them to this script directly
+java @user_jvm_args.txt @%s "$@"
+`
+
+const forgeRunBat = "@echo off\r\n" +
+	"REM Forge requires a configured set of both JVM and program arguments.\r\n" +
+	"REM Add custom JVM arguments to the user_jvm_args.txt\r\n" +
+	"REM Add custom program arguments {such as nogui} to this file in the next line before the %%* or\r\n" +
+	"REM  pass them to this script directly\r\n" +
+	"java @user_jvm_args.txt @%s %%*\r\n" +
+	"pause\r\n"
+
+const forgeUserJvmArgs = `# Xmx and Xms set the maximum and minimum RAM usage, respectively.
+# They can take any number, followed by an M or a G.
+# M means Megabyte, G means Gigabyte.
+# For example, to set the maximum to 3GB: -Xmx3G
+# To set the minimum to 2.5GB: -Xms2500M
+
+# A good default for a modded server is 4GB.
+# Uncomment the next line to set it.
+# -Xmx4G
+`
+
+// usesForgeArgFiles determines if a Forge server for the given Minecraft version is launched
+// using argument files
+func usesForgeArgFiles(minecraftVsn string) bool {
+	return compareVersions(minecraftVsn, "1.17") >= 0
+}
+
+// forgeArgsName returns the name of the argument file for the current platform
+func forgeArgsName() string {
+	if runtime.GOOS == "windows" {
+		return "win_args.txt"
+	}
+	return "unix_args.txt"
+}
+
+func forgeArgsPath(minecraftVsn, forgeVsn, name string) string {
+	return path.Join("libraries", "net", "minecraftforge", "forge", minecraftVsn+"-"+forgeVsn, name)
+}
+
+// installForgeServerScripts extracts the argument files from the installer and generates the
+// launch scripts, mirroring what the official installer does
+func installForgeServerScripts(context *forgeContext) error {
+	for _, name := range []string{"unix_args.txt", "win_args.txt"} {
+		target := filepath.Join(context.baseDir, filepath.FromSlash(forgeArgsPath(context.minecraftVsn, context.forgeVsn, name)))
+		_, err := context.installArchive.writeFile(path.Join("data", name), target)
+		if err != nil {
+			return fmt.Errorf("failed to extract %s: %+v", name, err)
+		}
+	}
+
+	scripts := map[string]string{
+		"run.sh":  fmt.Sprintf(forgeRunSh, forgeArgsPath(context.minecraftVsn, context.forgeVsn, "unix_args.txt")),
+		"run.bat": fmt.Sprintf(forgeRunBat, filepath.FromSlash(forgeArgsPath(context.minecraftVsn, context.forgeVsn, "win_args.txt"))),
+	}
+	for name, content := range scripts {
+		err := writeStringFile(filepath.Join(context.baseDir, name), content)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %+v", name, err)
+		}
+	}
+	os.Chmod(filepath.Join(context.baseDir, "run.sh"), 0755)
+
+	// Don't clobber any settings the user has made
+	userArgs := filepath.Join(context.baseDir, "user_jvm_args.txt")
+	if !fileExists(userArgs) {
+		err := writeStringFile(userArgs, forgeUserJvmArgs)
+		if err != nil {
+			return fmt.Errorf("failed to write user_jvm_args.txt: %+v", err)
+		}
+	}
+
+	logAction("Generated run.sh and run.bat\n")
+	return nil
+}
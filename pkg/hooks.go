@@ -0,0 +1,103 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Hooks are shell commands run at points in a pack's lifecycle (e.g. to generate configs or fix
+// permissions after an install). They can be declared by the pack, in the hooks section of the
+// manifest's x-mcdex namespace, or for every pack, in the hooks section of the config file:
+//
+//	"x-mcdex": {"hooks": {"postInstall": "./scripts/fix-perms.sh"}}
+//
+// Commands run in the pack's game directory with the pack's metadata in MCDEX_* environment
+// variables. Manifest hooks come from whoever published the pack, so they only run if the user
+// agrees to it (or has set hooks.allowManifest in the config file).
+const (
+	HookPreInstall  = "preInstall"
+	HookPostInstall = "postInstall"
+	HookPostUpdate  = "postUpdate"
+)
+
+// runHook runs the manifest's and then the config's commands for a hook; a failing command
+// stops the operation that triggered it
+func (pack *ModPack) runHook(ctx context.Context, hook string, isClient bool) error {
+	var commands []string
+	if command, ok := pack.manifest.Search(mcdexNamespace, "hooks", hook).Data().(string); ok && command != "" {
+		if pack.allowManifestHook(hook, command) {
+			commands = append(commands, command)
+		} else {
+			warn(WarnSkippedHook, "%s hook from the manifest of %s not run", hook, pack.Name)
+		}
+	}
+
+	if cfg := GetConfig(); cfg != nil {
+		if command := cfg.getString("hooks."+hook, ""); command != "" {
+			commands = append(commands, command)
+		}
+	}
+
+	for _, command := range commands {
+		err := pack.runHookCommand(ctx, hook, command, isClient)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pack *ModPack) allowManifestHook(hook, command string) bool {
+	if cfg := GetConfig(); cfg != nil && cfg.getBool("hooks.allowManifest", false) {
+		return true
+	}
+	return promptYesNo(fmt.Sprintf("%s wants to run a %s hook: %s\nRun it?", pack.Name, hook, command))
+}
+
+func (pack *ModPack) runHookCommand(ctx context.Context, hook, command string, isClient bool) error {
+	logInfo("Running %s hook: %s\n", hook, command)
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	cmd.Dir = pack.gamePath()
+	cmd.Env = append(os.Environ(), pack.hookEnv(hook, isClient)...)
+
+	out, err := cmd.CombinedOutput()
+	if output := strings.TrimSpace(string(out)); output != "" {
+		logInfo("%s\n", output)
+	}
+	if err != nil {
+		return fmt.Errorf("%s hook failed: %+v", hook, err)
+	}
+	return nil
+}
+
+func (pack *ModPack) hookEnv(hook string, isClient bool) []string {
+	minecraftVsn, loaderVsn := pack.getVersions()
+	side := "server"
+	if isClient {
+		side = "client"
+	}
+
+	gameDir, _ := filepath.Abs(pack.gamePath())
+	modDir, _ := filepath.Abs(pack.modPath())
+	return []string{
+		"MCDEX_HOOK=" + hook,
+		"MCDEX_PACK_NAME=" + pack.Name,
+		"MCDEX_PACK_DIR=" + gameDir,
+		"MCDEX_MODS_DIR=" + modDir,
+		"MCDEX_MINECRAFT_VERSION=" + minecraftVsn,
+		"MCDEX_LOADER=" + pack.modLoader,
+		"MCDEX_LOADER_VERSION=" + loaderVsn,
+		"MCDEX_SIDE=" + side,
+	}
+}
@@ -0,0 +1,269 @@
+package pkg
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// InstallPlan describes what installing a pack would do, without changing anything on disk
+type InstallPlan struct {
+	Pack      string
+	Minecraft string
+	Loader    string // the loader's ID from the manifest, e.g. forge-36.1.0
+	Overrides []string
+	Downloads []PlannedDownload
+	Installed []string // mods that are already installed
+	Skipped   []string // mods that wouldn't be installed (and why)
+}
+
+// PlannedDownload is a mod file that would be downloaded
+type PlannedDownload struct {
+	Name     string
+	Filename string
+	URL      string
+	Size     int64 // in bytes; -1 if unknown
+}
+
+// PlanInstall works out what installing a pack (from a URL or pack archive, if one is given) into
+// a directory would do. Pack archives are downloaded to a temporary directory so their manifest
+// and overrides can be read; nothing is written to the pack's directory.
+func PlanInstall(ctx context.Context, dir, url string, isClient, enableMultiMC bool) (*InstallPlan, error) {
+	pack := new(ModPack)
+	db, err := OpenPackDatabase(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database for modpack: %+v", err)
+	}
+	pack.db = db
+
+	err = pack.initPaths(dir, enableMultiMC)
+	if err != nil {
+		return nil, err
+	}
+	pack.modDir = "mods"
+
+	var overrides []string
+	if url != "" {
+		overrides, err = pack.readArchivePlan(ctx, url)
+	} else {
+		err = pack.loadManifest()
+	}
+	if err != nil {
+		return nil, err
+	}
+	pack.identifyLoader()
+
+	// Mods that are already installed are recorded in the pack's cache (if it's been installed)
+	if fileExists(filepath.Join(pack.gamePath(), ".mcdex.cache")) {
+		pack.modCache, err = OpenMetaCache(pack)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to open mod cache: %+v", err)
+		}
+	}
+
+	plan := &InstallPlan{Pack: pack.Name, Minecraft: pack.minecraftVersion(), Overrides: overrides}
+	plan.Loader, _ = pack.manifest.Path("minecraft.modLoaders.id").Index(0).Data().(string)
+
+	files := manifestFiles(pack.manifest)
+	for _, f := range files {
+		modFile, err := newModPackFile(f)
+		if err != nil {
+			plan.Skipped = append(plan.Skipped, fmt.Sprintf("%+v", err))
+			continue
+		}
+
+		switch {
+		case !isClient && modFile.isClientOnly():
+			plan.Skipped = append(plan.Skipped, modFile.getName()+" (client-only)")
+			continue
+		case isClient && modFile.isServerOnly():
+			plan.Skipped = append(plan.Skipped, modFile.getName()+" (server-only)")
+			continue
+		case modFile.isOptional() && !installOptional && (pack.modCache == nil || !pack.isModInstalled(modFile)):
+			plan.Skipped = append(plan.Skipped, modFile.getName()+" (optional)")
+			continue
+		}
+
+		download, err := planModDownload(ctx, modFile)
+		if err != nil {
+			return nil, err
+		}
+
+		if pack.modInstalled(modFile, download.URL) {
+			plan.Installed = append(plan.Installed, modFile.getName())
+		} else {
+			plan.Downloads = append(plan.Downloads, download)
+		}
+	}
+	return plan, nil
+}
+
+// readArchivePlan reads the manifest and lists the overrides of a pack archive, downloading it to a
+// temporary directory if it isn't a local file
+func (pack *ModPack) readArchivePlan(ctx context.Context, url string) ([]string, error) {
+	archive := url
+	if !IsLocalPackArchive(url) {
+		if !strings.HasPrefix(url, "https://") {
+			return nil, fmt.Errorf("Invalid modpack URL %s; only https URLs are supported", url)
+		}
+
+		dir, err := newTempDir("", "mcdex-")
+		if err != nil {
+			return nil, err
+		}
+		defer releaseTempDir(dir)
+
+		archive = filepath.Join(dir, "pack.zip")
+		logInfo("Downloading modpack to read its manifest: %s\n", url)
+		err = downloadHttpFile(ctx, url, archive)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	zipFile, err := zip.OpenReader(archive)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to open %s: %v", archive, err)
+	}
+	defer zipFile.Close()
+
+	pack.manifest, err = readPackManifest(zipFile, pack.Name)
+	if err != nil {
+		return nil, err
+	}
+	migrateManifest(pack.manifest)
+
+	// A pack installed without a name goes into a directory named after it
+	if pack.Name == NamePlaceholder {
+		pack.Name = pack.fullName()
+		pack.rootPath = filepath.Join(filepath.Dir(pack.rootPath), pack.Name)
+	}
+
+	overrides, ok := pack.manifest.Path("overrides").Data().(string)
+	if !ok {
+		overrides = "overrides"
+	}
+	overrides += "/"
+
+	var result []string
+	for _, f := range zipFile.File {
+		if !f.FileInfo().IsDir() && strings.HasPrefix(f.Name, overrides) {
+			result = append(result, stripBadUTF8(strings.TrimPrefix(f.Name, overrides)))
+		}
+	}
+	return result, nil
+}
+
+// planModDownload works out where a mod would be downloaded from
+func planModDownload(ctx context.Context, modFile ModPackFile) (PlannedDownload, error) {
+	download := PlannedDownload{Name: modFile.getName(), Size: -1}
+	if source, projectID, fileID, ok := sourceFile(modFile); ok {
+		file, err := source.GetFile(ctx, projectID, fileID)
+		if err != nil {
+			return download, fmt.Errorf("failed to retrieve descriptor for %s: %+v", modFile.getName(), err)
+		}
+		download.URL = file.DownloadURL
+		download.Filename = file.Filename
+		if file.Size > 0 {
+			download.Size = file.Size
+		}
+	} else {
+		switch f := modFile.(type) {
+		case *MavenModFile:
+			download.URL, _ = f.module.toRepositoryPath(f.url)
+		case *ExtModFile:
+			download.URL = f.url
+		}
+		download.Filename = path.Base(download.URL)
+	}
+
+	if download.Size < 0 {
+		download.Size = remoteFileSize(ctx, download.URL)
+	}
+	return download, nil
+}
+
+// modInstalled determines if the file a mod would be installed from is already installed
+func (pack *ModPack) modInstalled(modFile ModPackFile, url string) bool {
+	if pack.modCache == nil {
+		return false
+	}
+
+	var lastFilename string
+	if f, ok := modFile.(*CurseForgeModFile); ok {
+		var lastFileID int
+		lastFileID, lastFilename = pack.modCache.GetLastModFile(f.projectID)
+		if lastFileID != f.fileID {
+			return false
+		}
+	} else {
+		var lastURL string
+		lastURL, lastFilename = pack.modCache.GetLastExtURL(modFile.cacheKey())
+		if lastURL != url {
+			return false
+		}
+	}
+	return lastFilename != "" && modFileExists(filepath.Join(pack.modPath(), lastFilename))
+}
+
+// remoteFileSize asks a server for the size of a file; -1 if it doesn't say
+func remoteFileSize(ctx context.Context, url string) int64 {
+	req, err := http.NewRequestWithContext(ctx, "HEAD", url, nil)
+	if err != nil {
+		return -1
+	}
+
+	res, err := getterClient.Do(req)
+	if err != nil {
+		return -1
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return -1
+	}
+	return res.ContentLength
+}
+
+// Print describes the plan on the console
+func (plan *InstallPlan) Print() {
+	fmt.Fprintf(consoleOutput, "Dry run; %s would be installed as follows:\n", plan.Pack)
+	fmt.Fprintf(consoleOutput, "Loader: %s for Minecraft %s\n", plan.Loader, plan.Minecraft)
+
+	if len(plan.Overrides) > 0 {
+		fmt.Fprintf(consoleOutput, "\nFiles from the pack archive (%d):\n", len(plan.Overrides))
+		for _, name := range plan.Overrides {
+			fmt.Fprintf(consoleOutput, "  %s\n", name)
+		}
+	}
+
+	var total int64
+	fmt.Fprintf(consoleOutput, "\nMods to download (%d):\n", len(plan.Downloads))
+	for _, d := range plan.Downloads {
+		size := "unknown size"
+		if d.Size >= 0 {
+			size = FormatBytes(d.Size)
+			total += d.Size
+		}
+		fmt.Fprintf(consoleOutput, "  %s: %s (%s)\n", d.Name, d.Filename, size)
+	}
+	fmt.Fprintf(consoleOutput, "Total: %s\n", FormatBytes(total))
+
+	if len(plan.Installed) > 0 {
+		fmt.Fprintf(consoleOutput, "\nAlready installed (%d):\n", len(plan.Installed))
+		for _, name := range plan.Installed {
+			fmt.Fprintf(consoleOutput, "  %s\n", name)
+		}
+	}
+
+	if len(plan.Skipped) > 0 {
+		fmt.Fprintf(consoleOutput, "\nSkipped (%d):\n", len(plan.Skipped))
+		for _, name := range plan.Skipped {
+			fmt.Fprintf(consoleOutput, "  %s\n", name)
+		}
+	}
+}
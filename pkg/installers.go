@@ -0,0 +1,44 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Installer jars for Forge and Fabric are cached in the mcdex directory so that installing the same
+// loader for multiple packs (or for both client and server) only downloads them once
+func installerCacheFile(name string) string {
+	return filepath.Join(Env().McdexDir, "cache", "installers", name)
+}
+
+// downloadInstaller returns the filename of the cached installer, downloading it if necessary
+func downloadInstaller(ctx context.Context, url, name string) (string, error) {
+	filename := installerCacheFile(name)
+	if fileExists(filename) {
+		logAction("Using cached installer %s\n", name)
+		return filename, nil
+	}
+
+	resp, err := HttpGet(ctx, url)
+	if err != nil {
+		return "", fmt.Errorf("download failed: %+v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("HTTP error %d", resp.StatusCode)
+	}
+
+	err = os.MkdirAll(filepath.Dir(filename), 0700)
+	if err != nil {
+		return "", fmt.Errorf("failed to create installer cache: %+v", err)
+	}
+
+	err = writeStream(filename, resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return filename, nil
+}
@@ -0,0 +1,192 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"sync"
+)
+
+var javaVersionRegex = regexp.MustCompile(`version "(\d+)(?:\.(\d+))?`)
+
+var javaVersionLock sync.Mutex
+var javaVersions = make(map[string]int)
+
+// javaMajorVersion runs "java -version" for the JVM in javaDir and returns the major version
+// (e.g. 8 for 1.8.0_292, 17 for 17.0.1)
+func javaMajorVersion(javaDir string) (int, error) {
+	javaVersionLock.Lock()
+	defer javaVersionLock.Unlock()
+
+	if vsn, ok := javaVersions[javaDir]; ok {
+		return vsn, nil
+	}
+
+	out, err := exec.Command(filepath.Join(javaDir, "bin", "java"+_executableExt()), "-version").CombinedOutput()
+	if err != nil {
+		return 0, fmt.Errorf("failed to run java -version: %+v", err)
+	}
+
+	match := javaVersionRegex.FindStringSubmatch(string(out))
+	if match == nil {
+		return 0, fmt.Errorf("unable to determine Java version from: %s", out)
+	}
+
+	vsn, _ := strconv.Atoi(match[1])
+	if vsn == 1 && match[2] != "" {
+		// Pre-9 versions are reported as 1.x
+		vsn, _ = strconv.Atoi(match[2])
+	}
+
+	javaVersions[javaDir] = vsn
+	return vsn, nil
+}
+
+// canUnpack200 determines if pack200 archives can be unpacked with the available JVM; unpack200
+// was removed in Java 14
+func canUnpack200() bool {
+	if !fileExists(unpack200Cmd()) {
+		return false
+	}
+
+	vsn, err := javaMajorVersion(Env().JavaDir)
+	return err != nil || vsn < 14
+}
+
+// javaVersionRange returns the oldest and newest (0 if unbounded) major Java versions that can run
+// the given Minecraft version (and its mod loaders)
+func javaVersionRange(minecraftVsn string) (int, int) {
+	minecraftVsn = snapshotRelease(minecraftVsn)
+	switch {
+	case compareVersions(minecraftVsn, "1.17") >= 0:
+		return requiredJavaVersion(minecraftVsn), 0
+	case compareVersions(minecraftVsn, "1.16") >= 0:
+		// Late 1.16 Forge builds work with newer JVMs
+		return 8, 0
+	}
+	return 8, 8
+}
+
+func javaCompatible(minecraftVsn string, vsn int) bool {
+	min, max := javaVersionRange(minecraftVsn)
+	return vsn >= min && (max == 0 || vsn <= max)
+}
+
+// javaCandidates lists all the JVMs we know how to find: JAVA_HOME/JRE_HOME, the runtimes bundled
+// with the Minecraft launcher, runtimes downloaded by mcdex and whatever is on the PATH
+func javaCandidates() []string {
+	var candidates []string
+	for _, env := range []string{"JAVA_HOME", "JRE_HOME"} {
+		if dir := os.Getenv(env); dir != "" {
+			candidates = append(candidates, dir)
+		}
+	}
+
+	runtimeDirs := []string{filepath.Join(Env().MinecraftDir, "runtime")}
+	if runtime.GOOS == "windows" {
+		runtimeDirs = append(runtimeDirs, filepath.Join(os.Getenv("ProgramFiles(x86)"), "Minecraft", "runtime"))
+	}
+	for _, dir := range runtimeDirs {
+		// Older launchers use runtime/jre-x64/<version>; newer ones use
+		// runtime/<component>/<platform>/<component>
+		for _, pattern := range []string{"*/*", "*/*/*"} {
+			names, _ := filepath.Glob(filepath.Join(dir, pattern))
+			candidates = append(candidates, names...)
+		}
+	}
+
+	names, _ := filepath.Glob(filepath.Join(javaRuntimesDir(), "*"))
+	for _, name := range names {
+		feature, err := strconv.Atoi(filepath.Base(name))
+		if err == nil {
+			candidates = append(candidates, findDownloadedJava(feature))
+		}
+	}
+
+	if path, err := exec.LookPath("java" + _executableExt()); err == nil {
+		if path, err = filepath.EvalSymlinks(path); err == nil {
+			candidates = append(candidates, filepath.Dir(filepath.Dir(path)))
+		}
+	}
+
+	var result []string
+	for _, dir := range candidates {
+		if dir != "" && _javaExists(dir) {
+			result = append(result, dir)
+		}
+	}
+	return result
+}
+
+// selectJavaFor ensures that the JVM mcdex uses (for running installers and servers) can run the
+// given Minecraft version, switching to another detected JVM (or offering to download one) if not
+func selectJavaFor(ctx context.Context, minecraftVsn string) error {
+	vsn, err := javaMajorVersion(envData.JavaDir)
+	if err == nil && javaCompatible(minecraftVsn, vsn) {
+		return nil
+	}
+
+	for _, dir := range javaCandidates() {
+		candidateVsn, err := javaMajorVersion(dir)
+		if err == nil && javaCompatible(minecraftVsn, candidateVsn) {
+			logAction("Using Java %d from %s for Minecraft %s\n", candidateVsn, dir, minecraftVsn)
+			envData.JavaDir = dir
+			return nil
+		}
+	}
+
+	min, _ := javaVersionRange(minecraftVsn)
+	reason := fmt.Sprintf("Minecraft %s requires Java %d, but Java %d was found", minecraftVsn, min, vsn)
+	if vsn == 0 {
+		reason = fmt.Sprintf("Minecraft %s requires Java %d, but the version of %s is unknown", minecraftVsn, min, javaCmd())
+	}
+	dir, err := offerJavaDownload(ctx, min, reason)
+	if err != nil {
+		return err
+	}
+
+	envData.JavaDir = dir
+	return nil
+}
+
+// requiredJavaVersion returns the major Java version that the given Minecraft version is built
+// for; 1.17 moved to Java 16, 1.18 to Java 17 and 1.20.5 to Java 21
+func requiredJavaVersion(minecraftVsn string) int {
+	minecraftVsn = snapshotRelease(minecraftVsn)
+	switch {
+	case compareVersions(minecraftVsn, "1.20.5") >= 0:
+		return 21
+	case compareVersions(minecraftVsn, "1.18") >= 0:
+		return 17
+	case compareVersions(minecraftVsn, "1.17") >= 0:
+		return 16
+	}
+	return 8
+}
+
+// snapshotRelease maps a snapshot to the release it leads up to, as far as Java is concerned: the
+// first snapshots to need Java 16, 17 and 21 were 21w19a, 21w37a and 24w14a. Other versions are
+// returned as-is.
+func snapshotRelease(minecraftVsn string) string {
+	if !isSnapshotVersion(minecraftVsn) {
+		return minecraftVsn
+	}
+
+	// Snapshot IDs (<year>w<week><letter>) sort by age as strings
+	switch {
+	case minecraftVsn >= "24w14a":
+		return "1.20.5"
+	case minecraftVsn >= "21w37a":
+		return "1.18"
+	case minecraftVsn >= "21w19a":
+		return "1.17"
+	case minecraftVsn >= "20w06a":
+		return "1.16"
+	}
+	return "1.15"
+}
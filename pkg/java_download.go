@@ -0,0 +1,296 @@
+package pkg
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Java runtime to download when no JVM could be found at all
+const defaultJavaVersion = 21
+
+const adoptiumAPI = "https://api.adoptium.net/v3"
+
+// Downloaded runtimes live in the mcdex directory, one per major version (e.g. java/17)
+func javaRuntimesDir() string {
+	return filepath.Join(Env().McdexDir, "java")
+}
+
+// findDownloadedJava returns the Java home of a previously downloaded runtime with the given
+// major version, or an empty string if there isn't one
+func findDownloadedJava(feature int) string {
+	dir := filepath.Join(javaRuntimesDir(), strconv.Itoa(feature))
+	names, _ := filepath.Glob(filepath.Join(dir, "*"))
+	for _, name := range names {
+		// macOS archives nest the actual home inside a bundle
+		for _, home := range []string{name, filepath.Join(name, "Contents", "Home")} {
+			if _javaExists(home) {
+				return home
+			}
+		}
+	}
+	return ""
+}
+
+// findAnyDownloadedJava returns the Java home of the newest downloaded runtime, if any
+func findAnyDownloadedJava() string {
+	names, _ := filepath.Glob(filepath.Join(javaRuntimesDir(), "*"))
+
+	var features []int
+	for _, name := range names {
+		feature, err := strconv.Atoi(filepath.Base(name))
+		if err == nil {
+			features = append(features, feature)
+		}
+	}
+
+	sort.Sort(sort.Reverse(sort.IntSlice(features)))
+	for _, feature := range features {
+		if home := findDownloadedJava(feature); home != "" {
+			return home
+		}
+	}
+	return ""
+}
+
+// offerJavaDownload asks the user if they'd like to download a Temurin JRE of the given major
+// version and, if so, installs it (reusing an earlier download if possible) and returns its home
+func offerJavaDownload(ctx context.Context, feature int, reason string) (string, error) {
+	if home := findDownloadedJava(feature); home != "" {
+		return home, nil
+	}
+
+	if !promptYesNo(fmt.Sprintf("%s; download a Java %d runtime (Eclipse Temurin) into %s?", reason, feature, javaRuntimesDir())) {
+		return "", fmt.Errorf("%s", reason)
+	}
+
+	return downloadJava(ctx, feature)
+}
+
+// adoptiumPlatform maps the current OS/architecture onto the names used by the Adoptium API
+func adoptiumPlatform() (string, string, error) {
+	var osName string
+	switch runtime.GOOS {
+	case "darwin":
+		osName = "mac"
+	case "linux", "windows", "aix":
+		osName = runtime.GOOS
+	default:
+		return "", "", fmt.Errorf("no Java runtimes available for %s", runtime.GOOS)
+	}
+
+	var arch string
+	switch runtime.GOARCH {
+	case "amd64":
+		arch = "x64"
+	case "386":
+		arch = "x86"
+	case "arm64":
+		arch = "aarch64"
+	case "arm":
+		arch = "arm"
+	case "ppc64le", "s390x":
+		arch = runtime.GOARCH
+	default:
+		return "", "", fmt.Errorf("no Java runtimes available for %s", runtime.GOARCH)
+	}
+
+	return osName, arch, nil
+}
+
+// downloadJava retrieves the latest Temurin JRE with the given major version and unpacks it into
+// the mcdex directory, returning the Java home
+func downloadJava(ctx context.Context, feature int) (string, error) {
+	osName, arch, err := adoptiumPlatform()
+	if err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf("%s/binary/latest/%d/ga/%s/%s/jre/hotspot/normal/eclipse", adoptiumAPI, feature, osName, arch)
+	resp, err := HttpGet(ctx, url)
+	if err != nil {
+		return "", fmt.Errorf("failed to download Java %d: %+v", feature, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("failed to download Java %d: HTTP error %d", feature, resp.StatusCode)
+	}
+
+	// Unpack into a temporary directory first, so that a failed download doesn't leave a broken
+	// runtime behind
+	err = os.MkdirAll(javaRuntimesDir(), 0700)
+	if err != nil {
+		return "", fmt.Errorf("failed to create %s: %+v", javaRuntimesDir(), err)
+	}
+
+	tmpDir, err := newTempDir(javaRuntimesDir(), "download-")
+	if err != nil {
+		return "", err
+	}
+	defer releaseTempDir(tmpDir)
+
+	archiveName := "jre.tar.gz"
+	if osName == "windows" {
+		archiveName = "jre.zip"
+	}
+	archive := filepath.Join(tmpDir, archiveName)
+
+	progress := newProgressReader(resp.Body, fmt.Sprintf("Downloading Java %d", feature), resp.ContentLength)
+	err = writeStream(archive, progress)
+	progress.finish(err)
+	if err != nil {
+		return "", err
+	}
+
+	runtimeDir := filepath.Join(tmpDir, "runtime")
+	if strings.HasSuffix(archiveName, ".zip") {
+		err = extractZip(archive, runtimeDir)
+	} else {
+		err = extractTarGz(archive, runtimeDir)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to unpack Java %d: %+v", feature, err)
+	}
+
+	target := filepath.Join(javaRuntimesDir(), strconv.Itoa(feature))
+	os.RemoveAll(target)
+	err = os.Rename(runtimeDir, target)
+	if err != nil {
+		return "", fmt.Errorf("failed to install Java %d: %+v", feature, err)
+	}
+
+	home := findDownloadedJava(feature)
+	if home == "" {
+		return "", fmt.Errorf("downloaded Java %d runtime is missing bin/java", feature)
+	}
+
+	logAction("Installed Java %d in %s\n", feature, home)
+	return home, nil
+}
+
+func extractZip(filename, targetDir string) error {
+	r, err := zip.OpenReader(filename)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	for _, f := range r.File {
+		target, err := safeJoin(targetDir, f.Name)
+		if err != nil {
+			return err
+		}
+
+		if f.FileInfo().IsDir() {
+			os.MkdirAll(target, 0755)
+			continue
+		}
+
+		err = extractEntry(f, target)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extractEntry(f *zip.File, target string) error {
+	data, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer data.Close()
+
+	return writeFileWithMode(target, data, f.Mode())
+}
+
+func extractTarGz(filename, targetDir string) error {
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	r := tar.NewReader(gz)
+	for {
+		header, err := r.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		target, err := safeJoin(targetDir, header.Name)
+		if err != nil {
+			return err
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(target, 0755)
+		case tar.TypeReg:
+			err = writeFileWithMode(target, r, os.FileMode(header.Mode))
+		case tar.TypeSymlink:
+			// Only allow relative links that stay within the runtime
+			err = checkLinkTarget(targetDir, target, header.Linkname)
+			if err == nil {
+				os.MkdirAll(filepath.Dir(target), 0755)
+				err = os.Symlink(header.Linkname, target)
+			}
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func checkLinkTarget(baseDir, link, linkTarget string) error {
+	if filepath.IsAbs(linkTarget) {
+		return fmt.Errorf("absolute link not allowed: %s -> %s", link, linkTarget)
+	}
+
+	base, err := filepath.Abs(baseDir)
+	if err != nil {
+		return err
+	}
+
+	resolved := filepath.Join(filepath.Dir(link), filepath.FromSlash(linkTarget))
+	if !strings.HasPrefix(resolved, base+string(filepath.Separator)) {
+		return fmt.Errorf("link escapes %s: %s -> %s", baseDir, link, linkTarget)
+	}
+	return nil
+}
+
+func writeFileWithMode(filename string, data io.Reader, mode os.FileMode) error {
+	err := os.MkdirAll(filepath.Dir(filename), 0755)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm()|0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, data)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
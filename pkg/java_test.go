@@ -0,0 +1,36 @@
+package pkg
+
+import "testing"
+
+func TestSnapshotRelease(t *testing.T) {
+	tests := []struct {
+		version string
+		want    string
+	}{
+		// Releases and pre-releases are returned as-is
+		{"1.16.5", "1.16.5"},
+		{"1.20.5-pre1", "1.20.5-pre1"},
+		{"1.18-rc3", "1.18-rc3"},
+
+		{"19w45a", "1.15"},
+		{"20w05a", "1.15"},
+		{"20w06a", "1.16"},
+		{"21w18a", "1.16"},
+		{"21w19a", "1.17"},
+		{"21w37a", "1.18"},
+		{"23w51b", "1.18"},
+		{"24w13a", "1.18"},
+		{"24w14a", "1.20.5"},
+		{"24w33a", "1.20.5"},
+
+		// Not snapshot IDs
+		{"24w14", "24w14"},
+		{"snapshot", "snapshot"},
+	}
+
+	for _, test := range tests {
+		if got := snapshotRelease(test.version); got != test.want {
+			t.Errorf("snapshotRelease(%q) = %q; expected %q", test.version, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package pkg
+
+import (
+	"fmt"
+	"strings"
+)
+
+// JVM settings for client profiles come from the manifest (x-mcdex.xmx, x-mcdex.xms, x-mcdex.javaArgs
+// and minecraft.javaArgs) and can be set from the command line with SetJVMOptions
+
+// SetJVMOptions overrides the heap sizes (e.g. 4G, 2048M) and adds extra JVM arguments for the
+// launcher profile/instance generated for the pack; empty values are ignored. The settings are
+// saved in the manifest, so later installs and updates keep them.
+func (pack *ModPack) SetJVMOptions(xmx, xms, javaArgs string) error {
+	for _, mem := range []string{xmx, xms} {
+		if mem != "" {
+			if _, err := parseMemory(mem); err != nil {
+				return err
+			}
+		}
+	}
+
+	pack.jvmXmx = xmx
+	pack.jvmXms = xms
+	pack.jvmArgs = javaArgs
+	return nil
+}
+
+// saveJVMOptions records the settings from SetJVMOptions in the manifest; they're kept until the
+// pack is given new ones. The manifest usually doesn't exist yet when they're set, so this waits
+// until the settings are used.
+func (pack *ModPack) saveJVMOptions() error {
+	changed := false
+	for field, value := range map[string]string{"xmx": pack.jvmXmx, "xms": pack.jvmXms, "javaArgs": pack.jvmArgs} {
+		if current, _ := mcdexString(pack.manifest, field); value != "" && value != current {
+			pack.manifest.Set(value, mcdexNamespace, field)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+	return pack.SaveManifest()
+}
+
+// jvmSettings returns the heap sizes (in megabytes, 0 if unset) and any other JVM arguments
+func (pack *ModPack) jvmSettings() (int, int, []string, error) {
+	err := pack.saveJVMOptions()
+	if err != nil {
+		return 0, 0, nil, err
+	}
+
+	var xmx, xms int
+	var args []string
+	manifestArgs, _ := pack.manifest.Path("minecraft.javaArgs").Data().(string)
+	extraArgs, _ := mcdexString(pack.manifest, "javaArgs")
+	for _, arg := range strings.Fields(manifestArgs + " " + extraArgs) {
+		// Pull heap sizes out of the arguments so they can be overridden
+		switch {
+		case strings.HasPrefix(arg, "-Xmx"):
+			xmx, err = parseMemory(arg[4:])
+		case strings.HasPrefix(arg, "-Xms"):
+			xms, err = parseMemory(arg[4:])
+		default:
+			args = append(args, arg)
+		}
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("invalid Java argument %s in manifest: %+v", arg, err)
+		}
+	}
+
+	if value, ok := mcdexString(pack.manifest, "xmx"); ok {
+		xmx, err = parseMemory(value)
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("invalid %s.xmx in manifest: %+v", mcdexNamespace, err)
+		}
+	}
+	if value, ok := mcdexString(pack.manifest, "xms"); ok {
+		xms, err = parseMemory(value)
+		if err != nil {
+			return 0, 0, nil, fmt.Errorf("invalid %s.xms in manifest: %+v", mcdexNamespace, err)
+		}
+	}
+
+	return xmx, xms, args, nil
+}
+
+// launcherJavaArgs combines the JVM settings into a single argument string
+func (pack *ModPack) launcherJavaArgs() (string, error) {
+	xmx, xms, args, err := pack.jvmSettings()
+	if err != nil {
+		return "", err
+	}
+
+	if xms > 0 {
+		args = append([]string{fmt.Sprintf("-Xms%dM", xms)}, args...)
+	}
+	if xmx > 0 {
+		args = append([]string{fmt.Sprintf("-Xmx%dM", xmx)}, args...)
+	}
+	return strings.Join(args, " "), nil
+}
+
+// applyMMCJVMSettings records the JVM settings in a MultiMC instance.cfg
+func (pack *ModPack) applyMMCJVMSettings(instFile string) error {
+	xmx, xms, args, err := pack.jvmSettings()
+	if err != nil {
+		return err
+	}
+
+	settings := make(map[string]string)
+	if xmx > 0 || xms > 0 {
+		settings["OverrideMemory"] = "true"
+		if xmx > 0 {
+			settings["MaxMemAlloc"] = fmt.Sprint(xmx)
+		}
+		if xms > 0 {
+			settings["MinMemAlloc"] = fmt.Sprint(xms)
+		}
+	}
+	if len(args) > 0 {
+		settings["OverrideJavaArgs"] = "true"
+		settings["JvmArgs"] = strings.Join(args, " ")
+	}
+
+	for _, key := range []string{"OverrideMemory", "MaxMemAlloc", "MinMemAlloc", "OverrideJavaArgs", "JvmArgs"} {
+		value, ok := settings[key]
+		if !ok {
+			continue
+		}
+
+		err = setMMCInstanceKey(instFile, key, value)
+		if err != nil {
+			return fmt.Errorf("failed to update instance.cfg: %+v", err)
+		}
+	}
+	return nil
+}
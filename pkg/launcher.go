@@ -18,25 +18,56 @@
 package pkg
 
 import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"regexp"
+	"time"
 
 	"github.com/Jeffail/gabs"
 )
 
 var nameRegex = regexp.MustCompile("^[\\w][\\w-.]*$")
 
+// Launcher installs from the Microsoft Store keep their profiles in a separate file; if both
+// exist, the most recently modified one is the one in use
+var launcherProfileFiles = []string{"launcher_profiles.json", "launcher_profiles_microsoft_store.json"}
+
+// Format version written by current launchers
+const launcherProfilesVersion = 3
+
 type launcherConfig struct {
 	data      *gabs.Container
 	filename  string
 	nameRegex *regexp.Regexp
 }
 
+// launcherProfilesFile determines which launcher_profiles file is in use
+func launcherProfilesFile() string {
+	var result string
+	var newest time.Time
+	for _, name := range launcherProfileFiles {
+		filename := filepath.Join(Env().MinecraftDir, name)
+		info, err := os.Stat(filename)
+		if err == nil && (result == "" || info.ModTime().After(newest)) {
+			result = filename
+			newest = info.ModTime()
+		}
+	}
+
+	if result == "" {
+		return filepath.Join(Env().MinecraftDir, launcherProfileFiles[0])
+	}
+	return result
+}
+
 func newLauncherConfig() (*launcherConfig, error) {
 	lc := new(launcherConfig)
-	lc.filename = filepath.Join(Env().MinecraftDir, "launcher_profiles.json")
+	lc.filename = launcherProfilesFile()
 	lc.data = gabs.New()
 
 	if fileExists(lc.filename) {
@@ -45,27 +76,92 @@ func newLauncherConfig() (*launcherConfig, error) {
 			return nil, err
 		}
 
+		// Everything in the file is preserved as-is; we only touch our own profile
 		lc.data, err = gabs.ParseJSON(rawdata)
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("failed to parse %s: %+v", lc.filename, err)
 		}
+	} else {
+		lc.data.Set(launcherProfilesVersion, "version")
+		lc.data.Set(map[string]interface{}{}, "settings")
+	}
+
+	if !lc.data.Exists("profiles") {
+		lc.data.Set(map[string]interface{}{}, "profiles")
 	}
 	return lc, nil
 }
 
-func (lc *launcherConfig) createProfile(name, version, gameDir, javaArgs string) error {
+// profileKey finds the key of an existing profile for the pack (matching either the key, the name
+// or the game directory); new profiles get a random key, like the ones the launcher generates
+func (lc *launcherConfig) profileKey(name, gameDir string) string {
+	profiles, _ := lc.data.S("profiles").ChildrenMap()
+	if _, ok := profiles[name]; ok {
+		return name
+	}
+
+	for key, profile := range profiles {
+		profileName, _ := profile.S("name").Data().(string)
+		profileDir, _ := profile.S("gameDir").Data().(string)
+		if profileName == name || (profileDir != "" && filepath.Clean(profileDir) == filepath.Clean(gameDir)) {
+			return key
+		}
+	}
+
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// Built-in launcher icon used when the pack doesn't provide one
+const defaultProfileIcon = "Furnace"
+
+// The launcher's timestamps are ISO 8601 with milliseconds
+const launcherTimeFormat = "2006-01-02T15:04:05.000Z"
+
+func (lc *launcherConfig) createProfile(name, version, gameDir, javaArgs, icon string) error {
 	if !nameRegex.MatchString(name) {
 		return fmt.Errorf("invalid profile name: %s", name)
 	}
-	lc.data.Set(name, "profiles", name, "name")
-	lc.data.Set(version, "profiles", name, "lastVersionId")
-	lc.data.Set(gameDir, "profiles", name, "gameDir")
+
+	key := lc.profileKey(name, gameDir)
+	now := time.Now().UTC().Format(launcherTimeFormat)
+	if !lc.data.Exists("profiles", key, "created") {
+		lc.data.Set(now, "profiles", key, "created")
+	}
+	lc.data.Set(now, "profiles", key, "lastUsed")
+	lc.data.Set("custom", "profiles", key, "type")
+	lc.data.Set(name, "profiles", key, "name")
+	lc.data.Set(version, "profiles", key, "lastVersionId")
+	lc.data.Set(gameDir, "profiles", key, "gameDir")
+	if icon == "" {
+		icon = defaultProfileIcon
+	}
+	lc.data.Set(icon, "profiles", key, "icon")
 	if javaArgs != "" {
-		lc.data.Set(javaArgs, "profiles", name, "javaArgs")
+		lc.data.Set(javaArgs, "profiles", key, "javaArgs")
 	}
 	return nil
 }
 
+// profileIcon returns the icon in the given file (if any) as a data URI, as the launcher expects
+// for custom icons
+func profileIcon(filename string) string {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return ""
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(data)
+}
+
+// save writes the profiles to a temporary file and moves it into place, so that a failure
+// part-way through can't leave the launcher with a truncated file
 func (lc *launcherConfig) save() error {
-	return writeJSON(lc.data, lc.filename)
+	tmpFilename := lc.filename + ".tmp"
+	err := writeJSON(lc.data, tmpFilename)
+	if err != nil {
+		os.Remove(tmpFilename)
+		return err
+	}
+	return os.Rename(tmpFilename, lc.filename)
 }
@@ -0,0 +1,373 @@
+package pkg
+
+import (
+	"context"
+	"database/sql"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/gabs"
+)
+
+const (
+	forgeMaven      = "https://maven.minecraftforge.net"
+	forgePromotions = "https://files.minecraftforge.net/net/minecraftforge/forge/promotions_slim.json"
+	neoForgeMaven   = "https://maven.neoforged.net/releases"
+	quiltMaven      = "https://maven.quiltmc.org/repository/release"
+)
+
+// Loader metadata fetched from the web is cached in the database for a while, then revalidated
+// with its ETag (so an unchanged document isn't downloaded again); if the site can't be reached the
+// cached copy is used regardless of age
+const metadataCacheTable = "metadata_cache"
+const metadataCacheTTL = 6 * time.Hour
+
+// metadataCacheSchema creates the metadata cache; databases from older versions of mcdex have
+// the table without the etag column, so it's added by initMetadataCache
+var metadataCacheSchema = fmt.Sprintf("CREATE TABLE %s(url PRIMARY KEY, data, tstamp INT, etag)", metadataCacheTable)
+
+// initMetadataCache creates the metadata cache, or adds the etag column to an older one
+func (db *Database) initMetadataCache() error {
+	if !db.hasTable(metadataCacheTable) {
+		_, err := db.sqlDb.Exec(metadataCacheSchema)
+		if err != nil {
+			return fmt.Errorf("failed to create metadata cache: %+v", err)
+		}
+		return nil
+	}
+
+	if !db.hasColumn(metadataCacheTable, "etag") {
+		_, err := db.sqlDb.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN etag", metadataCacheTable))
+		if err != nil {
+			return fmt.Errorf("failed to upgrade metadata cache: %+v", err)
+		}
+	}
+	return nil
+}
+
+// getCachedURL retrieves a document from a URL, going through the database's metadata cache
+func (db *Database) getCachedURL(ctx context.Context, url string) (string, error) {
+	return db.getCachedURLWithTTL(ctx, url, metadataCacheTTL)
+}
+
+// lookupCachedURL returns the cached copy of a URL (if any), along with its ETag and the time it
+// was last fetched or revalidated
+func (db *Database) lookupCachedURL(url string) (data, etag string, tstamp int64, cached bool, err error) {
+	err = db.initMetadataCache()
+	if err != nil {
+		return "", "", 0, false, err
+	}
+
+	var dbEtag sql.NullString
+	err = db.sqlDb.QueryRow(fmt.Sprintf("SELECT data, tstamp, etag FROM %s WHERE url = ?", metadataCacheTable), url).Scan(&data, &tstamp, &dbEtag)
+	switch {
+	case err == sql.ErrNoRows:
+		return "", "", 0, false, nil
+	case err != nil:
+		return "", "", 0, false, err
+	}
+	return data, dbEtag.String, tstamp, true, nil
+}
+
+// getCachedURLWithTTL retrieves a document from a URL through the metadata cache. A copy younger
+// than the TTL is used as-is; older copies are revalidated with the site.
+func (db *Database) getCachedURLWithTTL(ctx context.Context, url string, ttl time.Duration) (string, error) {
+	data, etag, tstamp, cached, err := db.lookupCachedURL(url)
+	if err != nil {
+		return "", err
+	}
+
+	if cached && time.Since(time.Unix(tstamp, 0)) < ttl {
+		return data, nil
+	}
+
+	fresh, freshEtag, changed, err := readChangedStringFromUrl(ctx, url, etag)
+	if err != nil {
+		if cached {
+			logWarn("Using cached copy of %s: %+v", url, err)
+			return data, nil
+		}
+		return "", err
+	}
+
+	if changed {
+		data = fresh
+		etag = freshEtag
+	} else {
+		logDebug("%s is unchanged; using cached copy", url)
+	}
+
+	// Failing to cache the document doesn't stop the install, but it'll be downloaded again next time
+	_, err = db.sqlDb.Exec(fmt.Sprintf("INSERT OR REPLACE INTO %s(url, data, tstamp, etag) VALUES (?, ?, ?, ?)", metadataCacheTable),
+		url, data, time.Now().Unix(), etag)
+	if err != nil {
+		warn(WarnOther, "unable to cache %s: %+v", url, err)
+	}
+	return data, nil
+}
+
+// readChangedStringFromUrl retrieves a document unless its ETag still matches; changed is false
+// when the site reports that the cached copy is current
+func readChangedStringFromUrl(ctx context.Context, url, etag string) (data, newEtag string, changed bool, err error) {
+	req, err := newGetRequest(ctx, url)
+	if err != nil {
+		return "", "", false, err
+	}
+	if etag != "" {
+		req.Header.Add("If-None-Match", etag)
+	}
+
+	res, err := getterClient.Do(req)
+	if err != nil {
+		return "", "", false, fmt.Errorf("Failed to read string from %s: %+v", url, err)
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotModified && etag != "":
+		return "", etag, false, nil
+	case res.StatusCode != http.StatusOK:
+		return "", "", false, fmt.Errorf("Failed to read string from %s: HTTP %d", url, res.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return "", "", false, fmt.Errorf("Failed to read string from %s: %+v", url, err)
+	}
+	return string(body), res.Header.Get("ETag"), true, nil
+}
+
+// getMavenVersions lists the versions of a module in a maven repository, using the cache
+func (db *Database) getMavenVersions(ctx context.Context, repo, module string) ([]string, error) {
+	m, err := NewMavenModule(module)
+	if err != nil {
+		return nil, err
+	}
+
+	metadataUrl, err := urlJoin(repo, path.Join(strings.Split(m.groupId, ".")...), m.artifactId, "maven-metadata.xml")
+	if err != nil {
+		return nil, err
+	}
+
+	metadataXml, err := db.getCachedURL(ctx, metadataUrl)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve %s: %+v", metadataUrl, err)
+	}
+
+	var metadata MavenMetadata
+	err = xml.Unmarshal([]byte(metadataXml), &metadata)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %+v", metadataUrl, err)
+	}
+	return metadata.VersionInfo.Versions, nil
+}
+
+// getLiveForgeVersions looks up the versions of Forge for a Minecraft version on Forge's own site;
+// used for Minecraft versions that are newer than the database. Which version is recommended comes
+// from Forge's promotions.
+func (db *Database) getLiveForgeVersions(ctx context.Context, mcvsn string) ([]forgeVersion, error) {
+	versions, err := db.getMavenVersions(ctx, forgeMaven, "net.minecraftforge:forge")
+	if err != nil {
+		return nil, err
+	}
+
+	var recommended string
+	promotions, err := db.getCachedURL(ctx, forgePromotions)
+	if err == nil {
+		if promos, err := gabs.ParseJSON([]byte(promotions)); err == nil {
+			recommended, _ = promos.Search("promos", mcvsn+"-recommended").Data().(string)
+		}
+	} else {
+		logDebug("Unable to retrieve Forge promotions: %+v", err)
+	}
+
+	// Maven versions are <minecraft version>-<forge version>, with the Minecraft version repeated at
+	// the end for some older builds
+	var result []forgeVersion
+	for _, v := range versions {
+		if !strings.HasPrefix(v, mcvsn+"-") {
+			continue
+		}
+		vsn := strings.TrimSuffix(strings.TrimPrefix(v, mcvsn+"-"), "-"+mcvsn)
+		result = append(result, forgeVersion{version: vsn, isrec: vsn == recommended})
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return compareVersions(result[i].version, result[j].version) > 0
+	})
+	return result, nil
+}
+
+// getLiveFabricVersions looks up the Fabric loader builds that support a Minecraft version on
+// Fabric's meta service, most recent first; used for versions (such as snapshots) that aren't in
+// the database. Only versions listed in Mojang's manifest are looked up.
+func (db *Database) getLiveFabricVersions(ctx context.Context, mcvsn string) ([]fabricLoaderVersion, error) {
+	published, err := isPublishedVersion(ctx, mcvsn)
+	if err != nil {
+		return nil, err
+	}
+	if !published {
+		return nil, fmt.Errorf("unknown Minecraft version %s", mcvsn)
+	}
+
+	url := fmt.Sprintf("%s/versions/loader/%s", fabricMetaURL, url.PathEscape(mcvsn))
+	data, err := db.getCachedURL(ctx, url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to retrieve %s: %+v", url, err)
+	}
+
+	builds, err := gabs.ParseJSON([]byte(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %+v", url, err)
+	}
+
+	var result []fabricLoaderVersion
+	children, _ := builds.Children()
+	for _, build := range children {
+		version, ok := build.Path("loader.version").Data().(string)
+		if !ok {
+			continue
+		}
+		stable, _ := build.Path("loader.stable").Data().(bool)
+		result = append(result, fabricLoaderVersion{version, stable})
+	}
+	return result, nil
+}
+
+type fabricLoaderVersion struct {
+	version string
+	stable  bool
+}
+
+// getNeoForgeVersions returns the versions of NeoForge for a Minecraft version, most recent first.
+// NeoForge versions start with the Minecraft version, minus the leading "1." (e.g. 20.4.80 is for
+// 1.20.4, 21.0.1 for 1.21); NeoForge for 1.20.1 was published under the old Forge name instead.
+func (db *Database) getNeoForgeVersions(ctx context.Context, mcvsn string) ([]string, error) {
+	var result []string
+	if mcvsn == "1.20.1" {
+		versions, err := db.getMavenVersions(ctx, neoForgeMaven, "net.neoforged:forge")
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range versions {
+			if strings.HasPrefix(v, mcvsn+"-") {
+				result = append(result, strings.TrimPrefix(v, mcvsn+"-"))
+			}
+		}
+	} else {
+		versions, err := db.getMavenVersions(ctx, neoForgeMaven, "net.neoforged:neoforge")
+		if err != nil {
+			return nil, err
+		}
+
+		prefix := strings.TrimPrefix(mcvsn, "1.")
+		if !strings.Contains(prefix, ".") {
+			prefix += ".0"
+		}
+		for _, v := range versions {
+			if strings.HasPrefix(v, prefix+".") {
+				result = append(result, v)
+			}
+		}
+	}
+
+	sortVersionsDesc(result)
+	return result, nil
+}
+
+// getQuiltVersions returns the versions of the Quilt loader, most recent first; like Fabric, loader
+// builds aren't tied to a version of Minecraft, but Quilt only supports the versions of Minecraft it
+// publishes mappings for
+func (db *Database) getQuiltVersions(ctx context.Context, mcvsn string) ([]string, error) {
+	mappings, err := db.getMavenVersions(ctx, quiltMaven, "org.quiltmc:hashed")
+	if err != nil {
+		return nil, err
+	}
+
+	supported := false
+	for _, v := range mappings {
+		if v == mcvsn {
+			supported = true
+			break
+		}
+	}
+	if !supported {
+		return nil, nil
+	}
+
+	result, err := db.getMavenVersions(ctx, quiltMaven, "org.quiltmc:quilt-loader")
+	if err != nil {
+		return nil, err
+	}
+
+	sortVersionsDesc(result)
+	return result, nil
+}
+
+func (db *Database) ListNeoForge(ctx context.Context, mcvsn string, verbose bool) error {
+	versions, err := db.getNeoForgeVersions(ctx, mcvsn)
+	if err != nil {
+		return err
+	}
+
+	if len(versions) == 0 {
+		return fmt.Errorf("No NeoForge version found for %s", mcvsn)
+	}
+
+	listLoaderVersions(versions, verbose)
+	return nil
+}
+
+func (db *Database) ListQuilt(ctx context.Context, mcvsn string, verbose bool) error {
+	versions, err := db.getQuiltVersions(ctx, mcvsn)
+	if err != nil {
+		return err
+	}
+
+	if len(versions) == 0 {
+		return fmt.Errorf("No Quilt version found for %s", mcvsn)
+	}
+
+	listLoaderVersions(versions, verbose)
+	return nil
+}
+
+// listLoaderVersions prints the latest stable version of a loader (and any newer beta); all the
+// other versions are only printed when verbose
+func listLoaderVersions(versions []string, verbose bool) {
+	latest := false
+	for _, v := range versions {
+		beta := strings.Contains(v, "-")
+		if !latest && !beta {
+			fmt.Fprintf(consoleOutput, "%s (latest)\n", v)
+			latest = true
+		} else if !latest && v == versions[0] {
+			fmt.Fprintf(consoleOutput, "%s (latest beta)\n", v)
+		} else if verbose {
+			fmt.Fprintf(consoleOutput, "%s\n", v)
+		}
+	}
+}
+
+// sortVersionsDesc sorts versions most recent first; a pre-release (e.g. 0.26.0-beta.1) comes
+// before the release it leads up to
+func sortVersionsDesc(versions []string) {
+	sort.Slice(versions, func(i, j int) bool {
+		a := strings.SplitN(versions[i], "-", 2)
+		b := strings.SplitN(versions[j], "-", 2)
+		if c := compareVersions(a[0], b[0]); c != 0 {
+			return c > 0
+		}
+		if len(a) != len(b) {
+			return len(a) < len(b)
+		}
+		return compareVersions(versions[i], versions[j]) > 0
+	})
+}
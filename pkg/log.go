@@ -0,0 +1,188 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// LogLevel controls which status messages are written; messages below the current level are
+// dropped
+type LogLevel int
+
+const (
+	LogDebug LogLevel = iota
+	LogInfo
+	LogWarn
+	LogError
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+func (l LogLevel) String() string {
+	if l < LogDebug || l > LogError {
+		return fmt.Sprintf("level(%d)", int(l))
+	}
+	return logLevelNames[l]
+}
+
+// ParseLogLevel converts the name of a level (debug, info, warn or error) to a LogLevel
+func ParseLogLevel(name string) (LogLevel, error) {
+	for i, levelName := range logLevelNames {
+		if strings.EqualFold(name, levelName) {
+			return LogLevel(i), nil
+		}
+	}
+	return LogInfo, fmt.Errorf("unknown log level %s; must be one of %s", name, strings.Join(logLevelNames, "|"))
+}
+
+const (
+	LogFormatText = "text"
+	LogFormatJSON = "json"
+)
+
+var logLevel = LogInfo
+var logFormat = LogFormatText
+
+// Every message is also written to the log file (if any), regardless of level, so it has
+// everything needed to troubleshoot an install
+var logFile io.WriteCloser
+
+// SetLogLevel sets the minimum level of messages written to the output
+func SetLogLevel(level LogLevel) {
+	logLevel = level
+}
+
+// SetLogFormat chooses between human readable messages (text) and one JSON object per
+// message (json); JSON messages include a timestamp and level, and progress bars are disabled
+func SetLogFormat(format string) error {
+	switch format {
+	case LogFormatText, LogFormatJSON:
+		logFormat = format
+		return nil
+	}
+	return fmt.Errorf("unknown log format %s; must be %s or %s", format, LogFormatText, LogFormatJSON)
+}
+
+// SetLogFile appends all messages (including debug messages) to a file, in the current log format
+func SetLogFile(filename string) error {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file %s: %+v", filename, err)
+	}
+
+	consoleLock.Lock()
+	defer consoleLock.Unlock()
+	if logFile != nil {
+		logFile.Close()
+	}
+	logFile = f
+	return nil
+}
+
+// CloseLogFile stops writing messages to the log file
+func CloseLogFile() {
+	consoleLock.Lock()
+	defer consoleLock.Unlock()
+	if logFile != nil {
+		logFile.Close()
+		logFile = nil
+	}
+}
+
+// Log writes a message at the given level; programs embedding mcdex can use it to interleave their
+// own messages with mcdex's
+func Log(level LogLevel, format string, values ...interface{}) {
+	consoleLock.Lock()
+	defer consoleLock.Unlock()
+	writeLog(level, styleNone, strings.TrimRight(fmt.Sprintf(format, values...), "\n"), false)
+}
+
+// logStyled writes a message in a style other than its level's (e.g. files being downloaded)
+func logStyled(level LogLevel, style string, format string, values ...interface{}) {
+	consoleLock.Lock()
+	defer consoleLock.Unlock()
+	writeLog(level, style, strings.TrimRight(fmt.Sprintf(format, values...), "\n"), false)
+}
+
+func logDebug(format string, values ...interface{}) {
+	Log(LogDebug, format, values...)
+}
+
+func logInfo(format string, values ...interface{}) {
+	Log(LogInfo, format, values...)
+}
+
+func logWarn(format string, values ...interface{}) {
+	Log(LogWarn, format, values...)
+}
+
+func logError(format string, values ...interface{}) {
+	Log(LogError, format, values...)
+}
+
+// writeLog writes a message to the output and the log file; transient messages (see logAction)
+// are redrawn in place on a terminal. Messages are colored on a terminal, in the given style or their
+// level's. The caller must hold consoleLock.
+func writeLog(level LogLevel, style string, msg string, transient bool) {
+	now := time.Now()
+	if logFile != nil {
+		fmt.Fprintln(logFile, formatLogRecord(now, level, msg, true))
+	}
+
+	if level < logLevel {
+		return
+	}
+
+	// Warnings and errors go to stderr, so they're seen even when the output is redirected
+	output := consoleOutput
+	if level >= LogWarn {
+		output = errorOutput
+	}
+
+	CONSOLE.Clear()
+	if logFormat == LogFormatJSON {
+		fmt.Fprintln(output, formatLogRecord(now, level, msg, false))
+		return
+	}
+
+	switch level {
+	case LogWarn:
+		msg = "WARNING: " + msg
+	case LogError:
+		msg = "ERROR: " + msg
+	}
+	if style == styleNone {
+		style = levelStyle(level)
+	}
+	msg = colorize(output, style, msg)
+
+	// Only a terminal can redraw; elsewhere (e.g. a pipe) the escape codes would be noise
+	if transient && isTerminalOutput() {
+		fmt.Fprintln(CONSOLE, msg)
+		CONSOLE.Print()
+	} else {
+		fmt.Fprintln(output, msg)
+	}
+}
+
+// formatLogRecord formats a message in the current log format; text messages in the log file
+// are prefixed with the time and level
+func formatLogRecord(t time.Time, level LogLevel, msg string, toFile bool) string {
+	if logFormat == LogFormatJSON {
+		data, _ := json.Marshal(map[string]string{
+			"time":  t.Format(time.RFC3339),
+			"level": level.String(),
+			"msg":   msg,
+		})
+		return string(data)
+	}
+
+	if toFile {
+		return fmt.Sprintf("%s %-5s %s", t.Format(time.RFC3339), strings.ToUpper(level.String()), msg)
+	}
+	return msg
+}
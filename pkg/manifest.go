@@ -0,0 +1,153 @@
+package pkg
+
+import (
+	"sort"
+
+	"github.com/Jeffail/gabs"
+)
+
+// Fields that are specific to mcdex are stored under this key (both at the top level of the manifest
+// and in each file entry) so that they don't collide with fields used by other tools
+const mcdexNamespace = "x-mcdex"
+
+// Entries for mods from Modrinth and external URLs are kept in the mcdex namespace rather than the
+// files list, which launchers importing the manifest expect to hold only CurseForge files
+var mcdexFilesPath = []string{mcdexNamespace, "files"}
+
+// isMcdexFileSource reports if entries from a source are kept in the mcdex namespace
+func isMcdexFileSource(source string) bool {
+	return source == "modrinth" || source == "ext"
+}
+
+// manifestFiles lists the entries of all the mods in a manifest: the files list, followed by the
+// entries in the mcdex namespace
+func manifestFiles(manifest *gabs.Container) []*gabs.Container {
+	files, _ := manifest.S("files").Children()
+	mcdexFiles, _ := manifest.Search(mcdexFilesPath...).Children()
+	return append(files, mcdexFiles...)
+}
+
+// setManifestFiles replaces the mod entries of a manifest, putting each in the list for its source
+func setManifestFiles(manifest *gabs.Container, entries []*gabs.Container) {
+	files := []interface{}{}
+	var mcdexFiles []interface{}
+	for _, entry := range entries {
+		if isMcdexFileSource(sourceForEntry(entry)) {
+			mcdexFiles = append(mcdexFiles, entry.Data())
+		} else {
+			files = append(files, entry.Data())
+		}
+	}
+
+	manifest.Set(files, "files")
+	if len(mcdexFiles) > 0 {
+		manifest.Set(mcdexFiles, mcdexFilesPath...)
+		return
+	}
+	manifest.Delete(mcdexFilesPath...)
+	if fields, _ := manifest.S(mcdexNamespace).ChildrenMap(); manifest.Exists(mcdexNamespace) && len(fields) == 0 {
+		manifest.Delete(mcdexNamespace)
+	}
+}
+
+// addManifestFile adds a mod entry to a manifest
+func addManifestFile(manifest *gabs.Container, entry map[string]interface{}) {
+	c, _ := gabs.Consume(entry)
+	setManifestFiles(manifest, append(manifestFiles(manifest), c))
+}
+
+// Fields that older versions of mcdex stored directly in each file entry
+var legacyFileFields = []string{"clientOnly", "locked", "desc"}
+
+// Fields that older versions of mcdex stored at the top level of the manifest
+var legacyManifestFields = []string{"extfiles"}
+
+// migrateManifest moves any mcdex-specific fields from their legacy locations into the mcdex
+// namespace, and converts legacy external files into file entries; returns true if anything was
+// changed
+func migrateManifest(manifest *gabs.Container) bool {
+	changed := false
+
+	for _, field := range legacyManifestFields {
+		if manifest.Exists(field) {
+			manifest.Set(manifest.S(field).Data(), mcdexNamespace, field)
+			manifest.Delete(field)
+			changed = true
+		}
+	}
+
+	files, _ := manifest.S("files").Children()
+	for _, f := range files {
+		for _, field := range legacyFileFields {
+			if f.Exists(field) {
+				f.Set(f.S(field).Data(), mcdexNamespace, field)
+				f.Delete(field)
+				changed = true
+			}
+		}
+	}
+
+	// Modrinth and external files used to be kept in the files list
+	for _, f := range files {
+		if isMcdexFileSource(sourceForEntry(f)) {
+			setManifestFiles(manifest, manifestFiles(manifest))
+			changed = true
+			break
+		}
+	}
+
+	// The extfiles map only recorded a URL for each external file; they're now entries like any
+	// other mod (see ExtModFile)
+	if extfiles, ok := manifest.Search(mcdexNamespace, "extfiles").Data().(map[string]interface{}); ok {
+		var names []string
+		for name := range extfiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		manifest.Delete(mcdexNamespace, "extfiles")
+		entries := manifestFiles(manifest)
+		for _, name := range names {
+			url, _ := extfiles[name].(string)
+			entry, _ := gabs.Consume(ExtModFile{name: name, url: url}.toJson())
+			entries = append(entries, entry)
+		}
+		setManifestFiles(manifest, entries)
+		changed = true
+	}
+
+	return changed
+}
+
+// mcdexValue returns a mcdex-specific field from a manifest or file entry
+func mcdexValue(c *gabs.Container, field string) interface{} {
+	return c.Search(mcdexNamespace, field).Data()
+}
+
+func mcdexBool(c *gabs.Container, field string) bool {
+	value, ok := mcdexValue(c, field).(bool)
+	return ok && value
+}
+
+func mcdexString(c *gabs.Container, field string) (string, bool) {
+	value, ok := mcdexValue(c, field).(string)
+	return value, ok
+}
+
+// isRequired reports if a file entry is required; CurseForge manifests mark optional mods with
+// "required": false
+func isRequired(c *gabs.Container) bool {
+	value, ok := c.Path("required").Data().(bool)
+	return !ok || value
+}
+
+// mcdexFields returns (creating if necessary) the mcdex namespace in a JSON map being
+// built for a file entry
+func mcdexFields(entry map[string]interface{}) map[string]interface{} {
+	fields, ok := entry[mcdexNamespace].(map[string]interface{})
+	if !ok {
+		fields = make(map[string]interface{})
+		entry[mcdexNamespace] = fields
+	}
+	return fields
+}
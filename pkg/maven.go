@@ -1,6 +1,7 @@
 package pkg
 
 import (
+	"context"
 	"encoding/xml"
 	"fmt"
 	"path"
@@ -29,6 +30,28 @@ type MavenMetadataVersionInfo struct {
 	Versions []string `xml:"versions>version"`
 }
 
+// latestVersion returns the most recent release of a module; repositories that don't record one
+// fall back to the latest version, or the last one listed
+func (m MavenMetadata) latestVersion() string {
+	switch {
+	case m.VersionInfo.Release != "":
+		return m.VersionInfo.Release
+	case m.VersionInfo.Latest != "":
+		return m.VersionInfo.Latest
+	case len(m.VersionInfo.Versions) > 0:
+		return m.VersionInfo.Versions[len(m.VersionInfo.Versions)-1]
+	}
+	return ""
+}
+
+// latestMatchingVersion returns the most recent version of a module that meets a constraint
+func (m MavenMetadata) latestMatchingVersion(constraint string) (string, error) {
+	if constraint == "" {
+		return m.latestVersion(), nil
+	}
+	return latestMatchingVersion(m.VersionInfo.Versions, constraint)
+}
+
 func NewMavenModule(module string) (MavenModule, error) {
 	// First, break up the string into maven components: group, artifact and version
 	parts := strings.SplitN(module, ":", 3)
@@ -102,14 +125,55 @@ func (m MavenModule) toVersionPath(repo string, version string, extension string
 	return m.toRepositoryPath(repo)
 }
 
-func (m MavenModule) loadMetadata(repo string) (MavenMetadata, error) {
+// Maven repositories publish checksums alongside each artifact; SHA-256 is preferred, but many
+// repositories only publish SHA-1
+var mavenChecksums = []struct {
+	extension string
+	hashFile  func(string) (string, error)
+}{
+	{".sha256", sha256File},
+	{".sha1", sha1File},
+}
+
+// verifyMavenChecksum checks a downloaded artifact against the checksum published next to it in the
+// repository; an artifact without a checksum is accepted with a warning
+func verifyMavenChecksum(ctx context.Context, url, filename string) error {
+	for _, checksum := range mavenChecksums {
+		published, err := ReadStringFromUrl(ctx, url + checksum.extension)
+		if err != nil {
+			logDebug("No %s checksum for %s: %+v", checksum.extension, url, err)
+			continue
+		}
+
+		// Checksum files may also contain the name of the file after the hash
+		fields := strings.Fields(published)
+		if len(fields) == 0 {
+			continue
+		}
+		expected := strings.ToLower(fields[0])
+
+		actual, err := checksum.hashFile(filename)
+		if err != nil {
+			return fmt.Errorf("failed to verify %s: %+v", path.Base(url), err)
+		}
+		if actual != expected {
+			return fmt.Errorf("checksum mismatch for %s: expected %s, got %s (from %s)", path.Base(url), expected, actual, url+checksum.extension)
+		}
+		return nil
+	}
+
+	warn(WarnOther, "%s has no published checksum; unable to verify it", path.Base(url))
+	return nil
+}
+
+func (m MavenModule) loadMetadata(ctx context.Context, repo string) (MavenMetadata, error) {
 	groupPath := path.Join(strings.Split(m.groupId, ".")...)
 	metadataUrl, err := urlJoin(repo, groupPath, m.artifactId, "maven-metadata.xml")
 	if err != nil {
 		return MavenMetadata{}, err
 	}
 
-	metadataXml, err := ReadStringFromUrl(metadataUrl)
+	metadataXml, err := ReadStringFromUrl(ctx, metadataUrl)
 	if err != nil {
 		return MavenMetadata{}, fmt.Errorf("unable to retrieve %s: %+v", metadataUrl, err)
 	}
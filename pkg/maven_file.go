@@ -1,7 +1,11 @@
 package pkg
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"path"
+	"path/filepath"
 
 	"github.com/Jeffail/gabs"
 )
@@ -10,9 +14,12 @@ type MavenModFile struct {
 	module     MavenModule
 	url        string
 	clientOnly bool
+	serverOnly bool
+	optional   bool
+	pinned     bool // the version was chosen when the mod was selected, so it isn't updated
 }
 
-func SelectMavenModFile(pack *ModPack, mod string, url string, clientOnly bool) error {
+func SelectMavenModFile(ctx context.Context, pack *ModPack, mod string, url string, clientOnly bool) error {
 	module, err := NewMavenModule(mod)
 	if err != nil {
 		return fmt.Errorf("invalid module %s: %+v", mod, err)
@@ -23,16 +30,20 @@ func SelectMavenModFile(pack *ModPack, mod string, url string, clientOnly bool)
 	}
 
 	// If no version is provided, load metadata
-	if module.version == "" {
-		metadata, err := module.loadMetadata(url)
+	pinned := module.version != ""
+	if !pinned {
+		metadata, err := module.loadMetadata(ctx, url)
 		if err != nil {
 			return fmt.Errorf("failed to load metadata for %s: %+v", mod, err)
 		}
 
-		module.version = metadata.VersionInfo.Release
+		module.version, err = metadata.latestMatchingVersion(pack.modConstraint(ctx, &MavenModFile{module: module}, ""))
+		if err != nil {
+			return fmt.Errorf("failed to select version of %s: %+v", mod, err)
+		}
 	}
 
-	return pack.selectMod(&MavenModFile{module, url, clientOnly})
+	return pack.selectMod(&MavenModFile{module, url, clientOnly, false, false, pinned})
 }
 
 func NewMavenModFile(modJson *gabs.Container) *MavenModFile {
@@ -44,24 +55,87 @@ func NewMavenModFile(modJson *gabs.Container) *MavenModFile {
 	if !ok {
 		url = "https://files.mcdex.net/maven2"
 	}
-	clientOnly, ok := modJson.Path("clientOnly").Data().(bool)
-	return &MavenModFile{module, url, ok && clientOnly}
+	return &MavenModFile{module, url, mcdexBool(modJson, "clientOnly"), mcdexBool(modJson, "serverOnly"), !isRequired(modJson), mcdexBool(modJson, "pinned")}
 }
 
-func (f MavenModFile) install(pack *ModPack) error {
+func (f MavenModFile) install(ctx context.Context, pack *ModPack) error {
 	// If no version is specified, bail
 	if f.module.version == "" {
 		return fmt.Errorf("no version specified for %s", f.module)
 	}
 
-	// Download it
+	// If the user disabled this version of the mod, there's nothing to do
+	if fileExists(filepath.Join(pack.modPath(), f.installedFilename(pack)+disabledSuffix)) {
+		logDebug("Skipping %s (disabled)\n", f.getName())
+		return nil
+	}
+
+	// If a different version was installed before, clean it up
 	downloadUrl, _ := f.module.toRepositoryPath(f.url)
-	_, err := downloadHttpFileToDir(downloadUrl, pack.modPath(), true)
-	return err
+	lastURL, _ := pack.modCache.GetLastExtURL(f.cacheKey())
+	if lastURL != "" && lastURL != downloadUrl {
+		pack.modCache.CleanupExtFile(f.cacheKey())
+	}
+
+	filename := f.installedFilename(pack)
+	if fileExists(filepath.Join(pack.modPath(), filename)) {
+		logDebug("Skipping %s\n", filename)
+	} else {
+		err := f.download(ctx, pack, downloadUrl)
+		if err != nil {
+			return err
+		}
+	}
+
+	pack.modCache.AddExtFile(f.cacheKey(), downloadUrl, filename)
+	return pack.applyDisabledState(&f, filename)
+}
+
+// download retrieves the artifact into a temporary directory and verifies it against the
+// repository's checksum before moving it into the mods directory
+func (f MavenModFile) download(ctx context.Context, pack *ModPack, downloadUrl string) error {
+	dir, err := newTempDir(pack.gamePath(), ".mcdex-")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		os.RemoveAll(dir)
+		forgetTempDir(dir)
+	}()
+
+	filename, err := downloadHttpFileToDir(ctx, downloadUrl, dir, false)
+	if err != nil {
+		return err
+	}
+
+	err = verifyMavenChecksum(ctx, downloadUrl, filepath.Join(dir, filename))
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(filepath.Join(dir, filename), filepath.Join(pack.modPath(), f.installedFilename(pack)))
 }
 
-func (f *MavenModFile) update(pack *ModPack) (bool, error) {
-	fmt.Printf("%s is not eligible for update; not yet implemented\n", f.getName())
+func (f *MavenModFile) update(ctx context.Context, pack *ModPack) (bool, error) {
+	if f.pinned {
+		warn(WarnSkippedLocked, "%s was selected at a specific version; not updated", f.getName())
+		return false, nil
+	}
+
+	metadata, err := f.module.loadMetadata(ctx, f.url)
+	if err != nil {
+		return false, fmt.Errorf("failed to load metadata for %s: %+v", f.getName(), err)
+	}
+
+	latest, err := metadata.latestMatchingVersion(pack.modConstraint(ctx, f, ""))
+	if err != nil {
+		return false, fmt.Errorf("failed to find latest version of %s: %+v", f.getName(), err)
+	}
+
+	if latest != "" && compareVersions(latest, f.module.version) > 0 {
+		f.module.version = latest
+		return true, nil
+	}
 	return false, nil
 }
 
@@ -73,6 +147,26 @@ func (f MavenModFile) isClientOnly() bool {
 	return f.clientOnly
 }
 
+func (f MavenModFile) isServerOnly() bool {
+	return f.serverOnly
+}
+
+func (f MavenModFile) isOptional() bool {
+	return f.optional
+}
+
+func (f MavenModFile) cacheKey() string {
+	return fmt.Sprintf("maven:%s:%s", f.module.groupId, f.module.artifactId)
+}
+
+func (f MavenModFile) installedFilename(pack *ModPack) string {
+	if f.module.version == "" {
+		return ""
+	}
+	repoPath, _ := f.module.toRepositoryPath("/")
+	return path.Base(repoPath)
+}
+
 func (f MavenModFile) equalsJson(modJson *gabs.Container) bool {
 	moduleId, ok := modJson.Path("module").Data().(string)
 	if !ok {
@@ -93,8 +187,17 @@ func (f MavenModFile) toJson() map[string]interface{} {
 		"url":    f.url,
 	}
 
+	if f.optional {
+		result["required"] = false
+	}
 	if f.clientOnly {
-		result["clientOnly"] = true
+		mcdexFields(result)["clientOnly"] = true
+	}
+	if f.serverOnly {
+		mcdexFields(result)["serverOnly"] = true
+	}
+	if f.pinned {
+		mcdexFields(result)["pinned"] = true
 	}
 
 	return result
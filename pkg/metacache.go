@@ -17,16 +17,22 @@
 package pkg
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 )
 
 // MetaCache is a local cache file that tracks the installed files so that updates
 // don't need to re-download every file
 type MetaCache struct {
-	modPath string
+	modPath  string
+	gamePath string
 	db      *sql.DB
 	dbPath  string
 }
@@ -35,9 +41,10 @@ func OpenMetaCache(pack *ModPack) (*MetaCache, error) {
 	mc := new(MetaCache)
 
 	mc.modPath = pack.modPath()
+	mc.gamePath = pack.gamePath()
 	mc.dbPath = filepath.Join(pack.gamePath(), ".mcdex.cache")
 
-	db, err := sql.Open("sqlite3", mc.dbPath)
+	db, err := sql.Open(sqliteDriver, mc.dbPath)
 	if err != nil {
 		return nil, err
 	}
@@ -52,14 +59,23 @@ func OpenMetaCache(pack *ModPack) (*MetaCache, error) {
 		return nil, err
 	}
 
-	mc.db = db
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS disabled(key PRIMARY KEY)")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS overrides(filename PRIMARY KEY, hash)")
+	if err != nil {
+		return nil, err
+	}
 
-	// Cleanup the cache; make sure that any entries are files that actually exist
-	err = mc.Cleanup(pack)
+	_, err = db.Exec("CREATE TABLE IF NOT EXISTS settings(key PRIMARY KEY, value)")
 	if err != nil {
 		return nil, err
 	}
 
+	mc.db = db
+
 	return mc, nil
 }
 
@@ -73,7 +89,7 @@ func (mc *MetaCache) AddModFile(projectId, fileId int, filename string) error {
 // AddExtFile registers a new external file install in the cache
 func (mc *MetaCache) AddExtFile(key, url, filename string) error {
 	_, err := mc.db.Exec("INSERT OR REPLACE INTO extfiles(key, url, filename) VALUES (?, ?, ?)",
-		key, filename)
+		key, url, filename)
 	return err
 }
 
@@ -86,7 +102,7 @@ func (mc *MetaCache) GetLastModFile(projectId int) (int, string) {
 	case err == sql.ErrNoRows:
 		return 0, ""
 	case err != nil:
-		fmt.Printf("Error looking up file ID from meta cache for %d: %+v\n", projectId, err)
+		logWarn("Error looking up file ID from meta cache for %d: %+v\n", projectId, err)
 		return -1, ""
 	}
 
@@ -102,7 +118,7 @@ func (mc *MetaCache) GetLastExtURL(key string) (string, string) {
 	case err == sql.ErrNoRows:
 		return "", ""
 	case err != nil:
-		fmt.Printf("Error looking up extfiles key from meta cache for %s: %+v\n", key, err)
+		logWarn("Error looking up extfiles key from meta cache for %s: %+v\n", key, err)
 		return "", ""
 	}
 	return url, filename
@@ -118,7 +134,10 @@ func (mc *MetaCache) CleanupModFile(projectId int) error {
 		return err
 	}
 
-	os.Remove(filepath.Join(mc.modPath, filename))
+	err = mc.removeReplacedFile(fmt.Sprintf("curseforge:%d", projectId), filename)
+	if err != nil {
+		return err
+	}
 
 	_, err = mc.db.Exec("DELETE FROM mods WHERE pid = ?", projectId)
 	return err
@@ -134,7 +153,7 @@ func (mc *MetaCache) CleanupExtFile(key string) error {
 		return err
 	}
 
-	err = os.Remove(filepath.Join(mc.modPath, filename))
+	err = mc.removeReplacedFile(key, filename)
 	if err != nil {
 		return err
 	}
@@ -143,14 +162,50 @@ func (mc *MetaCache) CleanupExtFile(key string) error {
 	return err
 }
 
-func (mc *MetaCache) Cleanup(pack *ModPack) error {
+// removeReplacedFile removes the installed file for a mod that's being replaced by another version.
+// If the user disabled the file by renaming it, the mod is recorded as disabled so the new version
+// is disabled too (see applyDisabledState).
+func (mc *MetaCache) removeReplacedFile(key, filename string) error {
+	fullName := filepath.Join(mc.modPath, filename)
+	if !fileExists(fullName) && fileExists(fullName+disabledSuffix) {
+		err := mc.SetDisabled(key, true)
+		if err != nil {
+			return fmt.Errorf("failed to record state of %s: %+v", filename, err)
+		}
+	}
+
+	os.Remove(fullName)
+	os.Remove(fullName + disabledSuffix)
 	return nil
-	// Build a map of the current project IDs in the pack for easy reference
+}
+
+// removedModsDir is where mod files that are no longer part of the pack are moved to, rather than
+// being deleted outright
+const removedModsDir = ".mcdex-removed"
+
+// Cleanup reconciles the cache with the pack's manifest. Entries whose files are gone are dropped;
+// files that are still on disk but no longer in the manifest are moved into removedModsDir. When
+// confirm is set, the user is asked before anything is moved (and nothing is when stdin isn't
+// interactive).
+func (mc *MetaCache) Cleanup(pack *ModPack, confirm bool) error {
+	// Nothing to reconcile against until the pack has a manifest
+	if pack.manifest == nil {
+		return nil
+	}
+
+	// Build a map of the current project IDs (and cache keys) in the pack for easy reference
 	knownProjects := make(map[int]bool)
-	packFiles, _ := pack.manifest.Path("files").Children()
-	for _, f := range packFiles {
-		// Get the project & file ID
-		projectID := int(f.Path("projectID").Data().(float64))
+	knownKeys := make(map[string]bool)
+	for _, f := range manifestFiles(pack.manifest) {
+		if modFile, err := newModPackFile(f); err == nil {
+			knownKeys[modFile.cacheKey()] = true
+		}
+
+		// Only CurseForge entries are tracked by project ID
+		projectID, err := intValue(f, "projectID")
+		if err != nil {
+			continue
+		}
 		knownProjects[projectID] = true
 	}
 
@@ -160,21 +215,396 @@ func (mc *MetaCache) Cleanup(pack *ModPack) error {
 		return err
 	}
 
+	var staleMods []int
+	var staleNames []string
 	for filename, pid := range cache {
-		// If the file in the cache doesn't actually exist, remove it
-		if !fileExists(filepath.Join(mc.modPath, filename)) {
-			err = mc.CleanupModFile(pid)
+		// If the file in the cache doesn't actually exist (enabled or disabled), forget it
+		if !modFileExists(filepath.Join(mc.modPath, filename)) {
+			_, err = mc.db.Exec("DELETE FROM mods WHERE pid = ?", pid)
 			if err != nil {
-				fmt.Printf("Failed to cleanup missing file %s: %+v\n", filename, err)
+				logWarn("Failed to cleanup missing file %s: %+v\n", filename, err)
 			}
+			continue
 		}
 
-		// If the project ID in the cache doesn't exist in the manifest, remove it
+		// If the project ID in the cache doesn't exist in the manifest, the file is stale
 		if _, ok := knownProjects[pid]; !ok {
-			err = mc.CleanupModFile(pid)
+			staleMods = append(staleMods, pid)
+			staleNames = append(staleNames, filename)
+		}
+	}
+
+	// Do the same for external files
+	extfiles, err := mc.listExtFiles()
+	if err != nil {
+		return err
+	}
+
+	var staleExtFiles []string
+	for key, filename := range extfiles {
+		if !modFileExists(filepath.Join(mc.modPath, filename)) {
+			_, err = mc.db.Exec("DELETE FROM extfiles WHERE key = ?", key)
 			if err != nil {
-				fmt.Printf("Failed to cleanup missing project %d: %+v\n", pid, err)
+				logWarn("Failed to cleanup external file %s: %+v\n", filename, err)
 			}
+		} else if !knownKeys[key] {
+			staleExtFiles = append(staleExtFiles, key)
+			staleNames = append(staleNames, filename)
+		}
+	}
+
+	if len(staleNames) == 0 {
+		return nil
+	}
+
+	sort.Strings(staleNames)
+	backupDir := filepath.Join(mc.gamePath, removedModsDir)
+	if confirm {
+		for _, filename := range staleNames {
+			logInfo("No longer part of the pack: %s\n", filename)
+		}
+		if !promptYesNo(fmt.Sprintf("Move %d mod file(s) that are no longer part of the pack to %s?", len(staleNames), backupDir)) {
+			warn(WarnPreservedFiles, "%d mod file(s) no longer part of the pack were left in place: %s",
+				len(staleNames), strings.Join(staleNames, ", "))
+			return nil
+		}
+	}
+
+	for _, pid := range staleMods {
+		err = mc.removeModFile("SELECT filename FROM mods WHERE pid = ?", "DELETE FROM mods WHERE pid = ?", pid)
+		if err != nil {
+			logWarn("Failed to cleanup missing project %d: %+v\n", pid, err)
+		}
+	}
+	for _, key := range staleExtFiles {
+		err = mc.removeModFile("SELECT filename FROM extfiles WHERE key = ?", "DELETE FROM extfiles WHERE key = ?", key)
+		if err != nil {
+			logWarn("Failed to cleanup external file %s: %+v\n", key, err)
+		}
+	}
+
+	return nil
+}
+
+// removeModFile moves a cached file (and its disabled copy) into removedModsDir and drops it from
+// the cache
+func (mc *MetaCache) removeModFile(query, deleteQuery string, id interface{}) error {
+	var filename string
+	err := mc.db.QueryRow(query, id).Scan(&filename)
+	switch {
+	case err == sql.ErrNoRows:
+		return nil
+	case err != nil:
+		return err
+	}
+
+	backupDir := filepath.Join(mc.gamePath, removedModsDir)
+	err = os.MkdirAll(backupDir, 0700)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range []string{filename, filename + disabledSuffix} {
+		src := filepath.Join(mc.modPath, name)
+		if !fileExists(src) {
+			continue
+		}
+		logInfo("Moving %s to %s (no longer part of the pack)\n", name, removedModsDir)
+		err = os.Rename(src, filepath.Join(backupDir, name))
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = mc.db.Exec(deleteQuery, id)
+	return err
+}
+
+// listExtFiles returns a map of extfiles keys to filenames
+func (mc *MetaCache) listExtFiles() (map[string]string, error) {
+	rows, err := mc.db.Query("SELECT key, filename FROM extfiles")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]string)
+	for rows.Next() {
+		var key, filename string
+		err = rows.Scan(&key, &filename)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = filename
+	}
+	return result, nil
+}
+
+// AddOverride registers a file (relative to the game directory) extracted from the pack archive
+func (mc *MetaCache) AddOverride(filename, hash string) error {
+	_, err := mc.db.Exec("INSERT OR REPLACE INTO overrides(filename, hash) VALUES (?, ?)", filename, hash)
+	return err
+}
+
+// GetOverrideHash returns the hash of an override file when it was last installed
+func (mc *MetaCache) GetOverrideHash(filename string) string {
+	var hash string
+	err := mc.db.QueryRow("SELECT hash FROM overrides WHERE filename = ?", filename).Scan(&hash)
+	if err != nil {
+		return ""
+	}
+	return hash
+}
+
+// CleanupOverrides removes any previously installed override files that are not in the
+// provided set of current files
+func (mc *MetaCache) CleanupOverrides(current map[string]bool) error {
+	overrides, err := mc.listOverrides()
+	if err != nil {
+		return err
+	}
+
+	for filename, hash := range overrides {
+		if current[filename] {
+			continue
+		}
+
+		fullName := filepath.Join(mc.gamePath, filepath.FromSlash(filename))
+
+		// Leave files the user has modified in place
+		diskHash, err := sha256File(fullName)
+		if err == nil && diskHash != hash {
+			warn(WarnPreservedFiles, "%s was modified locally; left in place though no longer part of pack", filename)
+		} else if err == nil {
+			logInfo("Removing %s (no longer part of pack)\n", filename)
+			os.Remove(fullName)
+		}
+
+		_, err = mc.db.Exec("DELETE FROM overrides WHERE filename = ?", filename)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (mc *MetaCache) listOverrides() (map[string]string, error) {
+	rows, err := mc.db.Query("SELECT filename, hash FROM overrides")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]string)
+	for rows.Next() {
+		var filename, hash string
+		err := rows.Scan(&filename, &hash)
+		if err != nil {
+			return nil, err
+		}
+		result[filename] = hash
+	}
+	return result, nil
+}
+
+// SetDisabled records whether the mod with the given key has been disabled by the user
+func (mc *MetaCache) SetDisabled(key string, disabled bool) error {
+	var err error
+	if disabled {
+		_, err = mc.db.Exec("INSERT OR REPLACE INTO disabled(key) VALUES (?)", key)
+	} else {
+		_, err = mc.db.Exec("DELETE FROM disabled WHERE key = ?", key)
+	}
+	return err
+}
+
+// IsDisabled returns true if the mod with the given key has been disabled by the user
+func (mc *MetaCache) IsDisabled(key string) bool {
+	var count int
+	err := mc.db.QueryRow("SELECT count(*) FROM disabled WHERE key = ?", key).Scan(&count)
+	return err == nil && count > 0
+}
+
+// SetServerInstall records whether the pack's mods were installed for a server (rather than a client)
+func (mc *MetaCache) SetServerInstall(server bool) error {
+	_, err := mc.db.Exec("INSERT OR REPLACE INTO settings(key, value) VALUES ('server', ?)", server)
+	return err
+}
+
+// IsServerInstall returns true if the pack's mods were last installed for a server
+func (mc *MetaCache) IsServerInstall() bool {
+	var server bool
+	err := mc.db.QueryRow("SELECT value FROM settings WHERE key = 'server'").Scan(&server)
+	return err == nil && server
+}
+
+// TrackedFilenames returns the set of all filenames in the mods directory that are known to the cache
+func (mc *MetaCache) TrackedFilenames() (map[string]bool, error) {
+	rows, err := mc.db.Query("SELECT filename FROM mods UNION SELECT filename FROM extfiles")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := make(map[string]bool)
+	for rows.Next() {
+		var filename string
+		err := rows.Scan(&filename)
+		if err != nil {
+			return nil, err
+		}
+		result[filename] = true
+	}
+
+	// Mods shipped as part of the pack archive are tracked as overrides
+	overrides, err := mc.listOverrides()
+	if err != nil {
+		return nil, err
+	}
+
+	modDir, _ := filepath.Rel(mc.gamePath, mc.modPath)
+	for filename := range overrides {
+		if path.Dir(filename) == filepath.ToSlash(modDir) {
+			result[path.Base(filename)] = true
+		}
+	}
+
+	return result, nil
+}
+
+// Verify prints the contents of the cache, flagging any entries that refer to files which no
+// longer exist; returns the number of problems found
+func (mc *MetaCache) Verify() (int, error) {
+	problems := 0
+	status := func(filename string) string {
+		if modFileExists(filename) {
+			return "ok"
+		}
+		problems++
+		return "MISSING"
+	}
+
+	fmt.Fprintf(consoleOutput, "Mods:\n")
+	rows, err := mc.db.Query("SELECT pid, fid, filename FROM mods ORDER BY filename")
+	if err != nil {
+		return problems, err
+	}
+	for rows.Next() {
+		var pid, fid int
+		var filename string
+		if err := rows.Scan(&pid, &fid, &filename); err != nil {
+			rows.Close()
+			return problems, err
+		}
+		fmt.Fprintf(consoleOutput, "  %s [%d:%d] %s\n", filename, pid, fid, status(filepath.Join(mc.modPath, filename)))
+	}
+	rows.Close()
+
+	fmt.Fprintf(consoleOutput, "External files:\n")
+	rows, err = mc.db.Query("SELECT key, url, filename FROM extfiles ORDER BY filename")
+	if err != nil {
+		return problems, err
+	}
+	for rows.Next() {
+		var key, url, filename string
+		if err := rows.Scan(&key, &url, &filename); err != nil {
+			rows.Close()
+			return problems, err
+		}
+		fmt.Fprintf(consoleOutput, "  %s [%s] %s\n", filename, url, status(filepath.Join(mc.modPath, filename)))
+	}
+	rows.Close()
+
+	fmt.Fprintf(consoleOutput, "Overrides:\n")
+	overrides, err := mc.listOverrides()
+	if err != nil {
+		return problems, err
+	}
+	var names []string
+	for filename := range overrides {
+		names = append(names, filename)
+	}
+	sort.Strings(names)
+	for _, filename := range names {
+		fmt.Fprintf(consoleOutput, "  %s %s\n", filename, status(filepath.Join(mc.gamePath, filepath.FromSlash(filename))))
+	}
+
+	return problems, nil
+}
+
+// Rebuild discards the installed mod entries in the cache and reconstructs them by identifying
+// the files in the mods directory and matching them against the manifest
+func (mc *MetaCache) Rebuild(ctx context.Context, pack *ModPack) error {
+	// Build a map of the current project IDs in the pack
+	knownProjects := make(map[int]bool)
+	packFiles, _ := pack.manifest.Path("files").Children()
+	for _, f := range packFiles {
+		projectID, err := intValue(f, "projectID")
+		if err == nil {
+			knownProjects[projectID] = true
+		}
+	}
+
+	entries, err := ioutil.ReadDir(mc.modPath)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %+v", mc.modPath, err)
+	}
+
+	var filenames []string
+	for _, entry := range entries {
+		if !entry.IsDir() && (strings.HasSuffix(entry.Name(), ".jar") || strings.HasSuffix(entry.Name(), ".jar"+disabledSuffix)) {
+			filenames = append(filenames, entry.Name())
+		}
+	}
+
+	matches, err := pack.identifyModFiles(ctx, mc.modPath, filenames)
+	if err != nil {
+		return err
+	}
+
+	_, err = mc.db.Exec("DELETE FROM mods")
+	if err != nil {
+		return err
+	}
+
+	for _, filename := range filenames {
+		match, ok := matches[filename]
+		if !ok || !knownProjects[match.projectID] {
+			fmt.Fprintf(consoleOutput, "  %s: not part of manifest\n", filename)
+			continue
+		}
+
+		fmt.Fprintf(consoleOutput, "  %s: %s\n", filename, match.name)
+		err = mc.AddModFile(match.projectID, match.fileID, strings.TrimSuffix(filename, disabledSuffix))
+		if err != nil {
+			return err
+		}
+		delete(knownProjects, match.projectID)
+	}
+
+	for pid := range knownProjects {
+		fmt.Fprintf(consoleOutput, "  project %d: not installed; will be downloaded on next install\n", pid)
+	}
+
+	// Drop external file entries that no longer exist
+	rows, err := mc.db.Query("SELECT key, filename FROM extfiles")
+	if err != nil {
+		return err
+	}
+	var staleKeys []string
+	for rows.Next() {
+		var key, filename string
+		if rows.Scan(&key, &filename) == nil && !modFileExists(filepath.Join(mc.modPath, filename)) {
+			staleKeys = append(staleKeys, key)
+		}
+	}
+	rows.Close()
+
+	for _, key := range staleKeys {
+		_, err = mc.db.Exec("DELETE FROM extfiles WHERE key = ?", key)
+		if err != nil {
+			return err
 		}
 	}
 
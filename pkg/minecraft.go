@@ -1,17 +1,78 @@
 package pkg
 
 import (
+	"context"
 	"fmt"
 	"path"
 	"path/filepath"
+	"regexp"
 
 	"github.com/Jeffail/gabs"
 )
 
 const GLOBAL_MANIFEST = "https://launchermeta.mojang.com/mc/game/version_manifest.json"
 
+// Minecraft release versions (e.g. 1.16.5) are used as-is; anything else, such as a snapshot
+// (24w14a) or pre-release (1.20.5-pre1), has to be listed in Mojang's version manifest
+var releaseVersionRegex = regexp.MustCompile(`^1\.\d+(\.\d+)?$`)
+var snapshotVersionRegex = regexp.MustCompile(`^\d\dw\d\d[a-z]$`)
+
+// Version that selects the latest snapshot (e.g. pack.create mypack fabric snapshot)
+const latestSnapshot = "snapshot"
+
+// resolveMinecraftVersion checks that a version of Minecraft exists, resolving "snapshot" to the
+// latest snapshot
+func resolveMinecraftVersion(ctx context.Context, version string) (string, error) {
+	if releaseVersionRegex.MatchString(version) {
+		return version, nil
+	}
+
+	globalManifest, err := getGlobalManifest(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to retrieve global manifest: %+v", err)
+	}
+
+	if version == latestSnapshot {
+		snapshot, ok := globalManifest.Path("latest.snapshot").Data().(string)
+		if !ok {
+			return "", fmt.Errorf("no snapshot found in the global manifest")
+		}
+		logInfo("Using Minecraft snapshot %s\n", snapshot)
+		return snapshot, nil
+	}
+
+	if !manifestHasVersion(globalManifest, version) {
+		return "", fmt.Errorf("unknown Minecraft version %s", version)
+	}
+	return version, nil
+}
+
+// isPublishedVersion checks that a version of Minecraft is listed in Mojang's version manifest
+func isPublishedVersion(ctx context.Context, version string) (bool, error) {
+	globalManifest, err := getGlobalManifest(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to retrieve global manifest: %+v", err)
+	}
+	return manifestHasVersion(globalManifest, version), nil
+}
+
+func manifestHasVersion(globalManifest *gabs.Container, version string) bool {
+	versionObjs, _ := globalManifest.Path("versions").Children()
+	for _, versionObj := range versionObjs {
+		if id, _ := versionObj.Path("id").Data().(string); id == version {
+			return true
+		}
+	}
+	return false
+}
+
+// isSnapshotVersion determines if a version of Minecraft is a weekly snapshot
+func isSnapshotVersion(version string) bool {
+	return snapshotVersionRegex.MatchString(version)
+}
+
 // Install (if necessary) the minecraft JAR file of the requested version and type (client, server)
-func installMinecraftJar(version string, isClient bool, baseDir string) (string, error) {
+func installMinecraftJar(ctx context.Context, version string, isClient bool, baseDir string) (string, error) {
 	// First, check to see if a JAR is present in versions/<vsn>/<vsn>.jar (client) or in base
 	// directory for servers
 	var filename string
@@ -25,8 +86,8 @@ func installMinecraftJar(version string, isClient bool, baseDir string) (string,
 		return filename, nil
 	}
 
-	// JAR doesn't exist; grab the global index and the version specific manifest
-	globalManifest, err := getJSONFromURL(GLOBAL_MANIFEST)
+	// JAR doesn't exist; grab the global index and the version specific manifest (both cached)
+	globalManifest, err := getGlobalManifest(ctx)
 	if err != nil {
 		return "", fmt.Errorf("failed to retrieve global manifest: %+v", err)
 	}
@@ -36,7 +97,7 @@ func installMinecraftJar(version string, isClient bool, baseDir string) (string,
 	versionObjs, _ := globalManifest.Path("versions").Children()
 	for _, versionObj := range versionObjs {
 		if versionObj.Path("id").Data().(string) == version {
-			manifest, err = getJSONFromURL(versionObj.Path("url").Data().(string))
+			manifest, err = getVersionManifest(ctx, versionObj)
 			if err != nil {
 				return "", fmt.Errorf("failed to retrieve manifest for %s: %+v", version, err)
 			}
@@ -58,7 +119,7 @@ func installMinecraftJar(version string, isClient bool, baseDir string) (string,
 
 	// Download the version into appropriate place
 	logAction("Downloading %s: %s\n", path.Base(filename), url)
-	err = downloadHttpFile(url, filename)
+	err = downloadHttpFile(ctx, url, filename)
 	if err != nil {
 		return "", fmt.Errorf("failed to retrieve URL for %s: %+v", version, err)
 	}
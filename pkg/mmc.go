@@ -2,10 +2,14 @@ package pkg
 
 import (
 	"bufio"
+	"context"
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"os/exec"
 	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
 
 	"github.com/Jeffail/gabs"
@@ -15,17 +19,101 @@ const MMC_CONFIG = `InstanceType=OneSix
 iconKey=flame
 name=%s
 `
+
+// Prism Launcher keeps instance settings in an INI section
+const PRISM_CONFIG = `[General]
+ConfigVersion=1.2
+InstanceType=OneSix
+iconKey=flame
+name=%s
+`
 const InstanceDirKey = "InstanceDir="
+const IconsDirKey = "IconsDir="
+
+var packProjectRegex = regexp.MustCompile(`/projects/([\w-]+)/`)
+
+// MultiMC and its forks (PolyMC, Prism Launcher) share the same instance format, but differ in
+// executable and config file names
+type mmcFlavor struct {
+	name        string
+	executables []string
+	configFile  string
+}
+
+var mmcFlavors = []mmcFlavor{
+	{"Prism Launcher", []string{"prismlauncher", "PrismLauncher"}, "prismlauncher.cfg"},
+	{"PolyMC", []string{"polymc", "PolyMC"}, "polymc.cfg"},
+	{"MultiMC", []string{"MultiMC"}, "multimc.cfg"},
+}
+
+// Components in mmc-pack.json for each mod loader; loaders built on Fabric's mappings also
+// need the intermediary component
+var mmcLoaderComponents = map[string]string{
+	"forge":    "net.minecraftforge",
+	"neoforge": "net.neoforged",
+	"fabric":   "net.fabricmc.fabric-loader",
+	"quilt":    "org.quiltmc.quilt-loader",
+}
+
+const mmcIntermediaryComponent = "net.fabricmc.intermediary"
+
+// FindMMCDir looks for MultiMC, PolyMC or Prism Launcher on the path and returns the directory
+// containing the executable
+func FindMMCDir() string {
+	for _, flavor := range mmcFlavors {
+		for _, exe := range flavor.executables {
+			if path, err := exec.LookPath(exe); err == nil {
+				if path, err := filepath.EvalSymlinks(path); err == nil {
+					return filepath.Dir(path)
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// IsMMCDir determines if the directory contains a MultiMC-style launcher (or its data)
+func IsMMCDir(dir string) bool {
+	_, ok := detectMMCFlavor(dir)
+	return ok
+}
+
+func detectMMCFlavor(dir string) (mmcFlavor, bool) {
+	// Config files are the most reliable indicator, since Prism/PolyMC often keep their data
+	// separate from the executable
+	for _, flavor := range mmcFlavors {
+		if fileExists(filepath.Join(dir, flavor.configFile)) {
+			return flavor, true
+		}
+	}
+
+	for _, flavor := range mmcFlavors {
+		for _, exe := range flavor.executables {
+			if fileExists(filepath.Join(dir, exe+_executableExt())) {
+				return flavor, true
+			}
+		}
+	}
+	return mmcFlavors[len(mmcFlavors)-1], false
+}
 
 func _mmcInstancesDir() (string, error) {
-	// Default if not found in config file
-	dir := "instances"
+	return _mmcConfigDir(InstanceDirKey, "instances")
+}
 
+func _mmcIconsDir() (string, error) {
+	return _mmcConfigDir(IconsDirKey, "icons")
+}
+
+// _mmcConfigDir reads a directory setting from the launcher's config file, resolving it
+// relative to the launcher directory
+func _mmcConfigDir(key, dir string) (string, error) {
 	if Env().MultiMCDir == "" {
 		return "", errors.New("MultiMC directory is not set")
 	}
 
-	cfg, err := ioutil.ReadFile(filepath.Join(Env().MultiMCDir, "multimc.cfg"))
+	flavor, _ := detectMMCFlavor(Env().MultiMCDir)
+	cfg, err := ioutil.ReadFile(filepath.Join(Env().MultiMCDir, flavor.configFile))
 	if err != nil {
 		return "", err
 	}
@@ -34,8 +122,8 @@ func _mmcInstancesDir() (string, error) {
 	scanner.Split(bufio.ScanLines)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
-		if strings.HasPrefix(line, InstanceDirKey) {
-			dir = strings.TrimSpace(line[len(InstanceDirKey):])
+		if strings.HasPrefix(line, key) {
+			dir = strings.TrimSpace(line[len(key):])
 			break
 		}
 	}
@@ -48,16 +136,32 @@ func _mmcInstancesDir() (string, error) {
 }
 
 func generateMMCConfig(pack *ModPack) error {
-	fmt.Printf("Generating instance.cfg for MultiMC\n")
+	flavor, _ := detectMMCFlavor(Env().MultiMCDir)
+	instConfig := MMC_CONFIG
+	if flavor.configFile == "prismlauncher.cfg" {
+		instConfig = PRISM_CONFIG
+	}
+
+	logInfo("Generating instance.cfg for %s\n", flavor.name)
 	instFile := filepath.Join(pack.rootPath, "instance.cfg")
 	if fileExists(instFile) {
-		fmt.Printf("  Already exists... Skipping\n")
-	} else if err := ioutil.WriteFile(instFile, []byte(fmt.Sprintf(MMC_CONFIG, pack.fullName())), 0644); err != nil {
+		warn(WarnSkippedExisting, "%s already exists; not regenerated", instFile)
+	} else if err := ioutil.WriteFile(instFile, []byte(fmt.Sprintf(instConfig, pack.fullName())), 0644); err != nil {
 		return fmt.Errorf("failed to save instance.cfg: %+v", err)
 	}
 
-	minecraftVsn, forgeVsn := pack.getVersions()
-	fmt.Printf("Generating mmc-pack.json for MultiMC\n")
+	err := pack.applyMMCJVMSettings(instFile)
+	if err != nil {
+		return err
+	}
+
+	minecraftVsn, loaderVsn := pack.getVersions()
+	loaderComponent, ok := mmcLoaderComponents[pack.modLoader]
+	if !ok {
+		return fmt.Errorf("%s does not support mod loader %s", flavor.name, pack.modLoader)
+	}
+
+	logInfo("Generating mmc-pack.json for %s\n", flavor.name)
 	mmcpack := gabs.New()
 	_, _ = mmcpack.Array("components")
 	_ = mmcpack.ArrayAppend(map[string]interface{}{
@@ -65,18 +169,136 @@ func generateMMCConfig(pack *ModPack) error {
 		"uid":       "net.minecraft",
 		"version":   minecraftVsn,
 	}, "components")
+	if pack.modLoader == "fabric" || pack.modLoader == "quilt" {
+		_ = mmcpack.ArrayAppend(map[string]interface{}{
+			"dependencyOnly": true,
+			"uid":            mmcIntermediaryComponent,
+			"version":        minecraftVsn,
+		}, "components")
+	}
 	_ = mmcpack.ArrayAppend(map[string]interface{}{
-		"uid":     "net.minecraftforge",
-		"version": forgeVsn,
+		"uid":     loaderComponent,
+		"version": loaderVsn,
 	}, "components")
 	_, _ = mmcpack.Set(1, "formatVersion")
 
 	packFile := filepath.Join(pack.rootPath, "mmc-pack.json")
 	if fileExists(packFile) {
-		fmt.Printf("  Already exists... Skipping\n")
+		warn(WarnSkippedExisting, "%s already exists; not regenerated", packFile)
 	} else if err := writeJSON(mmcpack, packFile); err != nil {
 		return fmt.Errorf("failed to save mmc-pack.json: %+v", err)
 	}
 
 	return nil
 }
+
+// AdoptMMCInstance generates a manifest for an existing MultiMC instance that was created by
+// another tool, identifying the installed mods by fingerprint so that mcdex can manage it
+func (pack *ModPack) AdoptMMCInstance(ctx context.Context) error {
+	if pack.manifest != nil {
+		return fmt.Errorf("%s is already managed by mcdex", pack.Name)
+	}
+
+	// Use the components in mmc-pack.json to identify the Minecraft and loader versions
+	mmcpack, err := gabs.ParseJSONFile(filepath.Join(pack.rootPath, "mmc-pack.json"))
+	if err != nil {
+		return fmt.Errorf("failed to load mmc-pack.json: %+v", err)
+	}
+
+	minecraftVsn, loader, loaderVsn := parseMMCComponents(mmcpack)
+	if minecraftVsn == "" || loaderVsn == "" {
+		return fmt.Errorf("unable to identify Minecraft and mod loader versions in mmc-pack.json")
+	}
+	pack.modLoader = loader
+
+	logInfo("Adopting %s (Minecraft %s, %s %s)\n", pack.Name, minecraftVsn, pack.modLoader, loaderVsn)
+
+	pack.initManifest(pack.Name, minecraftVsn)
+	pack.manifest.ArrayOfSizeP(0, "files")
+	pack.setLoaderVersion(loaderVsn)
+
+	err = pack.SaveManifest()
+	if err != nil {
+		return err
+	}
+
+	// Identify all the mods in the instance and register them; if this fails, the mods will
+	// be identified again on the next install or update
+	err = pack.adoptUntrackedMods(ctx, false)
+	if err != nil {
+		warn(WarnUnknownMods, "unable to identify mods in %s: %+v", pack.Name, err)
+	}
+
+	// Fill in any missing MultiMC files
+	return generateMMCConfig(pack)
+}
+
+// InstallMMCIcon downloads the icon of the CurseForge project the pack was installed from into the
+// launcher's icons directory and points the instance at it
+func (pack *ModPack) InstallMMCIcon(ctx context.Context, packURL string) error {
+	// Only packs from CurseForge have a project (and so an icon)
+	match := packProjectRegex.FindStringSubmatch(packURL)
+	if match == nil {
+		logDebug("No CurseForge project in %s; using the default icon", packURL)
+		return nil
+	}
+
+	// Pack URLs may refer to the project by slug instead of ID
+	projectID := match[1]
+	if _, err := strconv.Atoi(projectID); err != nil {
+		// Modpacks aren't tied to a mod loader, so match any of them
+		id, err := pack.db.FindProjectBySlug(ctx, projectID, "fabric+forge", 1)
+		if err != nil {
+			return err
+		}
+		projectID = strconv.Itoa(id)
+	}
+
+	project, err := projectSources["curseforge"].GetProject(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve project %s: %+v", projectID, err)
+	}
+
+	if project.IconURL == "" {
+		return fmt.Errorf("%s has no icon", project.Name)
+	}
+
+	iconsDir, err := _mmcIconsDir()
+	if err != nil {
+		return err
+	}
+
+	iconKey := "mcdex-" + project.ID
+	iconFile := filepath.Join(iconsDir, iconKey+".png")
+	if !fileExists(iconFile) {
+		pack.trackCreated(iconFile)
+	}
+	err = downloadHttpFile(ctx, project.IconURL, iconFile)
+	if err != nil {
+		return fmt.Errorf("failed to download icon: %+v", err)
+	}
+
+	return setMMCInstanceKey(filepath.Join(pack.rootPath, "instance.cfg"), "iconKey", iconKey)
+}
+
+// setMMCInstanceKey updates (or adds) a setting in instance.cfg
+func setMMCInstanceKey(filename, key, value string) error {
+	data, err := readStringFile(filename)
+	if err != nil {
+		return err
+	}
+
+	found := false
+	lines := strings.Split(strings.TrimRight(data, "\n"), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, key+"=") {
+			lines[i] = key + "=" + value
+			found = true
+		}
+	}
+	if !found {
+		lines = append(lines, key+"="+value)
+	}
+
+	return writeStringFile(filename, strings.Join(lines, "\n")+"\n")
+}
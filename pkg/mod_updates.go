@@ -0,0 +1,165 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Number of changelog lines shown when reviewing an update
+const changelogSnippetLines = 5
+
+// Answers when reviewing an update (see reviewModUpdate)
+const (
+	updateAccept = "y"
+	updateSkip   = "n"
+	updateLock   = "l"
+)
+
+// modUpdateDetail describes an update from one file of a mod to another
+type modUpdateDetail struct {
+	From        string
+	To          string
+	ReleaseType int    // 0 if the mod's source doesn't have release types
+	Changelog   string // empty if there isn't one
+}
+
+// sourceFile identifies the project and file of a mod hosted by a ProjectSource; other mods
+// (e.g. Maven artifacts) aren't
+func sourceFile(modFile ModPackFile) (ProjectSource, string, string, bool) {
+	switch f := modFile.(type) {
+	case *CurseForgeModFile:
+		return f.source(), strconv.Itoa(f.projectID), strconv.Itoa(f.fileID), true
+	case *ModrinthModFile:
+		return f.source(), f.projectID, f.fileID, true
+	}
+	return nil, "", "", false
+}
+
+// modFileVersion identifies the file (or version) of a mod selected in the manifest
+func modFileVersion(modFile ModPackFile) string {
+	switch f := modFile.(type) {
+	case *CurseForgeModFile:
+		return strconv.Itoa(f.fileID)
+	case *ModrinthModFile:
+		return f.fileID
+	case *MavenModFile:
+		return f.module.version
+	case *ExtModFile:
+		return f.version
+	}
+	return ""
+}
+
+// describeModUpdate looks up the details of an update; anything that can't be retrieved is left
+// out, since the details are informational
+func describeModUpdate(ctx context.Context, pack *ModPack, from, to ModPackFile) modUpdateDetail {
+	detail := modUpdateDetail{From: from.installedFilename(pack), To: to.getName()}
+
+	source, projectID, fileID, ok := sourceFile(to)
+	if !ok {
+		if detail.From == "" {
+			detail.From = from.getName()
+		}
+		return detail
+	}
+
+	if detail.From == "" {
+		detail.From = from.getName()
+		if _, _, oldFileID, _ := sourceFile(from); oldFileID != "" {
+			if file, err := source.GetFile(ctx, projectID, oldFileID); err == nil {
+				detail.From = file.Filename
+			}
+		}
+	}
+
+	if file, err := source.GetFile(ctx, projectID, fileID); err == nil {
+		detail.To = file.Filename
+		detail.ReleaseType = file.ReleaseType
+	}
+	detail.Changelog, _ = source.GetChangelog(ctx, projectID, fileID)
+	return detail
+}
+
+// changelogSnippet trims a changelog to its first few lines
+func changelogSnippet(changelog string) string {
+	lines := strings.Split(strings.TrimSpace(changelog), "\n")
+	if len(lines) > changelogSnippetLines {
+		lines = append(lines[:changelogSnippetLines], "...")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// reviewModUpdate shows an available update and asks whether to apply it, skip it or lock the mod
+// at its current version
+func reviewModUpdate(ctx context.Context, pack *ModPack, from, to ModPackFile) string {
+	detail := describeModUpdate(ctx, pack, from, to)
+
+	fmt.Fprintf(consoleOutput, "\n%s: %s -> %s", colorize(consoleOutput, styleBold, from.getName()), colorize(consoleOutput, styleOld, detail.From), colorize(consoleOutput, styleNew, detail.To))
+	if detail.ReleaseType != 0 {
+		fmt.Fprintf(consoleOutput, " (%s)", ReleaseTypeName(detail.ReleaseType))
+	}
+	fmt.Fprintln(consoleOutput)
+
+	if detail.Changelog != "" {
+		for _, line := range strings.Split(changelogSnippet(detail.Changelog), "\n") {
+			fmt.Fprintf(consoleOutput, "  %s\n", colorize(consoleOutput, styleDim, "| "+line))
+		}
+	}
+
+	return promptChoice("Update (y), skip (n) or lock at the current version (l)?",
+		[]string{updateAccept, updateSkip, updateLock}, updateSkip)
+}
+
+// WriteUpdateReport writes a markdown report of applied updates, with their changelogs, into the
+// pack directory (updates-<date>.md) so it can be shared with the pack's players; reports for
+// later updates on the same day are appended. The report's filename is returned.
+func (pack *ModPack) WriteUpdateReport(ctx context.Context, updates []ModUpdate) (string, error) {
+	if len(updates) == 0 {
+		return "", nil
+	}
+
+	now := time.Now()
+	var report strings.Builder
+	fmt.Fprintf(&report, "# Mod updates for %s (%s)\n", pack.Name, now.Format("2006-01-02 15:04"))
+	for _, update := range updates {
+		fmt.Fprintf(&report, "\n## %s\n\n", update.Name)
+		if update.from == nil || update.to == nil {
+			continue
+		}
+
+		detail := describeModUpdate(ctx, pack, update.from, update.to)
+		fmt.Fprintf(&report, "`%s` -> `%s`", detail.From, detail.To)
+		if detail.ReleaseType != 0 {
+			fmt.Fprintf(&report, " (%s)", ReleaseTypeName(detail.ReleaseType))
+		}
+		report.WriteString("\n")
+
+		if detail.Changelog != "" {
+			fmt.Fprintf(&report, "\n%s\n", detail.Changelog)
+		}
+	}
+
+	filename := filepath.Join(pack.gamePath(), fmt.Sprintf("updates-%s.md", now.Format("2006-01-02")))
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return "", fmt.Errorf("failed to open %s: %+v", filename, err)
+	}
+	defer f.Close()
+
+	// Separate the reports of multiple updates
+	text := report.String()
+	if stat, err := f.Stat(); err == nil && stat.Size() > 0 {
+		text = "\n" + text
+	}
+
+	_, err = f.WriteString(text)
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s: %+v", filename, err)
+	}
+	return filename, nil
+}
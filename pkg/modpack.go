@@ -19,6 +19,10 @@ package pkg
 
 import (
 	"archive/zip"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -31,11 +35,8 @@ import (
 
 const NamePlaceholder = "*"
 
-var VALID_URL_PREFIXES = []string{
-	"https://www.feed-the-beast.com/download",
-	"https://www.curseforge.com/minecraft/modpacks/",
-	"https://minecraft.curseforge.com/",
-}
+// Launchers disable a mod by appending this suffix to the JAR filename
+const disabledSuffix = ".disabled"
 
 // ModPack is a directory, manifest and other components that represent a pack
 type ModPack struct {
@@ -47,14 +48,30 @@ type ModPack struct {
 	modCache *MetaCache
 	db       *Database
 	modLoader string
+
+	// Version constraints from the constraints file, by cache key; loaded on first use
+	constraints map[string]string
+
+	// Set while the pack is being changed in a transaction (see Transaction)
+	txn *packTransaction
+
+	// Command-line overrides for the JVM settings in the manifest
+	jvmXmx  string
+	jvmXms  string
+	jvmArgs string
 }
 
 type ModPackFile interface {
-	install(pack *ModPack) error
-	update(pack *ModPack) (bool, error)
+	install(ctx context.Context, pack *ModPack) error
+	update(ctx context.Context, pack *ModPack) (bool, error)
 
 	getName() string
 	isClientOnly() bool
+	isServerOnly() bool
+	isOptional() bool
+
+	cacheKey() string
+	installedFilename(pack *ModPack) string
 
 	equalsJson(modJson *gabs.Container) bool
 	toJson() map[string]interface{}
@@ -70,46 +87,31 @@ func (pack *ModPack) fullName() string {
 	)
 }
 
-func OpenModPack(dir string, enableMultiMC bool) (*ModPack, error) {
-	return NewModPack(dir, "00", true, enableMultiMC)
+func OpenModPack(ctx context.Context, dir string, enableMultiMC bool) (*ModPack, error) {
+	return NewModPack(ctx, dir, "00", true, enableMultiMC)
 }
 
-func NewModPack(dir string, modLoader string, requireManifest bool, enableMultiMC bool) (*ModPack, error) {
+func NewModPack(ctx context.Context, dir string, modLoader string, requireManifest bool, enableMultiMC bool) (*ModPack, error) {
 	pack := new(ModPack)
 
 	// Open a copy of the database for modpack related ops
-	db, err := OpenDatabase()
+	db, err := OpenPackDatabase(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database for modpack: %+v", err)
 	}
 	pack.db = db
 
-	// Initialize path & name
-	if filepath.IsAbs(dir) {
-		pack.rootPath = dir
-		pack.Name = filepath.Base(dir)
-	} else if enableMultiMC {
-		pack.Name = dir
-		if mmcDir, err := _mmcInstancesDir(); err == nil {
-			pack.rootPath = filepath.Join(mmcDir, dir)
-		} else {
-			return nil, err
-		}
-	} else if dir == "." {
-		pack.rootPath, _ = os.Getwd()
-		pack.Name = filepath.Base(pack.rootPath)
-	} else {
-		pack.rootPath = filepath.Join(Env().McdexDir, "pack", dir)
-		pack.Name = dir
+	err = pack.initPaths(dir, enableMultiMC)
+	if err != nil {
+		return nil, err
 	}
 
 	// Use a temp directory until manifest is downloaded
 	if pack.Name == NamePlaceholder && !requireManifest {
-		pack.rootPath, _ = ioutil.TempDir(filepath.Dir(pack.rootPath), "mcdex-")
-	}
-
-	if enableMultiMC {
-		pack.gameDir = "minecraft"
+		pack.rootPath, err = newTempDir(filepath.Dir(pack.rootPath), "mcdex-")
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	// Try to load the manifest; only raise an error if we require it to be loaded
@@ -121,18 +123,12 @@ func NewModPack(dir string, modLoader string, requireManifest bool, enableMultiM
 	// If we loaded a manifest from disk, use the provided mod loader; otherwise, fallback to
 	// user provided
 	if pack.manifest != nil && pack.manifest.ExistsP("minecraft.modLoaders.id") {
-		// Identify the loader (forge or fabric)
-		loaderVsn := pack.manifest.Path("minecraft.modLoaders.id").Index(0).Data().(string)
-		if strings.HasPrefix(loaderVsn, "fabric-") {
-			pack.modLoader = "fabric"
-		} else {
-			pack.modLoader = "forge"
-		}
+		pack.identifyLoader()
 	} else {
 		pack.modLoader = modLoader
 	}
 
-	fmt.Printf("-- %s --\n", pack.gamePath())
+	logInfo("-- %s --\n", pack.gamePath())
 
 	// Create the directories
 	err = os.MkdirAll(pack.gamePath(), 0700)
@@ -154,7 +150,43 @@ func NewModPack(dir string, modLoader string, requireManifest bool, enableMultiM
 	return pack, nil
 }
 
-func (pack *ModPack) Download(url string) error {
+// initPaths determines the pack's name and location from the directory (or name) it was given
+func (pack *ModPack) initPaths(dir string, enableMultiMC bool) error {
+	if filepath.IsAbs(dir) {
+		pack.rootPath = dir
+		pack.Name = filepath.Base(dir)
+	} else if enableMultiMC {
+		pack.Name = dir
+		if mmcDir, err := _mmcInstancesDir(); err == nil {
+			pack.rootPath = filepath.Join(mmcDir, dir)
+		} else {
+			return err
+		}
+	} else if dir == "." {
+		pack.rootPath, _ = os.Getwd()
+		pack.Name = filepath.Base(pack.rootPath)
+	} else {
+		pack.rootPath = filepath.Join(Env().McdexDir, "pack", dir)
+		pack.Name = dir
+	}
+
+	if enableMultiMC {
+		// MultiMC accepts either minecraft or .minecraft; prefer whatever already exists
+		pack.gameDir = "minecraft"
+		if !dirExists(filepath.Join(pack.rootPath, pack.gameDir)) && dirExists(filepath.Join(pack.rootPath, ".minecraft")) {
+			pack.gameDir = ".minecraft"
+		}
+	}
+	return nil
+}
+
+// Download retrieves the pack's archive (pack.zip) from a URL or, if url is the path to a local
+// file, copies it into place
+func (pack *ModPack) Download(ctx context.Context, url string) error {
+	if IsLocalPackArchive(url) {
+		return pack.copyArchive(url)
+	}
+
 	// Check for a pack.url file; we use this to track where the pack
 	// file came from so that we can re-download the pack when it changes.
 	// This supports the use case of installing v 1.0.x of a pack and then updating
@@ -174,22 +206,25 @@ func (pack *ModPack) Download(url string) error {
 		return nil
 	}
 
-	fmt.Printf("Starting download of modpack: %s\n", url)
+	logInfo("Starting download of modpack: %s\n", url)
 
-	// For the moment, we only support modpacks from Curseforge or FTB; check and enforce these conditions
-	if !hasAnyPrefix(url, VALID_URL_PREFIXES...) {
-		return fmt.Errorf("Invalid modpack URL; we only support Curseforge & feed-the-beast.com right now")
+	// Packs can come from anywhere (the format is identified once it's downloaded), but only
+	// over https
+	if !strings.HasPrefix(url, "https://") {
+		return fmt.Errorf("Invalid modpack URL %s; only https URLs are supported", url)
 	}
 
 	// Start the download
-	resp, err := HttpGet(url)
+	resp, err := HttpGet(ctx, url)
 	if err != nil {
 		return fmt.Errorf("Failed to download %s: %+v", pack.Name, err)
 	}
 	defer resp.Body.Close()
 
 	// Store pack.zip in the working dir
-	err = writeStream(packFilename, resp.Body)
+	progress := newProgressReader(resp.Body, "Downloading "+pack.Name, resp.ContentLength)
+	err = writeStream(packFilename, progress)
+	progress.finish(err)
 	if err != nil {
 		return err
 	}
@@ -198,6 +233,50 @@ func (pack *ModPack) Download(url string) error {
 	return writeStringFile(packURLFile, url)
 }
 
+// IsLocalPackArchive determines if a pack "URL" is actually a (manually downloaded) pack archive
+func IsLocalPackArchive(url string) bool {
+	return !strings.Contains(url, "://") && fileExists(url)
+}
+
+// copyArchive installs a local pack archive as pack.zip, after making sure it actually is a pack
+func (pack *ModPack) copyArchive(filename string) error {
+	zipFile, err := zip.OpenReader(filename)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %+v", filename, err)
+	}
+	format, _ := detectPackFormat(zipFile)
+	zipFile.Close()
+	if format == "" {
+		return fmt.Errorf("%s is not a modpack", filename)
+	}
+
+	logInfo("Installing modpack from %s\n", filename)
+	f, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	err = writeStream(filepath.Join(pack.gamePath(), "pack.zip"), f)
+	if err != nil {
+		return err
+	}
+
+	// The pack didn't come from a URL, so make sure a later install from a URL downloads it again
+	os.Remove(filepath.Join(pack.gamePath(), "pack.url"))
+	return nil
+}
+
+// identifyLoader determines the mod loader (forge or fabric) from the manifest
+func (pack *ModPack) identifyLoader() {
+	loaderVsn, _ := pack.manifest.Path("minecraft.modLoaders.id").Index(0).Data().(string)
+	if strings.HasPrefix(loaderVsn, "fabric-") {
+		pack.modLoader = "fabric"
+	} else {
+		pack.modLoader = "forge"
+	}
+}
+
 func (pack *ModPack) ProcessManifest() error {
 	// Open the pack.zip and parse the manifest
 	zipFile, err := zip.OpenReader(filepath.Join(pack.gamePath(), "pack.zip"))
@@ -205,8 +284,8 @@ func (pack *ModPack) ProcessManifest() error {
 		return fmt.Errorf("Failed to open pack.zip: %v", err)
 	}
 
-	// Find the manifest file and decode it
-	pack.manifest, err = findJSONFile(zipFile, "manifest.json")
+	// Find the manifest file (converting it from other formats, if necessary) and decode it
+	pack.manifest, err = readPackManifest(zipFile, pack.Name)
 	_ = zipFile.Close()
 	if err != nil {
 		return err
@@ -223,20 +302,31 @@ func (pack *ModPack) ProcessManifest() error {
 		return fmt.Errorf("unexpected manifest type: %s", mtype)
 	}
 
+	migrateManifest(pack.manifest)
+	pack.identifyLoader()
+
 	if pack.Name == NamePlaceholder {
 		baseName := pack.fullName()
 		name := baseName
 		for i := 1; dirExists(filepath.Join(filepath.Dir(pack.rootPath), name)); i++ {
 			name = fmt.Sprintf("%s (%d)", baseName, i)
 		}
-		fmt.Printf("Modpack %q will be installed to directory %q\n", baseName, name)
+		logInfo("Modpack %q will be installed to directory %q\n", baseName, name)
+		oldRoot := pack.rootPath
 		newRoot := filepath.Join(filepath.Dir(pack.rootPath), name)
-		if err = os.Rename(pack.rootPath, newRoot); err != nil {
-			fmt.Printf("Unable to install to %q, will remain in temp directory %q:\n\t%+v\n", name, filepath.Base(pack.rootPath), err)
+		if pack.txn != nil {
+			// The staged pack is swapped in under its new name when the transaction commits
+			oldRoot = pack.txn.root
+			pack.txn.root = newRoot
+			pack.Name = name
+			releaseTempDir(oldRoot)
+		} else if err = os.Rename(pack.rootPath, newRoot); err != nil {
+			logWarn("Unable to install to %q, will remain in temp directory %q:\n\t%+v\n", name, filepath.Base(pack.rootPath), err)
 		} else {
 			pack.rootPath = newRoot
 			pack.Name = name
 		}
+		forgetTempDir(oldRoot)
 	}
 
 	return pack.SaveManifest()
@@ -246,42 +336,124 @@ func (pack *ModPack) minecraftVersion() string {
 	return pack.manifest.Path("minecraft.version").Data().(string)
 }
 
-func (pack *ModPack) CreateManifest(name, minecraftVsn string) error {
+// releaseChannel determines the release channel used when updating a mod: the mod's own channel,
+// if it has one, or the pack's default (minecraft.releaseChannel)
+func (pack *ModPack) releaseChannel(modChannel string) string {
+	channel := modChannel
+	if channel == "" {
+		channel, _ = pack.manifest.Path("minecraft.releaseChannel").Data().(string)
+	}
+
+	if err := validateReleaseChannel(channel); err != nil {
+		warn(WarnManifest, "%+v; using the default", err)
+		return ""
+	}
+	return channel
+}
+
+// SetReleaseChannel sets the release types (release, beta or alpha) that updates select by
+// default; an empty channel prefers releases but falls back to beta/alpha files
+func (pack *ModPack) SetReleaseChannel(channel string) error {
+	if err := validateReleaseChannel(channel); err != nil {
+		return err
+	}
+
+	if channel == "" {
+		pack.manifest.Delete("minecraft", "releaseChannel")
+	} else {
+		pack.manifest.Set(channel, "minecraft", "releaseChannel")
+	}
+	return pack.SaveManifest()
+}
+
+// CreateManifest creates the manifest for a new pack; the loader version is selected based on the
+// Minecraft version unless a specific version is given. Snapshots and pre-releases (or "snapshot",
+// for the latest snapshot) are accepted as the Minecraft version.
+func (pack *ModPack) CreateManifest(ctx context.Context, name, minecraftVsn, forgeChannel, loaderVsn string) error {
+	minecraftVsn, err := resolveMinecraftVersion(ctx, minecraftVsn)
+	if err != nil {
+		return err
+	}
+
 	// Create the manifest and set basic info
+	pack.initManifest(name, minecraftVsn)
+
+	if loaderVsn != "" {
+		err = pack.db.checkLoaderVsn(ctx, pack.modLoader, minecraftVsn, loaderVsn)
+		if err == nil {
+			pack.setLoaderVersion(loaderVsn)
+		}
+	} else {
+		// Select the appropriate loader version based on Minecraft version
+		err = pack.selectLoader(ctx, minecraftVsn, forgeChannel)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Write the manifest file
+	err = pack.SaveManifest()
+	if err != nil {
+		return fmt.Errorf("failed to save manifest.json: %+v", err)
+	}
+
+	return nil
+}
+
+func (pack *ModPack) initManifest(name, minecraftVsn string) {
 	pack.manifest = gabs.New()
 	pack.manifest.SetP(minecraftVsn, "minecraft.version")
 	pack.manifest.SetP("minecraftModpack", "manifestType")
 	pack.manifest.SetP(1, "manifestVersion")
 	pack.manifest.SetP(name, "name")
 	pack.manifest.SetP("0.0.1", "version")
+}
+
+func (pack *ModPack) setLoaderVersion(loaderVsn string) {
+	loader := make(map[string]interface{})
+	loader["id"] = fmt.Sprintf("%s-%s", pack.modLoader, loaderVsn)
+	loader["primary"] = true
 
-	// Select the appropriate loader version based on Minecraft version
+	pack.manifest.ArrayOfSizeP(1, "minecraft.modLoaders")
+	pack.manifest.Path("minecraft.modLoaders").SetIndex(loader, 0)
+}
+
+func (pack *ModPack) selectLoader(ctx context.Context, minecraftVsn, forgeChannel string) error {
 	var err error
 	var loaderVsn string
 	if pack.modLoader == "fabric" {
-		loaderVsn, err = pack.db.lookupFabricVsn(minecraftVsn)
+		loaderVsn, err = pack.db.lookupFabricVsn(ctx, minecraftVsn)
 	} else {
-		loaderVsn, err = pack.db.lookupForgeVsn(minecraftVsn)
+		loaderVsn, err = pack.db.lookupForgeVsn(ctx, minecraftVsn, forgeChannel)
 	}
 
 	if err != nil {
 		return err
 	}
 
-	loader := make(map[string]interface{})
-	loader["id"] = fmt.Sprintf("%s-%s", pack.modLoader, loaderVsn)
-	loader["primary"] = true
-
-	pack.manifest.ArrayOfSizeP(1, "minecraft.modLoaders")
-	pack.manifest.Path("minecraft.modLoaders").SetIndex(loader, 0)
+	pack.setLoaderVersion(loaderVsn)
+	return nil
+}
 
-	// Write the manifest file
-	err = pack.SaveManifest()
+// UpdateLoader re-selects the mod loader version for the pack's Minecraft version
+func (pack *ModPack) UpdateLoader(ctx context.Context, forgeChannel string, dryRun bool) error {
+	minecraftVsn, oldVsn := pack.getVersions()
+	err := pack.selectLoader(ctx, minecraftVsn, forgeChannel)
 	if err != nil {
-		return fmt.Errorf("failed to save manifest.json: %+v", err)
+		return err
 	}
 
-	return nil
+	_, newVsn := pack.getVersions()
+	if oldVsn == newVsn {
+		logInfo("%s %s is already selected\n", pack.modLoader, oldVsn)
+		return nil
+	}
+
+	logInfo("Updating %s: %s -> %s\n", pack.modLoader, oldVsn, newVsn)
+	if dryRun {
+		return nil
+	}
+	return pack.SaveManifest()
 }
 
 func (pack *ModPack) getVersions() (string, string) {
@@ -291,138 +463,418 @@ func (pack *ModPack) getVersions() (string, string) {
 	return minecraftVsn, loaderVsn
 }
 
-func (pack *ModPack) CreateLauncherProfile() error {
+// installClientLoader installs the pack's mod loader into the Minecraft directory, returning the
+// ID of the version profile
+func (pack *ModPack) installClientLoader(ctx context.Context) (string, error) {
+	// Loader versions that weren't there before are removed if the pack's install fails
+	versionsDir := filepath.Join(Env().MinecraftDir, "versions")
+	existing := listDir(versionsDir)
+	defer func() {
+		for name := range listDir(versionsDir) {
+			if !existing[name] {
+				pack.trackCreated(filepath.Join(versionsDir, name))
+			}
+		}
+	}()
+
+	return pack.installClientLoaderTo(ctx, Env().MinecraftDir)
+}
+
+// installClientLoaderTo installs the pack's mod loader for clients into a Minecraft directory,
+// returning the ID of its version profile
+func (pack *ModPack) installClientLoaderTo(ctx context.Context, baseDir string) (string, error) {
 	// Using manifest config version + mod loader, look for an installed
 	// version of forge|fabric with the appropriate version
 	minecraftVsn, loaderVsn := pack.getVersions()
 
-	var loaderId string
-	var err error
+	// Installers (and Forge's processors) need a JVM that can handle this version of Minecraft
+	err := selectJavaFor(ctx, minecraftVsn)
+	if err != nil {
+		return "", err
+	}
 
+	var loaderId string
 	if pack.modLoader == "fabric" {
-		loaderId, err = installClientFabric(minecraftVsn, loaderVsn)
+		loaderId, err = installClientFabric(ctx, baseDir, minecraftVsn, loaderVsn)
 	} else {
-		loaderId, err = installClientForge(minecraftVsn, loaderVsn)
+		loaderId, err = installClientForge(ctx, baseDir, minecraftVsn, loaderVsn)
 	}
 
 	if err != nil {
-		return fmt.Errorf("failed to install %s %s: %+v", pack.modLoader, loaderVsn, err)
+		return "", fmt.Errorf("failed to install %s %s: %+v", pack.modLoader, loaderVsn, err)
 	}
+	return loaderId, nil
+}
 
-	// Check the manifest for any Java arguments
-	javaArgs := ""
-	if pack.manifest.ExistsP("minecraft.javaArgs") {
-		javaArgs = pack.manifest.Path("minecraft.javaArgs").Data().(string)
+// clientLoaderId is the ID of the version profile for the pack's mod loader
+func (pack *ModPack) clientLoaderId() string {
+	minecraftVsn, loaderVsn := pack.getVersions()
+	if pack.modLoader == "fabric" {
+		return fabricContext{minecraftVsn: minecraftVsn, fabricVsn: loaderVsn}.fabricId()
 	}
+	return forgeContext{minecraftVsn: minecraftVsn, forgeVsn: loaderVsn}.forgeId()
+}
 
-	// Finally, load the launcher_profiles.json and make a new entry
-	// with appropriate name and reference to our pack directory and forge version
-	lc, err := newLauncherConfig()
+func (pack *ModPack) CreateLauncherProfile(ctx context.Context) error {
+	loaderId, err := pack.installClientLoader(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to load launcher_profiles.json: %+v", err)
+		return err
 	}
 
-	fmt.Printf("Creating profile: %s\n", pack.Name)
-	err = lc.createProfile(pack.Name, loaderId, pack.gamePath(), javaArgs)
+	// Merge the Java arguments from the manifest and command line
+	javaArgs, err := pack.launcherJavaArgs()
 	if err != nil {
-		return fmt.Errorf("failed to create profile: %+v", err)
+		return err
 	}
 
-	err = lc.save()
-	if err != nil {
-		return fmt.Errorf("failed to save profile: %+v", err)
-	}
+	// Finally, load the launcher_profiles.json and make a new entry with appropriate name and
+	// reference to our pack directory and forge version; when installing, this waits until the pack
+	// is in place
+	gamePath := filepath.Join(pack.installedRootPath(), pack.gameDir)
+	icon := profileIcon(filepath.Join(pack.gamePath(), "icon.png"))
+	return pack.afterCommit(func() error {
+		lc, err := newLauncherConfig()
+		if err != nil {
+			return fmt.Errorf("failed to load launcher_profiles.json: %+v", err)
+		}
 
-	return nil
+		logInfo("Creating profile: %s\n", pack.Name)
+		err = lc.createProfile(pack.Name, loaderId, gamePath, javaArgs, icon)
+		if err != nil {
+			return fmt.Errorf("failed to create profile: %+v", err)
+		}
+
+		err = lc.save()
+		if err != nil {
+			return fmt.Errorf("failed to save profile: %+v", err)
+		}
+		return nil
+	})
 }
 
-func (pack *ModPack) InstallMods(isClient bool) error {
+// Optional mods (those marked "required": false) are only installed when asked for
+var installOptional = false
+
+// SetInstallOptional includes (or excludes) optional mods when installing a pack's mods
+func SetInstallOptional(enabled bool) {
+	installOptional = enabled
+}
+
+func (pack *ModPack) InstallMods(ctx context.Context, isClient bool) error {
 	// Make sure mods directory already exists
 	os.MkdirAll(pack.modPath(), 0700)
 
+	err := pack.runHook(ctx, HookPreInstall, isClient)
+	if err != nil {
+		return err
+	}
+
+	// Remember which side the mods are for, so later updates (and their hooks) match
+	err = pack.modCache.SetServerInstall(!isClient)
+	if err != nil {
+		return fmt.Errorf("failed to update mod cache: %+v", err)
+	}
+
+	// Cleanup the cache; make sure that any entries are files that actually exist
+	err = pack.modCache.Cleanup(pack, true)
+	if err != nil {
+		return fmt.Errorf("failed to cleanup mod cache: %+v", err)
+	}
+
 	// Using manifest, download each mod file into pack directory
-	files, _ := pack.manifest.Path("files").Children()
+	var failures []failedDownload
+	files := manifestFiles(pack.manifest)
 	for _, f := range files {
+		if err := checkCanceled(ctx); err != nil {
+			return err
+		}
+
 		modFile, err := newModPackFile(f)
 		if err != nil {
-			return err
+			warn(WarnManifest, "%+v; skipping", err)
+			continue
 		}
 
 		if !isClient && modFile.isClientOnly() {
-			fmt.Printf("Skipping client-only mod %s\n", modFile.getName())
+			warn(WarnSkippedClientOnly, "%s is client-only; not installed", modFile.getName())
 			continue
 		}
 
-		err = modFile.install(pack)
-		if err != nil {
+		if isClient && modFile.isServerOnly() {
+			warn(WarnSkippedServerOnly, "%s is server-only; not installed", modFile.getName())
+			continue
+		}
+
+		if modFile.isOptional() && !pack.includeOptional(modFile) {
+			warn(WarnSkippedOptional, "%s is optional; not installed (use -with-optional to include it)", modFile.getName())
+			continue
+		}
+
+		err = modFile.install(ctx, pack)
+		if err != nil && ignoreFailures {
+			warn(WarnFailedDownloads, "%s: %+v", modFile.getName(), err)
+			failures = append(failures, failedDownload{modFile.cacheKey(), modFile.getName(), err.Error()})
+		} else if err != nil {
 			return fmt.Errorf("error installing mod file: %+v", err)
 		}
 	}
 
-	return nil
+	if ignoreFailures {
+		err = pack.writeFailedDownloads(failures)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Look for any mods that were manually added and see if we can identify them
+	err = pack.adoptUntrackedMods(ctx, true)
+	if err != nil {
+		warn(WarnUnknownMods, "unable to identify untracked mods: %+v", err)
+	}
+
+	return pack.runHook(ctx, HookPostInstall, isClient)
 }
 
 func (pack *ModPack) selectMod(modFile ModPackFile) error {
-	// Make sure files entry exists in manifest
-	if !pack.manifest.Exists("files") {
-		pack.manifest.ArrayOfSizeP(0, "files")
+	entry, err := gabs.Consume(modFile.toJson())
+	if err != nil {
+		return err
 	}
 
-	// Walk through the list of files; if we find one with same project ID, delete it
+	// Walk through the list of files; if we find one with same project ID, replace it
 	existingIndex := -1
-	files, _ := pack.manifest.S("files").Children()
+	files := manifestFiles(pack.manifest)
 	for i, child := range files {
 		if modFile.equalsJson(child) {
-			// Found a matching project ID; note the index so we can replace it
 			existingIndex = i
 			break
 		}
 	}
 
 	if existingIndex > -1 {
-		pack.manifest.S("files").SetIndex(modFile.toJson(), existingIndex)
+		files[existingIndex] = entry
 	} else {
-		pack.manifest.ArrayAppendP(modFile.toJson(), "files")
+		files = append(files, entry)
 	}
+	setManifestFiles(pack.manifest, files)
 
-	fmt.Printf("Registering: %s\n", modFile.getName())
+	logInfo("Registering: %s\n", modFile.getName())
 	return pack.SaveManifest()
 }
 
-func (pack *ModPack) UpdateMods(dryRun bool) error {
+// includeOptional decides if an optional mod is installed: always when optional mods are included
+// (see SetInstallOptional) or the mod is already installed; otherwise the user is asked, if mcdex is
+// running on a terminal
+func (pack *ModPack) includeOptional(modFile ModPackFile) bool {
+	if installOptional || pack.isModInstalled(modFile) {
+		return true
+	}
+	return isInteractive() && isTerminalOutput() &&
+		promptYesNo(fmt.Sprintf("Install optional mod %s?", modFile.getName()))
+}
+
+// isModInstalled checks that a mod's file is in the mods directory (enabled or not); the name
+// of a pinned Maven file is known whether or not it's been downloaded
+func (pack *ModPack) isModInstalled(modFile ModPackFile) bool {
+	name := modFile.installedFilename(pack)
+	return name != "" && modFileExists(filepath.Join(pack.modPath(), name))
+}
+
+// hasModFile determines if the manifest has an entry for a mod
+func (pack *ModPack) hasModFile(modFile ModPackFile) bool {
+	files := manifestFiles(pack.manifest)
+	for _, child := range files {
+		if modFile.equalsJson(child) {
+			return true
+		}
+	}
+	return false
+}
+
+// findModFile locates the manifest entry for a mod, identified either by slug, maven module or
+// ext:<name> for files downloaded from a URL
+func (pack *ModPack) findModFile(ctx context.Context, name string) (ModPackFile, *gabs.Container, error) {
+	target, err := pack.resolveModName(ctx, name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	files := manifestFiles(pack.manifest)
+	for _, child := range files {
+		if target.equalsJson(child) {
+			modFile, err := newModPackFile(child)
+			return modFile, child, err
+		}
+	}
+
+	return nil, nil, fmt.Errorf("%s is not selected in this pack", name)
+}
+
+// resolveModName identifies the mod a name (slug, modrinth:<slug>, ext:<name> or maven module)
+// refers to; the result is only useful for comparing against manifest entries and cache keys
+func (pack *ModPack) resolveModName(ctx context.Context, name string) (ModPackFile, error) {
+	if strings.HasPrefix(name, modrinthPrefix) {
+		project, err := projectSources["modrinth"].GetProject(ctx, strings.TrimPrefix(name, modrinthPrefix))
+		if err != nil {
+			return nil, fmt.Errorf("unknown mod %s: %+v", name, err)
+		}
+		return &ModrinthModFile{projectID: project.ID}, nil
+	} else if strings.HasPrefix(name, extPrefix) {
+		return &ExtModFile{name: strings.TrimPrefix(name, extPrefix)}, nil
+	} else if module, err := NewMavenModule(name); err == nil {
+		return &MavenModFile{module: module}, nil
+	}
+
+	projectID, err := pack.db.findModBySlug(ctx, name, pack.modLoader)
+	if err != nil {
+		return nil, fmt.Errorf("unknown mod %s: %+v", name, err)
+	}
+	return &CurseForgeModFile{projectID: projectID}, nil
+}
+
+// SetModEnabled disables (or re-enables) a mod by renaming its installed JAR; the state is tracked
+// so that subsequent installs and updates keep the mod disabled
+func (pack *ModPack) SetModEnabled(ctx context.Context, name string, enabled bool) error {
+	modFile, _, err := pack.findModFile(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	filename := modFile.installedFilename(pack)
+	if filename != "" {
+		enabledFile := filepath.Join(pack.modPath(), filename)
+		disabledFile := enabledFile + disabledSuffix
+		if enabled && fileExists(disabledFile) {
+			err = os.Rename(disabledFile, enabledFile)
+		} else if !enabled && fileExists(enabledFile) {
+			err = os.Rename(enabledFile, disabledFile)
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to rename %s: %+v", filename, err)
+		}
+	}
+
+	err = pack.modCache.SetDisabled(modFile.cacheKey(), !enabled)
+	if err != nil {
+		return fmt.Errorf("failed to record state of %s: %+v", modFile.getName(), err)
+	}
+
+	if enabled {
+		logInfo("Enabled %s\n", modFile.getName())
+	} else {
+		logInfo("Disabled %s\n", modFile.getName())
+	}
+	return nil
+}
+
+// applyDisabledState renames a freshly installed file if the user previously disabled the mod
+func (pack *ModPack) applyDisabledState(modFile ModPackFile, filename string) error {
+	if !pack.modCache.IsDisabled(modFile.cacheKey()) {
+		return nil
+	}
+
+	fullName := filepath.Join(pack.modPath(), filename)
+	logInfo("Keeping %s disabled\n", filename)
+	return os.Rename(fullName, fullName+disabledSuffix)
+}
+
+// UpdateMods selects the latest file for each mod that isn't locked, returning the mods that were
+// updated; on a dry run, the mods that would be updated are returned and the manifest isn't changed
+func (pack *ModPack) UpdateMods(ctx context.Context, dryRun bool) ([]ModUpdate, error) {
+	return pack.updateModsInTransaction(ctx, dryRun, false)
+}
+
+// UpdateModsInteractively shows each available update (with a snippet of its changelog) and asks
+// whether to apply it, skip it or lock the mod at its current version; nothing is selected until
+// the update is accepted
+func (pack *ModPack) UpdateModsInteractively(ctx context.Context) ([]ModUpdate, error) {
+	if !isInteractive() {
+		return nil, fmt.Errorf("interactive updates need a terminal")
+	}
+	return pack.updateModsInTransaction(ctx, false, true)
+}
+
+// updateModsInTransaction updates the mods, restoring the manifest if any of them fails
+func (pack *ModPack) updateModsInTransaction(ctx context.Context, dryRun, review bool) ([]ModUpdate, error) {
+	if dryRun {
+		return pack.updateMods(ctx, dryRun, review)
+	}
+
+	var updates []ModUpdate
+	err := pack.Transaction(func() error {
+		var err error
+		updates, err = pack.updateMods(ctx, dryRun, review)
+		return err
+	})
+	return updates, err
+}
+
+func (pack *ModPack) updateMods(ctx context.Context, dryRun, review bool) ([]ModUpdate, error) {
+	// Before updating, pick up any mods that were manually added so they get updated too
+	if !dryRun {
+		err := pack.adoptUntrackedMods(ctx, true)
+		if err != nil {
+			warn(WarnUnknownMods, "unable to identify untracked mods: %+v", err)
+		}
+	}
+
 	// Walk over each file, looking for a more recent file ID for the
 	// appropriate version
-	files, _ := pack.manifest.S("files").Children()
+	var updates []ModUpdate
+	files := manifestFiles(pack.manifest)
 	for _, child := range files {
 		modFile, err := newModPackFile(child)
 		if err != nil {
-			return fmt.Errorf("unable to update: %+v", err)
+			warn(WarnManifest, "%+v; skipping", err)
+			continue
 		}
 
-		isLocked := child.Exists("locked") && child.S("locked").Data().(bool)
-		if isLocked {
-			fmt.Printf("Skipping update: %s (locked)\n", modFile.getName())
+		if mcdexBool(child, "locked") {
+			warn(WarnSkippedLocked, "%s is locked; not updated", modFile.getName())
 			continue
 		}
 
-		updated, err := modFile.update(pack)
-		if err != nil {
-			return err
+		filename := modFile.installedFilename(pack)
+		current, _ := newModPackFile(child)
+		updated, err := modFile.update(ctx, pack)
+		if _, ok := err.(constraintError); ok {
+			warn(WarnSkippedConstraint, "%s: %+v; not updated", modFile.getName(), err)
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		if updated && review {
+			switch reviewModUpdate(ctx, pack, current, modFile) {
+			case updateSkip:
+				continue
+			case updateLock:
+				child.Set(true, mcdexNamespace, "locked")
+				logInfo("Locked %s at its current version\n", current.getName())
+				continue
+			}
 		}
 
 		if updated {
 			if dryRun {
-				fmt.Printf("Update available: %s\n", modFile.getName())
+				logStyled(LogInfo, styleNew, "Update available: %s\n", modFile.getName())
 			} else {
 				pack.selectMod(modFile)
 			}
+			updates = append(updates, ModUpdate{modFile.cacheKey(), modFile.getName(), filename, current, modFile})
 		}
 	}
 
-	if !dryRun {
-		return pack.SaveManifest()
+	if dryRun {
+		return updates, nil
 	}
-	return nil
+
+	err := pack.SaveManifest()
+	if err != nil {
+		return nil, err
+	}
+	return updates, pack.runHook(ctx, HookPostUpdate, !pack.modCache.IsServerInstall())
 }
 
 func (pack *ModPack) SaveManifest() error {
@@ -438,9 +890,13 @@ func (pack *ModPack) loadManifest() error {
 	// Load the manifest
 	manifest, err := gabs.ParseJSONFile(filepath.Join(pack.gamePath(), "manifest.json"))
 	if err != nil {
-		return fmt.Errorf("Failed to load manifest from %s: %+v", pack.gamePath, err)
+		return fmt.Errorf("Failed to load manifest from %s: %+v", pack.gamePath(), err)
 	}
 	pack.manifest = manifest
+
+	// Move any mcdex-specific fields from older manifests into the mcdex namespace; the
+	// migrated manifest is written out the next time it's saved
+	migrateManifest(pack.manifest)
 	return nil
 }
 
@@ -452,18 +908,28 @@ func (pack *ModPack) InstallOverrides() error {
 	}
 	defer zipFile.Close()
 
-	fmt.Printf("Installing files from modpack archive\n")
-	overrides := pack.manifest.Path("overrides").Data().(string) + "/"
+	logInfo("Installing files from modpack archive\n")
+	// Hand-made packs may leave out the overrides directory; CurseForge's default is "overrides"
+	overrides, ok := pack.manifest.Path("overrides").Data().(string)
+	if !ok {
+		overrides = "overrides"
+	}
+	overrides += "/"
 
 	// Walk over every file in the pack that is prefixed with installOverrides
 	// and write it out
+	installed := make(map[string]bool)
+	var preserved []string
 	for _, f := range zipFile.File {
 		if f.FileInfo().IsDir() || !strings.HasPrefix(f.Name, overrides) {
 			continue
 		}
 
-		filename := filepath.Join(pack.gamePath(), strings.Replace(f.Name, overrides, "", -1))
-		filename = stripBadUTF8(filename)
+		relName := stripBadUTF8(strings.TrimPrefix(f.Name, overrides))
+		filename, err := safeJoin(pack.gamePath(), relName)
+		if err != nil {
+			return fmt.Errorf("invalid file in modpack archive: %+v", err)
+		}
 
 		// Make sure the directory for the file exists
 		os.MkdirAll(filepath.Dir(filename), 0700)
@@ -473,26 +939,74 @@ func (pack *ModPack) InstallOverrides() error {
 			return fmt.Errorf("failed to open %s: %+v", f.Name, err)
 		}
 
-		err = writeStream(filename, freader)
+		data, err := ioutil.ReadAll(freader)
+		freader.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %+v", f.Name, err)
+		}
+
+		hashBytes := sha256.Sum256(data)
+		newHash := hex.EncodeToString(hashBytes[:])
+		installed[relName] = true
+
+		// If the file already exists, check if the user modified it since it was last installed;
+		// if so, write the new version alongside instead of overwriting their changes
+		targetFile := filename
+		if fileExists(filename) {
+			lastHash := pack.modCache.GetOverrideHash(relName)
+			diskHash, _ := sha256File(filename)
+			if diskHash == newHash {
+				err = pack.modCache.AddOverride(relName, newHash)
+				if err != nil {
+					return fmt.Errorf("failed to register %s in mod cache: %+v", relName, err)
+				}
+				continue
+			}
+
+			if lastHash != "" && diskHash != lastHash {
+				if newHash == lastHash {
+					// Nothing changed in the pack; keep the user's version
+					continue
+				}
+				targetFile = filename + ".new"
+				preserved = append(preserved, relName)
+			}
+		}
+
+		err = writeStream(targetFile, bytes.NewReader(data))
 		if err != nil {
 			return fmt.Errorf("failed to save: %+v", err)
 		}
+
+		err = pack.modCache.AddOverride(relName, newHash)
+		if err != nil {
+			return fmt.Errorf("failed to register %s in mod cache: %+v", relName, err)
+		}
 	}
 
-	return nil
+	for _, name := range preserved {
+		warn(WarnPreservedFiles, "%s was modified locally; new version saved as %s.new", name, name)
+	}
+
+	// Remove any overrides from a previous install that are no longer in the pack
+	return pack.modCache.CleanupOverrides(installed)
 }
 
-func (pack *ModPack) InstallServer() error {
+func (pack *ModPack) InstallServer(ctx context.Context) error {
 	// Get the minecraft + forge versions from manifest
 	minecraftVsn := pack.manifest.Path("minecraft.version").Data().(string)
 	loaderVsn := pack.manifest.Path("minecraft.modLoaders.id").Index(0).Data().(string)
 	loaderVsn = strings.TrimPrefix(loaderVsn, pack.modLoader + "-")
 
-	var err error
+	err := selectJavaFor(ctx, minecraftVsn)
+	if err != nil {
+		return err
+	}
+
 	if pack.modLoader == "fabric" {
-		err = installServerFabric(minecraftVsn, loaderVsn, pack.gamePath())
+		err = installServerFabric(ctx, minecraftVsn, loaderVsn, pack.gamePath())
 	} else {
-		err = installServerForge(minecraftVsn, loaderVsn, pack.gamePath())
+		err = installServerForge(ctx, minecraftVsn, loaderVsn, pack.gamePath())
 	}
 
 	if err != nil {
@@ -502,11 +1016,39 @@ func (pack *ModPack) InstallServer() error {
 	return nil
 }
 
+// VerifyCache reports on the state of the pack's metacache
+func (pack *ModPack) VerifyCache() error {
+	problems, err := pack.modCache.Verify()
+	if err != nil {
+		return fmt.Errorf("failed to verify mod cache: %+v", err)
+	}
+
+	if problems > 0 {
+		logWarn("%d cache entries refer to missing files; use cache.rebuild to repair\n", problems)
+	}
+	return nil
+}
+
+// RebuildCache reconstructs the pack's metacache from the installed files
+func (pack *ModPack) RebuildCache(ctx context.Context) error {
+	logInfo("Rebuilding mod cache for %s\n", pack.Name)
+	return pack.modCache.Rebuild(ctx, pack)
+}
+
 func (pack *ModPack) GenerateMMCConfig() error {
 	return generateMMCConfig(pack)
 }
 
 func newModPackFile(modJson *gabs.Container) (ModPackFile, error) {
+	source := sourceForEntry(modJson)
+	if source == "modrinth" {
+		return NewModrinthModFile(modJson), nil
+	} else if source == "ext" {
+		return NewExtModFile(modJson), nil
+	} else if source != "curseforge" {
+		return nil, fmt.Errorf("unknown source %s for mod file entry: %s", source, modJson.String())
+	}
+
 	if modJson.ExistsP("projectID") {
 		return NewCurseForgeModFile(modJson), nil
 	} else if modJson.ExistsP("module") {
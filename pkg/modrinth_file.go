@@ -0,0 +1,164 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/Jeffail/gabs"
+)
+
+// Prefix used to select a mod from Modrinth instead of CurseForge (e.g. modrinth:sodium)
+const modrinthPrefix = "modrinth:"
+
+type ModrinthModFile struct {
+	projectID  string
+	fileID     string
+	name       string
+	clientOnly bool
+	serverOnly bool
+	optional   bool
+	constraint string // only files with a matching version are selected (see parseVersionConstraint)
+	channel    string // overrides the pack's release channel (see ModPack.releaseChannel)
+}
+
+func SelectModrinthModFile(ctx context.Context, pack *ModPack, mod string, clientOnly bool) error {
+	mod, constraint := splitVersionConstraint(mod)
+	source := projectSources["modrinth"]
+	project, err := source.GetProject(ctx, strings.TrimPrefix(mod, modrinthPrefix))
+	if err != nil {
+		return fmt.Errorf("unknown mod %s: %+v", mod, err)
+	}
+
+	modFile := ModrinthModFile{projectID: project.ID, name: project.Name, clientOnly: clientOnly, constraint: constraint}
+	_, err = modFile.update(ctx, pack)
+	if err != nil {
+		return fmt.Errorf("failed to get latest file for %s (%s): %+v", mod, project.ID, err)
+	}
+
+	// Modrinth reports which sides a project is used on
+	if !clientOnly && sideDetection {
+		modFile.clientOnly = detectClientOnly(ctx, &FileInfo{ClientOnly: project.ClientOnly, SidesKnown: true}, project.Name)
+	}
+
+	return pack.selectMod(&modFile)
+}
+
+func NewModrinthModFile(modJson *gabs.Container) *ModrinthModFile {
+	projectID, _ := strValue(modJson, "projectID")
+	fileID, _ := strValue(modJson, "fileID")
+	name, ok := mcdexString(modJson, "desc")
+	if !ok {
+		name = fmt.Sprintf("Modrinth project %s: %s", projectID, fileID)
+	}
+	constraint, _ := mcdexString(modJson, "constraint")
+	channel, _ := mcdexString(modJson, "releaseChannel")
+	return &ModrinthModFile{projectID, fileID, name, mcdexBool(modJson, "clientOnly"), mcdexBool(modJson, "serverOnly"), !isRequired(modJson), constraint, channel}
+}
+
+func (f ModrinthModFile) source() ProjectSource {
+	return projectSources["modrinth"]
+}
+
+func (f ModrinthModFile) install(ctx context.Context, pack *ModPack) error {
+	file, err := f.source().GetFile(ctx, f.projectID, f.fileID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve descriptor for %s: %+v", f.name, err)
+	}
+
+	// Check the mod cache to see if we already have the right file installed
+	lastURL, lastFilename := pack.modCache.GetLastExtURL(f.cacheKey())
+	if lastURL == file.DownloadURL && modFileExists(filepath.Join(pack.modPath(), lastFilename)) {
+		logDebug("Skipping %s\n", lastFilename)
+		return nil
+	} else if lastURL != "" {
+		// A different version of the file is installed; clean it up
+		pack.modCache.CleanupExtFile(f.cacheKey())
+	}
+
+	filename, err := downloadHttpFileToDir(ctx, file.DownloadURL, pack.modPath(), true)
+	if err != nil {
+		return err
+	}
+
+	pack.modCache.AddExtFile(f.cacheKey(), file.DownloadURL, filename)
+	return pack.applyDisabledState(&f, filename)
+}
+
+func (f *ModrinthModFile) update(ctx context.Context, pack *ModPack) (bool, error) {
+	files, err := f.source().GetLatestFiles(ctx, f.projectID)
+	if err != nil {
+		return false, fmt.Errorf("failed to retrieve project for %s: %+v", f.name, err)
+	}
+
+	files, err = filterFilesByConstraint(files, pack.modConstraint(ctx, f, f.constraint))
+	if err != nil {
+		return false, err
+	}
+
+	latest, err := selectLatestFile(files, pack.minecraftVersion(), pack.modLoader, pack.releaseChannel(f.channel))
+	if err != nil {
+		return false, err
+	}
+
+	// Modrinth version IDs aren't ordered, but the files are listed newest first
+	if latest.ID != f.fileID {
+		f.fileID = latest.ID
+		return true, nil
+	}
+	return false, nil
+}
+
+func (f ModrinthModFile) getName() string {
+	return f.name
+}
+
+func (f ModrinthModFile) isClientOnly() bool {
+	return f.clientOnly
+}
+
+func (f ModrinthModFile) isServerOnly() bool {
+	return f.serverOnly
+}
+
+func (f ModrinthModFile) isOptional() bool {
+	return f.optional
+}
+
+func (f ModrinthModFile) cacheKey() string {
+	return "modrinth:" + f.projectID
+}
+
+func (f ModrinthModFile) installedFilename(pack *ModPack) string {
+	_, filename := pack.modCache.GetLastExtURL(f.cacheKey())
+	return filename
+}
+
+func (f ModrinthModFile) equalsJson(modJson *gabs.Container) bool {
+	projectID, ok := modJson.Path("projectID").Data().(string)
+	return ok && sourceForEntry(modJson) == "modrinth" && projectID == f.projectID
+}
+
+func (f ModrinthModFile) toJson() map[string]interface{} {
+	result := map[string]interface{}{
+		"projectID": f.projectID,
+		"fileID":    f.fileID,
+		"required":  !f.optional,
+	}
+	mcdexFields(result)["source"] = "modrinth"
+	mcdexFields(result)["desc"] = f.name
+	if f.clientOnly {
+		mcdexFields(result)["clientOnly"] = true
+	}
+	if f.serverOnly {
+		mcdexFields(result)["serverOnly"] = true
+	}
+	if f.constraint != "" {
+		mcdexFields(result)["constraint"] = f.constraint
+	}
+	if f.channel != "" {
+		mcdexFields(result)["releaseChannel"] = f.channel
+	}
+	return result
+}
@@ -0,0 +1,61 @@
+package pkg
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/Jeffail/gabs"
+)
+
+// Mojang's version manifest goes through the database's metadata cache (see getCachedURLWithTTL);
+// it changes whenever a snapshot is published, so it's revalidated more often than loader metadata
+const versionManifestTTL = 1 * time.Hour
+
+// getGlobalManifest retrieves Mojang's list of Minecraft versions, going through the cache
+func getGlobalManifest(ctx context.Context) (*gabs.Container, error) {
+	db, err := OpenPackDatabase(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := db.getCachedURLWithTTL(ctx, GLOBAL_MANIFEST, versionManifestTTL)
+	if err != nil {
+		return nil, err
+	}
+	return gabs.ParseJSON([]byte(data))
+}
+
+// getVersionManifest retrieves the manifest of a Minecraft version, given its entry in the global
+// manifest. Version manifests don't change once they're published, so a cached copy is used as long
+// as it matches the hash in the entry.
+func getVersionManifest(ctx context.Context, versionObj *gabs.Container) (*gabs.Container, error) {
+	url, _ := versionObj.Path("url").Data().(string)
+
+	db, err := OpenPackDatabase(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if hash, ok := versionObj.Path("sha1").Data().(string); ok {
+		data, _, _, cached, err := db.lookupCachedURL(url)
+		if err == nil && cached && sha1String(data) == hash {
+			if manifest, err := gabs.ParseJSON([]byte(data)); err == nil {
+				return manifest, nil
+			}
+		}
+	}
+
+	data, err := db.getCachedURLWithTTL(ctx, url, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve %s: %+v", url, err)
+	}
+	return gabs.ParseJSON([]byte(data))
+}
+
+func sha1String(data string) string {
+	hash := sha1.Sum([]byte(data))
+	return hex.EncodeToString(hash[:])
+}
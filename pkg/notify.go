@@ -0,0 +1,94 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Discord rejects messages longer than this
+const maxNotificationLength = 2000
+
+// ModUpdate describes a mod for which a newer file is available (or was selected)
+type ModUpdate struct {
+	Key      string
+	Name     string
+	Filename string // the file installed before the update; empty if the mod wasn't installed
+
+	from, to ModPackFile // the mod before and after the update (see describeModUpdate)
+}
+
+// NotifyWebhook returns the webhook to notify about updates (notify.webhook in the config file),
+// if any
+func NotifyWebhook() string {
+	if cfg := GetConfig(); cfg != nil {
+		return cfg.getString("notify.webhook", "")
+	}
+	return ""
+}
+
+// NotifyUpdates posts a summary of mod updates to a Discord or Slack webhook, so people running
+// updates unattended (e.g. from cron) hear about them; nothing is posted if there are no updates
+func NotifyUpdates(ctx context.Context, webhookURL string, pack *ModPack, updates []ModUpdate, applied bool) error {
+	if len(updates) == 0 {
+		return nil
+	}
+
+	var text strings.Builder
+	if applied {
+		fmt.Fprintf(&text, "Updated %d mod(s) in %s:\n", len(updates), pack.Name)
+	} else {
+		fmt.Fprintf(&text, "%d mod update(s) available for %s:\n", len(updates), pack.Name)
+	}
+
+	for i, update := range updates {
+		line := fmt.Sprintf("- %s\n", update.Name)
+		if update.Filename != "" {
+			line = fmt.Sprintf("- %s (was %s)\n", update.Name, update.Filename)
+		}
+
+		if text.Len()+len(line) > maxNotificationLength-50 {
+			fmt.Fprintf(&text, "...and %d more\n", len(updates)-i)
+			break
+		}
+		text.WriteString(line)
+	}
+
+	return postWebhook(ctx, webhookURL, text.String())
+}
+
+// postWebhook sends a message to a webhook; Discord expects the message in the content field,
+// while Slack (and most others that mimic it) expect it in the text field
+func postWebhook(ctx context.Context, webhookURL, message string) error {
+	u, err := url.Parse(webhookURL)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL %s: %+v", webhookURL, err)
+	}
+
+	field := "text"
+	if strings.HasSuffix(u.Hostname(), "discord.com") || strings.HasSuffix(u.Hostname(), "discordapp.com") {
+		field = "content"
+	}
+
+	body, err := json.Marshal(map[string]string{field: message})
+	if err != nil {
+		return fmt.Errorf("failed to encode notification: %+v", err)
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewReader(body))
+	req.Header.Add("Content-Type", "application/json")
+	res, err := getterClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post notification to %s: %+v", u.Hostname(), err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("failed to post notification to %s: %d", u.Hostname(), res.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,195 @@
+package pkg
+
+import (
+	"archive/zip"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/Jeffail/gabs"
+)
+
+// Modpack archive formats that can be installed; CurseForge's manifest.json is what mcdex uses
+// internally, so the others are converted to it
+const (
+	packFormatCurseForge = "curseforge"
+	packFormatModrinth   = "modrinth"
+	packFormatMMC        = "mmc"
+)
+
+// Dependencies in modrinth.index.json for each mod loader
+var modrinthLoaderDependencies = map[string]string{
+	"forge":         "forge",
+	"neoforge":      "neoforge",
+	"fabric-loader": "fabric",
+	"quilt-loader":  "quilt",
+}
+
+// Files hosted on Modrinth's CDN identify the project and version they belong to
+var modrinthCDNRegex = regexp.MustCompile(`^https://cdn\.modrinth\.com/data/([\w]+)/versions/([\w]+)/`)
+
+// readPackManifest identifies the format of a modpack archive and returns its manifest, in
+// CurseForge's format
+func readPackManifest(zipFile *zip.ReadCloser, name string) (*gabs.Container, error) {
+	format, prefix := detectPackFormat(zipFile)
+	logDebug("Modpack archive format: %s", format)
+
+	switch format {
+	case packFormatCurseForge:
+		return findJSONFile(&zipFile.Reader, "manifest.json")
+	case packFormatModrinth:
+		index, err := findJSONFile(&zipFile.Reader, "modrinth.index.json")
+		if err != nil {
+			return nil, err
+		}
+		return convertModrinthIndex(index)
+	case packFormatMMC:
+		mmcpack, err := findJSONFile(&zipFile.Reader, prefix+"mmc-pack.json")
+		if err != nil {
+			return nil, err
+		}
+		return convertMMCInstance(zipFile, mmcpack, prefix, name)
+	}
+	return nil, fmt.Errorf("unrecognized modpack archive; expected a CurseForge or Modrinth modpack, or a MultiMC instance")
+}
+
+// detectPackFormat looks for the files that identify each format; MultiMC instances are usually
+// exported with the instance directory at the top of the archive, so its path is returned too
+func detectPackFormat(zipFile *zip.ReadCloser) (string, string) {
+	for _, f := range zipFile.File {
+		switch f.Name {
+		case "manifest.json":
+			return packFormatCurseForge, ""
+		case "modrinth.index.json":
+			return packFormatModrinth, ""
+		}
+	}
+
+	for _, f := range zipFile.File {
+		dir, file := path.Split(f.Name)
+		if file == "mmc-pack.json" && strings.Count(dir, "/") <= 1 {
+			return packFormatMMC, dir
+		}
+	}
+	return "", ""
+}
+
+// convertModrinthIndex converts a Modrinth modpack index (modrinth.index.json) into a manifest;
+// only files hosted on Modrinth can be tracked, anything else is skipped with a warning. Files
+// that are optional on clients become optional mods.
+func convertModrinthIndex(index *gabs.Container) (*gabs.Container, error) {
+	name, _ := strValue(index, "name")
+	version, _ := strValue(index, "versionId")
+	minecraftVsn, err := strValue(index, "dependencies.minecraft")
+	if err != nil {
+		return nil, fmt.Errorf("no Minecraft version in modrinth.index.json")
+	}
+
+	var loader, loaderVsn string
+	deps, _ := index.Path("dependencies").ChildrenMap()
+	for dep, value := range deps {
+		if l, ok := modrinthLoaderDependencies[dep]; ok {
+			loader = l
+			loaderVsn, _ = value.Data().(string)
+		}
+	}
+	if loader == "" {
+		return nil, fmt.Errorf("no mod loader in modrinth.index.json")
+	}
+
+	manifest := newManifest(name, version, minecraftVsn, loader, loaderVsn)
+	files, _ := index.Path("files").Children()
+	for _, file := range files {
+		filePath, _ := strValue(file, "path")
+		downloadURL, _ := file.Path("downloads").Index(0).Data().(string)
+
+		match := modrinthCDNRegex.FindStringSubmatch(downloadURL)
+		if match == nil || path.Dir(filePath) != "mods" {
+			warn(WarnManifest, "%s isn't a mod hosted on Modrinth; not installed", filePath)
+			continue
+		}
+
+		serverSupport, _ := strValue(file, "env.server")
+		clientSupport, _ := strValue(file, "env.client")
+		modFile := ModrinthModFile{
+			projectID:  match[1],
+			fileID:     match[2],
+			name:       strings.TrimSuffix(path.Base(filePath), ".jar"),
+			clientOnly: serverSupport == "unsupported",
+			serverOnly: clientSupport == "unsupported",
+			optional:   clientSupport == "optional",
+		}
+		addManifestFile(manifest, modFile.toJson())
+	}
+	return manifest, nil
+}
+
+// convertMMCInstance converts an exported MultiMC instance into a manifest; the instance's game
+// directory becomes the overrides, and its mods are identified when the pack is installed
+func convertMMCInstance(zipFile *zip.ReadCloser, mmcpack *gabs.Container, prefix, name string) (*gabs.Container, error) {
+	minecraftVsn, loader, loaderVsn := parseMMCComponents(mmcpack)
+	if minecraftVsn == "" || loader == "" {
+		return nil, fmt.Errorf("unable to identify Minecraft and mod loader versions in mmc-pack.json")
+	}
+
+	if prefix != "" {
+		name = strings.TrimSuffix(prefix, "/")
+	}
+	manifest := newManifest(name, "", minecraftVsn, loader, loaderVsn)
+
+	// MultiMC accepts either minecraft or .minecraft as the game directory
+	overrides := prefix + ".minecraft"
+	for _, f := range zipFile.File {
+		if strings.HasPrefix(f.Name, prefix+"minecraft/") {
+			overrides = prefix + "minecraft"
+			break
+		}
+	}
+	manifest.Set(overrides, "overrides")
+	return manifest, nil
+}
+
+// newManifest creates a manifest for a pack converted from another format
+func newManifest(name, version, minecraftVsn, loader, loaderVsn string) *gabs.Container {
+	if version == "" {
+		version = "0.0.1"
+	}
+
+	manifest := gabs.New()
+	manifest.SetP(minecraftVsn, "minecraft.version")
+	manifest.SetP("minecraftModpack", "manifestType")
+	manifest.SetP(1.0, "manifestVersion")
+	manifest.SetP(name, "name")
+	manifest.SetP(version, "version")
+	manifest.SetP("overrides", "overrides")
+	manifest.ArrayOfSizeP(1, "minecraft.modLoaders")
+	manifest.Path("minecraft.modLoaders").SetIndex(map[string]interface{}{
+		"id":      fmt.Sprintf("%s-%s", loader, loaderVsn),
+		"primary": true,
+	}, 0)
+	manifest.ArrayOfSizeP(0, "files")
+	return manifest
+}
+
+// parseMMCComponents identifies the Minecraft and mod loader versions in mmc-pack.json
+func parseMMCComponents(mmcpack *gabs.Container) (string, string, string) {
+	var minecraftVsn, loader, loaderVsn string
+	components, _ := mmcpack.Path("components").Children()
+	for _, c := range components {
+		uid, _ := strValue(c, "uid")
+		vsn, _ := strValue(c, "version")
+		if uid == "net.minecraft" {
+			minecraftVsn = vsn
+			continue
+		}
+
+		for l, loaderUid := range mmcLoaderComponents {
+			if uid == loaderUid {
+				loader = l
+				loaderVsn = vsn
+			}
+		}
+	}
+	return minecraftVsn, loader, loaderVsn
+}
@@ -0,0 +1,297 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Jeffail/gabs"
+)
+
+// ModStatus describes a mod selected in a pack; mods are identified by their cache key (e.g.
+// curseforge:<project ID>) since not every source has a slug
+type ModStatus struct {
+	Key        string
+	Name       string
+	Filename   string // empty if the mod isn't installed
+	ClientOnly bool
+	ServerOnly bool
+	Optional   bool
+	Locked     bool
+	Disabled   bool
+}
+
+// Mods lists the mods selected in the pack's manifest
+func (pack *ModPack) Mods() []ModStatus {
+	var result []ModStatus
+	files := manifestFiles(pack.manifest)
+	for _, f := range files {
+		modFile, err := newModPackFile(f)
+		if err != nil {
+			continue
+		}
+
+		result = append(result, ModStatus{
+			Key:        modFile.cacheKey(),
+			Name:       modFile.getName(),
+			Filename:   modFile.installedFilename(pack),
+			ClientOnly: modFile.isClientOnly(),
+			ServerOnly: modFile.isServerOnly(),
+			Optional:   modFile.isOptional(),
+			Locked:     mcdexBool(f, "locked"),
+			Disabled:   pack.modCache.IsDisabled(modFile.cacheKey()),
+		})
+	}
+	return result
+}
+
+// OutdatedMod describes a mod for which a newer file is available than the one in the manifest
+type OutdatedMod struct {
+	Key         string
+	Name        string
+	Current     string    // the file ID (or version) selected in the manifest
+	Latest      string    // the file ID (or version) of the newest file
+	LatestFile  string    // the newest file's name; empty if it isn't known until it's downloaded
+	ReleaseType int       // the newest file's release type; 0 if the source doesn't have them
+	Published   time.Time // when the newest file was published; zero if unknown
+	Locked      bool
+}
+
+// OutdatedMods checks every mod for a newer file, without changing the manifest; mods that can't
+// be checked are reported as warnings
+func (pack *ModPack) OutdatedMods(ctx context.Context) []OutdatedMod {
+	var result []OutdatedMod
+	files := manifestFiles(pack.manifest)
+	for _, f := range files {
+		modFile, err := newModPackFile(f)
+		if err != nil {
+			warn(WarnManifest, "%+v; skipping", err)
+			continue
+		}
+
+		current := modFileVersion(modFile)
+		updated, err := modFile.update(ctx, pack)
+		if err != nil {
+			warn(WarnOther, "unable to check %s for updates: %+v", modFile.getName(), err)
+			continue
+		} else if !updated {
+			continue
+		}
+
+		mod := OutdatedMod{
+			Key:     modFile.cacheKey(),
+			Name:    modFile.getName(),
+			Current: current,
+			Latest:  modFileVersion(modFile),
+			Locked:  mcdexBool(f, "locked"),
+		}
+		if source, projectID, fileID, ok := sourceFile(modFile); ok {
+			if file, err := source.GetFile(ctx, projectID, fileID); err == nil {
+				mod.LatestFile = file.Filename
+				mod.ReleaseType = file.ReleaseType
+				mod.Published = file.Date
+			}
+		}
+		result = append(result, mod)
+	}
+	return result
+}
+
+// findModFileByKey locates the manifest entry (and its index) for a mod by cache key
+func (pack *ModPack) findModFileByKey(key string) (ModPackFile, *gabs.Container, int, error) {
+	files := manifestFiles(pack.manifest)
+	for i, f := range files {
+		modFile, err := newModPackFile(f)
+		if err == nil && modFile.cacheKey() == key {
+			return modFile, f, i, nil
+		}
+	}
+	return nil, nil, -1, fmt.Errorf("%s is not selected in this pack", key)
+}
+
+// setModHash records the SHA-256 hash of a mod's file in its manifest entry
+func (pack *ModPack) setModHash(key, hash string) error {
+	_, entry, _, err := pack.findModFileByKey(key)
+	if err != nil {
+		return err
+	}
+
+	_, err = entry.Set(hash, "sha256")
+	if err != nil {
+		return fmt.Errorf("failed to record hash of %s: %+v", key, err)
+	}
+	return pack.SaveManifest()
+}
+
+// CheckModUpdate determines if a newer file is available for a mod; the manifest isn't changed
+func (pack *ModPack) CheckModUpdate(ctx context.Context, key string) (bool, error) {
+	modFile, _, _, err := pack.findModFileByKey(key)
+	if err != nil {
+		return false, err
+	}
+	return modFile.update(ctx, pack)
+}
+
+// ModUpdateCheck is the result of checking a mod for a newer file (see CheckModUpdates)
+type ModUpdateCheck struct {
+	Key     string
+	Updated bool
+	Err     error
+}
+
+// CheckModUpdates looks for newer files for the given mods in the background, sending the result for
+// each on the returned channel; it's closed once they've all been checked. The checks use a copy of
+// the manifest, so the pack can be changed (e.g. by the caller applying the results) while they run.
+func (pack *ModPack) CheckModUpdates(ctx context.Context, keys []string) <-chan ModUpdateCheck {
+	snapshot := *pack
+	snapshot.constraints = nil
+	snapshot.txn = nil
+	manifest, err := gabs.ParseJSON(pack.manifest.Bytes())
+	snapshot.manifest = manifest
+
+	results := make(chan ModUpdateCheck)
+	go func() {
+		defer close(results)
+		for _, key := range keys {
+			if ctx.Err() != nil {
+				return
+			}
+
+			check := ModUpdateCheck{Key: key, Err: err}
+			if err == nil {
+				check.Updated, check.Err = snapshot.CheckModUpdate(ctx, key)
+			}
+			results <- check
+		}
+	}()
+	return results
+}
+
+// UpdateMod selects the latest file for a mod and installs it; locked mods are left alone
+func (pack *ModPack) UpdateMod(ctx context.Context, key string) error {
+	modFile, entry, _, err := pack.findModFileByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if mcdexBool(entry, "locked") {
+		return fmt.Errorf("%s is locked", modFile.getName())
+	}
+
+	updated, err := modFile.update(ctx, pack)
+	if err != nil || !updated {
+		return err
+	}
+
+	err = pack.selectMod(modFile)
+	if err != nil {
+		return err
+	}
+
+	err = modFile.install(ctx, pack)
+	if err != nil {
+		return err
+	}
+	return pack.runHook(ctx, HookPostUpdate, !pack.modCache.IsServerInstall())
+}
+
+// RemoveMod drops a mod from the manifest and deletes its installed file
+func (pack *ModPack) RemoveMod(key string) error {
+	_, _, index, err := pack.findModFileByKey(key)
+	if err != nil {
+		return err
+	}
+
+	files := manifestFiles(pack.manifest)
+	setManifestFiles(pack.manifest, append(files[:index], files[index+1:]...))
+
+	err = pack.SaveManifest()
+	if err != nil {
+		return err
+	}
+
+	// The cache cleans up any files that are no longer in the manifest
+	return pack.modCache.Cleanup(pack, false)
+}
+
+// SetModServerOnly marks a mod (identified by slug or maven module) as only used on servers, so
+// it's skipped when installing the pack on a client
+func (pack *ModPack) SetModServerOnly(ctx context.Context, name string) error {
+	err := pack.markServerOnly(ctx, name)
+	if err != nil {
+		return err
+	}
+	return pack.SaveManifest()
+}
+
+func (pack *ModPack) markServerOnly(ctx context.Context, name string) error {
+	_, entry, err := pack.findModFile(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	entry.Set(true, mcdexNamespace, "serverOnly")
+	entry.Delete(mcdexNamespace, "clientOnly")
+	return nil
+}
+
+// SetModOptional marks a mod (identified by slug or maven module) as optional, so it's only
+// installed when optional mods are included (see SetInstallOptional)
+func (pack *ModPack) SetModOptional(ctx context.Context, name string) error {
+	err := pack.markOptional(ctx, name)
+	if err != nil {
+		return err
+	}
+	return pack.SaveManifest()
+}
+
+func (pack *ModPack) markOptional(ctx context.Context, name string) error {
+	_, entry, err := pack.findModFile(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	entry.Set(false, "required")
+	return nil
+}
+
+// SetModLocked locks (or unlocks) a mod at its current version; locked mods are skipped by updates
+func (pack *ModPack) SetModLocked(key string, locked bool) error {
+	_, entry, _, err := pack.findModFileByKey(key)
+	if err != nil {
+		return err
+	}
+
+	if locked {
+		entry.Set(true, mcdexNamespace, "locked")
+	} else {
+		entry.Delete(mcdexNamespace, "locked")
+	}
+	return pack.SaveManifest()
+}
+
+// SetModReleaseChannel overrides the pack's release channel for a mod (identified by slug or
+// modrinth:<slug>); an empty channel reverts to the pack's
+func (pack *ModPack) SetModReleaseChannel(ctx context.Context, name, channel string) error {
+	if err := validateReleaseChannel(channel); err != nil {
+		return err
+	}
+
+	modFile, entry, err := pack.findModFile(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	switch modFile.(type) {
+	case *CurseForgeModFile, *ModrinthModFile:
+	default:
+		return fmt.Errorf("%s has no release types; a release channel can't be used", modFile.getName())
+	}
+
+	if channel == "" {
+		entry.Delete(mcdexNamespace, "releaseChannel")
+	} else {
+		entry.Set(channel, mcdexNamespace, "releaseChannel")
+	}
+	return pack.SaveManifest()
+}
@@ -0,0 +1,99 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Pack URLs built by GetPackURL identify the CurseForge project (by ID or slug) and file
+var curseForgePackURLRegex = regexp.MustCompile(`/projects/([\w-]+)/files/(\d+)/`)
+
+// SourceURL returns the URL the pack was downloaded from, or empty if it wasn't installed from a
+// URL (e.g. it was created locally or installed from a pack archive on disk)
+func (pack *ModPack) SourceURL() string {
+	url, _ := readStringFile(filepath.Join(pack.gamePath(), "pack.url"))
+	return strings.TrimSpace(url)
+}
+
+// CheckPackUpdate looks for a newer file of the CurseForge or Modrinth project the pack was
+// installed from; it returns the URL of the newest file, or empty if the pack is up to date
+func (pack *ModPack) CheckPackUpdate(ctx context.Context) (string, error) {
+	url := pack.SourceURL()
+	if url == "" {
+		return "", fmt.Errorf("%s wasn't installed from a URL; unable to check for updates", pack.Name)
+	}
+
+	var latestURL string
+	if match := curseForgePackURLRegex.FindStringSubmatch(url); match != nil {
+		slug := match[1]
+		if id, err := strconv.Atoi(slug); err == nil {
+			slug, err = pack.db.findSlugByProject(ctx, id)
+			if err != nil {
+				return "", fmt.Errorf("unknown modpack project %d: %+v", id, err)
+			}
+		}
+
+		var err error
+		latestURL, err = pack.db.GetPackURL(ctx, slug, "")
+		if err != nil {
+			return "", err
+		}
+
+		// The latest URL refers to the project by ID, so compare just the files
+		if latest := curseForgePackURLRegex.FindStringSubmatch(latestURL); latest != nil && latest[2] == match[2] {
+			return "", nil
+		}
+	} else if match := modrinthCDNRegex.FindStringSubmatch(url); match != nil {
+		files, err := projectSources["modrinth"].GetLatestFiles(ctx, match[1])
+		if err != nil {
+			return "", fmt.Errorf("failed to retrieve versions of %s: %+v", match[1], err)
+		}
+		if len(files) == 0 {
+			return "", fmt.Errorf("no versions found for %s", match[1])
+		}
+
+		if files[0].ID == match[2] {
+			return "", nil
+		}
+		latestURL = files[0].DownloadURL
+	} else {
+		return "", fmt.Errorf("%s isn't a CurseForge or Modrinth modpack; unable to check for updates", url)
+	}
+
+	if latestURL == url {
+		return "", nil
+	}
+	return latestURL, nil
+}
+
+// ApplyPackUpdate downloads a new file of the pack and installs its manifest and overrides in
+// place; mcdex's settings for the pack (JVM options, hooks, etc.) are kept, and config files the
+// user changed are preserved (see InstallOverrides). The caller is responsible for installing the
+// mods, which only downloads the ones that changed.
+func (pack *ModPack) ApplyPackUpdate(ctx context.Context, url string) error {
+	settings := pack.manifest.S(mcdexNamespace).Data()
+
+	err := pack.Download(ctx, url)
+	if err != nil {
+		return err
+	}
+
+	err = pack.ProcessManifest()
+	if err != nil {
+		return err
+	}
+
+	if settings != nil && !pack.manifest.Exists(mcdexNamespace) {
+		pack.manifest.Set(settings, mcdexNamespace)
+		err = pack.SaveManifest()
+		if err != nil {
+			return err
+		}
+	}
+
+	return pack.InstallOverrides()
+}
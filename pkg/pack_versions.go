@@ -0,0 +1,150 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PackFile is a published file (i.e. version) of a modpack
+type PackFile struct {
+	FileID      int
+	Timestamp   int
+	Filename    string // empty if the file list couldn't be retrieved from CurseForge
+	DisplayName string
+}
+
+// GetPackFiles lists the files of a modpack, newest first; the database only knows the file IDs
+// and dates, so the names of the files are looked up on CurseForge when possible
+func (db *Database) GetPackFiles(ctx context.Context, slug string) (int, []PackFile, error) {
+	// TODO: Remove forge
+	pid, err := db.FindProjectBySlug(ctx, slug, "forge", 1)
+	if err != nil {
+		return -1, nil, err
+	}
+
+	if db.live {
+		files, err := db.livePackFiles(ctx, pid)
+		if err == nil && len(files) == 0 {
+			err = fmt.Errorf("No modpack file found for %s", slug)
+		}
+		return pid, files, err
+	}
+
+	rows, err := db.sqlDb.Query("select fileid, tstamp from files where projectid = ? order by tstamp desc", pid)
+	if err != nil {
+		return -1, nil, fmt.Errorf("failed to lookup files for %s: %+v", slug, err)
+	}
+	defer rows.Close()
+
+	var files []PackFile
+	for rows.Next() {
+		var file PackFile
+		err = rows.Scan(&file.FileID, &file.Timestamp)
+		if err != nil {
+			return -1, nil, err
+		}
+		files = append(files, file)
+	}
+
+	if len(files) == 0 {
+		return -1, nil, fmt.Errorf("No modpack file found for %s", slug)
+	}
+
+	names, err := getCurseForgeFileNames(ctx, pid)
+	if err != nil {
+		logDebug("Unable to retrieve file names for %s: %+v", slug, err)
+	}
+	for i := range files {
+		if name, ok := names[files[i].FileID]; ok {
+			files[i].Filename, files[i].DisplayName = name[0], name[1]
+		}
+	}
+	return pid, files, nil
+}
+
+// getCurseForgeFileNames retrieves the filename and display name of each of a project's files
+func getCurseForgeFileNames(ctx context.Context, projectID int) (map[int][2]string, error) {
+	files, err := getCurseForgeFiles(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[int][2]string)
+	for _, file := range files {
+		result[file.ID] = [2]string{file.Filename, file.DisplayName}
+	}
+	return result, nil
+}
+
+// GetPackURL returns the download URL for a version of a modpack: either a file ID, (part of) the
+// name of a file, or empty for the latest file
+func (db *Database) GetPackURL(ctx context.Context, slug, version string) (string, error) {
+	pid, files, err := db.GetPackFiles(ctx, slug)
+	if err != nil {
+		return "", err
+	}
+
+	fileID := files[0].FileID
+	if version != "" {
+		fileID, err = findPackFile(files, version)
+		if err != nil {
+			return "", fmt.Errorf("%s: %+v; use pack.versions to list the available versions", slug, err)
+		}
+	}
+
+	// Construct a URL using the slug and file ID
+	return fmt.Sprintf("https://minecraft.curseforge.com/projects/%d/files/%d/download", pid, fileID), nil
+}
+
+// findPackFile resolves a version (a file ID or part of a file's name) to a file ID; file IDs the
+// database doesn't know about yet are trusted as-is
+func findPackFile(files []PackFile, version string) (int, error) {
+	if fileID, err := strconv.Atoi(version); err == nil {
+		return fileID, nil
+	}
+
+	version = strings.ToLower(version)
+	var matches []PackFile
+	for _, file := range files {
+		if strings.Contains(strings.ToLower(file.DisplayName), version) || strings.Contains(strings.ToLower(file.Filename), version) {
+			matches = append(matches, file)
+		}
+	}
+
+	switch {
+	case len(matches) == 1:
+		return matches[0].FileID, nil
+	case len(matches) > 1:
+		// Prefer an exact match on the display name (e.g. "1.2" vs "1.2.1")
+		for _, file := range matches {
+			if strings.ToLower(file.DisplayName) == version {
+				return file.FileID, nil
+			}
+		}
+		return -1, fmt.Errorf("version %s is ambiguous (%d files match)", version, len(matches))
+	case files[0].Filename == "":
+		return -1, fmt.Errorf("file names aren't available to match version %s against; use a file ID", version)
+	}
+	return -1, fmt.Errorf("no file matching version %s", version)
+}
+
+// ListPackVersions prints the files available for a modpack, newest first
+func (db *Database) ListPackVersions(ctx context.Context, slug string) error {
+	_, files, err := db.GetPackFiles(ctx, slug)
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		date := time.Unix(int64(file.Timestamp), 0).Format("2006-01-02")
+		name := file.DisplayName
+		if name == "" {
+			name = file.Filename
+		}
+		fmt.Fprintf(consoleOutput, "%d | %s | %s\n", file.FileID, date, name)
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/Jeffail/gabs"
+)
+
+// PackLocation describes a pack managed by mcdex, either in the mcdex pack directory or as a
+// MultiMC instance
+type PackLocation struct {
+	Name             string
+	Dir              string
+	MultiMC          bool
+	MinecraftVersion string
+	Loader           string
+
+	manifestFile string
+}
+
+// ListPacks finds all the packs (with a manifest) in the mcdex pack directory and, if configured,
+// the MultiMC instances directory
+func ListPacks() []PackLocation {
+	var result []PackLocation
+	result = append(result, findPacks(filepath.Join(Env().McdexDir, "pack"), []string{""}, false)...)
+	if dir, err := _mmcInstancesDir(); err == nil {
+		result = append(result, findPacks(dir, []string{"minecraft", ".minecraft"}, true)...)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return strings.ToLower(result[i].Name) < strings.ToLower(result[j].Name)
+	})
+	return result
+}
+
+func findPacks(baseDir string, gameDirs []string, multiMC bool) []PackLocation {
+	var result []PackLocation
+	dirs, _ := filepath.Glob(filepath.Join(baseDir, "*"))
+	for _, dir := range dirs {
+		for _, gameDir := range gameDirs {
+			manifest, err := gabs.ParseJSONFile(filepath.Join(dir, gameDir, "manifest.json"))
+			if err != nil {
+				continue
+			}
+
+			minecraftVsn, _ := strValue(manifest, "minecraft.version")
+			loader := "forge"
+			if loaderID, _ := manifest.Path("minecraft.modLoaders.id").Index(0).Data().(string); strings.HasPrefix(loaderID, "fabric-") {
+				loader = "fabric"
+			}
+
+			result = append(result, PackLocation{filepath.Base(dir), dir, multiMC, minecraftVsn, loader,
+				filepath.Join(dir, gameDir, "manifest.json")})
+			break
+		}
+	}
+	return result
+}
+
+// ProjectIDs returns the IDs of the CurseForge projects selected in the pack
+func (p PackLocation) ProjectIDs() map[int]bool {
+	result := make(map[int]bool)
+	manifest, err := gabs.ParseJSONFile(p.manifestFile)
+	if err != nil {
+		return result
+	}
+
+	files, _ := manifest.Path("files").Children()
+	for _, f := range files {
+		if sourceForEntry(f) != "curseforge" {
+			continue
+		}
+		if projectID, err := intValue(f, "projectID"); err == nil {
+			result[projectID] = true
+		}
+	}
+	return result
+}
+
+// PackDir resolves the name of a pack to its directory in the mcdex pack directory (or the MultiMC
+// instances directory); names that would escape the directory (e.g. ../x or absolute paths) are
+// rejected
+func PackDir(name string, multiMC bool) (string, error) {
+	baseDir := filepath.Join(Env().McdexDir, "pack")
+	if multiMC {
+		var err error
+		baseDir, err = _mmcInstancesDir()
+		if err != nil {
+			return "", err
+		}
+	}
+
+	dir, err := safeJoin(baseDir, name)
+	if err != nil {
+		return "", fmt.Errorf("invalid pack name %s: %+v", name, err)
+	}
+	if base, _ := filepath.Abs(baseDir); dir == base {
+		return "", fmt.Errorf("invalid pack name %s", name)
+	}
+	return dir, nil
+}
+
+// PackInstallOptions are the settings for InstallPack; the zero value installs the pack and its
+// mods into a launcher profile, backing up the pack's worlds first
+type PackInstallOptions struct {
+	MultiMC      bool // install as a MultiMC instance rather than a launcher profile
+	SkipMods     bool // install the pack itself, but not its mods
+	NoBackup     bool // don't back up the pack's worlds before overwriting them
+	BackupConfig bool // include the config directory in the backup
+	BackupKeep   int  // number of backups to keep (see BackupWorlds)
+
+	// JVM settings for the profile or instance (see SetJVMOptions)
+	Xmx, Xms, JavaArgs string
+}
+
+// InstallPack downloads a modpack into the directory (or MultiMC instance) and installs it along
+// with its mods; if url is empty, the pack already in the directory is installed. If anything
+// fails, the pack is put back the way it was.
+func InstallPack(ctx context.Context, dir, url string, options PackInstallOptions) error {
+	// A pack that's being downloaded (or copied) gets its manifest from the archive
+	cp, err := NewModPack(ctx, dir, "", url == "", options.MultiMC)
+	if err != nil {
+		return err
+	}
+
+	err = cp.SetJVMOptions(options.Xmx, options.Xms, options.JavaArgs)
+	if err != nil {
+		return err
+	}
+
+	if url != "" && !options.NoBackup {
+		// Protect any existing worlds before we start overwriting things
+		_, err = cp.BackupWorlds(options.BackupConfig, options.BackupKeep)
+		if err != nil {
+			return err
+		}
+	}
+
+	return cp.Transaction(func() error {
+		if url != "" {
+			err := cp.Download(ctx, url)
+			if err != nil {
+				return err
+			}
+
+			err = cp.ProcessManifest()
+			if err != nil {
+				return err
+			}
+
+			// Install overrides from the modpack; this is a bit of a misnomer since
+			// under usual circumstances there are no mods in the modpack file that
+			// will be also be downloaded
+			err = cp.InstallOverrides()
+			if err != nil {
+				return err
+			}
+		}
+
+		return cp.InstallPackFiles(ctx, url, options)
+	})
+}
+
+// InstallPackFiles sets up the launcher profile (or MultiMC instance) for a pack and installs its
+// mods; url is where the pack was downloaded from, if anywhere
+func (pack *ModPack) InstallPackFiles(ctx context.Context, url string, options PackInstallOptions) error {
+	var err error
+	if options.MultiMC {
+		err = pack.GenerateMMCConfig()
+		if err != nil {
+			return err
+		}
+
+		// Use the pack's icon instead of the default
+		if url != "" && !IsLocalPackArchive(url) {
+			err = pack.InstallMMCIcon(ctx, url)
+			if err != nil {
+				logWarn("Unable to install pack icon: %+v\n", err)
+			}
+		}
+	} else {
+		err = pack.CreateLauncherProfile(ctx)
+		if err != nil {
+			return err
+		}
+	}
+
+	if options.SkipMods {
+		return nil
+	}
+
+	// Install mods (include client-side only mods)
+	return pack.InstallMods(ctx, true)
+}
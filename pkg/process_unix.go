@@ -0,0 +1,11 @@
+//go:build !windows
+// +build !windows
+
+package pkg
+
+import "syscall"
+
+func processExists(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
@@ -0,0 +1,16 @@
+//go:build windows
+// +build windows
+
+package pkg
+
+import "os"
+
+func processExists(pid int) bool {
+	// On Windows, FindProcess fails if the process doesn't exist
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
@@ -0,0 +1,102 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+const progressInterval = 250 * time.Millisecond
+
+// DownloadObserver is notified of the progress of each download; front-ends that draw their own
+// progress (such as the TUI) register one with SetDownloadObserver
+type DownloadObserver interface {
+	DownloadStarted(label string, total int64)
+	DownloadProgress(label string, count int64)
+	DownloadFinished(label string, err error)
+}
+
+var downloadObserver DownloadObserver
+
+// SetDownloadObserver registers an observer for downloads (or removes it, if nil)
+func SetDownloadObserver(observer DownloadObserver) {
+	downloadObserver = observer
+}
+
+// progressReader wraps a download stream and displays how much of it has been read so far;
+// progress is only displayed when the output is a terminal and info messages are displayed (i.e.
+// not with -q)
+type progressReader struct {
+	reader     io.Reader
+	label      string
+	total      int64
+	count      int64
+	lastUpdate time.Time
+	enabled    bool
+	observer   DownloadObserver
+}
+
+func newProgressReader(reader io.Reader, label string, total int64) *progressReader {
+	p := &progressReader{reader: reader, label: label, total: total, enabled: logLevel <= LogInfo && isTerminalOutput(), observer: downloadObserver}
+	if p.observer != nil {
+		p.observer.DownloadStarted(label, total)
+	}
+	return p
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.reader.Read(buf)
+	p.count += int64(n)
+	if time.Since(p.lastUpdate) > progressInterval {
+		p.lastUpdate = time.Now()
+		if p.enabled {
+			p.print()
+		}
+		if p.observer != nil {
+			p.observer.DownloadProgress(p.label, p.count)
+		}
+	}
+	return n, err
+}
+
+func (p *progressReader) print() {
+	if p.total > 0 {
+		fmt.Fprintf(consoleOutput, "\r%s: %3d%% (%s of %s)", p.label, p.count*100/p.total, FormatBytes(p.count), FormatBytes(p.total))
+	} else {
+		fmt.Fprintf(consoleOutput, "\r%s: %s", p.label, FormatBytes(p.count))
+	}
+}
+
+// finish displays the final count and moves to the next line; err is the result of the download
+func (p *progressReader) finish(err error) {
+	if p.enabled {
+		p.print()
+		fmt.Fprintln(consoleOutput)
+	}
+	if p.observer != nil {
+		p.observer.DownloadProgress(p.label, p.count)
+		p.observer.DownloadFinished(p.label, err)
+	}
+}
+
+// notifyDownloadFailed reports a download that failed before any data was received
+func notifyDownloadFailed(name string, err error) {
+	if downloadObserver != nil {
+		label := "Downloading " + name
+		downloadObserver.DownloadStarted(label, 0)
+		downloadObserver.DownloadFinished(label, err)
+	}
+}
+
+// FormatBytes displays a size in human-readable units
+func FormatBytes(count int64) string {
+	switch {
+	case count >= 1<<30:
+		return fmt.Sprintf("%.1f GB", float64(count)/(1<<30))
+	case count >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(count)/(1<<20))
+	case count >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(count)/(1<<10))
+	}
+	return fmt.Sprintf("%d B", count)
+}
@@ -0,0 +1,136 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// Dependency levels in the deps table
+const (
+	depRequired = 1
+	depOptional = 2
+)
+
+// ProjectDependency is a project that a mod depends upon
+type ProjectDependency struct {
+	ProjectID int
+	Slug      string
+	Required  bool
+}
+
+// GetProjectDependencies returns the dependencies of the latest file of a project for the Minecraft
+// version, according to the database
+func (db *Database) GetProjectDependencies(slug, mcvsn string) ([]ProjectDependency, error) {
+	var projectID int
+	err := db.sqlDb.QueryRow("select projectid from projects where slug = ?", slug).Scan(&projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find project %s: %+v", slug, err)
+	}
+
+	fileID, err := db.getLatestProjectFile(projectID, mcvsn)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := db.getFileDependencies(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query deps for %s: %+v", slug, err)
+	}
+	return result, nil
+}
+
+// getFileDependencies returns the required and optional dependencies of a file, according to the
+// database
+func (db *Database) getFileDependencies(fileID int) ([]ProjectDependency, error) {
+	rows, err := db.sqlDb.Query(`select deps.projectid, projects.slug, deps.level from deps, projects 
+									where deps.fileid = ? and deps.level in (?, ?) and projects.projectid = deps.projectid
+									order by deps.level, projects.slug`, fileID, depRequired, depOptional)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []ProjectDependency
+	for rows.Next() {
+		var dep ProjectDependency
+		var level int
+		err = rows.Scan(&dep.ProjectID, &dep.Slug, &level)
+		if err != nil {
+			return nil, err
+		}
+		dep.Required = level == depRequired
+		result = append(result, dep)
+	}
+	return result, nil
+}
+
+// getLatestProjectFile returns the ID of the most recent file for a project that targets the given
+// Minecraft version (or any version, if empty)
+func (db *Database) getLatestProjectFile(projectID int, mcvsn string) (int, error) {
+	query := "select fileid from files where projectid = ? order by tstamp desc limit 1"
+	args := []interface{}{projectID}
+	if mcvsn != "" && db.hasColumn("versions", "fileid") {
+		query = `select fileid from files where projectid = ? and 
+					fileid in (select fileid from versions where projectid = ? and mcvsn = ?) order by tstamp desc limit 1`
+		args = append(args, projectID, mcvsn)
+	}
+
+	var fileID int
+	err := db.sqlDb.QueryRow(query, args...).Scan(&fileID)
+	if err != nil {
+		return 0, fmt.Errorf("no files found for project %d: %+v", projectID, err)
+	}
+	return fileID, nil
+}
+
+// ProjectDetail describes a project along with its latest files, for display in the explorer
+type ProjectDetail struct {
+	ProjectInfo
+	Downloads    int
+	LatestFiles  []FileInfo
+	Dependencies []string // slugs of the projects required by the latest file for the Minecraft version
+}
+
+// GetProjectDetail looks up a CurseForge project by slug and retrieves its details from the
+// project API; dependencies are reported for the latest file matching the version and loader
+func (db *Database) GetProjectDetail(ctx context.Context, slug, loader, mcvsn string) (*ProjectDetail, error) {
+	var projectID, downloads int
+	err := db.sqlDb.QueryRow("select projectid, downloads from projects where slug = ?", slug).Scan(&projectID, &downloads)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find project %s: %+v", slug, err)
+	}
+
+	source := projectSources["curseforge"]
+	project, err := source.GetProject(ctx, strconv.Itoa(projectID))
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve project %s: %+v", slug, err)
+	}
+
+	files, err := source.GetLatestFiles(ctx, project.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve files for %s: %+v", slug, err)
+	}
+
+	detail := &ProjectDetail{ProjectInfo: *project, Downloads: downloads, LatestFiles: files}
+
+	latest, err := selectLatestFile(files, mcvsn, loader, "")
+	if err != nil {
+		return detail, nil
+	}
+
+	file, err := source.GetFile(ctx, project.ID, latest.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve file %s for %s: %+v", latest.ID, slug, err)
+	}
+
+	for _, dep := range file.Dependencies {
+		depID, _ := strconv.Atoi(dep)
+		depSlug, err := db.findSlugByProject(ctx, depID)
+		if err != nil {
+			depSlug = fmt.Sprintf("project %s", dep)
+		}
+		detail.Dependencies = append(detail.Dependencies, depSlug)
+	}
+	return detail, nil
+}
@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Pterodactyl (and Pelican) panels import server types as "eggs": a JSON description of the
+// Docker image, startup command, install script and user-configurable variables
+
+const pterodactylInstallScript = `#!/bin/bash
+# Generated by mcdex; downloads the server pack archive into the server directory
+apt-get update && apt-get install -y curl unzip
+mkdir -p /mnt/server && cd /mnt/server
+curl -sSL -o pack.zip "${PACK_URL}"
+unzip -o pack.zip && rm pack.zip
+`
+
+// ExportPterodactylEgg writes a Pterodactyl egg for the installed server into the pack directory
+// and returns the filename
+func (pack *ModPack) ExportPterodactylEgg() (string, error) {
+	launchArgs, err := pack.serverLaunchArgsFor("unix_args.txt")
+	if err != nil {
+		return "", err
+	}
+
+	minecraftVsn, loaderVsn := pack.getVersions()
+	javaVsn := requiredJavaVersion(minecraftVsn)
+
+	startup := fmt.Sprintf("java -Xms128M -Xmx{{SERVER_MEMORY}}M %s nogui", strings.Join(launchArgs, " "))
+
+	// The config sections are themselves JSON-encoded strings
+	configFiles, _ := json.Marshal(map[string]interface{}{
+		"server.properties": map[string]interface{}{
+			"parser": "properties",
+			"find": map[string]string{
+				"server-ip":   "0.0.0.0",
+				"server-port": "{{server.build.default.port}}",
+				"query.port":  "{{server.build.default.port}}",
+			},
+		},
+	})
+	configStartup, _ := json.Marshal(map[string]string{"done": ")! For help, type "})
+
+	egg := map[string]interface{}{
+		"_comment":    "Generated by mcdex",
+		"meta":        map[string]interface{}{"version": "PTDL_v2", "update_url": nil},
+		"exported_at": time.Now().Format(time.RFC3339),
+		"name":        pack.Name,
+		"author":      "mcdex@mcdex.net",
+		"description": fmt.Sprintf("%s (Minecraft %s, %s %s)", pack.Name, minecraftVsn, pack.modLoader, loaderVsn),
+		"features":    []string{"eula", "java_version", "pid_limit"},
+		"docker_images": map[string]string{
+			fmt.Sprintf("Java %d", javaVsn): fmt.Sprintf("ghcr.io/pterodactyl/yolks:java_%d", javaVsn),
+		},
+		"file_denylist": []string{},
+		"startup":       startup,
+		"config": map[string]string{
+			"files":   string(configFiles),
+			"startup": string(configStartup),
+			"logs":    "{}",
+			"stop":    "stop",
+		},
+		"scripts": map[string]interface{}{
+			"installation": map[string]string{
+				"script":     pterodactylInstallScript,
+				"container":  "ghcr.io/pterodactyl/installers:debian",
+				"entrypoint": "bash",
+			},
+		},
+		"variables": []map[string]interface{}{
+			{
+				"name":          "Pack URL",
+				"description":   "URL of a ZIP archive containing the installed server directory",
+				"env_variable":  "PACK_URL",
+				"default_value": "",
+				"user_viewable": true,
+				"user_editable": true,
+				"rules":         "required|url",
+				"field_type":    "text",
+			},
+		},
+	}
+
+	data, err := json.MarshalIndent(egg, "", "    ")
+	if err != nil {
+		return "", err
+	}
+
+	filename := filepath.Join(pack.gamePath(), "egg-"+dockerNameRegex.ReplaceAllString(strings.ToLower(pack.Name), "-")+".json")
+	err = writeStringFile(filename, string(data))
+	if err != nil {
+		return "", fmt.Errorf("failed to write %s: %+v", filename, err)
+	}
+
+	return filename, nil
+}
@@ -0,0 +1,60 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Full-text index over project slugs, names and descriptions; if the database doesn't ship with
+// the index, it's built the first time the database is opened
+const searchTable = "projects_fts"
+
+// Searches that look like regular expressions are matched against slugs the old-fashioned way
+var regexSearchChars = regexp.MustCompile(`[\^\$\.\*\+\?\(\)\[\]\{\}\|\\]`)
+
+func (db *Database) ensureSearchIndex() error {
+	if db.hasTable(searchTable) {
+		return nil
+	}
+
+	logInfo("Building search index...\n")
+	tx, err := db.sqlDb.Begin()
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("CREATE VIRTUAL TABLE %s USING fts4(slug, name, description)", searchTable))
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to create search index: %+v", err)
+	}
+
+	_, err = tx.Exec(fmt.Sprintf("INSERT INTO %s(docid, slug, name, description) SELECT projectid, slug, name, description FROM projects", searchTable))
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("failed to populate search index: %+v", err)
+	}
+
+	return tx.Commit()
+}
+
+// searchExpression converts a user's search into an FTS query; each word in the search must
+// match (as a prefix) the slug, name or description of the project
+func searchExpression(search string) string {
+	words := strings.FieldsFunc(search, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	var terms []string
+	for _, word := range words {
+		terms = append(terms, word+"*")
+	}
+	return strings.Join(terms, " ")
+}
+
+// useSearchIndex determines if a search can be done with the full-text index
+func (db *Database) useSearchIndex(search string) bool {
+	return db.hasSearchIndex && searchExpression(search) != "" && !regexSearchChars.MatchString(search)
+}
@@ -0,0 +1,239 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"strings"
+)
+
+// Mods required by a selected mod are only selected along with it when asked for
+var selectDependencies = false
+
+// SetSelectDependencies includes (or excludes) the mods that a mod requires when selecting it
+func SetSelectDependencies(enabled bool) {
+	selectDependencies = enabled
+}
+
+// SelectMod adds (or updates) a mod in the pack, along with any mods it requires (see
+// SetSelectDependencies), and saves the manifest. Mods from Modrinth and files downloaded from a URL (ext:<name>) are explicitly
+// prefixed; otherwise, Maven is tried before CurseForge.
+func SelectMod(ctx context.Context, pack *ModPack, modId, url string, clientOnly bool) error {
+	err := selectMod(ctx, pack, modId, url, clientOnly, make(map[string]bool))
+	if err != nil {
+		return err
+	}
+	return pack.SaveManifest()
+}
+
+// ModSelection is a mod to be selected by SelectMods
+type ModSelection struct {
+	ModID      string
+	ClientOnly bool
+	ServerOnly bool
+	Optional   bool
+}
+
+// SelectMods adds several mods (and any mods they require) to the pack, saving the manifest once
+// they've all been selected; a mod that can't be selected doesn't stop the others from being added
+func SelectMods(ctx context.Context, pack *ModPack, mods []ModSelection) error {
+	visited := make(map[string]bool)
+	var failed []string
+	for _, mod := range mods {
+		if err := checkCanceled(ctx); err != nil {
+			return err
+		}
+
+		err := selectMod(ctx, pack, mod.ModID, "", mod.ClientOnly, visited)
+		slug, _ := splitVersionConstraint(mod.ModID)
+		if err == nil && mod.ServerOnly {
+			err = pack.markServerOnly(ctx, slug)
+		}
+		if err == nil && mod.Optional {
+			err = pack.markOptional(ctx, slug)
+		}
+		if err != nil {
+			logError("Failed to select %s: %+v", mod.ModID, err)
+			failed = append(failed, mod.ModID)
+		}
+	}
+
+	err := pack.SaveManifest()
+	if err != nil {
+		return err
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to select %d of %d mods: %s", len(failed), len(mods), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// ReadModList reads a list of mods to select from a text file: one mod (slug, modrinth:<slug> or
+// maven artifact ID) per line, optionally followed by "client" for client-side only mods, "server"
+// for server-side only mods and/or "optional" for optional mods. Blank lines and lines starting with
+// # are ignored.
+func ReadModList(filename string) ([]ModSelection, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mod list: %+v", err)
+	}
+
+	var result []ModSelection
+	for i, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		mod := ModSelection{ModID: fields[0]}
+		for _, marker := range fields[1:] {
+			if strings.HasPrefix(marker, "#") {
+				break
+			}
+			switch marker {
+			case "client":
+				mod.ClientOnly = true
+			case "server":
+				mod.ServerOnly = true
+			case "optional":
+				mod.Optional = true
+			default:
+				return nil, fmt.Errorf("%s:%d: unknown marker %q for %s; expected client, server or optional", filename, i+1, marker, mod.ModID)
+			}
+		}
+		if mod.ClientOnly && mod.ServerOnly {
+			return nil, fmt.Errorf("%s:%d: %s can't be both client and server only", filename, i+1, mod.ModID)
+		}
+		result = append(result, mod)
+	}
+	return result, nil
+}
+
+func selectMod(ctx context.Context, pack *ModPack, modId, url string, clientOnly bool, visited map[string]bool) error {
+	slug, _ := splitVersionConstraint(modId)
+	visited[slug] = true
+
+	if strings.HasPrefix(modId, modrinthPrefix) {
+		err := SelectModrinthModFile(ctx, pack, modId, clientOnly)
+		if err != nil || !selectDependencies {
+			return err
+		}
+		return selectModrinthDependencies(ctx, pack, slug, clientOnly, visited)
+	}
+
+	if strings.HasPrefix(modId, extPrefix) {
+		return SelectExtModFile(ctx, pack, modId, url, ExtVersionSource{}, clientOnly)
+	}
+
+	// First, try to select the mod using Maven
+	err := SelectMavenModFile(ctx, pack, modId, url, clientOnly)
+	if err == nil {
+		return nil
+	}
+
+	// Hmm, not a maven-based mod; let's try as a CurseForge mod
+	err = SelectCurseForgeModFile(ctx, pack, modId, url, clientOnly)
+	if err != nil && pack.db.live && url == "" {
+		// Without the database, there's no way to tell if the mod is only on Modrinth
+		logInfo("%s not found on CurseForge (%+v); trying Modrinth\n", modId, err)
+		return selectMod(ctx, pack, modrinthPrefix+modId, "", clientOnly, visited)
+	} else if err != nil || !selectDependencies {
+		return err
+	}
+
+	return selectCurseForgeDependencies(ctx, pack, slug, clientOnly, visited)
+}
+
+// selectCurseForgeDependencies selects the required dependencies of the file chosen for a
+// CurseForge mod, if they aren't already part of the pack
+func selectCurseForgeDependencies(ctx context.Context, pack *ModPack, modId string, clientOnly bool, visited map[string]bool) error {
+	modFile, _, err := pack.findModFile(ctx, modId)
+	if err != nil {
+		return err
+	}
+
+	cfFile, ok := modFile.(*CurseForgeModFile)
+	if !ok {
+		return nil
+	}
+
+	file, err := cfFile.source().GetFile(ctx, strconv.Itoa(cfFile.projectID), strconv.Itoa(cfFile.fileID))
+	if err != nil {
+		warn(WarnOther, "unable to check dependencies of %s: %+v", cfFile.name, err)
+		return nil
+	}
+
+	for _, dep := range file.Dependencies {
+		depID, _ := strconv.Atoi(dep)
+		slug, err := pack.db.findSlugByProject(ctx, depID)
+		if err != nil {
+			warn(WarnOther, "%s requires unknown project %s", cfFile.name, dep)
+			continue
+		}
+
+		if visited[slug] {
+			continue
+		}
+
+		// Leave dependencies that have already been selected alone
+		if _, _, err := pack.findModFile(ctx, slug); err == nil {
+			visited[slug] = true
+			continue
+		}
+
+		logInfo("%s requires %s\n", cfFile.name, slug)
+		err = selectMod(ctx, pack, slug, "", clientOnly, visited)
+		if err != nil {
+			return fmt.Errorf("failed to select %s (required by %s): %+v", slug, cfFile.name, err)
+		}
+	}
+	return nil
+}
+
+// selectModrinthDependencies selects the projects required by the version chosen for a Modrinth
+// mod, if they aren't already part of the pack
+func selectModrinthDependencies(ctx context.Context, pack *ModPack, modId string, clientOnly bool, visited map[string]bool) error {
+	modFile, _, err := pack.findModFile(ctx, modId)
+	if err != nil {
+		return err
+	}
+
+	mrFile, ok := modFile.(*ModrinthModFile)
+	if !ok {
+		return nil
+	}
+
+	file, err := mrFile.source().GetFile(ctx, mrFile.projectID, mrFile.fileID)
+	if err != nil {
+		warn(WarnOther, "unable to check dependencies of %s: %+v", mrFile.name, err)
+		return nil
+	}
+
+	for _, dep := range file.Dependencies {
+		project, err := mrFile.source().GetProject(ctx, dep)
+		if err != nil {
+			warn(WarnOther, "%s requires unknown project %s", mrFile.name, dep)
+			continue
+		}
+
+		depId := modrinthPrefix + project.Slug
+		if visited[depId] {
+			continue
+		}
+
+		// Leave dependencies that have already been selected alone
+		if pack.hasModFile(&ModrinthModFile{projectID: project.ID}) {
+			visited[depId] = true
+			continue
+		}
+
+		logInfo("%s requires %s\n", mrFile.name, project.Slug)
+		err = selectMod(ctx, pack, depId, "", clientOnly, visited)
+		if err != nil {
+			return fmt.Errorf("failed to select %s (required by %s): %+v", project.Slug, mrFile.name, err)
+		}
+	}
+	return nil
+}
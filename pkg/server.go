@@ -0,0 +1,141 @@
+package pkg
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var serverDoneRegex = regexp.MustCompile(`Done \([\d.,]+s\)!`)
+
+const smokeTestHeap = "-Xmx2G"
+
+const minecraftEULA = "https://aka.ms/MinecraftEULA"
+
+// serverLaunchArgs returns the Java arguments used to launch the installed server
+func (pack *ModPack) serverLaunchArgs() ([]string, error) {
+	return pack.serverLaunchArgsFor(forgeArgsName())
+}
+
+// serverLaunchArgsFor returns the Java arguments used to launch the installed server, using the
+// named Forge argument file (unix_args.txt or win_args.txt) where applicable
+func (pack *ModPack) serverLaunchArgsFor(argsName string) ([]string, error) {
+	minecraftVsn, loaderVsn := pack.getVersions()
+
+	var jar string
+	if pack.modLoader == "fabric" {
+		jar = fabricServerLauncher
+	} else if usesForgeArgFiles(minecraftVsn) {
+		argsFile := forgeArgsPath(minecraftVsn, loaderVsn, argsName)
+		if !fileExists(filepath.Join(pack.gamePath(), filepath.FromSlash(argsFile))) {
+			return nil, fmt.Errorf("server arguments %s not found; has the server been installed?", argsFile)
+		}
+		return []string{"@user_jvm_args.txt", "@" + argsFile}, nil
+	} else {
+		jar = fmt.Sprintf("forge-%s-%s.jar", minecraftVsn, loaderVsn)
+	}
+
+	if !fileExists(filepath.Join(pack.gamePath(), jar)) {
+		return nil, fmt.Errorf("server launcher %s not found; has the server been installed?", jar)
+	}
+
+	return []string{"-jar", jar}, nil
+}
+
+// AcceptEULA writes an eula.txt into the server directory indicating the Minecraft EULA has been
+// accepted; without it, the server refuses to start
+func (pack *ModPack) AcceptEULA() error {
+	eula := fmt.Sprintf("# By changing the setting below to TRUE you are indicating your agreement to our EULA (%s).\n"+
+		"# Accepted via mcdex on %s\neula=true\n", minecraftEULA, time.Now().Format(time.RFC1123))
+	err := writeStringFile(filepath.Join(pack.gamePath(), "eula.txt"), eula)
+	if err != nil {
+		return fmt.Errorf("failed to write eula.txt: %+v", err)
+	}
+
+	logAction("Accepted Minecraft EULA\n")
+	return nil
+}
+
+// SmokeTestServer launches the installed server and waits for it to finish starting up (or crash)
+// within the timeout, then stops it again
+func (pack *ModPack) SmokeTestServer(ctx context.Context, timeout time.Duration) error {
+	launchArgs, err := pack.serverLaunchArgs()
+	if err != nil {
+		return err
+	}
+
+	args := append([]string{smokeTestHeap}, launchArgs...)
+	args = append(args, "nogui")
+
+	cmd := javaCommand(ctx, args...)
+	cmd.Dir = pack.gamePath()
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+
+	// Merge stdout/stderr so we can watch for startup and crash messages
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+
+	logInfo("Starting server for smoke test (timeout %s)\n", timeout)
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("failed to start server: %+v", err)
+	}
+
+	// Wait for the process to exit in the background
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+		writer.Close()
+	}()
+
+	// Scan the output, looking for the "Done" line; keep the tail around for reporting failures
+	started := make(chan bool, 1)
+	scanned := make(chan bool)
+	var tail []string
+	go func() {
+		defer close(scanned)
+		scanner := bufio.NewScanner(reader)
+		for scanner.Scan() {
+			line := scanner.Text()
+			tail = append(tail, line)
+			if len(tail) > 20 {
+				tail = tail[1:]
+			}
+			if serverDoneRegex.MatchString(line) {
+				select {
+				case started <- true:
+				default:
+				}
+			}
+		}
+	}()
+
+	select {
+	case <-started:
+		logInfo("Server started successfully; stopping\n")
+		io.WriteString(stdin, "stop\n")
+		select {
+		case <-exited:
+		case <-time.After(time.Minute):
+			cmd.Process.Kill()
+		}
+		return nil
+	case err = <-exited:
+		<-scanned
+		return fmt.Errorf("server exited before startup completed (%v):\n%s", err, strings.Join(tail, "\n"))
+	case <-time.After(timeout):
+		cmd.Process.Kill()
+		<-scanned
+		return fmt.Errorf("server did not start within %s:\n%s", timeout, strings.Join(tail, "\n"))
+	}
+}
@@ -0,0 +1,125 @@
+package pkg
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var memoryRegex = regexp.MustCompile(`^(?i)(\d+)([MG])$`)
+
+// Aikar's G1 tuning flags (https://mcflags.emc.gs); servers with more than 12GB of heap use a
+// slightly different set of generation sizes
+var aikarFlags = []string{
+	"-XX:+UseG1GC", "-XX:+ParallelRefProcEnabled", "-XX:MaxGCPauseMillis=200",
+	"-XX:+UnlockExperimentalVMOptions", "-XX:+DisableExplicitGC", "-XX:+AlwaysPreTouch",
+	"-XX:G1HeapWastePercent=5", "-XX:G1MixedGCCountTarget=4", "-XX:G1MixedGCLiveThresholdPercent=90",
+	"-XX:G1RSetUpdatingPauseTimeRatio=2", "-XX:SurvivorRatio=32", "-XX:+PerfDisableSharedMem",
+	"-XX:MaxTenuringThreshold=1", "-Dusing.aikars.flags=https://mcflags.emc.gs", "-Daikars.new.flags=true",
+}
+
+var aikarSmallHeapFlags = []string{
+	"-XX:G1NewSizePercent=30", "-XX:G1MaxNewSizePercent=40", "-XX:G1HeapRegionSize=8M",
+	"-XX:G1ReservePercent=20", "-XX:InitiatingHeapOccupancyPercent=15",
+}
+
+var aikarLargeHeapFlags = []string{
+	"-XX:G1NewSizePercent=40", "-XX:G1MaxNewSizePercent=50", "-XX:G1HeapRegionSize=16M",
+	"-XX:G1ReservePercent=15", "-XX:InitiatingHeapOccupancyPercent=20",
+}
+
+const startSh = `#!/usr/bin/env sh
+# Generated by mcdex; adjust the memory settings below as necessary
+cd "$(dirname "$0")"
+java %s %s nogui "$@"
+`
+
+const startBat = "@echo off\r\n" +
+	"REM Generated by mcdex; adjust the memory settings below as necessary\r\n" +
+	"cd /d \"%%~dp0\"\r\n" +
+	"java %s %s nogui %%*\r\n" +
+	"pause\r\n"
+
+// parseMemory converts a heap size (e.g. 4G, 6144M) into megabytes
+func parseMemory(mem string) (int, error) {
+	match := memoryRegex.FindStringSubmatch(mem)
+	if match == nil {
+		return 0, fmt.Errorf("invalid memory size %s; expected a value such as 4G or 4096M", mem)
+	}
+
+	size, _ := strconv.Atoi(match[1])
+	if strings.EqualFold(match[2], "G") {
+		size *= 1024
+	}
+	return size, nil
+}
+
+// defaultServerMemory picks a heap size (in megabytes) based on the number of mods in the pack
+func (pack *ModPack) defaultServerMemory() int {
+	files := manifestFiles(pack.manifest)
+	switch {
+	case len(files) < 50:
+		return 4096
+	case len(files) < 150:
+		return 6144
+	case len(files) < 250:
+		return 8192
+	}
+	return 10240
+}
+
+// serverJvmArgs returns the memory and GC arguments for a server with the given heap size
+func serverJvmArgs(memory int) []string {
+	// Aikar recommends setting the minimum and maximum heap to the same value
+	args := []string{fmt.Sprintf("-Xms%dM", memory), fmt.Sprintf("-Xmx%dM", memory)}
+	args = append(args, aikarFlags...)
+	if memory > 12*1024 {
+		return append(args, aikarLargeHeapFlags...)
+	}
+	return append(args, aikarSmallHeapFlags...)
+}
+
+// GenerateStartScripts writes start.sh and start.bat into the server directory; mem is the heap
+// size to use (e.g. 6G) or empty to pick one based on the size of the pack
+func (pack *ModPack) GenerateStartScripts(mem string) error {
+	var memory int
+	if mem != "" {
+		var err error
+		memory, err = parseMemory(mem)
+		if err != nil {
+			return err
+		}
+	} else {
+		memory = pack.defaultServerMemory()
+	}
+
+	jvmArgs := strings.Join(serverJvmArgs(memory), " ")
+
+	unixArgs, err := pack.serverLaunchArgsFor("unix_args.txt")
+	if err != nil {
+		return err
+	}
+
+	winArgs, err := pack.serverLaunchArgsFor("win_args.txt")
+	if err != nil {
+		return err
+	}
+
+	scripts := map[string]string{
+		"start.sh":  fmt.Sprintf(startSh, jvmArgs, strings.Join(unixArgs, " ")),
+		"start.bat": fmt.Sprintf(startBat, jvmArgs, filepath.FromSlash(strings.Join(winArgs, " "))),
+	}
+	for name, content := range scripts {
+		err := writeStringFile(filepath.Join(pack.gamePath(), name), content)
+		if err != nil {
+			return fmt.Errorf("failed to write %s: %+v", name, err)
+		}
+	}
+	os.Chmod(filepath.Join(pack.gamePath(), "start.sh"), 0755)
+
+	logAction("Generated start.sh and start.bat (%dM heap)\n", memory)
+	return nil
+}
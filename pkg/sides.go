@@ -0,0 +1,183 @@
+package pkg
+
+import (
+	"archive/zip"
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Mods selected without mod.select.client are checked to see if they're only meant for clients,
+// so they aren't installed on servers by mistake
+var sideDetection = true
+
+// SetSideDetection enables (or disables) detecting client-side only mods when they're selected
+func SetSideDetection(enabled bool) {
+	sideDetection = enabled
+}
+
+// Forge and NeoForge mods declare themselves client-side only in their mods.toml
+var clientSideOnlyRegex = regexp.MustCompile(`^\s*clientSideOnly\s*=\s*true\b`)
+
+// detectClientOnly checks if a file is only meant for clients; the source's metadata is used when
+// it says which sides a file supports, otherwise the jar's metadata is read from the server
+func detectClientOnly(ctx context.Context, file *FileInfo, name string) bool {
+	if !sideDetection {
+		return false
+	}
+
+	clientOnly, known := file.ClientOnly, file.SidesKnown
+	if !known && file.DownloadURL != "" {
+		clientOnly, known = remoteJarClientOnly(ctx, file.DownloadURL)
+	}
+
+	if clientOnly {
+		logInfo("%s is client-side only; it won't be installed on servers", name)
+	} else if !known {
+		logDebug("Unable to determine which sides %s runs on; assuming both", name)
+	}
+	return clientOnly
+}
+
+// remoteJarClientOnly checks a jar's metadata without downloading the whole jar: only the zip
+// directory and the metadata files are fetched, using range requests. If the server doesn't
+// support them, the sides are unknown.
+func remoteJarClientOnly(ctx context.Context, url string) (bool, bool) {
+	reader, err := newHttpRangeReader(ctx, url)
+	if err != nil {
+		logDebug("Unable to read %s to check its sides: %+v", url, err)
+		return false, false
+	}
+
+	zipFile, err := zip.NewReader(reader, reader.size)
+	if err != nil {
+		logDebug("Unable to read %s to check its sides: %+v", url, err)
+		return false, false
+	}
+	return zipClientOnly(zipFile)
+}
+
+// jarClientOnly checks a mod's metadata to see if it's client-side only; the second result is
+// false if the jar doesn't say
+func jarClientOnly(filename string) (bool, bool) {
+	zipFile, err := zip.OpenReader(filename)
+	if err != nil {
+		return false, false
+	}
+	defer zipFile.Close()
+	return zipClientOnly(&zipFile.Reader)
+}
+
+func zipClientOnly(zipFile *zip.Reader) (bool, bool) {
+	// Fabric and Quilt mods have an environment of client, server or * (both)
+	if modJson, err := findJSONFile(zipFile, "fabric.mod.json"); err == nil {
+		if env, ok := modJson.Path("environment").Data().(string); ok {
+			return env == "client", true
+		}
+	}
+	if modJson, err := findJSONFile(zipFile, "quilt.mod.json"); err == nil {
+		if env, ok := modJson.Path("minecraft.environment").Data().(string); ok {
+			return env == "client", true
+		}
+	}
+
+	for _, f := range zipFile.File {
+		if f.Name != "META-INF/mods.toml" && f.Name != "META-INF/neoforge.mods.toml" {
+			continue
+		}
+
+		freader, err := f.Open()
+		if err != nil {
+			return false, false
+		}
+		defer freader.Close()
+
+		scanner := bufio.NewScanner(freader)
+		for scanner.Scan() {
+			if clientSideOnlyRegex.MatchString(strings.TrimSpace(scanner.Text())) {
+				return true, true
+			}
+		}
+		return false, false
+	}
+	return false, false
+}
+
+// httpRangeReader reads parts of a file on a web server as they're needed, using range requests
+type httpRangeReader struct {
+	ctx  context.Context
+	url  string
+	size int64
+}
+
+// newHttpRangeReader checks that the server supports range requests for a URL and finds the
+// size of the file
+func newHttpRangeReader(ctx context.Context, url string) (*httpRangeReader, error) {
+	reader := &httpRangeReader{ctx: ctx, url: url}
+	resp, err := reader.get(0, 0)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	// Content-Range: bytes 0-0/<size>
+	contentRange := resp.Header.Get("Content-Range")
+	i := strings.LastIndex(contentRange, "/")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	reader.size, err = strconv.ParseInt(contentRange[i+1:], 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Content-Range %q", contentRange)
+	}
+	return reader, nil
+}
+
+func (r *httpRangeReader) ReadAt(p []byte, off int64) (int, error) {
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p)) - 1
+	if end >= r.size {
+		end = r.size - 1
+	}
+
+	resp, err := r.get(off, end)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	n, err := io.ReadFull(resp.Body, p[:end-off+1])
+	if err == nil && n < len(p) {
+		err = io.EOF
+	}
+	return n, err
+}
+
+// get retrieves a range of bytes; the server must respond with just that range, so the whole file
+// is never downloaded
+func (r *httpRangeReader) get(start, end int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(r.ctx, "GET", r.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("User-Agent", "Mozilla/5.0 AppleWebKit/589.6 (KHTML, like Gecko) Brave Chrome/79.1.3945.88 Safari/577.36")
+	req.Header.Add("Range", fmt.Sprintf("bytes=%d-%d", start, end))
+	addAuthHeader(req)
+
+	resp, err := getterClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusPartialContent {
+		resp.Body.Close()
+		return nil, fmt.Errorf("range requests aren't supported for %s (status %d)", r.url, resp.StatusCode)
+	}
+	return resp, nil
+}
@@ -0,0 +1,209 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/gabs"
+)
+
+// Release types for files, in order of preference
+const (
+	ReleaseTypeRelease = 1
+	ReleaseTypeBeta    = 2
+	ReleaseTypeAlpha   = 3
+)
+
+// ProjectInfo describes a project (mod or modpack) hosted by a ProjectSource
+type ProjectInfo struct {
+	ID         string
+	Slug       string
+	Name       string
+	Summary    string
+	IconURL    string
+	Authors    []string // empty if the source doesn't report them
+	SourceURL  string   // empty if the project doesn't link to its source code
+	IssuesURL  string   // empty if the project doesn't link to an issue tracker
+	ClientOnly bool     // the source reports that the project isn't used on servers
+}
+
+// FileInfo describes a single file of a project; files that support multiple versions of
+// Minecraft (or loaders) are reported once per version
+type FileInfo struct {
+	ID               string
+	ProjectID        string
+	Filename         string
+	DownloadURL      string
+	MinecraftVersion string
+	Loader           string // empty if the file isn't specific to a loader
+	ReleaseType      int
+	Dependencies     []string  // IDs of projects the file requires
+	ClientOnly       bool      // the file is only used on clients
+	SidesKnown       bool      // the source reports which sides (client/server) the file is used on
+	Date             time.Time // when the file was published; zero if the source didn't report it
+	Size             int64     // in bytes; zero if the source didn't report it
+}
+
+// ProjectSource is a backend that hosts mod projects and their files; all of the knowledge about
+// a given site's API lives in its ProjectSource implementation
+type ProjectSource interface {
+	Name() string
+	GetProject(ctx context.Context, projectID string) (*ProjectInfo, error)
+	GetFile(ctx context.Context, projectID, fileID string) (*FileInfo, error)
+	GetLatestFiles(ctx context.Context, projectID string) ([]FileInfo, error)
+	GetChangelog(ctx context.Context, projectID, fileID string) (string, error) // plain text; empty if there isn't one
+}
+
+var projectSources = map[string]ProjectSource{
+	"curseforge": curseForgeSource{},
+	"modrinth":   modrinthSource{},
+}
+
+// GetProjectSource returns the source for a site by name (curseforge or modrinth)
+func GetProjectSource(name string) (ProjectSource, error) {
+	source, ok := projectSources[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown project source %s", name)
+	}
+	return source, nil
+}
+
+// sourceForEntry returns the name of the source a manifest entry was selected from; entries
+// without a source are from CurseForge
+func sourceForEntry(modJson *gabs.Container) string {
+	if source, ok := mcdexString(modJson, "source"); ok {
+		return source
+	}
+	return "curseforge"
+}
+
+// Release channels, which limit the release types selected by updates; a channel includes the
+// types of the channels before it (e.g. beta also accepts releases)
+var releaseChannels = map[string]int{
+	"release": ReleaseTypeRelease,
+	"beta":    ReleaseTypeBeta,
+	"alpha":   ReleaseTypeAlpha,
+}
+
+// validateReleaseChannel checks a channel name; an empty channel is the default policy
+func validateReleaseChannel(channel string) error {
+	if _, ok := releaseChannels[channel]; !ok && channel != "" {
+		return fmt.Errorf("invalid release channel %s; expected release, beta or alpha", channel)
+	}
+	return nil
+}
+
+// selectLatestFile picks the most recent file for the Minecraft version and loader; the files must
+// be ordered newest first. With a release channel, the newest file the channel accepts is picked;
+// otherwise releases are preferred over beta/alpha files.
+func selectLatestFile(files []FileInfo, minecraftVersion, modLoader, channel string) (*FileInfo, error) {
+	var selected *FileInfo
+	selectedType := math.MaxInt8
+	maxType, hasChannel := releaseChannels[channel]
+	for i, file := range files {
+		if file.MinecraftVersion != minecraftVersion {
+			continue
+		}
+
+		if file.Loader != "" && !loaderRunsFile(modLoader, file.Loader) {
+			continue
+		}
+
+		if hasChannel {
+			if file.ReleaseType <= maxType {
+				selected = &files[i]
+				break
+			}
+			continue
+		}
+
+		if file.ReleaseType < selectedType {
+			selectedType = file.ReleaseType
+			selected = &files[i]
+		}
+	}
+
+	if selected == nil {
+		return nil, noFileError{minecraftVersion}
+	}
+	return selected, nil
+}
+
+// Loaders that can also run mods built for another loader
+var compatibleLoaders = map[string][]string{
+	"quilt": {"fabric"},
+}
+
+// loaderRunsFile determines if a file built for fileLoader can be used with the pack's loader
+func loaderRunsFile(modLoader, fileLoader string) bool {
+	if fileLoader == modLoader {
+		return true
+	}
+	for _, l := range compatibleLoaders[modLoader] {
+		if l == fileLoader {
+			return true
+		}
+	}
+	return false
+}
+
+func ReleaseTypeName(releaseType int) string {
+	switch releaseType {
+	case ReleaseTypeRelease:
+		return "release"
+	case ReleaseTypeBeta:
+		return "beta"
+	case ReleaseTypeAlpha:
+		return "alpha"
+	}
+	return "unknown-release"
+}
+
+// noFileError indicates that a project has no file for the requested version of Minecraft
+type noFileError struct {
+	minecraftVersion string
+}
+
+func (e noFileError) Error() string {
+	return fmt.Sprintf("no version found for Minecraft %s\n", e.minecraftVersion)
+}
+
+// printProjectInfo displays a project, returning its most recent files (see printProjectFiles)
+func printProjectInfo(ctx context.Context, source ProjectSource, projectID string) ([]FileInfo, error) {
+	project, err := source.GetProject(ctx, projectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve project %s: %+v", projectID, err)
+	}
+
+	files, err := source.GetLatestFiles(ctx, project.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve files for %s: %+v", project.Slug, err)
+	}
+
+	fmt.Fprintf(consoleOutput, "%s (%s)\n  %s\n", project.Name, project.Slug, project.Summary)
+	if len(project.Authors) > 0 {
+		fmt.Fprintf(consoleOutput, "Authors: %s\n", strings.Join(project.Authors, ", "))
+	}
+	if project.SourceURL != "" {
+		fmt.Fprintf(consoleOutput, "Source: %s\n", project.SourceURL)
+	}
+	if project.IssuesURL != "" {
+		fmt.Fprintf(consoleOutput, "Issues: %s\n", project.IssuesURL)
+	}
+	return files, nil
+}
+
+func printProjectFiles(files []FileInfo) {
+	fmt.Fprintf(consoleOutput, "Files:\n")
+
+	for _, file := range files {
+		loader := file.Loader
+		if loader == "" {
+			loader = "forge"
+		}
+		fmt.Fprintf(consoleOutput, "* %s for Minecraft %s, %s, %s\n", file.Filename, file.MinecraftVersion, loader, ReleaseTypeName(file.ReleaseType))
+	}
+}
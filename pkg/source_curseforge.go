@@ -0,0 +1,294 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/gabs"
+)
+
+const curseForgeAPI = "https://addons-ecs.forgesvc.net/api/v2"
+
+// The core API (which needs a key; see auth.login) is the only one that still does fingerprint lookups
+const curseForgeCoreAPI = "https://api.curseforge.com/v1"
+
+const fingerprintURL = curseForgeCoreAPI + "/fingerprints"
+
+// Dependency type for libraries a file can't work without (others are optional, embedded, etc.)
+const curseForgeRequiredDependency = 3
+
+// curseForgeSource retrieves project metadata from the CurseForge API
+type curseForgeSource struct{}
+
+func (s curseForgeSource) Name() string {
+	return "curseforge"
+}
+
+func (s curseForgeSource) GetProject(ctx context.Context, projectID string) (*ProjectInfo, error) {
+	project, err := getJSONFromURL(ctx, fmt.Sprintf("%s/addon/%s", curseForgeAPI, projectID))
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := intValue(project, "id")
+	name, _ := strValue(project, "name")
+	slug, _ := strValue(project, "slug")
+	summary, _ := strValue(project, "summary")
+
+	// The project's logo is the default attachment
+	var iconURL string
+	attachments, _ := project.Path("attachments").Children()
+	for _, attachment := range attachments {
+		if isDefault, _ := attachment.Path("isDefault").Data().(bool); isDefault {
+			iconURL, _ = strValue(attachment, "thumbnailUrl")
+		}
+	}
+
+	var authors []string
+	authorList, _ := project.Path("authors").Children()
+	for _, author := range authorList {
+		if authorName, err := strValue(author, "name"); err == nil {
+			authors = append(authors, authorName)
+		}
+	}
+
+	sourceURL, _ := strValue(project, "links.sourceUrl")
+	issuesURL, _ := strValue(project, "links.issuesUrl")
+	return &ProjectInfo{strconv.Itoa(id), slug, name, summary, iconURL, authors, sourceURL, issuesURL, false}, nil
+}
+
+func (s curseForgeSource) GetFile(ctx context.Context, projectID, fileID string) (*FileInfo, error) {
+	descriptor, err := getJSONFromURL(ctx, fmt.Sprintf("%s/addon/%s/file/%s", curseForgeAPI, projectID, fileID))
+	if err != nil {
+		return nil, err
+	}
+
+	downloadURL, err := strValue(descriptor, "downloadUrl")
+	if err != nil {
+		return nil, fmt.Errorf("no download URL for file %s: %+v", fileID, err)
+	}
+
+	filename, _ := strValue(descriptor, "fileName")
+	releaseType, _ := intValue(descriptor, "releaseType")
+	fileDate, _ := strValue(descriptor, "fileDate")
+	size, _ := intValue(descriptor, "fileLength")
+	date, _ := time.Parse(time.RFC3339, fileDate)
+
+	var dependencies []string
+	deps, _ := descriptor.Path("dependencies").Children()
+	for _, dep := range deps {
+		depType, _ := intValue(dep, "type")
+		depID, err := intValue(dep, "addonId")
+		if err == nil && depType == curseForgeRequiredDependency {
+			dependencies = append(dependencies, strconv.Itoa(depID))
+		}
+	}
+
+	// Newer files are tagged with the sides (Client and/or Server) they're used on
+	var client, server bool
+	gameVersions, _ := descriptor.Path("gameVersion").Children()
+	for _, v := range gameVersions {
+		switch v.Data() {
+		case "Client":
+			client = true
+		case "Server":
+			server = true
+		}
+	}
+
+	return &FileInfo{
+		ID:           fileID,
+		ProjectID:    projectID,
+		Filename:     filename,
+		DownloadURL:  downloadURL,
+		ReleaseType:  releaseType,
+		Dependencies: dependencies,
+		ClientOnly:   client && !server,
+		SidesKnown:   client || server,
+		Date:         date,
+		Size:         int64(size),
+	}, nil
+}
+
+// GetChangelog retrieves a file's changelog; CurseForge changelogs are HTML, which is reduced to
+// plain text
+func (s curseForgeSource) GetChangelog(ctx context.Context, projectID, fileID string) (string, error) {
+	changelog, err := ReadStringFromUrl(ctx, fmt.Sprintf("%s/addon/%s/file/%s/changelog", curseForgeAPI, projectID, fileID))
+	if err != nil {
+		return "", err
+	}
+	return htmlToText(changelog), nil
+}
+
+// GetLatestFiles returns the latest file for each version of Minecraft (and release type)
+func (s curseForgeSource) GetLatestFiles(ctx context.Context, projectID string) ([]FileInfo, error) {
+	project, err := getJSONFromURL(ctx, fmt.Sprintf("%s/addon/%s", curseForgeAPI, projectID))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []FileInfo
+	files, _ := project.Path("gameVersionLatestFiles").Children()
+	for _, file := range files {
+		fileType, _ := intValue(file, "fileType") // 1 = release, 2 = beta, 3 = alpha
+		fileID, _ := intValue(file, "projectFileId")
+		filename, _ := strValue(file, "projectFileName")
+		targetVsn, _ := strValue(file, "gameVersion")
+
+		result = append(result, FileInfo{
+			ID:               strconv.Itoa(fileID),
+			ProjectID:        projectID,
+			Filename:         filename,
+			MinecraftVersion: targetVsn,
+			Loader:           curseForgeLoader(file),
+			ReleaseType:      fileType,
+		})
+	}
+	return result, nil
+}
+
+// curseForgeFile is an entry in the complete list of a project's files
+type curseForgeFile struct {
+	ID                int
+	Filename          string
+	DisplayName       string
+	MinecraftVersions []string
+	Loaders           []string
+	ReleaseType       int
+	Date              time.Time
+}
+
+// Mod loaders are listed along with the Minecraft versions in a file's game versions
+var curseForgeLoaderVersions = map[string]string{
+	"Forge":    "forge",
+	"Fabric":   "fabric",
+	"NeoForge": "neoforge",
+	"Quilt":    "quilt",
+}
+
+var minecraftVersionRegex = regexp.MustCompile(`^\d+\.\d+`)
+
+// getCurseForgeFiles retrieves every file ever published for a project, newest first
+func getCurseForgeFiles(ctx context.Context, projectID int) ([]curseForgeFile, error) {
+	list, err := getJSONFromURL(ctx, fmt.Sprintf("%s/addon/%d/files", curseForgeAPI, projectID))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []curseForgeFile
+	files, _ := list.Children()
+	for _, file := range files {
+		fileID, err := intValue(file, "id")
+		if err != nil {
+			continue
+		}
+
+		entry := curseForgeFile{ID: fileID}
+		entry.Filename, _ = strValue(file, "fileName")
+		entry.DisplayName, _ = strValue(file, "displayName")
+		entry.ReleaseType, _ = intValue(file, "releaseType")
+		if date, err := strValue(file, "fileDate"); err == nil {
+			entry.Date, _ = time.Parse(time.RFC3339, date)
+		}
+
+		gameVersions, _ := file.Path("gameVersion").Children()
+		for _, v := range gameVersions {
+			vsn, _ := v.Data().(string)
+			if loader, ok := curseForgeLoaderVersions[vsn]; ok {
+				entry.Loaders = append(entry.Loaders, loader)
+			} else if minecraftVersionRegex.MatchString(vsn) {
+				entry.MinecraftVersions = append(entry.MinecraftVersions, vsn)
+			}
+		}
+		result = append(result, entry)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Date.After(result[j].Date)
+	})
+	return result, nil
+}
+
+// getCurseForgeFileInfos retrieves every file of a project, newest first, as a FileInfo for each
+// version of Minecraft and loader the file supports
+func getCurseForgeFileInfos(ctx context.Context, projectID int) ([]FileInfo, error) {
+	files, err := getCurseForgeFiles(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+
+	var result []FileInfo
+	for _, file := range files {
+		loaders := file.Loaders
+		if len(loaders) == 0 {
+			loaders = []string{""}
+		}
+		for _, mcvsn := range file.MinecraftVersions {
+			for _, loader := range loaders {
+				result = append(result, FileInfo{
+					ID:               strconv.Itoa(file.ID),
+					ProjectID:        strconv.Itoa(projectID),
+					Filename:         file.Filename,
+					MinecraftVersion: mcvsn,
+					Loader:           loader,
+					ReleaseType:      file.ReleaseType,
+					Date:             file.Date,
+				})
+			}
+		}
+	}
+	return result, nil
+}
+
+// PrintCurseForgeModFiles lists every file of a project, so a specific file ID can be selected
+func PrintCurseForgeModFiles(ctx context.Context, projectID int) error {
+	files, err := getCurseForgeFiles(ctx, projectID)
+	if err != nil {
+		return fmt.Errorf("failed to retrieve files for project %d: %+v", projectID, err)
+	}
+
+	for _, file := range files {
+		// Older files predate loader tags; they're all for Forge
+		loaders := "forge"
+		if len(file.Loaders) > 0 {
+			loaders = strings.Join(file.Loaders, "+")
+		}
+		fmt.Fprintf(consoleOutput, "%d | %s | %s | %s | %s | %s\n", file.ID, file.Date.Format("2006-01-02"), file.DisplayName,
+			strings.Join(file.MinecraftVersions, ", "), loaders, ReleaseTypeName(file.ReleaseType))
+	}
+	return nil
+}
+
+var htmlBreakRegex = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|h\d)>`)
+var htmlTagRegex = regexp.MustCompile(`<[^>]*>`)
+
+// htmlToText strips the markup from an HTML fragment, keeping line breaks
+func htmlToText(fragment string) string {
+	text := htmlBreakRegex.ReplaceAllString(fragment, "\n")
+	text = html.UnescapeString(htmlTagRegex.ReplaceAllString(text, ""))
+
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func curseForgeLoader(file *gabs.Container) string {
+	modLoaderId, _ := intValue(file, "modLoader") // 1 == forge, 4 == fabric
+	switch modLoaderId {
+	case 1:
+		return "forge"
+	case 4:
+		return "fabric"
+	}
+	return ""
+}
@@ -0,0 +1,138 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Jeffail/gabs"
+)
+
+const modrinthAPI = "https://api.modrinth.com/v2"
+
+// modrinthSource retrieves project metadata from the Modrinth API; Modrinth "versions" are
+// treated as files
+type modrinthSource struct{}
+
+func (s modrinthSource) Name() string {
+	return "modrinth"
+}
+
+// GetProject looks up a project by ID or slug
+func (s modrinthSource) GetProject(ctx context.Context, projectID string) (*ProjectInfo, error) {
+	project, err := getJSONFromURL(ctx, fmt.Sprintf("%s/project/%s", modrinthAPI, projectID))
+	if err != nil {
+		return nil, err
+	}
+
+	id, _ := strValue(project, "id")
+	slug, _ := strValue(project, "slug")
+	name, _ := strValue(project, "title")
+	summary, _ := strValue(project, "description")
+	iconURL, _ := strValue(project, "icon_url")
+	sourceURL, _ := strValue(project, "source_url")
+	issuesURL, _ := strValue(project, "issues_url")
+	serverSide, _ := strValue(project, "server_side")
+	return &ProjectInfo{id, slug, name, summary, iconURL, nil, sourceURL, issuesURL, serverSide == "unsupported"}, nil
+}
+
+func (s modrinthSource) GetFile(ctx context.Context, projectID, fileID string) (*FileInfo, error) {
+	version, err := getJSONFromURL(ctx, fmt.Sprintf("%s/version/%s", modrinthAPI, fileID))
+	if err != nil {
+		return nil, err
+	}
+
+	files := modrinthFiles(version)
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no files available for version %s", fileID)
+	}
+	return &files[0], nil
+}
+
+// GetChangelog retrieves a version's changelog, which Modrinth stores as markdown
+func (s modrinthSource) GetChangelog(ctx context.Context, projectID, fileID string) (string, error) {
+	version, err := getJSONFromURL(ctx, fmt.Sprintf("%s/version/%s", modrinthAPI, fileID))
+	if err != nil {
+		return "", err
+	}
+
+	changelog, _ := strValue(version, "changelog")
+	return strings.TrimSpace(changelog), nil
+}
+
+// GetLatestFiles returns the files for all versions of the project, newest first
+func (s modrinthSource) GetLatestFiles(ctx context.Context, projectID string) ([]FileInfo, error) {
+	versions, err := getJSONFromURL(ctx, fmt.Sprintf("%s/project/%s/version", modrinthAPI, projectID))
+	if err != nil {
+		return nil, err
+	}
+
+	var result []FileInfo
+	children, _ := versions.Children()
+	for _, version := range children {
+		result = append(result, modrinthFiles(version)...)
+	}
+	return result, nil
+}
+
+// modrinthFiles expands a version into a FileInfo for each supported Minecraft version and loader;
+// the projects the version requires are its dependencies
+func modrinthFiles(version *gabs.Container) []FileInfo {
+	id, _ := strValue(version, "id")
+	projectID, _ := strValue(version, "project_id")
+
+	published, _ := strValue(version, "date_published")
+	date, _ := time.Parse(time.RFC3339, published)
+
+	var releaseType int
+	versionType, _ := strValue(version, "version_type")
+	switch versionType {
+	case "release":
+		releaseType = ReleaseTypeRelease
+	case "beta":
+		releaseType = ReleaseTypeBeta
+	default:
+		releaseType = ReleaseTypeAlpha
+	}
+
+	// Use the primary file of the version (or the first one, if none are marked primary)
+	var filename, url string
+	var size int
+	files, _ := version.S("files").Children()
+	for _, file := range files {
+		primary, _ := file.S("primary").Data().(bool)
+		if url == "" || primary {
+			filename, _ = strValue(file, "filename")
+			url, _ = strValue(file, "url")
+			size, _ = intValue(file, "size")
+		}
+	}
+
+	if url == "" {
+		return nil
+	}
+
+	// Dependencies may only identify a specific version, without its project; those are skipped
+	var dependencies []string
+	deps, _ := version.S("dependencies").Children()
+	for _, dep := range deps {
+		depType, _ := strValue(dep, "dependency_type")
+		depProject, _ := strValue(dep, "project_id")
+		if depType == "required" && depProject != "" {
+			dependencies = append(dependencies, depProject)
+		}
+	}
+
+	var result []FileInfo
+	gameVersions, _ := version.S("game_versions").Children()
+	loaders, _ := version.S("loaders").Children()
+	for _, gameVersion := range gameVersions {
+		mcvsn, _ := gameVersion.Data().(string)
+		for _, loader := range loaders {
+			loaderName, _ := loader.Data().(string)
+			result = append(result, FileInfo{id, projectID, filename, url, mcvsn, loaderName, releaseType, dependencies, false, false, date, int64(size)})
+		}
+	}
+	return result
+}
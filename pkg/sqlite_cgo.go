@@ -0,0 +1,12 @@
+//go:build cgo && !purego
+// +build cgo,!purego
+
+package pkg
+
+import (
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// The SQLite driver used for the mod database and each pack's mod cache; builds without cgo (or
+// with the purego tag) use a pure Go driver instead (see sqlite_purego.go)
+const sqliteDriver = "sqlite3"
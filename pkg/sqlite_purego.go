@@ -0,0 +1,12 @@
+//go:build !cgo || purego
+// +build !cgo purego
+
+package pkg
+
+import (
+	_ "modernc.org/sqlite"
+)
+
+// The pure Go SQLite driver, for cross-compiles and static binaries (e.g. CGO_ENABLED=0 go build, or
+// go build -tags purego); the database files are the same as with the cgo driver
+const sqliteDriver = "sqlite"
@@ -0,0 +1,85 @@
+package pkg
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+)
+
+// Named Minecraft installations ("targets") are registered in the config of the default mcdex
+// directory, so that they can be looked up before the environment is initialized
+
+func targetsConfig() (*Config, error) {
+	return loadConfig(_findMcdexDir(MinecraftDir()))
+}
+
+// LookupTarget returns the Minecraft directory registered under the given name
+func LookupTarget(name string) (string, error) {
+	cfg, err := targetsConfig()
+	if err != nil {
+		return "", err
+	}
+
+	dir, ok := cfg.data.Search("targets", name).Data().(string)
+	if !ok {
+		return "", fmt.Errorf("unknown target %s; register it with target.add", name)
+	}
+	return dir, nil
+}
+
+// AddTarget registers a Minecraft directory under the given name
+func AddTarget(name, dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+
+	if !dirExists(dir) {
+		return fmt.Errorf("%s does not exist", dir)
+	}
+
+	cfg, err := targetsConfig()
+	if err != nil {
+		return err
+	}
+
+	cfg.set(dir, "targets", name)
+	logInfo("Registered target %s: %s\n", name, dir)
+	return cfg.save()
+}
+
+// RemoveTarget unregisters the named Minecraft directory
+func RemoveTarget(name string) error {
+	cfg, err := targetsConfig()
+	if err != nil {
+		return err
+	}
+
+	if !cfg.data.Exists("targets", name) {
+		return fmt.Errorf("unknown target %s", name)
+	}
+
+	cfg.delete("targets", name)
+	return cfg.save()
+}
+
+// ListTargets displays all the registered Minecraft directories
+func ListTargets() error {
+	cfg, err := targetsConfig()
+	if err != nil {
+		return err
+	}
+
+	targets, _ := cfg.data.S("targets").ChildrenMap()
+	var names []string
+	for name := range targets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		dir, _ := targets[name].Data().(string)
+		fmt.Fprintf(consoleOutput, "%s: %s\n", name, dir)
+	}
+	return nil
+}
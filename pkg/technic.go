@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Technic packs are a ZIP of the game directory, with the mod loader's version profile provided as
+// version.json inside bin/modpack.jar
+var technicDirs = []string{"mods", "config", "scripts", "resources"}
+
+// ExportTechnic builds a Technic-compatible ZIP of the pack in the current directory and returns
+// its filename
+func (pack *ModPack) ExportTechnic(ctx context.Context) (string, error) {
+	versionJson, err := pack.technicVersionProfile(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	modpackJar, err := technicModpackJar(versionJson)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate modpack.jar: %+v", err)
+	}
+
+	name, _ := strValue(pack.manifest, "name")
+	version, _ := strValue(pack.manifest, "version")
+	filename, _ := filepath.Abs(fmt.Sprintf("%s-%s-technic.zip", strings.ReplaceAll(name, " ", "_"), version))
+
+	f, err := os.Create(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	writer := zip.NewWriter(f)
+	entry, err := writer.Create("bin/modpack.jar")
+	if err == nil {
+		_, err = entry.Write(modpackJar)
+	}
+
+	for _, dir := range technicDirs {
+		if err == nil && dirExists(filepath.Join(pack.gamePath(), dir)) {
+			err = addDirToZip(writer, pack.gamePath(), dir)
+		}
+	}
+
+	if err == nil {
+		err = writer.Close()
+	}
+	if err == nil {
+		err = f.Close()
+	}
+	if err != nil {
+		os.Remove(filename)
+		return "", fmt.Errorf("failed to write %s: %+v", filename, err)
+	}
+
+	return filename, nil
+}
+
+// technicVersionProfile reads the version profile for the pack's mod loader. If the loader isn't
+// already installed in the Minecraft directory, it's installed into a temporary directory rather
+// than adding a version the user didn't ask for.
+func (pack *ModPack) technicVersionProfile(ctx context.Context) ([]byte, error) {
+	loaderId := pack.clientLoaderId()
+	profile := filepath.Join(Env().MinecraftDir, "versions", loaderId, loaderId+".json")
+	if !fileExists(profile) {
+		dir, err := newTempDir("", "mcdex-technic-")
+		if err != nil {
+			return nil, err
+		}
+		defer releaseTempDir(dir)
+
+		loaderId, err = pack.installClientLoaderTo(ctx, dir)
+		if err != nil {
+			return nil, err
+		}
+		profile = filepath.Join(dir, "versions", loaderId, loaderId+".json")
+	}
+
+	versionJson, err := ioutil.ReadFile(profile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read version profile for %s: %+v", loaderId, err)
+	}
+	return versionJson, nil
+}
+
+// technicModpackJar wraps the version profile in a JAR, as expected by the Technic launcher
+func technicModpackJar(versionJson []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	entry, err := writer.Create("version.json")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = entry.Write(versionJson)
+	if err != nil {
+		return nil, err
+	}
+
+	err = writer.Close()
+	return buf.Bytes(), err
+}
@@ -0,0 +1,150 @@
+package pkg
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// All temporary directories created by mcdex are recorded in a journal (along with the PID of
+// the owning process) so that they can be cleaned up on the next run if mcdex exits without
+// getting a chance to remove them.
+var tempDirLock sync.Mutex
+var tempDirs = make(map[string]bool)
+
+func tempJournalFile() string {
+	return filepath.Join(Env().McdexDir, "tmp.journal")
+}
+
+// newTempDir creates a new temporary directory (in the system temp directory if dir is empty) and
+// registers it in the journal
+func newTempDir(dir, pattern string) (string, error) {
+	tempDirLock.Lock()
+	defer tempDirLock.Unlock()
+
+	path, err := ioutil.TempDir(dir, pattern)
+	if err != nil {
+		return "", fmt.Errorf("failed to create temp directory: %+v", err)
+	}
+
+	tempDirs[path] = true
+	err = writeTempJournal()
+	if err != nil {
+		logWarn("Failed to update temp directory journal: %+v\n", err)
+	}
+	return path, nil
+}
+
+// releaseTempDir removes a temporary directory and its journal entry
+func releaseTempDir(path string) {
+	os.RemoveAll(path)
+	forgetTempDir(path)
+}
+
+// forgetTempDir removes a temporary directory from the journal without deleting it; used when a
+// temporary directory becomes permanent
+func forgetTempDir(path string) {
+	tempDirLock.Lock()
+	defer tempDirLock.Unlock()
+
+	delete(tempDirs, path)
+	err := writeTempJournal()
+	if err != nil {
+		logWarn("Failed to update temp directory journal: %+v\n", err)
+	}
+}
+
+// CleanupTempDirs removes all temporary directories created by this process
+func CleanupTempDirs() {
+	tempDirLock.Lock()
+	paths := make([]string, 0, len(tempDirs))
+	for path := range tempDirs {
+		paths = append(paths, path)
+	}
+	tempDirLock.Unlock()
+
+	for _, path := range paths {
+		releaseTempDir(path)
+	}
+}
+
+// writeTempJournal rewrites the journal; entries from other running mcdex processes are preserved
+func writeTempJournal() error {
+	var lines []string
+	for _, entry := range readTempJournal() {
+		if entry.pid != os.Getpid() {
+			lines = append(lines, fmt.Sprintf("%d\t%s", entry.pid, entry.path))
+		}
+	}
+
+	for path := range tempDirs {
+		lines = append(lines, fmt.Sprintf("%d\t%s", os.Getpid(), path))
+	}
+
+	if len(lines) == 0 {
+		err := os.Remove(tempJournalFile())
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	return writeStringFile(tempJournalFile(), strings.Join(lines, "\n")+"\n")
+}
+
+type tempJournalEntry struct {
+	pid  int
+	path string
+}
+
+func readTempJournal() []tempJournalEntry {
+	data, err := readStringFile(tempJournalFile())
+	if err != nil {
+		return nil
+	}
+
+	var result []tempJournalEntry
+	for _, line := range strings.Split(data, "\n") {
+		parts := strings.SplitN(line, "\t", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		pid, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+		result = append(result, tempJournalEntry{pid, parts[1]})
+	}
+	return result
+}
+
+// sweepTempDirs removes any leftover temporary directories from previous runs of mcdex that
+// are no longer running
+func sweepTempDirs() {
+	tempDirLock.Lock()
+	defer tempDirLock.Unlock()
+
+	var remaining []string
+	for _, entry := range readTempJournal() {
+		if entry.pid != os.Getpid() && processExists(entry.pid) {
+			remaining = append(remaining, fmt.Sprintf("%d\t%s", entry.pid, entry.path))
+			continue
+		}
+
+		if dirExists(entry.path) {
+			logInfo("Removing leftover temp directory %s\n", entry.path)
+			os.RemoveAll(entry.path)
+		}
+	}
+
+	if len(remaining) == 0 {
+		os.Remove(tempJournalFile())
+	} else {
+		writeStringFile(tempJournalFile(), strings.Join(remaining, "\n")+"\n")
+	}
+}
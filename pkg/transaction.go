@@ -0,0 +1,262 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// packTransaction stages a change to a pack: the pack directory is mirrored into a staging directory
+// alongside it, the change is made there and, if it succeeds, the staging directory is swapped in
+// for the pack. A failed install or update just discards the staging directory, so the pack is never
+// left half-changed.
+//
+// Files directly in the pack and game directories (the manifest, mod cache, instance.cfg and the like)
+// may be rewritten in place, so they're copied into the staging directory; pack.zip and everything
+// in subdirectories (mods, files from the pack archive, worlds) is only ever replaced, so it's hard
+// linked and beginning a transaction is cheap even for large packs.
+type packTransaction struct {
+	root     string         // where the pack is swapped in when the transaction commits
+	stage    string         // staging directory; the pack's root while the transaction is running
+	created  []string       // files and directories outside the pack to remove if the change fails
+	onCommit []func() error // changes outside the pack, made once the pack has been swapped in
+}
+
+// Transaction runs a change to the pack (e.g. an install or update) in a staging directory; the pack
+// is only replaced if the change succeeds. Changes outside the pack directory are covered too: the
+// launcher profile is only saved once the pack is in place, and loader versions installed for the
+// pack are removed if it fails.
+func (pack *ModPack) Transaction(fn func() error) error {
+	if pack.txn != nil {
+		// Already part of a transaction
+		return fn()
+	}
+
+	txn, err := pack.beginTransaction()
+	if err != nil {
+		return err
+	}
+
+	pack.txn = txn
+	err = fn()
+	pack.txn = nil
+
+	if err == nil {
+		return pack.commit(txn)
+	}
+
+	abortErr := pack.abort(txn)
+	if abortErr != nil {
+		logWarn("Unable to discard changes to %s: %+v\n", pack.Name, abortErr)
+	} else {
+		logWarn("Discarded changes to %s\n", pack.Name)
+	}
+	return err
+}
+
+func (pack *ModPack) beginTransaction() (*packTransaction, error) {
+	stage, err := newTempDir(filepath.Dir(pack.rootPath), ".mcdex-txn-")
+	if err != nil {
+		return nil, err
+	}
+
+	txn := &packTransaction{root: pack.rootPath, stage: stage}
+	err = mirrorPackDir(pack.rootPath, stage, pack.gameDir)
+	if err != nil {
+		releaseTempDir(stage)
+		return nil, fmt.Errorf("failed to stage %s: %+v", pack.Name, err)
+	}
+
+	err = pack.moveTo(stage)
+	if err != nil {
+		releaseTempDir(stage)
+		return nil, err
+	}
+	return txn, nil
+}
+
+// mirrorPackDir recreates a pack directory in the staging directory; see packTransaction for which
+// files are copied and which are linked
+func mirrorPackDir(root, stage, gameDir string) error {
+	gamePath := filepath.Join(root, gameDir)
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		name, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(stage, name)
+
+		switch {
+		case info.IsDir():
+			return os.MkdirAll(target, 0700)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, target)
+		case !info.Mode().IsRegular():
+			return nil
+		}
+
+		dir := filepath.Dir(path)
+		if (dir == root || dir == gamePath) && info.Name() != "pack.zip" {
+			return copyFileTo(path, target)
+		}
+		if os.Link(path, target) != nil {
+			return copyFileTo(path, target)
+		}
+		return nil
+	})
+}
+
+// moveTo points the pack at a different root directory, reopening the mod cache there
+func (pack *ModPack) moveTo(root string) error {
+	if pack.modCache != nil {
+		pack.modCache.db.Close()
+	}
+
+	pack.rootPath = root
+	var err error
+	pack.modCache, err = OpenMetaCache(pack)
+	if err != nil {
+		return fmt.Errorf("failed to open mod cache: %+v", err)
+	}
+	return nil
+}
+
+// commit swaps the staging directory in for the pack and makes the changes that were put off until
+// then
+func (pack *ModPack) commit(txn *packTransaction) error {
+	pack.modCache.db.Close()
+
+	// The previous pack is moved aside (rather than deleted) until the staged one is in place; it's
+	// left out of the temp directory journal so it's never swept up if mcdex dies part-way through
+	var old string
+	if dirExists(txn.root) {
+		var err error
+		old, err = ioutil.TempDir(filepath.Dir(txn.root), ".mcdex-old-")
+		if err == nil {
+			os.Remove(old)
+			err = os.Rename(txn.root, old)
+		}
+		if err != nil {
+			pack.abort(txn)
+			return fmt.Errorf("failed to replace %s: %+v", txn.root, err)
+		}
+	}
+
+	err := os.Rename(txn.stage, txn.root)
+	if err != nil {
+		if old != "" {
+			os.Rename(old, txn.root)
+		}
+		pack.abort(txn)
+		return fmt.Errorf("failed to replace %s: %+v", txn.root, err)
+	}
+
+	forgetTempDir(txn.stage)
+	if old != "" {
+		os.RemoveAll(old)
+	}
+
+	err = pack.moveTo(txn.root)
+	if err != nil {
+		return err
+	}
+
+	for _, fn := range txn.onCommit {
+		err = fn()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// abort discards the staging directory (and anything created outside the pack for it) and points
+// the pack back at its previous state
+func (pack *ModPack) abort(txn *packTransaction) error {
+	for _, path := range txn.created {
+		os.RemoveAll(path)
+	}
+	releaseTempDir(txn.stage)
+
+	// A new pack that was never swapped in has nothing to go back to
+	if !dirExists(txn.root) {
+		pack.modCache.db.Close()
+		pack.rootPath = txn.root
+		return nil
+	}
+
+	err := pack.moveTo(txn.root)
+	if err != nil {
+		return err
+	}
+	if fileExists(filepath.Join(pack.gamePath(), "manifest.json")) {
+		return pack.loadManifest()
+	}
+	return nil
+}
+
+// installedRootPath is where the pack will be once any transaction it's part of commits; paths
+// recorded outside the pack (e.g. in launcher profiles) use it rather than the staging directory
+func (pack *ModPack) installedRootPath() string {
+	if pack.txn != nil {
+		return pack.txn.root
+	}
+	return pack.rootPath
+}
+
+// trackCreated records a file or directory that was created outside the pack for a change, so
+// it's removed if the transaction the pack is part of fails
+func (pack *ModPack) trackCreated(path string) {
+	if pack.txn != nil {
+		pack.txn.created = append(pack.txn.created, path)
+	}
+}
+
+// afterCommit runs a change outside the pack once the transaction the pack is part of commits (or
+// right away, if it isn't part of one)
+func (pack *ModPack) afterCommit(fn func() error) error {
+	if pack.txn != nil {
+		pack.txn.onCommit = append(pack.txn.onCommit, fn)
+		return nil
+	}
+	return fn()
+}
+
+// listDir returns the names of the entries in a directory (none if it doesn't exist)
+func listDir(dir string) map[string]bool {
+	result := make(map[string]bool)
+	entries, _ := ioutil.ReadDir(dir)
+	for _, entry := range entries {
+		result[entry.Name()] = true
+	}
+	return result
+}
+
+func copyFileTo(source, target string) error {
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
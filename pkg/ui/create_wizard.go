@@ -0,0 +1,126 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/rivo/tview"
+	"mcdex/pkg"
+)
+
+// PackSpec is the pack the user described in the create wizard
+type PackSpec struct {
+	Name             string
+	Loader           string
+	MinecraftVersion string
+	MultiMC          bool
+	Mods             []string // slugs of mods to add once the pack is created
+}
+
+var wizardLoaders = []string{"fabric", "forge"}
+
+// RunCreateWizard walks the user through the choices needed to create a pack: its name, mod loader,
+// Minecraft version (from the database), launcher and initial mods. The result is nil if the user
+// cancelled.
+func RunCreateWizard(ctx context.Context, db *pkg.Database, multiMC bool) (*PackSpec, error) {
+	versions := make(map[string][]string)
+	for _, loader := range wizardLoaders {
+		mcvsns, err := db.GetSupportedMCVersions(loader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get supported MC versions for %s: %+v", loader, err)
+		}
+		versions[loader] = mcvsns
+	}
+
+	// MultiMC instances can only be created if it was found (or given with -mmcdir)
+	launchers := []string{"Vanilla launcher"}
+	if pkg.Env().MultiMCDir != "" {
+		launchers = append(launchers, "MultiMC")
+	} else {
+		multiMC = false
+	}
+
+	app := tview.NewApplication()
+	spec := &PackSpec{Loader: wizardLoaders[0], MultiMC: multiMC}
+	var created bool
+
+	form := tview.NewForm()
+	versionDropDown := tview.NewDropDown().SetLabel("Minecraft version:")
+	refreshVersions := func() {
+		versionDropDown.SetOptions(versions[spec.Loader], func(text string, index int) {
+			spec.MinecraftVersion = text
+		})
+		if len(versions[spec.Loader]) > 0 {
+			versionDropDown.SetCurrentOption(0)
+		} else {
+			spec.MinecraftVersion = ""
+		}
+	}
+
+	form.AddInputField("Name:", "", 40, nil, func(text string) {
+		spec.Name = strings.TrimSpace(text)
+	})
+	form.AddDropDown("Loader:", wizardLoaders, 0, func(option string, index int) {
+		spec.Loader = option
+		refreshVersions()
+	})
+	form.AddFormItem(versionDropDown)
+	launcher := 0
+	if spec.MultiMC {
+		launcher = 1
+	}
+	form.AddDropDown("Launcher:", launchers, launcher, func(option string, index int) {
+		spec.MultiMC = index == 1
+	})
+	form.AddInputField("Mods (optional):", "", 40, nil, func(text string) {
+		spec.Mods = strings.FieldsFunc(text, func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+	})
+	refreshVersions()
+
+	status := tview.NewTextView().SetDynamicColors(true).
+		SetText("Mods are given by their slugs, separated by commas (e.g. jei, journeymap)")
+
+	form.AddButton("Create", func() {
+		switch {
+		case spec.Name == "":
+			status.SetText("[red]A name is required")
+		case spec.Name == pkg.NamePlaceholder:
+			status.SetText(fmt.Sprintf("[red]%q can't be used as a name", pkg.NamePlaceholder))
+		case spec.MinecraftVersion == "":
+			status.SetText(fmt.Sprintf("[red]No Minecraft versions are available for %s; try db.update", spec.Loader))
+		default:
+			// Check the mods before anything is created, so a typo doesn't leave a half-made pack
+			for _, mod := range spec.Mods {
+				_, err := db.FindProjectBySlug(ctx, mod, spec.Loader, 0)
+				if err != nil {
+					status.SetText(fmt.Sprintf("[red]%s", tview.Escape(err.Error())))
+					return
+				}
+			}
+			created = true
+			app.Stop()
+		}
+	})
+	form.AddButton("Cancel", func() {
+		app.Stop()
+	})
+	form.SetCancelFunc(func() {
+		app.Stop()
+	})
+	form.SetBorder(true).SetTitle(" Create a pack ")
+
+	app.SetRoot(makeCenteredModal(tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(form, 0, 1, true).
+		AddItem(status, 2, 0, false), 70, 17), true)
+	app.SetFocus(form)
+
+	err := app.Run()
+	if err != nil || !created {
+		return nil, err
+	}
+	return spec, nil
+}
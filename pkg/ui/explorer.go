@@ -1,36 +1,72 @@
 package ui
 
 import (
+	"context"
 	"fmt"
+	"github.com/gdamore/tcell/v2"
 	"github.com/pkg/browser"
 	"github.com/rivo/tview"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
 	"mcdex/pkg"
+	"strings"
 )
 
 type Explorer struct {
+	ctx context.Context
 	app *tview.Application
 	db *pkg.Database
 
 	modBrowser *ModBrowser
+	packBrowser *ModBrowser
+	packManager *PackManager
 
 	pages *tview.Pages
 }
 
-func NewExplorer(db *pkg.Database) (*Explorer, error) {
+func NewExplorer(ctx context.Context, db *pkg.Database) (*Explorer, error) {
 	var err error
-	e := &Explorer{db: db, app: tview.NewApplication()}
-	e.app.EnableMouse(false)
+	keys, err = loadKeymap(pkg.GetConfig())
+	if err != nil {
+		return nil, err
+	}
 
-	e.modBrowser, err = NewModBrowser(e.app, db)
+	e := &Explorer{ctx: ctx, db: db, app: tview.NewApplication()}
+	e.app.EnableMouse(pkg.GetConfig() != nil && pkg.GetConfig().TUIMouseEnabled())
+
+	e.modBrowser, err = NewModBrowser(e.app, db, 0)
 	if err != nil {
 		return nil, fmt.Errorf("error initializing mod browser: %+v", err)
 	}
-	e.modBrowser.SetModSelectedFunc(e.showModDetail)
+	e.modBrowser.SetModSelectedFunc(e.showPackPicker)
+	e.modBrowser.SetModOpenedFunc(e.openModPage)
+	e.modBrowser.SetModInfoFunc(e.showModDetail)
+
+	e.packBrowser, err = NewModBrowser(e.app, db, 1)
+	if err != nil {
+		return nil, fmt.Errorf("error initializing modpack browser: %+v", err)
+	}
+	e.packBrowser.SetModSelectedFunc(e.showPackInstall)
+	e.packBrowser.SetModOpenedFunc(e.openPackPage)
+	e.packBrowser.SetModInfoFunc(e.showPackDetail)
+
+	e.packManager = NewPackManager(e.ctx, e.app)
 
 	e.pages = tview.NewPages().
-		AddPage("mod_browser", e.modBrowser.RootView(), true, true)
+		AddPage("mod_browser", e.modBrowser.RootView(), true, true).
+		AddPage("pack_browser", e.packBrowser.RootView(), true, false).
+		AddPage("pack_manager", e.packManager.RootView(), true, false)
+
+	help := tview.NewTextView().
+		SetText(fmt.Sprintf(" %s: Mods  %s: Modpacks  %s: Installed packs  %s: Install  %s: Details  %s: Help  %s: Quit",
+			keys.name("mods"), keys.name("modpacks"), keys.name("packs"), keys.name("install"), keys.name("details"),
+			keys.name("help"), keys.name("quit")))
 
-	e.app.SetRoot(e.pages, true)
+	e.app.SetRoot(tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(e.pages, 0, 1, true).
+		AddItem(help, 1, 0, false), true)
+	e.app.SetInputCapture(e.onKey)
 
 	return e, nil
 }
@@ -39,9 +75,247 @@ func (e *Explorer) Run() error {
 	return e.app.Run()
 }
 
+// onKey handles the keys that apply everywhere: switching between the browsers, help and quitting
+func (e *Explorer) onKey(event *tcell.EventKey) *tcell.EventKey {
+	// Don't steal characters while the user is typing
+	_, typing := e.app.GetFocus().(*tview.InputField)
+	matches := func(action string) bool {
+		return keys.matches(action, event) && !(typing && keys.isChar(action))
+	}
+
+	switch {
+	case matches("mods"):
+		e.pages.SwitchToPage("mod_browser")
+	case matches("modpacks"):
+		e.pages.SwitchToPage("pack_browser")
+	case matches("packs"):
+		e.pages.SwitchToPage("pack_manager")
+		e.packManager.Refresh()
+	case matches("help"):
+		e.showHelp()
+	case matches("quit"):
+		e.app.Stop()
+	default:
+		return event
+	}
+	return nil
+}
+
+// showHelp displays the key bindings
+func (e *Explorer) showHelp() {
+	if e.pages.HasPage("help") {
+		return
+	}
+
+	view := tview.NewTextView().SetDynamicColors(true)
+	for _, action := range keyActions {
+		fmt.Fprintf(view, "[yellow]%-10s[white] %s\n", tview.Escape(keys.name(action.name)), action.desc)
+	}
+	fmt.Fprintf(view, "\nKeys can be changed in the tui.keys section of config.json")
+	view.SetBorder(true).SetTitle(" Keys (Esc: Close) ")
+	view.SetDoneFunc(func(key tcell.Key) {
+		e.pages.RemovePage("help")
+	})
+
+	e.pages.AddPage("help", makeCenteredModal(view, 70, len(keyActions)+4), true, true)
+	e.app.SetFocus(view)
+}
+
+func modPageURL(slug string) string {
+	return fmt.Sprintf("https://www.curseforge.com/minecraft/mc-mods/%s", slug)
+}
+
+func packPageURL(slug string) string {
+	return fmt.Sprintf("https://www.curseforge.com/minecraft/modpacks/%s", slug)
+}
+
+func (e *Explorer) openModPage(slug string, loader string, mcvsn string) {
+	browser.OpenURL(modPageURL(slug))
+}
+
+func (e *Explorer) openPackPage(slug string, loader string, mcvsn string) {
+	browser.OpenURL(packPageURL(slug))
+}
+
 func (e *Explorer) showModDetail(slug string, loader string, mcvsn string) {
-	url := fmt.Sprintf("https://www.curseforge.com/minecraft/mc-mods/%s", slug)
-	browser.OpenURL(url)
+	e.showProjectDetail(slug, loader, mcvsn, modPageURL(slug))
+}
+
+func (e *Explorer) showPackDetail(slug string, loader string, mcvsn string) {
+	e.showProjectDetail(slug, loader, mcvsn, packPageURL(slug))
+}
+
+// showProjectDetail displays a pane with the project's details; they're retrieved in the
+// background, since it requires a few API calls
+func (e *Explorer) showProjectDetail(slug, loader, mcvsn, url string) {
+	view := tview.NewTextView().
+		SetDynamicColors(true).
+		SetWordWrap(true).
+		SetText("Loading...")
+	view.SetBorder(true).SetTitle(fmt.Sprintf(" %s (%s: Open in browser, Esc: Close) ", slug, keys.name("open")))
+	view.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyEscape {
+			e.pages.RemovePage("mod_detail")
+			return nil
+		} else if keys.matches("open", event) {
+			browser.OpenURL(url)
+			return nil
+		}
+		return event
+	})
+
+	e.pages.AddPage("mod_detail", makeCenteredModal(view, 100, 30), true, true)
+	e.app.SetFocus(view)
+
+	go func() {
+		detail, err := e.db.GetProjectDetail(e.ctx, slug, loader, mcvsn)
+		e.app.QueueUpdateDraw(func() {
+			if err != nil {
+				view.SetText(fmt.Sprintf("[red]%s", tview.Escape(err.Error())))
+				return
+			}
+			view.SetText(formatProjectDetail(detail, mcvsn))
+			view.ScrollToBeginning()
+		})
+	}()
+}
+
+func formatProjectDetail(detail *pkg.ProjectDetail, mcvsn string) string {
+	printer := message.NewPrinter(language.English)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[yellow]%s[white]\n%s\n\n", tview.Escape(detail.Name), tview.Escape(detail.Summary))
+	if len(detail.Authors) > 0 {
+		fmt.Fprintf(&b, "Authors: %s\n", tview.Escape(strings.Join(detail.Authors, ", ")))
+	}
+	b.WriteString(printer.Sprintf("Downloads: %d\n\n", detail.Downloads))
+
+	if len(detail.Dependencies) > 0 {
+		fmt.Fprintf(&b, "Requires (Minecraft %s): %s\n\n", mcvsn, tview.Escape(strings.Join(detail.Dependencies, ", ")))
+	}
+
+	b.WriteString("[yellow]Latest files[white]\n")
+	for _, file := range detail.LatestFiles {
+		loader := file.Loader
+		if loader == "" {
+			loader = "any"
+		}
+		fmt.Fprintf(&b, "%-10s %-8s %-6s %s\n", file.MinecraftVersion, loader, pkg.ReleaseTypeName(file.ReleaseType),
+			tview.Escape(file.Filename))
+	}
+	return b.String()
+}
+
+// showPackInstall asks the user where a modpack should be installed
+func (e *Explorer) showPackInstall(slug string, loader string, mcvsn string) {
+	multiMC := false
+	form := tview.NewForm().
+		AddInputField("Directory:", slug, 40, nil, nil).
+		AddCheckbox("MultiMC instance:", false, func(checked bool) {
+			multiMC = checked
+		})
+
+	form.AddButton("Install", func() {
+		dir := form.GetFormItem(0).(*tview.InputField).GetText()
+		if dir == "" {
+			return
+		}
+		e.pages.RemovePage("pack_install")
+		e.installPack(slug, dir, multiMC)
+	})
+	form.AddButton("Cancel", func() {
+		e.pages.RemovePage("pack_install")
+	})
+	form.SetCancelFunc(func() {
+		e.pages.RemovePage("pack_install")
+	})
+	form.SetBorder(true).SetTitle(fmt.Sprintf(" Install %s ", slug))
+
+	e.pages.AddPage("pack_install", makeCenteredModal(form, 60, 9), true, true)
+	e.app.SetFocus(form)
+}
+
+// installPack runs the equivalent of pack.install, displaying the progress in the install dashboard
+func (e *Explorer) installPack(slug, dir string, multiMC bool) {
+	e.app.Suspend(func() {
+		RunInstallDashboard(fmt.Sprintf("Installing %s to %s", slug, dir), func() error {
+			url, err := e.db.GetLatestPackURL(e.ctx, slug)
+			if err != nil {
+				return err
+			}
+			return pkg.InstallPack(e.ctx, dir, url, pkg.PackInstallOptions{MultiMC: multiMC})
+		})
+	})
+}
+
+// showPackPicker lets the user choose a pack (matching the mod's loader and Minecraft version) to
+// add the mod to
+func (e *Explorer) showPackPicker(slug string, loader string, mcvsn string) {
+	list := tview.NewList().ShowSecondaryText(false)
+	for _, p := range pkg.ListPacks() {
+		if p.Loader != loader || p.MinecraftVersion != mcvsn {
+			continue
+		}
+
+		pack := p
+		label := pack.Name
+		if pack.MultiMC {
+			label += " (MultiMC)"
+		}
+		list.AddItem(label, pack.Dir, 0, func() {
+			e.confirmSelectMod(slug, pack)
+		})
+	}
+
+	if list.GetItemCount() == 0 {
+		e.showMessage(fmt.Sprintf("No %s %s packs found", loader, mcvsn))
+		return
+	}
+
+	list.SetDoneFunc(func() {
+		e.pages.RemovePage("pack_picker")
+	})
+	list.SetBorder(true).SetTitle(fmt.Sprintf(" Add %s to... ", slug))
+
+	e.pages.AddPage("pack_picker", makeCenteredModal(list, 60, list.GetItemCount()+2), true, true)
+	e.app.SetFocus(list)
+}
+
+func (e *Explorer) confirmSelectMod(slug string, pack pkg.PackLocation) {
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("Add %s to %s?", slug, pack.Name)).
+		AddButtons([]string{"Add", "Add (client only)", "Cancel"}).
+		SetDoneFunc(func(index int, label string) {
+			e.pages.RemovePage("confirm")
+			if index == 0 || index == 1 {
+				e.pages.RemovePage("pack_picker")
+				e.selectMod(slug, pack, index == 1)
+			}
+		})
+	e.pages.AddPage("confirm", modal, true, true)
+}
+
+// selectMod runs the equivalent of mod.select, displaying the progress in the install dashboard
+func (e *Explorer) selectMod(slug string, pack pkg.PackLocation, clientOnly bool) {
+	e.app.Suspend(func() {
+		RunInstallDashboard(fmt.Sprintf("Adding %s to %s", slug, pack.Name), func() error {
+			cp, err := pkg.OpenModPack(e.ctx, pack.Dir, pack.MultiMC)
+			if err != nil {
+				return err
+			}
+			return pkg.SelectMod(e.ctx, cp, slug, "", clientOnly)
+		})
+	})
+}
+
+func (e *Explorer) showMessage(text string) {
+	modal := tview.NewModal().
+		SetText(text).
+		AddButtons([]string{"OK"}).
+		SetDoneFunc(func(index int, label string) {
+			e.pages.RemovePage("message")
+		})
+	e.pages.AddPage("message", modal, true, true)
 }
 
 func makeCenteredModal(p tview.Primitive, width, height int) tview.Primitive {
@@ -0,0 +1,192 @@
+package ui
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"mcdex/pkg"
+	"os"
+	"strings"
+	"time"
+)
+
+const progressBarWidth = 20
+
+// InstallDashboard displays a progress bar for each download during an install, with the rest of
+// the install's output in a log pane (instead of interleaving the two on the terminal)
+type InstallDashboard struct {
+	app *tview.Application
+
+	table *tview.Table
+	log *tview.TextView
+	status *tview.TextView
+
+	downloads map[string]*downloadState
+	done bool
+}
+
+type downloadState struct {
+	row int
+	total int64
+	count int64
+	started time.Time
+	elapsed time.Duration
+	finished bool
+	err error
+}
+
+// RunInstallDashboard runs an install with the dashboard displayed; once the install is complete,
+// a summary is displayed until a key is pressed
+func RunInstallDashboard(title string, install func() error) error {
+	d := &InstallDashboard{app: tview.NewApplication(), downloads: make(map[string]*downloadState)}
+
+	d.table = tview.NewTable().SetFixed(1, 0)
+	d.table.SetBorder(true).SetTitle(fmt.Sprintf(" %s ", title))
+	for col, header := range []string{"File", "Progress", "Size", "Speed", "Status"} {
+		d.table.SetCell(0, col, tview.NewTableCell(header).SetSelectable(false))
+	}
+
+	d.log = tview.NewTextView()
+	d.log.SetBorder(true).SetTitle(" Output ")
+
+	d.status = tview.NewTextView().SetDynamicColors(true).SetText("Installing...")
+
+	d.app.SetRoot(tview.NewFlex().
+		SetDirection(tview.FlexRow).
+		AddItem(d.table, 0, 2, false).
+		AddItem(d.log, 0, 1, false).
+		AddItem(d.status, 2, 0, false), true)
+
+	// Don't let the install be interrupted part way; once it's done, any key exits
+	d.app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if d.done {
+			d.app.Stop()
+		}
+		return nil
+	})
+
+	// Capture the output of the install for the log pane
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return fmt.Errorf("failed to capture output: %+v", err)
+	}
+	stdout := os.Stdout
+	os.Stdout = writer
+	pkg.SetOutput(writer)
+	go d.copyLog(reader)
+
+	pkg.SetDownloadObserver(d)
+	result := make(chan error, 1)
+	go func() {
+		err := install()
+		pkg.SetDownloadObserver(nil)
+		pkg.SetOutput(stdout)
+		os.Stdout = stdout
+		writer.Close()
+
+		result <- err
+		d.app.QueueUpdateDraw(func() {
+			d.finish(err)
+		})
+	}()
+
+	err = d.app.Run()
+	if err != nil {
+		return err
+	}
+	return <-result
+}
+
+func (d *InstallDashboard) copyLog(reader *os.File) {
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		line := scanner.Text()
+		d.app.QueueUpdateDraw(func() {
+			fmt.Fprintln(d.log, line)
+		})
+	}
+	reader.Close()
+}
+
+func (d *InstallDashboard) DownloadStarted(label string, total int64) {
+	d.app.QueueUpdateDraw(func() {
+		state := &downloadState{row: d.table.GetRowCount(), total: total, started: time.Now()}
+		d.downloads[label] = state
+		d.table.SetCell(state.row, 0, tview.NewTableCell(strings.TrimPrefix(label, "Downloading ")).SetMaxWidth(50))
+		d.updateRow(state)
+		d.table.ScrollToEnd()
+	})
+}
+
+func (d *InstallDashboard) DownloadProgress(label string, count int64) {
+	d.app.QueueUpdateDraw(func() {
+		if state, ok := d.downloads[label]; ok {
+			state.count = count
+			state.elapsed = time.Since(state.started)
+			d.updateRow(state)
+		}
+	})
+}
+
+func (d *InstallDashboard) DownloadFinished(label string, err error) {
+	d.app.QueueUpdateDraw(func() {
+		if state, ok := d.downloads[label]; ok {
+			state.finished = true
+			state.err = err
+			state.elapsed = time.Since(state.started)
+			d.updateRow(state)
+		}
+	})
+}
+
+func (d *InstallDashboard) updateRow(state *downloadState) {
+	bar := ""
+	if state.total > 0 {
+		filled := int(state.count * progressBarWidth / state.total)
+		bar = strings.Repeat("█", filled) + strings.Repeat("░", progressBarWidth-filled)
+	} else if state.finished {
+		bar = strings.Repeat("█", progressBarWidth)
+	}
+
+	speed := ""
+	if seconds := state.elapsed.Seconds(); seconds > 0 && state.count > 0 {
+		speed = pkg.FormatBytes(int64(float64(state.count)/seconds)) + "/s"
+	}
+
+	status := "[yellow]downloading"
+	if state.err != nil {
+		status = "[red]failed"
+	} else if state.finished {
+		status = "[green]done"
+	}
+
+	d.table.SetCell(state.row, 1, tview.NewTableCell(bar))
+	d.table.SetCell(state.row, 2, tview.NewTableCell(pkg.FormatBytes(state.count)))
+	d.table.SetCell(state.row, 3, tview.NewTableCell(speed))
+	d.table.SetCell(state.row, 4, tview.NewTableCell(status))
+}
+
+// finish displays a summary of the downloads and the result of the install
+func (d *InstallDashboard) finish(err error) {
+	d.done = true
+
+	var completed, failed int
+	var bytes int64
+	for label, state := range d.downloads {
+		bytes += state.count
+		if state.err != nil {
+			failed++
+			fmt.Fprintf(d.log, "Failed: %s: %+v\n", strings.TrimPrefix(label, "Downloading "), state.err)
+		} else if state.finished {
+			completed++
+		}
+	}
+
+	summary := fmt.Sprintf("%d files downloaded (%s), %d failed", completed, pkg.FormatBytes(bytes), failed)
+	if err != nil {
+		d.status.SetText(fmt.Sprintf("[red]Install failed:[white] %s\n%s; press any key to exit", tview.Escape(err.Error()), summary))
+	} else {
+		d.status.SetText(fmt.Sprintf("[green]Install complete:[white] %s; press any key to exit", summary))
+	}
+}
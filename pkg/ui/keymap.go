@@ -0,0 +1,114 @@
+package ui
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"mcdex/pkg"
+	"strings"
+	"unicode/utf8"
+)
+
+// An action that can be bound to a key; bindings can be overridden in the tui.keys section of
+// the config file (e.g. "tui.keys": {"install": "Ctrl-I", "quit": "Ctrl-Q"})
+type keyAction struct {
+	name string
+	desc string
+	defaultKey string
+}
+
+var keyActions = []keyAction{
+	{"next", "Move to the next field", "Tab"},
+	{"prev", "Move to the previous field", "Backtab"},
+	{"install", "Install the selected mod or pack", "Enter"},
+	{"details", "Show details of the selected mod or pack", "i"},
+	{"open", "Open the selected mod or pack in a browser", "o"},
+	{"search", "Jump to the search field", "/"},
+	{"sort.downloads", "Sort by downloads", "d"},
+	{"sort.updated", "Sort by recently updated", "u"},
+	{"sort.name", "Sort by name", "n"},
+	{"mods", "Browse mods", "F1"},
+	{"modpacks", "Browse modpacks", "F2"},
+	{"packs", "Manage installed packs", "F3"},
+	{"check", "Check for mod updates (installed packs)", "c"},
+	{"update", "Update the selected mod (installed packs)", "u"},
+	{"update.all", "Update all mods (installed packs)", "U"},
+	{"lock", "Lock/unlock the selected mod (installed packs)", "l"},
+	{"remove", "Remove the selected mod (installed packs)", "r"},
+	{"help", "Show this help", "?"},
+	{"quit", "Quit", "q"},
+}
+
+// keyBinding is either a special key (e.g. F1, Ctrl-Q) or a character
+type keyBinding struct {
+	name string
+	key tcell.Key
+	char rune
+}
+
+type keymap map[string]keyBinding
+
+var keys keymap
+
+// loadKeymap builds the key bindings from the defaults and the user's configuration; invalid
+// bindings in the configuration are reported and ignored
+func loadKeymap(cfg *pkg.Config) (keymap, error) {
+	result := make(keymap)
+	for _, action := range keyActions {
+		binding, _ := parseKey(action.defaultKey)
+		result[action.name] = binding
+	}
+
+	if cfg == nil {
+		return result, nil
+	}
+
+	for action, name := range cfg.TUIKeys() {
+		if _, ok := result[action]; !ok {
+			return nil, fmt.Errorf("unknown action %s in tui.keys", action)
+		}
+
+		binding, err := parseKey(name)
+		if err != nil {
+			return nil, fmt.Errorf("invalid key for %s in tui.keys: %+v", action, err)
+		}
+		result[action] = binding
+	}
+	return result, nil
+}
+
+func parseKey(name string) (keyBinding, error) {
+	if utf8.RuneCountInString(name) == 1 {
+		r, _ := utf8.DecodeRuneInString(name)
+		return keyBinding{name: name, key: tcell.KeyRune, char: r}, nil
+	}
+
+	for key, keyName := range tcell.KeyNames {
+		if strings.EqualFold(keyName, name) {
+			return keyBinding{name: keyName, key: key}, nil
+		}
+	}
+	return keyBinding{}, fmt.Errorf("unknown key %s", name)
+}
+
+// matches determines if the event triggers the action
+func (k keymap) matches(action string, event *tcell.EventKey) bool {
+	binding, ok := k[action]
+	if !ok {
+		return false
+	}
+
+	if binding.key == tcell.KeyRune {
+		return event.Key() == tcell.KeyRune && event.Rune() == binding.char
+	}
+	return event.Key() == binding.key
+}
+
+// isChar determines if the action is bound to a character (rather than a special key), in which
+// case it shouldn't be triggered while the user is typing
+func (k keymap) isChar(action string) bool {
+	return k[action].key == tcell.KeyRune
+}
+
+func (k keymap) name(action string) string {
+	return k[action].name
+}
@@ -8,19 +8,42 @@ import (
 	"golang.org/x/text/message"
 	"mcdex/pkg"
 	"strings"
+	"time"
 )
 
+// How long to wait for typing to pause before refreshing the table with a new search
+const searchDelay = 200 * time.Millisecond
+
 type ModSelectedHandler func(slug string, loader string, mcvsn string)
 
+// Sort orders available in the sort drop down
+type sortOrder struct {
+	name string
+	field string
+	ascending bool
+}
+
+var sortOrders = []sortOrder{
+	{"Downloads", "downloads", false},
+	{"Recently updated", "modified_ts", false},
+	{"Name", "slug", true},
+}
+
 type ModBrowser struct {
 	app *tview.Application
 	db *pkg.Database
+	ptype int
 
 	table *tview.Table
 	loaderDropDown *tview.DropDown
 	vsnDropDown *tview.DropDown
+	searchField *tview.InputField
+	categoryDropDown *tview.DropDown
+	sortDropDown *tview.DropDown
+	packDropDown *tview.DropDown
+	depsView *tview.TextView
 
-	root tview.Primitive
+	root *tview.Flex
 
 	focusOrder []tview.Primitive
 	focusIndex int
@@ -30,14 +53,24 @@ type ModBrowser struct {
 
 	loader string
 	mcvsn string
+	search string
+	searchTimer *time.Timer
+	category string
+
+	// Pack used to indicate which dependencies are already present
+	packs []pkg.PackLocation
+	targetPack *pkg.PackLocation
 
 	orderByField string
 	ascending bool
 
 	onModSelected ModSelectedHandler
+	onModOpened ModSelectedHandler
+	onModInfo ModSelectedHandler
 }
 
-func NewModBrowser(app *tview.Application, db *pkg.Database) (*ModBrowser, error) {
+// NewModBrowser creates a browser for projects of the given type (0 = mods, 1 = modpacks)
+func NewModBrowser(app *tview.Application, db *pkg.Database, ptype int) (*ModBrowser, error) {
 	forgeMcVersions, err := db.GetSupportedMCVersions("forge")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get supported MC version for Forge: %+v", err)
@@ -48,9 +81,15 @@ func NewModBrowser(app *tview.Application, db *pkg.Database) (*ModBrowser, error
 		return nil, fmt.Errorf("failed to get support MC versions for Fabric: %+v", err)
 	}
 
+	categories, err := db.GetCategories()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get categories: %+v", err)
+	}
+
 	b := &ModBrowser{
 		app: app,
 		db: db,
+		ptype: ptype,
 		forgeMcVersions: forgeMcVersions,
 		fabricMcVersions: fabricMcVersions,
 		orderByField: "downloads",
@@ -62,7 +101,7 @@ func NewModBrowser(app *tview.Application, db *pkg.Database) (*ModBrowser, error
 		SetFixed(1, 1).
 		SetSelectable(true, false).
 		SetEvaluateAllRows(true).
-		SetSelectedFunc(b.modSelected).
+		SetSelectionChangedFunc(b.modHighlighted).
 		SetDoneFunc(b.componentDone)
 
 	b.table.SetInputCapture(b.onTableKey)
@@ -77,19 +116,77 @@ func NewModBrowser(app *tview.Application, db *pkg.Database) (*ModBrowser, error
 		SetCurrentOption(0).
 		SetDoneFunc(b.componentDone)
 
+	b.searchField = tview.NewInputField().
+		SetLabel("Search:").
+		SetChangedFunc(b.searchChanged).
+		SetDoneFunc(b.searchDone)
+
+	b.categoryDropDown = tview.NewDropDown().
+		SetLabel("Category:").
+		SetOptions(append([]string{"All"}, categories...), b.categorySelected).
+		SetCurrentOption(0).
+		SetDoneFunc(b.componentDone)
+
+	var sortNames []string
+	for _, order := range sortOrders {
+		sortNames = append(sortNames, order.name)
+	}
+	b.sortDropDown = tview.NewDropDown().
+		SetLabel("Sort:").
+		SetOptions(sortNames, b.sortSelected).
+		SetCurrentOption(0).
+		SetDoneFunc(b.componentDone)
+
 	b.vsnDropDown.SetBorder(true)
 	b.loaderDropDown.SetBorder(true)
+	b.searchField.SetBorder(true)
+	b.categoryDropDown.SetBorder(true)
+	b.sortDropDown.SetBorder(true)
 
-	b.focusOrder = []tview.Primitive{b.loaderDropDown, b.vsnDropDown, b.table}
+	b.focusOrder = []tview.Primitive{b.loaderDropDown, b.vsnDropDown, b.categoryDropDown, b.sortDropDown, b.searchField, b.table}
 	b.focusIndex = 0
 
+	// Mods get a side panel with the dependencies of the highlighted mod
+	var body tview.Primitive = b.table
+	if ptype == 0 {
+		b.packs = pkg.ListPacks()
+		packNames := []string{"(none)"}
+		for _, p := range b.packs {
+			packNames = append(packNames, p.Name)
+		}
+
+		b.packDropDown = tview.NewDropDown().
+			SetLabel("Pack:").
+			SetOptions(packNames, b.packSelected).
+			SetCurrentOption(0).
+			SetDoneFunc(b.componentDone)
+		b.packDropDown.SetBorder(true)
+
+		b.depsView = tview.NewTextView().SetDynamicColors(true)
+		b.depsView.SetBorder(true).SetTitle(" Dependencies ")
+
+		b.focusOrder = append(b.focusOrder, b.packDropDown)
+		body = tview.NewFlex().
+			AddItem(b.table, 0, 3, true).
+			AddItem(tview.NewFlex().
+				SetDirection(tview.FlexRow).
+				AddItem(b.packDropDown, 3, 0, false).
+				AddItem(b.depsView, 0, 1, false),
+				0, 1, false)
+	}
+
 	b.root = tview.NewFlex().
 		SetDirection(tview.FlexRow).
 		AddItem(tview.NewFlex().
 			AddItem(b.loaderDropDown, 0, 1, true).
-			AddItem(b.vsnDropDown, 0, 1, true),
+			AddItem(b.vsnDropDown, 0, 1, true).
+			AddItem(b.categoryDropDown, 0, 1, true).
+			AddItem(b.sortDropDown, 0, 1, true).
+			AddItem(b.searchField, 0, 2, true),
 			0, 1, true).
-		AddItem(b.table, 0, 10, true)
+		AddItem(body, 0, 10, true)
+
+	b.root.SetInputCapture(b.onKey)
 
 	return b, nil
 }
@@ -98,6 +195,16 @@ func (b *ModBrowser) SetModSelectedFunc(f ModSelectedHandler) {
 	b.onModSelected = f
 }
 
+// SetModOpenedFunc sets the handler for opening the selected mod's web page ('o')
+func (b *ModBrowser) SetModOpenedFunc(f ModSelectedHandler) {
+	b.onModOpened = f
+}
+
+// SetModInfoFunc sets the handler for displaying details of the selected mod ('i')
+func (b *ModBrowser) SetModInfoFunc(f ModSelectedHandler) {
+	b.onModInfo = f
+}
+
 func (b *ModBrowser) RootView() tview.Primitive {
 	return b.root
 }
@@ -112,6 +219,84 @@ func (b *ModBrowser) versionSelected(name string, index int) {
 	b.refreshTable()
 }
 
+func (b *ModBrowser) categorySelected(name string, index int) {
+	if index == 0 {
+		b.category = ""
+	} else {
+		b.category = name
+	}
+
+	// Don't query until a version has been selected
+	if b.mcvsn != "" {
+		b.refreshTable()
+	}
+}
+
+func (b *ModBrowser) sortSelected(name string, index int) {
+	b.orderByField = sortOrders[index].field
+	b.ascending = sortOrders[index].ascending
+
+	// Don't query until a version has been selected
+	if b.mcvsn != "" {
+		b.refreshTable()
+	}
+}
+
+func (b *ModBrowser) packSelected(name string, index int) {
+	if index > 0 {
+		b.targetPack = &b.packs[index-1]
+	} else {
+		b.targetPack = nil
+	}
+
+	if b.depsView != nil {
+		row, _ := b.table.GetSelection()
+		b.modHighlighted(row, 0)
+	}
+}
+
+// modHighlighted displays the dependencies of the highlighted mod, marking those that are already
+// present in the target pack
+func (b *ModBrowser) modHighlighted(row, column int) {
+	if b.depsView == nil {
+		return
+	}
+
+	b.depsView.Clear()
+	if row < 1 || row >= b.table.GetRowCount() {
+		return
+	}
+
+	deps, err := b.db.GetProjectDependencies(b.table.GetCell(row, 0).Text, b.mcvsn)
+	if err != nil {
+		fmt.Fprintf(b.depsView, "[red]%s", tview.Escape(err.Error()))
+		return
+	}
+
+	if len(deps) == 0 {
+		fmt.Fprintf(b.depsView, "None")
+		return
+	}
+
+	var present map[int]bool
+	if b.targetPack != nil {
+		present = b.targetPack.ProjectIDs()
+	}
+
+	for _, dep := range deps {
+		kind := "optional"
+		if dep.Required {
+			kind = "required"
+		}
+
+		marker := ""
+		if present[dep.ProjectID] {
+			marker = " [green](in pack)[white]"
+		}
+		fmt.Fprintf(b.depsView, "%s (%s)%s\n", tview.Escape(dep.Slug), kind, marker)
+	}
+}
+
 func (b *ModBrowser) modSelected(row, column int) {
 	slug := b.table.GetCell(row, 0).Text
 	if b.onModSelected != nil {
@@ -119,11 +304,73 @@ func (b *ModBrowser) modSelected(row, column int) {
 	}
 }
 
+// searchChanged filters the table as the user types; the refresh is delayed until typing pauses
+// so that every keystroke doesn't run a query
+func (b *ModBrowser) searchChanged(text string) {
+	if b.searchTimer != nil {
+		b.searchTimer.Stop()
+	}
+
+	b.searchTimer = time.AfterFunc(searchDelay, func() {
+		b.app.QueueUpdateDraw(func() {
+			if b.search == text || b.searchField.GetText() != text {
+				return
+			}
+			b.search = text
+			if b.mcvsn != "" {
+				b.refreshTable()
+			}
+		})
+	})
+}
+
+func (b *ModBrowser) searchDone(key tcell.Key) {
+	if key == tcell.KeyEnter {
+		b.search = b.searchField.GetText()
+		b.refreshTable()
+		b.focus(b.table)
+		return
+	}
+	b.componentDone(key)
+}
+
+// focus moves the focus to a component, keeping the tab order in sync
+func (b *ModBrowser) focus(p tview.Primitive) {
+	for i, item := range b.focusOrder {
+		if item == p {
+			b.focusIndex = i
+		}
+	}
+	b.app.SetFocus(p)
+}
+
 func (b *ModBrowser) componentDone(key tcell.Key) {
-	if key == tcell.KeyTab {
-		b.focusIndex = (b.focusIndex+1) % len(b.focusOrder)
-		b.app.SetFocus(b.focusOrder[b.focusIndex])
+	if key == keys["next"].key {
+		b.moveFocus(1)
+	} else if key == keys["prev"].key {
+		b.moveFocus(-1)
+	}
+}
+
+func (b *ModBrowser) moveFocus(delta int) {
+	b.focusIndex = (b.focusIndex + delta + len(b.focusOrder)) % len(b.focusOrder)
+	b.app.SetFocus(b.focusOrder[b.focusIndex])
+}
+
+// onKey handles navigation that's bound to characters (special keys are handled by componentDone)
+func (b *ModBrowser) onKey(event *tcell.EventKey) *tcell.EventKey {
+	if _, typing := b.app.GetFocus().(*tview.InputField); typing {
+		return event
+	}
+
+	if keys.isChar("next") && keys.matches("next", event) {
+		b.moveFocus(1)
+		return nil
+	} else if keys.isChar("prev") && keys.matches("prev", event) {
+		b.moveFocus(-1)
+		return nil
 	}
+	return event
 }
 
 func (b *ModBrowser) refreshVersions() {
@@ -136,20 +383,41 @@ func (b *ModBrowser) refreshVersions() {
 }
 
 func (b *ModBrowser) onTableKey(event *tcell.EventKey) *tcell.EventKey{
-	if event.Rune() == 'd' {
-		b.orderByField = "downloads"
-		b.ascending = false
-		b.refreshTable()
+	if keys.matches("install", event) {
+		row, _ := b.table.GetSelection()
+		if row > 0 {
+			b.modSelected(row, 0)
+		}
 		return nil
-	} else if event.Rune() == 'u' {
-		b.orderByField = "modified_ts"
-		b.ascending = false
-		b.refreshTable()
+	} else if keys.matches("sort.downloads", event) {
+		b.sortDropDown.SetCurrentOption(0)
+		return nil
+	} else if keys.matches("sort.updated", event) {
+		b.sortDropDown.SetCurrentOption(1)
+		return nil
+	} else if keys.matches("sort.name", event) {
+		b.sortDropDown.SetCurrentOption(2)
+		return nil
+	} else if keys.matches("open", event) {
+		b.invokeHandler(b.onModOpened)
+		return nil
+	} else if keys.matches("details", event) {
+		b.invokeHandler(b.onModInfo)
+		return nil
+	} else if keys.matches("search", event) {
+		b.focus(b.searchField)
 		return nil
 	}
 	return event
 }
 
+func (b *ModBrowser) invokeHandler(handler ModSelectedHandler) {
+	row, _ := b.table.GetSelection()
+	if row > 0 && handler != nil {
+		handler(b.table.GetCell(row, 0).Text, b.loader, b.mcvsn)
+	}
+}
+
 func (b *ModBrowser) refreshTable() {
 	row := 1
 	printer := message.NewPrinter(language.English)
@@ -161,7 +429,7 @@ func (b *ModBrowser) refreshTable() {
 	b.table.SetCell(0, 2, tview.NewTableCell("Loader").SetSelectable(false))
 	b.table.SetCell(0, 3, tview.NewTableCell("Desc").SetSelectable(false))
 
-	b.db.ForEachMod(b.mcvsn, b.loader, b.orderByField, b.ascending,
+	b.db.ForEachProject(b.ptype, b.mcvsn, b.loader, b.search, b.category, b.orderByField, b.ascending,
 		func(id int, slug string, loader string, description string, downloads int, modifiedTs, createdTs int) error {
 			b.table.SetCell(row, 0, tview.NewTableCell(slug).SetMaxWidth(25))
 			b.table.SetCell(row, 1, tview.NewTableCell(printer.Sprintf("%d", downloads)))
@@ -0,0 +1,256 @@
+package ui
+
+import (
+	"context"
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"mcdex/pkg"
+)
+
+// PackManager lists the installed packs and lets the user update, remove and lock the mods in them
+type PackManager struct {
+	ctx context.Context
+	app *tview.Application
+
+	packList *tview.List
+	table *tview.Table
+
+	root *tview.Pages
+
+	packs []pkg.PackLocation
+	pack *pkg.ModPack
+	mods []pkg.ModStatus
+	updates map[string]bool
+
+	// Incremented whenever the mod list changes, so stale update checks can be discarded
+	generation int
+}
+
+func NewPackManager(ctx context.Context, app *tview.Application) *PackManager {
+	m := &PackManager{ctx: ctx, app: app}
+
+	m.packList = tview.NewList().
+		ShowSecondaryText(false).
+		SetSelectedFunc(m.packSelected)
+	m.packList.SetBorder(true).SetTitle(" Packs ")
+	m.packList.SetDoneFunc(func() {
+		m.app.SetFocus(m.table)
+	})
+
+	m.table = tview.NewTable().
+		SetBorders(false).
+		SetFixed(1, 0).
+		SetSelectable(true, false).
+		SetDoneFunc(func(key tcell.Key) {
+			m.app.SetFocus(m.packList)
+		})
+	m.table.SetBorder(true).SetTitle(fmt.Sprintf(" Mods (%s: Check updates, %s: Update, %s: Update all, %s: Lock, %s: Remove) ",
+		keys.name("check"), keys.name("update"), keys.name("update.all"), keys.name("lock"), keys.name("remove")))
+	m.table.SetInputCapture(m.onTableKey)
+
+	m.root = tview.NewPages().
+		AddPage("main", tview.NewFlex().
+			AddItem(m.packList, 0, 1, true).
+			AddItem(m.table, 0, 3, false), true, true)
+
+	return m
+}
+
+func (m *PackManager) RootView() tview.Primitive {
+	return m.root
+}
+
+// Refresh reloads the list of installed packs
+func (m *PackManager) Refresh() {
+	m.packs = pkg.ListPacks()
+	m.packList.Clear()
+	for _, p := range m.packs {
+		label := p.Name
+		if p.MultiMC {
+			label += " (MultiMC)"
+		}
+		m.packList.AddItem(label, p.Dir, 0, nil)
+	}
+	m.app.SetFocus(m.packList)
+}
+
+func (m *PackManager) packSelected(index int, mainText, secondaryText string, shortcut rune) {
+	// Opening a pack writes to stdout; redraw the whole screen afterwards
+	cp, err := pkg.OpenModPack(m.ctx, m.packs[index].Dir, m.packs[index].MultiMC)
+	m.app.Sync()
+	if err != nil {
+		m.showError(err)
+		return
+	}
+
+	m.pack = cp
+	m.updates = make(map[string]bool)
+	m.refreshTable()
+	m.app.SetFocus(m.table)
+}
+
+func (m *PackManager) refreshTable() {
+	m.generation++
+	m.mods = m.pack.Mods()
+	m.table.Clear()
+
+	m.table.SetCell(0, 0, tview.NewTableCell("Mod").SetSelectable(false))
+	m.table.SetCell(0, 1, tview.NewTableCell("Installed").SetSelectable(false))
+	m.table.SetCell(0, 2, tview.NewTableCell("Latest").SetSelectable(false))
+	m.table.SetCell(0, 3, tview.NewTableCell("Flags").SetSelectable(false))
+
+	for i, mod := range m.mods {
+		row := i + 1
+		installed := mod.Filename
+		if installed == "" {
+			installed = "(not installed)"
+		}
+
+		flags := ""
+		if mod.Locked {
+			flags += "locked "
+		}
+		if mod.ClientOnly {
+			flags += "client "
+		}
+		if mod.ServerOnly {
+			flags += "server "
+		}
+		if mod.Optional {
+			flags += "optional "
+		}
+		if mod.Disabled {
+			flags += "disabled"
+		}
+
+		m.table.SetCell(row, 0, tview.NewTableCell(mod.Name).SetMaxWidth(40))
+		m.table.SetCell(row, 1, tview.NewTableCell(installed).SetMaxWidth(50))
+		m.table.SetCell(row, 2, tview.NewTableCell(m.latestText(mod)))
+		m.table.SetCell(row, 3, tview.NewTableCell(flags))
+	}
+}
+
+func (m *PackManager) latestText(mod pkg.ModStatus) string {
+	updated, checked := m.updates[mod.Key]
+	switch {
+	case !checked:
+		return "?"
+	case updated:
+		return "[yellow]update available"
+	}
+	return "up to date"
+}
+
+func (m *PackManager) selectedMod() (pkg.ModStatus, bool) {
+	row, _ := m.table.GetSelection()
+	if m.pack == nil || row < 1 || row > len(m.mods) {
+		return pkg.ModStatus{}, false
+	}
+	return m.mods[row-1], true
+}
+
+func (m *PackManager) onTableKey(event *tcell.EventKey) *tcell.EventKey {
+	if keys.matches("check", event) {
+		m.checkUpdates()
+	} else if keys.matches("update", event) {
+		if mod, ok := m.selectedMod(); ok {
+			m.runInstall(fmt.Sprintf("Updating %s", mod.Name), func() error {
+				return m.pack.UpdateMod(m.ctx, mod.Key)
+			})
+		}
+	} else if keys.matches("update.all", event) {
+		if m.pack != nil {
+			m.runInstall(fmt.Sprintf("Updating %s", m.pack.Name), func() error {
+				_, err := m.pack.UpdateMods(m.ctx, false)
+				return err
+			})
+		}
+	} else if keys.matches("lock", event) {
+		if mod, ok := m.selectedMod(); ok {
+			if err := m.pack.SetModLocked(mod.Key, !mod.Locked); err != nil {
+				m.showError(err)
+			}
+			m.refreshTable()
+		}
+	} else if keys.matches("remove", event) {
+		if mod, ok := m.selectedMod(); ok {
+			m.confirmRemove(mod)
+		}
+	} else {
+		return event
+	}
+	return nil
+}
+
+// checkUpdates looks for newer files for each mod in the background, filling in the table as
+// results arrive; the results are only applied on the UI goroutine
+func (m *PackManager) checkUpdates() {
+	if m.pack == nil {
+		return
+	}
+
+	rows := make(map[string]int)
+	keys := make([]string, len(m.mods))
+	for i, mod := range m.mods {
+		rows[mod.Key] = i + 1
+		keys[i] = mod.Key
+	}
+
+	mods, generation := m.mods, m.generation
+	results := m.pack.CheckModUpdates(m.ctx, keys)
+	go func() {
+		for result := range results {
+			result := result
+			m.app.QueueUpdateDraw(func() {
+				if generation != m.generation || result.Err != nil {
+					return
+				}
+				row := rows[result.Key]
+				m.updates[result.Key] = result.Updated
+				m.table.GetCell(row, 2).SetText(m.latestText(mods[row-1]))
+			})
+		}
+
+		// Some sources print while checking; clean up the screen
+		m.app.QueueUpdate(func() {
+			m.app.Sync()
+		})
+	}()
+}
+
+func (m *PackManager) confirmRemove(mod pkg.ModStatus) {
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("Remove %s from %s?", mod.Name, m.pack.Name)).
+		AddButtons([]string{"Remove", "Cancel"}).
+		SetDoneFunc(func(index int, label string) {
+			m.root.RemovePage("confirm")
+			if index == 0 {
+				if err := m.pack.RemoveMod(mod.Key); err != nil {
+					m.showError(err)
+				}
+				m.refreshTable()
+			}
+			m.app.SetFocus(m.table)
+		})
+	m.root.AddPage("confirm", modal, true, true)
+}
+
+// runInstall runs an action with the TUI suspended, displaying its progress in the install dashboard
+func (m *PackManager) runInstall(title string, f func() error) {
+	m.app.Suspend(func() {
+		RunInstallDashboard(title, f)
+	})
+	m.updates = make(map[string]bool)
+	m.refreshTable()
+}
+
+func (m *PackManager) showError(err error) {
+	modal := tview.NewModal().
+		SetText(err.Error()).
+		AddButtons([]string{"OK"}).
+		SetDoneFunc(func(index int, label string) {
+			m.root.RemovePage("error")
+		})
+	m.root.AddPage("error", modal, true, true)
+}
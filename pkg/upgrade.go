@@ -0,0 +1,115 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/Jeffail/gabs"
+)
+
+// Upgrade moves the pack to a new version of Minecraft: the mod loader is re-selected and every mod
+// is re-resolved against the new version. Mods without a compatible build are listed (and dropped
+// from the pack); nothing is saved unless the user approves the changes.
+func (pack *ModPack) Upgrade(ctx context.Context, minecraftVsn, forgeChannel string, dryRun bool) error {
+	minecraftVsn, err := resolveMinecraftVersion(ctx, minecraftVsn)
+	if err != nil {
+		return err
+	}
+
+	oldMinecraftVsn, oldLoaderVsn := pack.getVersions()
+	if oldMinecraftVsn == minecraftVsn {
+		return fmt.Errorf("%s is already using Minecraft %s", pack.Name, minecraftVsn)
+	}
+
+	// Select the loader for the new version
+	pack.manifest.SetP(minecraftVsn, "minecraft.version")
+	err = pack.selectLoader(ctx, minecraftVsn, forgeChannel)
+	if err != nil {
+		return fmt.Errorf("no %s version available for Minecraft %s: %+v", pack.modLoader, minecraftVsn, err)
+	}
+	_, loaderVsn := pack.getVersions()
+
+	fmt.Fprintf(consoleOutput, "Upgrading %s: Minecraft %s -> %s, %s %s -> %s\n", pack.Name,
+		oldMinecraftVsn, minecraftVsn, pack.modLoader, oldLoaderVsn, loaderVsn)
+
+	// Re-resolve each of the mods against the new version
+	var kept []*gabs.Container
+	var incompatible []string
+	var unchecked []string
+	files := manifestFiles(pack.manifest)
+	for _, child := range files {
+		modFile, err := newModPackFile(child)
+		if err != nil {
+			warn(WarnManifest, "%+v; keeping as-is", err)
+			kept = append(kept, child)
+			continue
+		}
+
+		// Modrinth mods resolve against the pack's (now updated) Minecraft version
+		if mrFile, ok := modFile.(*ModrinthModFile); ok {
+			oldFileID := mrFile.fileID
+			_, err := mrFile.update(ctx, pack)
+			if _, ok := err.(noFileError); ok {
+				incompatible = append(incompatible, fmt.Sprintf("%s (https://modrinth.com/mod/%s)", mrFile.getName(), mrFile.projectID))
+				continue
+			} else if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(consoleOutput, "  %s: %s -> %s\n", mrFile.getName(), colorize(consoleOutput, styleOld, oldFileID), colorize(consoleOutput, styleNew, mrFile.fileID))
+			child.Set(mrFile.fileID, "fileID")
+			kept = append(kept, child)
+			continue
+		}
+
+		cfFile, ok := modFile.(*CurseForgeModFile)
+		if !ok {
+			// Other types of mods can't be resolved by Minecraft version; keep them as-is
+			unchecked = append(unchecked, modFile.getName())
+			kept = append(kept, child)
+			continue
+		}
+
+		fileID, err := cfFile.getLatestFile(ctx, minecraftVsn, pack.modLoader, pack.releaseChannel(cfFile.channel), pack.modConstraint(ctx, cfFile, cfFile.constraint))
+		if _, ok := err.(noFileError); ok {
+			url := ""
+			if slug, err := pack.db.findSlugByProject(ctx, cfFile.projectID); err == nil {
+				url = fmt.Sprintf(" (https://www.curseforge.com/minecraft/mc-mods/%s)", slug)
+			}
+			incompatible = append(incompatible, cfFile.getName()+url)
+			continue
+		} else if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(consoleOutput, "  %s: %s -> %s\n", cfFile.getName(), colorize(consoleOutput, styleOld, strconv.Itoa(cfFile.fileID)), colorize(consoleOutput, styleNew, strconv.Itoa(fileID)))
+		child.Set(fileID, "fileID")
+		kept = append(kept, child)
+	}
+
+	if len(incompatible) > 0 {
+		fmt.Fprintf(consoleOutput, "The following mods have no build for Minecraft %s and will be removed from the pack:\n", minecraftVsn)
+		for _, name := range incompatible {
+			fmt.Fprintf(consoleOutput, "  %s\n", colorize(consoleOutput, styleOld, name))
+		}
+	}
+
+	if len(unchecked) > 0 {
+		fmt.Fprintf(consoleOutput, "The following mods could not be checked and will be kept as-is:\n")
+		for _, name := range unchecked {
+			fmt.Fprintf(consoleOutput, "  %s\n", name)
+		}
+	}
+
+	if dryRun || !promptYesNo(fmt.Sprintf("Upgrade %s to Minecraft %s?", pack.Name, minecraftVsn)) {
+		fmt.Fprintf(consoleOutput, "No changes made to %s\n", pack.Name)
+		return nil
+	}
+
+	// Approved; drop the incompatible mods and save the manifest
+	setManifestFiles(pack.manifest, kept)
+
+	fmt.Fprintf(consoleOutput, "Upgraded %s to Minecraft %s; run pack.install to install the new versions\n", pack.Name, minecraftVsn)
+	return pack.SaveManifest()
+}
@@ -21,7 +21,13 @@ import (
 	"archive/zip"
 	"bufio"
 	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"hash"
 	"io"
 	"io/ioutil"
 	"net"
@@ -69,7 +75,7 @@ func NewHttpClient(followRedirects bool) http.Client {
 	}
 	err := http2.ConfigureTransport(&t)
 	if err != nil {
-		fmt.Printf("Error configuring http2: %+v\n", err)
+		logWarn("Error configuring http2: %+v\n", err)
 	}
 
 	if !followRedirects {
@@ -79,14 +85,42 @@ func NewHttpClient(followRedirects bool) http.Client {
 
 }
 
-func HttpGet(url string) (*http.Response, error) {
-	req, _ := http.NewRequest("GET", url, nil)
-	req.Header.Add("User-Agent", "Mozilla/5.0 AppleWebKit/589.6 (KHTML, like Gecko) Brave Chrome/79.1.3945.88 Safari/577.36")
+// HttpGet retrieves a URL, giving up when the context is done
+func HttpGet(ctx context.Context, url string) (*http.Response, error) {
+	req, err := newGetRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
 	return getterClient.Do(req)
 }
 
-func downloadHttpFile(url string, targetFile string) error {
-	resp, err := HttpGet(url)
+// newGetRequest prepares a GET request with the headers mcdex sends to every site
+func newGetRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("User-Agent", "Mozilla/5.0 AppleWebKit/589.6 (KHTML, like Gecko) Brave Chrome/79.1.3945.88 Safari/577.36")
+	addAuthHeader(req)
+	return req, nil
+}
+
+func addAuthHeader(req *http.Request) {
+	service, token := apiTokenForURL(req.URL.String())
+	if token == "" {
+		return
+	}
+
+	switch service {
+	case "curseforge":
+		req.Header.Add("x-api-key", token)
+	case "github":
+		req.Header.Add("Authorization", "token "+token)
+	}
+}
+
+func downloadHttpFile(ctx context.Context, url string, targetFile string) error {
+	resp, err := HttpGet(ctx, url)
 	if err != nil {
 		return fmt.Errorf("failed to retrieve %s: %+v", url, err)
 	}
@@ -102,17 +136,21 @@ func downloadHttpFile(url string, targetFile string) error {
 	return writeStream(targetFile, resp.Body)
 }
 
-func downloadHttpFileToDir(url string, targetDir string, skipIfExists bool) (string, error) {
+func downloadHttpFileToDir(ctx context.Context, url string, targetDir string, skipIfExists bool) (string, error) {
 	// Start the download
-	resp, err := HttpGet(url)
+	resp, err := HttpGet(ctx, url)
 	if err != nil {
-		return "", fmt.Errorf("failed to download %s: %+v", url, err)
+		err = fmt.Errorf("failed to download %s: %+v", url, err)
+		notifyDownloadFailed(path.Base(url), err)
+		return "", err
 	}
 	defer resp.Body.Close()
 
 	// If we didn't get back a 200, bail
 	if resp.StatusCode != 200 {
-		return "", fmt.Errorf("failed to download %s status %d", url, resp.StatusCode)
+		err = fmt.Errorf("failed to download %s status %d", url, resp.StatusCode)
+		notifyDownloadFailed(path.Base(url), err)
+		return "", err
 	}
 
 	// Extract the filename from the actual request (after following all redirects)
@@ -140,9 +178,12 @@ func downloadHttpFileToDir(url string, targetDir string, skipIfExists bool) (str
 	}
 
 	// Save the stream of the response to the file
-	fmt.Printf("Downloading %s\n", filepath.Base(filename))
-
-	err = writeStream(filename, resp.Body)
+	progress := newProgressReader(resp.Body, "Downloading "+filepath.Base(filename), resp.ContentLength)
+	if !progress.enabled {
+		logStyled(LogInfo, styleDownload, "Downloading %s\n", filepath.Base(filename))
+	}
+	err = writeStream(filename, progress)
+	progress.finish(err)
 	if err != nil {
 		return "", fmt.Errorf("failed to write %s: %+v", filename, err)
 	}
@@ -150,7 +191,7 @@ func downloadHttpFileToDir(url string, targetDir string, skipIfExists bool) (str
 	return filepath.Base(filename), nil
 }
 
-func findJSONFile(z *zip.ReadCloser, name string) (*gabs.Container, error) {
+func findJSONFile(z *zip.Reader, name string) (*gabs.Container, error) {
 	for _, f := range z.File {
 		if f.Name == name {
 			freader, err := f.Open()
@@ -181,14 +222,18 @@ func writeStream(filename string, data io.Reader) error {
 	}
 	defer f.Close()
 
-	// Stream the data into the temp file
+	// Stream the data into the temp file; don't leave a partial file behind if it fails (or the
+	// download is canceled)
 	writer := bufio.NewWriter(f)
 	_, err = io.Copy(writer, data)
+	if err == nil {
+		err = writer.Flush()
+	}
+	f.Close()
 	if err != nil {
+		os.Remove(tempFilename)
 		return fmt.Errorf("failed to write %s: %v", filename, err)
 	}
-	writer.Flush()
-	f.Close()
 
 	// Ok, write completed successfully, move the file
 	err = os.Rename(tempFilename, filename)
@@ -199,18 +244,75 @@ func writeStream(filename string, data io.Reader) error {
 	return nil
 }
 
+// safeJoin joins an untrusted relative path (e.g. a ZIP entry name) onto a base directory,
+// rejecting absolute paths and any path that would escape the base directory
+func safeJoin(baseDir, name string) (string, error) {
+	// ZIP entries should always use forward slashes, but be paranoid about Windows separators
+	name = strings.Replace(name, "\\", "/", -1)
+
+	hasDrive := len(name) > 1 && name[1] == ':'
+	if path.IsAbs(name) || filepath.IsAbs(name) || filepath.VolumeName(name) != "" || hasDrive {
+		return "", fmt.Errorf("absolute path not allowed: %s", name)
+	}
+
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", fmt.Errorf("parent directory reference not allowed: %s", name)
+		}
+	}
+
+	base, err := filepath.Abs(baseDir)
+	if err != nil {
+		return "", err
+	}
+
+	result := filepath.Join(base, filepath.FromSlash(name))
+	if result != base && !strings.HasPrefix(result, base+string(filepath.Separator)) {
+		return "", fmt.Errorf("path escapes %s: %s", baseDir, name)
+	}
+
+	return result, nil
+}
+
+func sha256File(filename string) (string, error) {
+	return hashFile(filename, sha256.New())
+}
+
+func sha1File(filename string) (string, error) {
+	return hashFile(filename, sha1.New())
+}
+
+func hashFile(filename string, hash hash.Hash) (string, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(hash, f)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
 func fileExists(filename string) bool {
 	_, err := os.Stat(filename)
 	return err == nil || os.IsExist(err)
 }
 
+// modFileExists checks for a mod file in either its enabled or disabled form
+func modFileExists(filename string) bool {
+	return fileExists(filename) || fileExists(filename+disabledSuffix)
+}
+
 func dirExists(dirname string) bool {
 	stat, err := os.Stat(dirname)
 	return err == nil && stat.IsDir()
 }
 
-func ReadStringFromUrl(url string) (string, error) {
-	res, err := HttpGet(url)
+func ReadStringFromUrl(ctx context.Context, url string) (string, error) {
+	res, err := HttpGet(ctx, url)
 	if err != nil {
 		return "", fmt.Errorf("Failed to read string from %s: %+v", url, err)
 	}
@@ -272,6 +374,38 @@ func parseVersion(version string) (int, int, int, error) {
 	}
 }
 
+// compareVersions compares two dotted version strings component by component, numerically
+// where possible; returns -1, 0 or 1 if a is less than, equal to or greater than b
+func compareVersions(a, b string) int {
+	splitFn := func(r rune) bool { return r == '.' || r == '-' || r == '+' }
+	aParts := strings.FieldsFunc(a, splitFn)
+	bParts := strings.FieldsFunc(b, splitFn)
+
+	for i := 0; i < len(aParts) && i < len(bParts); i++ {
+		aInt, aErr := strconv.Atoi(aParts[i])
+		bInt, bErr := strconv.Atoi(bParts[i])
+		switch {
+		case aErr == nil && bErr == nil:
+			if aInt != bInt {
+				if aInt < bInt {
+					return -1
+				}
+				return 1
+			}
+		case aParts[i] != bParts[i]:
+			return strings.Compare(aParts[i], bParts[i])
+		}
+	}
+
+	switch {
+	case len(aParts) < len(bParts):
+		return -1
+	case len(aParts) > len(bParts):
+		return 1
+	}
+	return 0
+}
+
 func stripBadUTF8(s string) string {
 	// Noop if we've already got a valid string
 	if utf8.ValidString(s) {
@@ -292,8 +426,8 @@ func stripBadUTF8(s string) string {
 	return string(v)
 }
 
-func getJSONFromURL(url string) (*gabs.Container, error) {
-	res, e := HttpGet(url)
+func getJSONFromURL(ctx context.Context, url string) (*gabs.Container, error) {
+	res, e := HttpGet(ctx, url)
 	if e != nil {
 		return nil, fmt.Errorf("Failed to complete HTTP request: %s %+v", url, e)
 	}
@@ -307,6 +441,29 @@ func getJSONFromURL(url string) (*gabs.Container, error) {
 	return gabs.ParseJSONBuffer(res.Body)
 }
 
+func postJSONToURL(ctx context.Context, url string, data interface{}) (*gabs.Container, error) {
+	body, err := json.Marshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to encode request for %s: %+v", url, err)
+	}
+
+	req, _ := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(body))
+	req.Header.Add("User-Agent", "Mozilla/5.0 AppleWebKit/589.6 (KHTML, like Gecko) Brave Chrome/79.1.3945.88 Safari/577.36")
+	req.Header.Add("Content-Type", "application/json")
+	addAuthHeader(req)
+	res, err := getterClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to complete HTTP request: %s %+v", url, err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("Failed to post to %s: %d", url, res.StatusCode)
+	}
+
+	return gabs.ParseJSONBuffer(res.Body)
+}
+
 func intValue(c *gabs.Container, path string) (int, error) {
 	data := c.Path(path).Data()
 	switch v := data.(type) {
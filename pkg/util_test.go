@@ -0,0 +1,108 @@
+package pkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSafeJoin(t *testing.T) {
+	base := tempDir(t)
+	defer os.RemoveAll(base)
+
+	tests := []struct {
+		name string
+		want string // relative to base; empty if the name is rejected
+	}{
+		{"config/mod.cfg", "config/mod.cfg"},
+		{"mods/a.jar", "mods/a.jar"},
+		{"./options.txt", "options.txt"},
+		{"config/../options.txt", ""},
+		{"..foo/bar", "..foo/bar"},
+		{"..", ""},
+		{"../outside.txt", ""},
+		{"../../etc/passwd", ""},
+		{"config/../../outside.txt", ""},
+		{"..\\outside.txt", ""},
+		{"config\\..\\..\\outside.txt", ""},
+		{"/etc/passwd", ""},
+		{"\\etc\\passwd", ""},
+		{"C:/Windows/win.ini", ""},
+		{"C:\\Windows\\win.ini", ""},
+		{"c:relative.txt", ""},
+	}
+
+	for _, test := range tests {
+		got, err := safeJoin(base, test.name)
+		switch {
+		case test.want == "" && err == nil:
+			t.Errorf("safeJoin(%q) = %q; expected an error", test.name, got)
+		case test.want != "" && err != nil:
+			t.Errorf("safeJoin(%q) failed: %v", test.name, err)
+		case test.want != "" && got != filepath.Join(base, filepath.FromSlash(test.want)):
+			t.Errorf("safeJoin(%q) = %q; expected %q", test.name, got, filepath.Join(base, test.want))
+		}
+	}
+}
+
+func TestZipHelperWriteFileToDir(t *testing.T) {
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	entries := []string{"config/mod.cfg", "../evil.txt", "config/../../evil.txt", "/tmp/evil.txt", "C:/evil.txt"}
+	for _, name := range entries {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(name))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zh, err := NewZipHelper(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent := tempDir(t)
+	defer os.RemoveAll(parent)
+	target := filepath.Join(parent, "pack")
+	tests := []struct {
+		name string
+		ok   bool
+	}{
+		{"config/mod.cfg", true},
+		{"../evil.txt", false},
+		{"config/../../evil.txt", false},
+		{"/tmp/evil.txt", false},
+		{"C:/evil.txt", false},
+	}
+
+	for _, test := range tests {
+		filename, err := zh.writeFileToDir(test.name, target)
+		switch {
+		case test.ok && err != nil:
+			t.Errorf("writeFileToDir(%q) failed: %v", test.name, err)
+		case test.ok && !fileExists(filename):
+			t.Errorf("writeFileToDir(%q) didn't write %s", test.name, filename)
+		case !test.ok && err == nil:
+			t.Errorf("writeFileToDir(%q) = %q; expected an error", test.name, filename)
+		}
+	}
+
+	if fileExists(filepath.Join(parent, "evil.txt")) {
+		t.Errorf("an entry was written outside the target directory")
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "mcdex-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
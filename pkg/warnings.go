@@ -0,0 +1,104 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// Warning categories; warnings are grouped by category in the summary printed at the end of a command
+const (
+	WarnSkippedClientOnly = "Skipped client-only mods"
+	WarnSkippedServerOnly = "Skipped server-only mods"
+	WarnSkippedOptional   = "Skipped optional mods"
+	WarnSkippedLocked     = "Skipped locked mods"
+	WarnSkippedConstraint = "Skipped mods with no file matching their constraint"
+	WarnSkippedExisting   = "Skipped existing files"
+	WarnManifest          = "Unrecognized manifest entries"
+	WarnUnknownMods       = "Unidentified mod files"
+	WarnPreservedFiles    = "Locally modified files preserved"
+	WarnStaleDatabase     = "Stale database"
+	WarnNoDatabase        = "Mod database unavailable"
+	WarnSkippedHook       = "Skipped hooks"
+	WarnFailedDownloads   = "Failed downloads"
+	WarnOther             = "Other warnings"
+)
+
+var warningCategories = []string{
+	WarnSkippedClientOnly,
+	WarnSkippedServerOnly,
+	WarnSkippedOptional,
+	WarnSkippedLocked,
+	WarnSkippedConstraint,
+	WarnSkippedExisting,
+	WarnManifest,
+	WarnUnknownMods,
+	WarnPreservedFiles,
+	WarnStaleDatabase,
+	WarnNoDatabase,
+	WarnSkippedHook,
+	WarnFailedDownloads,
+	WarnOther,
+}
+
+var warningLock sync.Mutex
+var warnings = make(map[string][]string)
+
+// warn prints a non-fatal warning and records it for the summary at the end of the command
+func warn(category string, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	logWarn("%s", msg)
+
+	warningLock.Lock()
+	defer warningLock.Unlock()
+	warnings[category] = append(warnings[category], msg)
+}
+
+// Warnings returns all the warnings recorded so far, grouped by category
+func Warnings() map[string][]string {
+	warningLock.Lock()
+	defer warningLock.Unlock()
+
+	result := make(map[string][]string)
+	for category, msgs := range warnings {
+		result[category] = append([]string(nil), msgs...)
+	}
+	return result
+}
+
+// ResetWarnings discards the warnings recorded so far (e.g. between operations in a long-running
+// process)
+func ResetWarnings() {
+	warningLock.Lock()
+	defer warningLock.Unlock()
+	warnings = make(map[string][]string)
+}
+
+// PrintWarnings displays a grouped summary of the warnings recorded during the command; if
+// asJson is set, the summary is written as a JSON document (to the output, rather than stderr) instead
+func PrintWarnings(asJson bool) {
+	all := Warnings()
+
+	if asJson {
+		data, _ := json.MarshalIndent(map[string]interface{}{"warnings": all}, "", "  ")
+		fmt.Fprintln(consoleOutput, string(data))
+		return
+	}
+
+	if len(all) == 0 {
+		return
+	}
+
+	fmt.Fprintf(errorOutput, "\n%s\n", colorize(errorOutput, styleWarn, "Warnings:"))
+	for _, category := range warningCategories {
+		msgs, ok := all[category]
+		if !ok {
+			continue
+		}
+
+		fmt.Fprintf(errorOutput, "  %s (%d):\n", category, len(msgs))
+		for _, msg := range msgs {
+			fmt.Fprintf(errorOutput, "    - %s\n", msg)
+		}
+	}
+}
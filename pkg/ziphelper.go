@@ -64,7 +64,11 @@ func (zh *ZipHelper) getJsonFile(name string) (*gabs.Container, error) {
 }
 
 func (zh *ZipHelper) writeFileToDir(zipFilename string, targetDir string) (string, error) {
-	return zh.writeFile(zipFilename, filepath.Join(targetDir, zipFilename))
+	filename, err := safeJoin(targetDir, zipFilename)
+	if err != nil {
+		return "", fmt.Errorf("invalid file in ZIP: %+v", err)
+	}
+	return zh.writeFile(zipFilename, filename)
 }
 
 func (zh *ZipHelper) writeFile(zipFilename string, filename string) (string, error) {